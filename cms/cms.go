@@ -0,0 +1,295 @@
+// Package cms builds CMS (RFC 5652) SignedData structures, as used by PAdES signatures and
+// RFC 3161 timestamp tokens.
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"sort"
+)
+
+var (
+	OIDData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	OIDTSTInfo    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+
+	OIDAttributeContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	OIDAttributeMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	OIDAttributeSigningTime          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	OIDAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	OIDAttributeTimeStampToken       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+	OIDSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	OIDRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	OIDECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+var ErrUnsupportedKey = errors.New("cms: unsupported private key type")
+
+// Attribute is a signed or unsigned attribute, Values are DER encoded
+type Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values [][]byte
+}
+
+type SignOptions struct {
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	Key         crypto.Signer
+
+	// ContentType of the encapsulated content, id-data when empty
+	ContentType asn1.ObjectIdentifier
+	// Detached leaves the content out of the structure, only its digest is signed
+	Detached bool
+
+	SignedAttributes   []Attribute
+	UnsignedAttributes []Attribute
+
+	// SignatureTimestamp returns an RFC 3161 token over the signature value, added as the
+	// signature-time-stamp unsigned attribute
+	SignatureTimestamp func(signature []byte) ([]byte, error)
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signerInfo struct {
+	Version int
+	// IssuerAndSerialNumber, or a [0] SubjectKeyIdentifier in structures made by others
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type essCertIDv2 struct {
+	CertHash     []byte
+	IssuerSerial issuerSerial
+}
+
+type issuerSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// Sign returns a DER encoded ContentInfo holding a SignedData over content, with one signer.
+// The content-type, message-digest and ESS signing-certificate-v2 attributes are always signed.
+func Sign(content []byte, opts SignOptions) ([]byte, error) {
+	contentType := opts.ContentType
+	if contentType == nil {
+		contentType = OIDData
+	}
+
+	digest := sha256.Sum256(content)
+
+	attrs := []Attribute{}
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value any
+	}{
+		{OIDAttributeContentType, contentType},
+		{OIDAttributeMessageDigest, digest[:]},
+	} {
+		der, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, Attribute{Type: a.oid, Values: [][]byte{der}})
+	}
+
+	signingCert, err := SigningCertificateV2(opts.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, signingCert)
+	attrs = append(attrs, opts.SignedAttributes...)
+
+	signedAttrs, err := encodeAttributes(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the attributes encoded as a SET OF, not with the implicit [0] tag
+	signedAttrsSet, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signedAttrs})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, sigAlg, err := signDigest(opts.Key, signedAttrsSet)
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerial{
+		Issuer: asn1.RawValue{FullBytes: opts.Certificate.RawIssuer},
+		Serial: opts.Certificate.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	si := signerInfo{
+		Version:            1,
+		SID:                asn1.RawValue{FullBytes: sid},
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: OIDSHA256},
+		SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs},
+		SignatureAlgorithm: sigAlg,
+		Signature:          signature,
+	}
+
+	unsignedAttrs := opts.UnsignedAttributes
+	if opts.SignatureTimestamp != nil {
+		token, err := opts.SignatureTimestamp(signature)
+		if err != nil {
+			return nil, err
+		}
+		unsignedAttrs = append(append([]Attribute{}, unsignedAttrs...), Attribute{Type: OIDAttributeTimeStampToken, Values: [][]byte{token}})
+	}
+
+	if len(unsignedAttrs) > 0 {
+		unsigned, err := encodeAttributes(unsignedAttrs)
+		if err != nil {
+			return nil, err
+		}
+		si.UnsignedAttrs = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: unsigned}
+	}
+
+	var certs bytes.Buffer
+	certs.Write(opts.Certificate.Raw)
+	for _, c := range opts.Chain {
+		certs.Write(c.Raw)
+	}
+
+	encap := encapContentInfo{EContentType: contentType}
+	if !opts.Detached {
+		octets, err := asn1.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		encap.EContent = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: octets}
+	}
+
+	version := 1
+	if !contentType.Equal(OIDData) {
+		version = 3
+	}
+
+	sd := signedData{
+		Version:          version,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: OIDSHA256}},
+		EncapContentInfo: encap,
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs.Bytes()},
+		SignerInfos:      []signerInfo{si},
+	}
+
+	sdBytes, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: OIDSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdBytes},
+	})
+}
+
+// SigningCertificateV2 builds the ESS signing-certificate-v2 attribute (RFC 5035) binding the signer certificate
+func SigningCertificateV2(cert *x509.Certificate) (Attribute, error) {
+	certHash := sha256.Sum256(cert.Raw)
+
+	// GeneralNames holding the issuer as a directoryName [4]
+	directoryName, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: cert.RawIssuer})
+	if err != nil {
+		return Attribute{}, err
+	}
+
+	value, err := asn1.Marshal(signingCertificateV2{
+		Certs: []essCertIDv2{{
+			CertHash: certHash[:],
+			IssuerSerial: issuerSerial{
+				Issuer: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: directoryName},
+				Serial: cert.SerialNumber,
+			},
+		}},
+	})
+	if err != nil {
+		return Attribute{}, err
+	}
+
+	return Attribute{Type: OIDAttributeSigningCertificateV2, Values: [][]byte{value}}, nil
+}
+
+// encodeAttributes returns the content of a DER SET OF Attribute, sorted as DER requires
+func encodeAttributes(attrs []Attribute) ([]byte, error) {
+	encoded := make([][]byte, 0, len(attrs))
+	for _, a := range attrs {
+		values := append([][]byte{}, a.Values...)
+		sort.Slice(values, func(i, j int) bool { return bytes.Compare(values[i], values[j]) < 0 })
+
+		der, err := asn1.Marshal(struct {
+			Type   asn1.ObjectIdentifier
+			Values asn1.RawValue
+		}{
+			Type:   a.Type,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(values, nil)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, der)
+	}
+
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	return bytes.Join(encoded, nil), nil
+}
+
+func signDigest(key crypto.Signer, data []byte) ([]byte, pkix.AlgorithmIdentifier, error) {
+	digest := sha256.Sum256(data)
+
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		return sig, pkix.AlgorithmIdentifier{Algorithm: OIDRSAEncryption, Parameters: asn1.NullRawValue}, err
+	case *ecdsa.PublicKey:
+		sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		return sig, pkix.AlgorithmIdentifier{Algorithm: OIDECDSAWithSHA256}, err
+	}
+
+	return nil, pkix.AlgorithmIdentifier{}, ErrUnsupportedKey
+}
@@ -0,0 +1,305 @@
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The structures in testdata were made by OpenSSL 3 with the P-256 key of signer.pem:
+//
+//	openssl cms -sign -in content.txt -signer signer.pem -inkey signer.key -binary -nodetach -md sha256 -outform DER -out attached.p7
+//	openssl cms -sign -in content.txt -signer signer.pem -inkey signer.key -binary -md sha256 -outform DER -out detached.p7
+//	openssl cms -sign -in content.txt -signer signer.pem -inkey signer.key -binary -nodetach -md sha256 -noattr -outform DER -out noattr.p7
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// newSigner returns a self-signed certificate for a new key of the given type
+func newSigner(t *testing.T, keyType string) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ecdsa":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Test " + keyType},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestParseOpenSSL(t *testing.T) {
+	content := readTestdata(t, "content.txt")
+
+	tests := []struct {
+		file     string
+		detached bool
+	}{
+		{"attached.p7", false},
+		{"detached.p7", true},
+		{"noattr.p7", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			sd, err := Parse(readTestdata(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sd.ContentType.Equal(OIDData) {
+				t.Errorf("content type = %v", sd.ContentType)
+			}
+			if tt.detached != (sd.Content == nil) {
+				t.Errorf("content = %q", sd.Content)
+			}
+			if !tt.detached && !bytes.Equal(sd.Content, content) {
+				t.Errorf("content = %q, want %q", sd.Content, content)
+			}
+
+			cert, err := sd.Verify(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cert.Subject.CommonName != "Test Signer" {
+				t.Errorf("signer = %s", cert.Subject)
+			}
+
+			if _, err := sd.Verify([]byte("Hello, CMS?\n")); err == nil {
+				t.Error("verified a changed content")
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	attached := readTestdata(t, "attached.p7")
+	data, err := asn1.Marshal(contentInfo{
+		ContentType: OIDData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: []byte{0x04, 0x00}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		der  []byte
+		err  error
+	}{
+		{"not signed data", data, ErrNotSignedData},
+		{"trailing data", append(append([]byte{}, attached...), 0x30, 0x00), ErrNotSignedData},
+		{"truncated", attached[:len(attached)/2], nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.der)
+			if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// Padding after the structure, as left in the /Contents of a PDF signature, is allowed
+	if _, err := Parse(append(append([]byte{}, attached...), 0, 0, 0)); err != nil {
+		t.Errorf("zero padding: %v", err)
+	}
+}
+
+func TestSignRoundTrip(t *testing.T) {
+	content := []byte("%PDF-1.7 byte ranges")
+	signedTime, err := asn1.Marshal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		detached    bool
+		contentType asn1.ObjectIdentifier
+	}{
+		{"rsa attached", "rsa", false, nil},
+		{"rsa detached", "rsa", true, nil},
+		{"ecdsa detached", "ecdsa", true, nil},
+		{"ecdsa other content type", "ecdsa", false, OIDTSTInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, key := newSigner(t, tt.key)
+
+			var timestamped []byte
+			der, err := Sign(content, SignOptions{
+				Certificate:      cert,
+				Key:              key,
+				ContentType:      tt.contentType,
+				Detached:         tt.detached,
+				SignedAttributes: []Attribute{{Type: OIDAttributeSigningTime, Values: [][]byte{signedTime}}},
+				SignatureTimestamp: func(signature []byte) ([]byte, error) {
+					timestamped = signature
+					return asn1.Marshal([]byte("token"))
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sd, err := Parse(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.contentType
+			if want == nil {
+				want = OIDData
+			}
+			if !sd.ContentType.Equal(want) {
+				t.Errorf("content type = %v, want %v", sd.ContentType, want)
+			}
+			if tt.detached != (sd.Content == nil) || !tt.detached && !bytes.Equal(sd.Content, content) {
+				t.Errorf("content = %q", sd.Content)
+			}
+
+			verified := content
+			if !tt.detached {
+				verified = nil
+			}
+			signer, err := sd.Verify(verified)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !signer.Equal(cert) {
+				t.Errorf("signer = %s", signer.Subject)
+			}
+
+			if !bytes.Equal(timestamped, sd.Signature()) {
+				t.Error("the signature timestamp is not over the signature value")
+			}
+			tokens := sd.UnsignedAttribute(OIDAttributeTimeStampToken)
+			if len(tokens) != 1 {
+				t.Fatalf("%d timestamp tokens", len(tokens))
+			}
+			var token []byte
+			if _, err := asn1.Unmarshal(tokens[0], &token); err != nil || string(token) != "token" {
+				t.Errorf("token = %q, %v", token, err)
+			}
+
+			if times := findAttribute(sd.signer.SignedAttrs.Bytes, OIDAttributeSigningTime); len(times) != 1 || !bytes.Equal(times[0], signedTime) {
+				t.Errorf("signing time = %x", times)
+			}
+		})
+	}
+}
+
+func TestSigningCertificateV2(t *testing.T) {
+	cert, _ := newSigner(t, "ecdsa")
+
+	attr, err := SigningCertificateV2(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attr.Type.Equal(OIDAttributeSigningCertificateV2) || len(attr.Values) != 1 {
+		t.Fatalf("attribute = %v", attr)
+	}
+
+	var value signingCertificateV2
+	if _, err := asn1.Unmarshal(attr.Values[0], &value); err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(cert.Raw)
+	if len(value.Certs) != 1 || !bytes.Equal(value.Certs[0].CertHash, hash[:]) || value.Certs[0].IssuerSerial.Serial.Cmp(cert.SerialNumber) != 0 {
+		t.Errorf("value = %+v", value)
+	}
+}
+
+func TestSignUnsupportedKey(t *testing.T) {
+	cert, _ := newSigner(t, "ecdsa")
+	_, key := newSigner(t, "ed25519")
+
+	if _, err := Sign([]byte("content"), SignOptions{Certificate: cert, Key: key}); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("err = %v, want ErrUnsupportedKey", err)
+	}
+}
+
+// TestOpenSSLVerifiesSign checks the structures made by Sign with OpenSSL, when it is installed
+func TestOpenSSLVerifiesSign(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+
+	dir := t.TempDir()
+	content := filepath.Join(dir, "content.txt")
+	if err := os.WriteFile(content, []byte("Hello, OpenSSL!\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keyType := range []string{"rsa", "ecdsa"} {
+		for _, detached := range []bool{false, true} {
+			cert, key := newSigner(t, keyType)
+			der, err := Sign([]byte("Hello, OpenSSL!\n"), SignOptions{Certificate: cert, Key: key, Detached: detached})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed := filepath.Join(dir, "signed.p7")
+			if err := os.WriteFile(signed, der, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			args := []string{"cms", "-verify", "-inform", "DER", "-in", signed, "-noverify", "-binary"}
+			if detached {
+				args = append(args, "-content", content)
+			}
+			out, err := exec.Command(openssl, args...).CombinedOutput()
+			if err != nil || !bytes.Contains(out, []byte("Hello, OpenSSL!")) {
+				t.Errorf("%s detached %v: %v\n%s", keyType, detached, err, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotSignedData     = errors.New("cms: not a SignedData structure")
+	ErrSignerNotFound    = errors.New("cms: signer certificate not found")
+	ErrDigestMismatch    = errors.New("cms: message digest does not match the content")
+	ErrUnsupportedDigest = errors.New("cms: unsupported digest algorithm")
+)
+
+var (
+	OIDSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	OIDSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// SignedData is a parsed ContentInfo holding a SignedData
+type SignedData struct {
+	ContentType asn1.ObjectIdentifier
+	// Content is nil for detached signatures
+	Content      []byte
+	Certificates []*x509.Certificate
+
+	signer signerInfo
+}
+
+// Parse reads a DER encoded ContentInfo holding a SignedData with at least one signer
+func Parse(der []byte) (*SignedData, error) {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, err
+	} else if len(bytes.TrimRight(rest, "\x00")) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrNotSignedData)
+	}
+	if !ci.ContentType.Equal(OIDSignedData) {
+		return nil, ErrNotSignedData
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, fmt.Errorf("%w: no signer", ErrNotSignedData)
+	}
+
+	out := &SignedData{ContentType: sd.EncapContentInfo.EContentType, signer: sd.SignerInfos[0]}
+
+	if len(sd.EncapContentInfo.EContent.Bytes) > 0 {
+		var content []byte
+		if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &content); err != nil {
+			return nil, err
+		}
+		out.Content = content
+	}
+
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		out.Certificates = certs
+	}
+
+	return out, nil
+}
+
+// Signature is the signature value of the first signer
+func (sd *SignedData) Signature() []byte {
+	return sd.signer.Signature
+}
+
+// Signer returns the certificate of the first signer
+func (sd *SignedData) Signer() (*x509.Certificate, error) {
+	var ias issuerAndSerial
+	if _, err := asn1.Unmarshal(sd.signer.SID.FullBytes, &ias); err == nil && ias.Serial != nil {
+		for _, c := range sd.Certificates {
+			if c.SerialNumber.Cmp(ias.Serial) == 0 && bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+				return c, nil
+			}
+		}
+		return nil, ErrSignerNotFound
+	}
+
+	// [0] SubjectKeyIdentifier
+	if sd.signer.SID.Class == asn1.ClassContextSpecific && sd.signer.SID.Tag == 0 {
+		for _, c := range sd.Certificates {
+			if bytes.Equal(c.SubjectKeyId, sd.signer.SID.Bytes) {
+				return c, nil
+			}
+		}
+	}
+
+	return nil, ErrSignerNotFound
+}
+
+// UnsignedAttribute returns the values of an unsigned attribute of the first signer
+func (sd *SignedData) UnsignedAttribute(oid asn1.ObjectIdentifier) [][]byte {
+	return findAttribute(sd.signer.UnsignedAttrs.Bytes, oid)
+}
+
+// Verify checks the signature of the first signer over content, or over the encapsulated content
+// when content is nil. Only the signature is checked, not the trust of the signer certificate.
+func (sd *SignedData) Verify(content []byte) (*x509.Certificate, error) {
+	if content == nil {
+		content = sd.Content
+	}
+
+	cert, err := sd.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := digestHash(sd.signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := content
+	if len(sd.signer.SignedAttrs.Bytes) > 0 {
+		digests := findAttribute(sd.signer.SignedAttrs.Bytes, OIDAttributeMessageDigest)
+		if len(digests) != 1 {
+			return nil, ErrDigestMismatch
+		}
+
+		var messageDigest []byte
+		if _, err := asn1.Unmarshal(digests[0], &messageDigest); err != nil {
+			return nil, err
+		}
+
+		h := hash.New()
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), messageDigest) {
+			return nil, ErrDigestMismatch
+		}
+
+		signed, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: sd.signer.SignedAttrs.Bytes})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	algorithm, err := signatureAlgorithm(cert, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cert.CheckSignature(algorithm, signed, sd.signer.Signature); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+func findAttribute(attrs []byte, oid asn1.ObjectIdentifier) [][]byte {
+	for len(attrs) > 0 {
+		var attr struct {
+			Type   asn1.ObjectIdentifier
+			Values asn1.RawValue
+		}
+		rest, err := asn1.Unmarshal(attrs, &attr)
+		if err != nil {
+			return nil
+		}
+		attrs = rest
+
+		if !attr.Type.Equal(oid) {
+			continue
+		}
+
+		var values [][]byte
+		for v := attr.Values.Bytes; len(v) > 0; {
+			var value asn1.RawValue
+			rest, err := asn1.Unmarshal(v, &value)
+			if err != nil {
+				return nil
+			}
+			values = append(values, value.FullBytes)
+			v = rest
+		}
+		return values
+	}
+	return nil
+}
+
+// digestHash maps a digest algorithm identifier to its hash function
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(OIDSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(OIDSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(OIDSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, ErrUnsupportedDigest
+}
+
+func signatureAlgorithm(cert *x509.Certificate, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.SHA256WithRSA, crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA,
+		}[hash], nil
+	case *ecdsa.PublicKey:
+		return map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.ECDSAWithSHA256, crypto.SHA384: x509.ECDSAWithSHA384, crypto.SHA512: x509.ECDSAWithSHA512,
+		}[hash], nil
+	}
+	return x509.UnknownSignatureAlgorithm, ErrUnsupportedKey
+}
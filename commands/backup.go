@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/managers"
+)
+
+var (
+	ErrBackupUsage  = errors.New("usage: backup [--with-keys] [file]")
+	ErrRestoreUsage = errors.New("usage: restore <file>")
+)
+
+// Backup writes a backup archive of the running instance, to BACKUP_DIR unless a file is given
+func Backup(args []string) error {
+	includeKeys := false
+	path := ""
+	for _, arg := range args {
+		switch {
+		case arg == "--with-keys":
+			includeKeys = true
+		case path == "" && arg != "" && arg[0] != '-':
+			path = arg
+		default:
+			return ErrBackupUsage
+		}
+	}
+	if path == "" {
+		path = filepath.Join(config.AppConfig.BackupDir, managers.BackupFileName(time.Now()))
+	}
+
+	manifest, err := managers.CreateBackup(path, includeKeys)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Backup written to", path)
+	fmt.Println("Documents:", manifest.Documents)
+	fmt.Println("Files:    ", len(manifest.Files))
+	for _, key := range manifest.Missing {
+		fmt.Println("Missing from storage:", key)
+	}
+	if !includeKeys {
+		fmt.Println("The master key and the seal key are not in the backup, keep a copy of them, or use --with-keys")
+	}
+	return nil
+}
+
+// Restore loads a backup archive into an empty instance
+func Restore(args []string) error {
+	if len(args) != 1 {
+		return ErrRestoreUsage
+	}
+
+	manifest, err := managers.RestoreBackup(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Restored", manifest.Documents, "documents and", len(manifest.Files), "files from", manifest.CreatedAt.Format(time.RFC3339))
+
+	hasMasterKey := false
+	for _, entry := range manifest.Keys {
+		if entry.Path == "keys/master.key" {
+			hasMasterKey = true
+		}
+	}
+	if !hasMasterKey {
+		fmt.Println("The backup has no master key: set MASTER_KEY or MASTER_KEY_FILE to the key of the backed up instance before starting")
+	}
+	return nil
+}
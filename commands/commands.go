@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+	// manualSchema commands run before pending migrations are applied
+	manualSchema bool
+}
+
+var registry = []command{
+	{name: "verify-audit", usage: "verify-audit [doc-id]    check the audit event hash chain", run: VerifyAudit},
+	{name: "encrypt-files", usage: "encrypt-files            encrypt stored files that are still in plaintext", run: EncryptFiles},
+	{name: "rewrap-keys", usage: "rewrap-keys              wrap all document keys with the current MASTER_KEY", run: RewrapKeys},
+	{name: "extract-text", usage: "extract-text [--all]     extract the text of documents not extracted yet, or all", run: ExtractText},
+	{name: "backup", usage: "backup [--with-keys]     write a backup archive, to BACKUP_DIR or a given file", run: Backup},
+	{name: "restore", usage: "restore <file>           load a backup archive into an empty instance", run: Restore, manualSchema: true},
+	{name: "reset-password", usage: "reset-password [email]   set a new password for a user, or the only owner", run: ResetPassword},
+	{name: "set-admin-email", usage: "set-admin-email <email>  change the login email of the only owner, or give the current one first", run: SetAdminEmail},
+	{name: "disable-2fa", usage: "disable-2fa <email>      turn two-factor authentication off for a locked-out user", run: DisableTwoFactor},
+	{name: "migrate", usage: "migrate status|up|down   show, apply or revert schema migrations", run: Migrate, manualSchema: true},
+}
+
+// AppliesMigrations tells whether pending migrations are applied before running args, the migrate
+// command handles them itself
+func AppliesMigrations(args []string) bool {
+	for _, cmd := range registry {
+		if cmd.name == args[0] {
+			return !cmd.manualSchema
+		}
+	}
+	return true
+}
+
+// Run executes the subcommand named by args[0]. The database must be initialized.
+func Run(args []string) error {
+	for _, cmd := range registry {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+
+	printUsage()
+	return fmt.Errorf("%w: %s", ErrUnknownCommand, args[0])
+}
+
+func printUsage() {
+	fmt.Println("Usage: inkra [command]")
+	fmt.Println()
+	fmt.Println("Without a command the server is started. Commands:")
+	for _, cmd := range registry {
+		fmt.Println("  " + cmd.usage)
+	}
+}
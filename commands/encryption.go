@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/managers"
+)
+
+var ErrEncryptionDisabled = errors.New("encryption is disabled, set ENCRYPTION_ENABLED=true")
+
+// EncryptFiles encrypts the stored files that are still in plaintext, checking every document
+func EncryptFiles(args []string) error {
+	if !config.AppConfig.EncryptionEnabled {
+		return ErrEncryptionDisabled
+	}
+	if err := config.EnsureMasterKey(); err != nil {
+		return err
+	}
+
+	report, err := managers.EncryptStoredFiles(true)
+
+	fmt.Println("Documents updated:", report.Documents)
+	fmt.Println("Files encrypted:  ", report.Files)
+	if report.Missing > 0 {
+		fmt.Println("Files missing:    ", report.Missing)
+	}
+
+	return err
+}
+
+// RewrapKeys wraps every document data key and two-factor secret with the current master key, after a rotation
+func RewrapKeys(args []string) error {
+	report, err := managers.RewrapDataKeys()
+
+	fmt.Println("Keys re-wrapped:      ", report.Rewrapped)
+	fmt.Println("Keys already current: ", report.Current)
+	if report.Failed > 0 {
+		fmt.Println("Keys not re-wrapped:  ", report.Failed)
+	}
+
+	if err == nil && report.Rewrapped > 0 {
+		fmt.Println("MASTER_KEY_PREVIOUS can now be removed")
+	}
+
+	return err
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fbn776/inkra/database"
+)
+
+var ErrMigrateUsage = errors.New("usage: migrate status|up [steps]|down [steps]")
+
+// Migrate shows or changes the schema version. It runs before the automatic migration on start.
+func Migrate(args []string) error {
+	if len(args) == 0 {
+		return ErrMigrateUsage
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return ErrMigrateUsage
+		}
+		steps = n
+	}
+
+	switch args[0] {
+	case "status":
+		return migrateStatus()
+	case "up":
+		applied, err := database.MigrateUp(steps)
+		for _, m := range applied {
+			fmt.Printf("Applied  %04d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		return err
+	case "down":
+		reverted, err := database.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d %s\n", m.Version, m.Name)
+		}
+		return err
+	}
+
+	return ErrMigrateUsage
+}
+
+func migrateStatus() error {
+	status, err := database.MigrationsStatus()
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, s := range status {
+		state := "pending"
+		if s.AppliedAt != nil {
+			state = "applied " + *s.AppliedAt
+		} else {
+			pending++
+		}
+		fmt.Printf("%04d  %-24s %s\n", s.Version, s.Name, state)
+	}
+
+	fmt.Println()
+	fmt.Println("Pending migrations:", pending)
+	return nil
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/managers"
+)
+
+var ErrExtractTextUsage = errors.New("usage: extract-text [--all]")
+
+// ExtractText extracts the text of the documents not extracted yet, or of all of them with --all, for instance
+// to retry the failed ones or to index again after an upgrade of the extraction
+func ExtractText(args []string) error {
+	all := len(args) > 0 && args[0] == "--all"
+	if len(args) > 0 && !all {
+		return ErrExtractTextUsage
+	}
+
+	extracted, failed := 0, 0
+	for offset := 0; ; offset += 100 {
+		docs, _, err := database.Docs.List(database.DocumentFilter{Limit: 100, Offset: offset})
+		if err != nil {
+			return err
+		}
+
+		for _, doc := range docs {
+			if doc.TextStatus == database.TextExtracted && !all {
+				continue
+			}
+			if err := managers.ExtractDocText(doc.Id); err != nil {
+				return err
+			}
+
+			if updated, err := database.Docs.Get(doc.Id); err == nil && updated.TextStatus == database.TextExtracted {
+				extracted++
+			} else {
+				failed++
+			}
+		}
+
+		if len(docs) < 100 {
+			break
+		}
+	}
+
+	fmt.Println("Documents extracted:", extracted)
+	if failed > 0 {
+		fmt.Println("Documents failed:   ", failed)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/managers"
+)
+
+var (
+	ErrDisableTwoFactorUsage = errors.New("usage: disable-2fa <email>")
+	ErrResetPasswordUsage    = errors.New("usage: reset-password [email]")
+	ErrSetAdminEmailUsage    = errors.New("usage: set-admin-email [current-email] <new-email>")
+	ErrNoSingleOwner         = errors.New("there are several owners, give the email of the user")
+)
+
+// DisableTwoFactor turns two-factor authentication off for a user who lost both the authenticator and the
+// recovery codes
+func DisableTwoFactor(args []string) error {
+	if len(args) != 1 {
+		return ErrDisableTwoFactorUsage
+	}
+
+	user, err := database.GetUserByEmail(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	if !user.TwoFactorEnabled {
+		fmt.Println("Two-factor authentication is not enabled for", user.Email)
+		return nil
+	}
+
+	if err := database.DisableTOTP(user.Id); err != nil {
+		return err
+	}
+
+	fmt.Println("Two-factor authentication disabled for", user.Email+", it can log in with its password alone")
+	return nil
+}
+
+// ResetPassword sets a new password, read from the terminal, for a user or for the only owner. The sessions of
+// the user are ended.
+func ResetPassword(args []string) error {
+	if len(args) > 1 {
+		return ErrResetPasswordUsage
+	}
+
+	user, err := userOrOwner(args)
+	if err != nil {
+		return err
+	}
+
+	password, err := config.ReadPassword("New password for " + user.Email + ": ")
+	if err != nil {
+		return err
+	}
+
+	ended, err := managers.SetPassword(user.Id, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Password changed for", user.Email+",", ended, "sessions ended")
+	if user.Disabled {
+		fmt.Println("The user is disabled, an owner must enable it before it can log in")
+	}
+	return nil
+}
+
+// SetAdminEmail changes the email a user, or the only owner, logs in with
+func SetAdminEmail(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return ErrSetAdminEmailUsage
+	}
+
+	newEmail := args[len(args)-1]
+	if !strings.Contains(newEmail, "@") {
+		return fmt.Errorf("%s is not an email", newEmail)
+	}
+
+	user, err := userOrOwner(args[:len(args)-1])
+	if err != nil {
+		return err
+	}
+
+	if err := database.SetUserEmail(user.Id, newEmail); err != nil {
+		return err
+	}
+
+	fmt.Println("Email of", user.Email, "changed to", database.NormalizeEmail(newEmail))
+	return nil
+}
+
+// userOrOwner returns the user of the email in args, or the only enabled owner when no email is given
+func userOrOwner(args []string) (database.User, error) {
+	if len(args) == 1 {
+		user, err := database.GetUserByEmail(args[0])
+		if err != nil {
+			return user, fmt.Errorf("%s: %w", args[0], err)
+		}
+		return user, nil
+	}
+
+	owners, err := database.ListOwners()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	switch len(owners) {
+	case 0:
+		return database.User{}, database.ErrUserNotFound
+	case 1:
+		return owners[0], nil
+	default:
+		for _, owner := range owners {
+			fmt.Println("Owner:", owner.Email)
+		}
+		return database.User{}, ErrNoSingleOwner
+	}
+}
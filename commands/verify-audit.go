@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fbn776/inkra/database"
+)
+
+var ErrAuditChainBroken = errors.New("audit chain is broken")
+
+// VerifyAudit walks the audit event hash chain, of one document if an id is given
+func VerifyAudit(args []string) error {
+	docId := ""
+	if len(args) > 0 {
+		docId = args[0]
+	}
+
+	report, err := database.VerifyEventChain(docId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Events checked:", report.Events)
+
+	if !report.Valid {
+		fmt.Println("First broken link:")
+		fmt.Println("  event:   ", report.Break.EventId)
+		fmt.Println("  document:", report.Break.DocId)
+		fmt.Println("  reason:  ", report.Break.Reason)
+		return ErrAuditChainBroken
+	}
+
+	fmt.Println("Chain head:", report.Head)
+	fmt.Println("Audit chain is intact")
+
+	return nil
+}
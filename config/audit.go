@@ -0,0 +1,78 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fbn776/inkra/database"
+)
+
+// loadAuditKey reads AUDIT_KEY, or the key file when it is not set, which keys the hashes of the audit events
+func loadAuditKey() error {
+	database.AuditHeadPath = AppConfig.AuditHeadPath
+
+	encoded := getEnv("AUDIT_KEY", "")
+	if encoded == "" {
+		data, err := os.ReadFile(AppConfig.AuditKeyPath)
+		if errors.Is(err, os.ErrNotExist) {
+			// Generated by EnsureAuditKey
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+
+	key, err := parseAuditKey(encoded)
+	if err != nil {
+		return fmt.Errorf("AUDIT_KEY: %w", err)
+	}
+
+	database.AuditKey = key
+	return nil
+}
+
+func parseAuditKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		key, err = hex.DecodeString(encoded)
+	}
+	if err != nil {
+		return nil, errors.New("must be base64 or hex encoded")
+	}
+	if len(key) < 32 {
+		return nil, errors.New("must be at least 32 bytes")
+	}
+	return key, nil
+}
+
+// EnsureAuditKey generates the audit key file when no key is configured
+func EnsureAuditKey() error {
+	if database.AuditKey != nil {
+		return nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(AppConfig.AuditKeyPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(AppConfig.AuditKeyPath, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		return err
+	}
+
+	database.AuditKey = key
+
+	fmt.Println("Generated audit key", AppConfig.AuditKeyPath, "- back it up, the audit history cannot be verified without it")
+	return nil
+}
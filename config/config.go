@@ -1,6 +1,15 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fbn776/inkra/encryption"
+	"github.com/fbn776/inkra/storage"
+)
 
 type Config struct {
 	Port          string
@@ -8,6 +17,82 @@ type Config struct {
 	AdminUsername string
 	AdminPassword string
 	MaxFileSize   int64
+
+	// DATABASE_URL, postgres://... for Postgres, empty or sqlite:<path> for SQLite
+	DatabaseURL string
+
+	// Storage of the document files, selected by STORAGE_BACKEND
+	Storage storage.Backend
+	// S3RedirectDownloads sends file downloads straight to presigned bucket urls
+	S3RedirectDownloads bool
+
+	// How long the invite link of a new user stays valid
+	InviteTTL time.Duration
+
+	// Access tokens are short-lived, sessions are kept alive by refresh tokens for RefreshTokenTTL after their
+	// last use
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// A user failing to log in LoginLockoutThreshold times in a row is locked out for LoginLockoutDuration, which
+	// doubles with each further failure up to LoginLockoutMax. Login attempts are kept for LoginAttemptsRetention.
+	LoginLockoutThreshold  int
+	LoginLockoutDuration   time.Duration
+	LoginLockoutMax        time.Duration
+	LoginAttemptsRetention time.Duration
+
+	// Single sign-on with an OpenID Connect provider, enabled when OIDCIssuer is set. OIDCRoleMapping gives the
+	// role of the users from their groups at the provider; users in none of them get OIDCDefaultRole, and can not
+	// log in when it is empty.
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        []string
+	OIDCGroupsClaim   string
+	OIDCRoleMapping   map[string]string
+	OIDCDefaultRole   string
+	OIDCAutoProvision bool
+	// Values of the acr claim meaning the user logged in with several factors at the provider, which then spares
+	// them the two-factor code of Inkra, as does an amr claim holding "mfa"
+	OIDCMFAAcrValues []string
+	// Name of the provider shown on the login button
+	OIDCName string
+
+	// Signed download urls of document files
+	DownloadSecret string
+	DownloadURLTTL time.Duration
+
+	// Server key used to apply a PAdES seal to signed documents
+	SealEnabled  bool
+	SealCertPath string
+	SealKeyPath  string
+	SealName     string
+
+	// RFC 3161 timestamp authority, "builtin" signs timestamps with the seal key and an empty value disables timestamping
+	TsaURL      string
+	TsaUsername string
+	TsaPassword string
+	// Certificate of the built-in authority, issued for the seal key with the timeStamping usage
+	TsaCertPath string
+
+	// Document files are encrypted with per-document data keys wrapped by the master key. Data keys
+	// wrapped by a previous master key can still be unwrapped until they are re-wrapped.
+	EncryptionEnabled  bool
+	MasterKeyPath      string
+	MasterKey          *encryption.MasterKey
+	PreviousMasterKeys []*encryption.MasterKey
+
+	// Audit events are hashed with an HMAC under the audit key, and the newest event is recorded in the head file.
+	// Both are kept outside the database, so that events can neither be rewritten nor deleted unnoticed.
+	AuditKeyPath  string
+	AuditHeadPath string
+
+	// Scheduled backups, made every BackupInterval when it is set, keeping the last BackupRetention
+	BackupDir         string
+	BackupInterval    time.Duration
+	BackupRetention   int
+	BackupIncludeKeys bool
 }
 
 var AppConfig Config
@@ -19,6 +104,20 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+func getInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
 func Load() {
 	AppConfig = Config{
 		Port:          getEnv("PORT", "8080"),
@@ -26,5 +125,80 @@ func Load() {
 		AdminUsername: getEnv("ADMIN_USERNAME", ""),
 		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
 		MaxFileSize:   100 << 20,
+
+		DatabaseURL: getEnv("DATABASE_URL", ""),
+
+		InviteTTL:       getDuration("INVITE_TTL", 7*24*time.Hour),
+		AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		LoginLockoutThreshold:  getInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		LoginLockoutDuration:   getDuration("LOGIN_LOCKOUT_DURATION", time.Minute),
+		LoginLockoutMax:        getDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+		LoginAttemptsRetention: getDuration("LOGIN_ATTEMPTS_RETENTION", 90*24*time.Hour),
+
+		DownloadURLTTL: getDuration("DOWNLOAD_URL_TTL", 15*time.Minute),
+
+		SealEnabled:  getEnv("SEAL_ENABLED", "true") == "true",
+		SealCertPath: getEnv("SEAL_CERT_PATH", "./data/seal-cert.pem"),
+		SealKeyPath:  getEnv("SEAL_KEY_PATH", "./data/seal-key.pem"),
+		SealName:     getEnv("SEAL_NAME", "Inkra"),
+
+		TsaURL:      getEnv("TSA_URL", "builtin"),
+		TsaUsername: getEnv("TSA_USERNAME", ""),
+		TsaPassword: getEnv("TSA_PASSWORD", ""),
+		TsaCertPath: getEnv("TSA_CERT_PATH", "./data/tsa-cert.pem"),
+
+		EncryptionEnabled: getEnv("ENCRYPTION_ENABLED", "true") == "true",
+		MasterKeyPath:     getEnv("MASTER_KEY_FILE", "./data/master.key"),
+
+		AuditKeyPath:  getEnv("AUDIT_KEY_FILE", "./data/audit.key"),
+		AuditHeadPath: getEnv("AUDIT_HEAD_FILE", "./data/audit-head.json"),
+
+		BackupDir:         getEnv("BACKUP_DIR", "./backups"),
+		BackupInterval:    getDuration("BACKUP_INTERVAL", 0),
+		BackupRetention:   getInt("BACKUP_RETENTION", 7),
+		BackupIncludeKeys: getEnv("BACKUP_INCLUDE_KEYS", "false") == "true",
+	}
+
+	AppConfig.DownloadSecret = getEnv("DOWNLOAD_URL_SECRET", AppConfig.JwtSecret)
+
+	backend, err := loadStorage()
+	if err != nil {
+		log.Fatal(err)
+	}
+	AppConfig.Storage = backend
+
+	if err := loadMasterKeys(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadAuditKey(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadOIDC(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func loadStorage() (storage.Backend, error) {
+	switch backend := getEnv("STORAGE_BACKEND", "local"); backend {
+	case "local":
+		return storage.NewLocal(getEnv("STORAGE_LOCAL_DIR", "./docs")), nil
+	case "s3":
+		AppConfig.S3RedirectDownloads = getEnv("S3_REDIRECT_DOWNLOADS", "false") == "true"
+
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  getEnv("S3_ENDPOINT", ""),
+			Region:    getEnv("S3_REGION", "us-east-1"),
+			Bucket:    getEnv("S3_BUCKET", ""),
+			AccessKey: getEnv("S3_ACCESS_KEY_ID", ""),
+			SecretKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+			PathStyle: getEnv("S3_PATH_STYLE", "true") == "true",
+			Prefix:    getEnv("S3_PREFIX", ""),
+		})
+	default:
+		return nil, errors.New("unknown STORAGE_BACKEND " + backend + ", expected local or s3")
 	}
 }
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fbn776/inkra/encryption"
+)
+
+// loadMasterKeys reads MASTER_KEY, or the key file when it is not set, and the retired keys still
+// accepted for unwrapping. Keys are loaded even with encryption disabled so existing files stay readable.
+func loadMasterKeys() error {
+	encoded := getEnv("MASTER_KEY", "")
+	if encoded == "" {
+		data, err := os.ReadFile(AppConfig.MasterKeyPath)
+		if errors.Is(err, os.ErrNotExist) {
+			// Generated by EnsureMasterKey
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		encoded = string(data)
+	}
+
+	key, err := encryption.ParseMasterKey(encoded)
+	if err != nil {
+		return fmt.Errorf("MASTER_KEY: %w", err)
+	}
+	AppConfig.MasterKey = key
+
+	for _, previous := range strings.Split(getEnv("MASTER_KEY_PREVIOUS", ""), ",") {
+		if strings.TrimSpace(previous) == "" {
+			continue
+		}
+		key, err := encryption.ParseMasterKey(previous)
+		if err != nil {
+			return fmt.Errorf("MASTER_KEY_PREVIOUS: %w", err)
+		}
+		AppConfig.PreviousMasterKeys = append(AppConfig.PreviousMasterKeys, key)
+	}
+
+	return nil
+}
+
+// EnsureMasterKey generates the master key file when encryption is enabled and no key is configured
+func EnsureMasterKey() error {
+	if !AppConfig.EncryptionEnabled || AppConfig.MasterKey != nil {
+		return nil
+	}
+
+	encoded, err := encryption.GenerateMasterKey()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(AppConfig.MasterKeyPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(AppConfig.MasterKeyPath, []byte(encoded+"\n"), 0600); err != nil {
+		return err
+	}
+
+	AppConfig.MasterKey, err = encryption.ParseMasterKey(encoded)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Generated master key", AppConfig.MasterKeyPath, "- back it up, encrypted documents cannot be read without it")
+	return nil
+}
@@ -1,18 +1,15 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/fbn776/inkra/database"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func RunInit() error {
-	count := 0
-	err := database.DB.QueryRow("SELECT Count(*) from SETTINGS").Scan(&count)
+	count, err := database.CountUsers()
 	if err != nil {
 		return err
 	}
@@ -26,14 +23,15 @@ func RunInit() error {
 	adminPassword := AppConfig.AdminPassword
 
 	if adminPassword == "" || adminUsername == "" {
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Print("Admin Username: ")
-		email, _ := reader.ReadString('\n')
-		adminUsername = strings.TrimSpace(email)
-
-		fmt.Print("Admin password: ")
-		password, _ := reader.ReadString('\n')
-		adminPassword = strings.TrimSpace(password)
+		adminUsername, err = ReadLine("Admin Username: ")
+		if err != nil {
+			return err
+		}
+
+		adminPassword, err = ReadPassword("Admin password: ")
+		if err != nil {
+			return err
+		}
 	}
 
 	hash, _ := bcrypt.GenerateFromPassword(
@@ -41,7 +39,20 @@ func RunInit() error {
 		bcrypt.DefaultCost,
 	)
 
-	_, err = database.DB.Exec("INSERT INTO SETTINGS (login_email, login_password_hash) VALUES (?, ?)", adminUsername, hash)
+	passwordHash := string(hash)
+	err = database.CreateUser(&database.User{
+		Id:           uuid.New().String(),
+		Email:        adminUsername,
+		PasswordHash: &passwordHash,
+		Role:         database.RoleOwner,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = EnsureSealKeyPair(); err != nil {
+		return err
+	}
 
 	fmt.Println("Initialization completed")
 
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fbn776/inkra/database"
+)
+
+// loadOIDC reads the single sign-on settings, which are only checked when OIDC_ISSUER is set
+func loadOIDC() error {
+	AppConfig.OIDCIssuer = getEnv("OIDC_ISSUER", "")
+	if AppConfig.OIDCIssuer == "" {
+		return nil
+	}
+
+	AppConfig.OIDCClientID = getEnv("OIDC_CLIENT_ID", "")
+	AppConfig.OIDCClientSecret = getEnv("OIDC_CLIENT_SECRET", "")
+	AppConfig.OIDCRedirectURL = getEnv("OIDC_REDIRECT_URL", "")
+	AppConfig.OIDCScopes = strings.Fields(getEnv("OIDC_SCOPES", "openid email profile"))
+	AppConfig.OIDCGroupsClaim = getEnv("OIDC_GROUPS_CLAIM", "groups")
+	AppConfig.OIDCDefaultRole = getEnv("OIDC_DEFAULT_ROLE", "")
+	AppConfig.OIDCAutoProvision = getEnv("OIDC_AUTO_PROVISION", "true") == "true"
+	AppConfig.OIDCName = getEnv("OIDC_NAME", "Single sign-on")
+	AppConfig.OIDCMFAAcrValues = strings.Fields(strings.ReplaceAll(
+		getEnv("OIDC_MFA_ACR_VALUES", "http://schemas.openid.net/pape/policies/2007/06/multi-factor"), ",", " ",
+	))
+
+	if AppConfig.OIDCClientID == "" || AppConfig.OIDCRedirectURL == "" {
+		return errors.New("OIDC_ISSUER is set but OIDC_CLIENT_ID or OIDC_REDIRECT_URL is missing")
+	}
+	if AppConfig.OIDCDefaultRole != "" && !database.IsValidRole(AppConfig.OIDCDefaultRole) {
+		return fmt.Errorf("OIDC_DEFAULT_ROLE: unknown role %s", AppConfig.OIDCDefaultRole)
+	}
+
+	// group=role pairs, comma separated. Group names may hold an "=", the role is after the last one.
+	AppConfig.OIDCRoleMapping = map[string]string{}
+	for _, pair := range strings.Split(getEnv("OIDC_ROLE_MAPPING", ""), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 || !database.IsValidRole(strings.TrimSpace(pair[i+1:])) {
+			return fmt.Errorf("OIDC_ROLE_MAPPING: expected group=owner|editor|viewer, got %q", pair)
+		}
+		AppConfig.OIDCRoleMapping[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+
+	return nil
+}
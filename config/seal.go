@@ -0,0 +1,153 @@
+package config
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EnsureSealKeyPair creates a self-signed seal certificate and key when none are configured yet, and
+// the certificate of the built-in timestamp authority when it is used
+func EnsureSealKeyPair() error {
+	// The built-in timestamp authority signs with the seal key even when sealing is off
+	if !AppConfig.SealEnabled && AppConfig.TsaURL != "builtin" {
+		return nil
+	}
+
+	_, certErr := os.Stat(AppConfig.SealCertPath)
+	_, keyErr := os.Stat(AppConfig.SealKeyPath)
+
+	if certErr == nil && keyErr == nil {
+		return ensureTsaCertificate()
+	}
+	if certErr == nil || keyErr == nil {
+		return errors.New("seal certificate and key must both exist, found only one of them")
+	}
+	if !errors.Is(certErr, os.ErrNotExist) {
+		return certErr
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 3072)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: AppConfig.SealName, Organization: []string{AppConfig.SealName}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		BasicConstraintsValid: true,
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{AppConfig.SealCertPath, AppConfig.SealKeyPath} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(AppConfig.SealKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(AppConfig.SealCertPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0644); err != nil {
+		return err
+	}
+
+	fmt.Println("Generated self-signed seal certificate", AppConfig.SealCertPath)
+	return ensureTsaCertificate()
+}
+
+// RFC 3161 requires the authority certificate to have a critical extended key usage holding only timeStamping
+var (
+	oidExtKeyUsage          = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtKeyUsageTimestamp = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+)
+
+// ensureTsaCertificate creates the self-signed certificate of the built-in timestamp authority,
+// using the seal key
+func ensureTsaCertificate() error {
+	if AppConfig.TsaURL != "builtin" {
+		return nil
+	}
+
+	if _, err := os.Stat(AppConfig.TsaCertPath); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	pair, err := tls.LoadX509KeyPair(AppConfig.SealCertPath, AppConfig.SealKeyPath)
+	if err != nil {
+		return err
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return errors.New("seal key cannot sign")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return err
+	}
+
+	extKeyUsage, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageTimestamp})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: AppConfig.SealName + " Timestamp Authority", Organization: []string{AppConfig.SealName}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtraExtensions:       []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: extKeyUsage}},
+		BasicConstraintsValid: true,
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(AppConfig.TsaCertPath), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(AppConfig.TsaCertPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0644); err != nil {
+		return err
+	}
+
+	fmt.Println("Generated timestamp authority certificate", AppConfig.TsaCertPath)
+	return nil
+}
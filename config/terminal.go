@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdin is shared by ReadLine and ReadPassword, so that lines buffered by one are not lost to the other
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadLine asks for a line on the terminal
+func ReadLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// ReadPassword asks for a password on the terminal without echoing it. When the standard input is not a terminal,
+// as when piped from a script, a line is read from it instead.
+func ReadPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return ReadLine(prompt)
+	}
+
+	fmt.Print(prompt)
+
+	password, err := term.ReadPassword(fd)
+	// The newline typed by the user was not echoed either
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(password)), nil
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+)
+
+// VerifyAudit walks the audit event chain (of one document with ?docId=) and reports the first broken link
+func VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	docId := r.URL.Query().Get("docId")
+
+	report, err := database.VerifyEventChain(docId)
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not verify audit chain")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetDocCertificate downloads the certificate of completion of a signed document, generating it if missing
+func GetDocCertificate(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: id")
+		return
+	}
+
+	doc, docErr := database.Docs.Get(id)
+
+	if errors.Is(docErr, database.ErrDocNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	if docErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
+		return
+	}
+
+	if !doc.IsSigned {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Document is not signed")
+		return
+	}
+
+	key := ""
+	if doc.CertificateKey != nil {
+		key = *doc.CertificateKey
+	}
+
+	dataKey, keyErr := managers.DocDataKey(&doc)
+	if keyErr != nil {
+		log.Println("Could not read document key", keyErr)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read certificate")
+		return
+	}
+
+	file, info, openErr := lib.OpenFile(key, dataKey)
+	if openErr != nil {
+		generatedKey, _, generateErr := managers.GenerateCertificate(doc)
+		if generateErr != nil {
+			log.Println("Could not generate certificate", generateErr)
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not generate certificate")
+			return
+		}
+
+		file, info, openErr = lib.OpenFile(generatedKey, dataKey)
+		if openErr != nil {
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read certificate")
+			return
+		}
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="certificate-%s.pdf"`, doc.Id))
+	http.ServeContent(w, r, "", info.ModTime, file)
+}
@@ -1,10 +1,10 @@
 package controllers
 
 import (
-	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -24,14 +24,6 @@ func GetAllDocs(w http.ResponseWriter, r *http.Request) {
 	limit := r.URL.Query().Get("limit")
 	signed := r.URL.Query().Get("signed")
 
-	var keywordParam *string
-	if keyword != "" {
-		k := "%" + keyword + "%"
-		keywordParam = &k
-	} else {
-		keywordParam = nil
-	}
-
 	if page == "" {
 		page = "1"
 	}
@@ -63,132 +55,17 @@ func GetAllDocs(w http.ResponseWriter, r *http.Request) {
 		signedParam = nil
 	}
 
-	prepRes, prepErr := database.DB.Prepare(`
-		SELECT 
-		    id,
-		    title,
-		    description,
-		    tags,
-		    original_name,
-		    original_path,
-		    signed_name,
-		    signed_path,
-		    is_signed,
-		    signed_at,
-		    signed_by_metadata,
-		    signed_by_ip,
-		    ip_whitelist,
-		    deleted_at,
-		    deleted,
-		    created_at,
-		    updated_at
-		FROM documents
-		WHERE 
-		    deleted = 0 AND
-		    (? IS NULL OR is_signed = ?) AND
-			(? IS NULL
-				OR title LIKE ?
-				OR description LIKE ?
-				OR original_name LIKE ?
-			)
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?;
-	`)
-
-	if prepErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not prepare statement")
-		return
-	}
-
-	var total int
-	countErr := database.DB.QueryRow(`
-		SELECT COUNT(*)
-		FROM documents
-		WHERE
-		    deleted = 0 AND
-			(? IS NULL OR is_signed = ?)
-			AND
-			(
-				? IS NULL
-				OR title LIKE ?
-				OR description LIKE ?
-				OR original_name LIKE ?
-			)`,
-		signedParam,
-		signedParam,
-		keywordParam,
-		keywordParam,
-		keywordParam,
-		keywordParam,
-	).Scan(&total)
-
-	if countErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get total count")
-		return
-	}
-
-	queryRes, queryErr := prepRes.Query(
-		signedParam,
-		signedParam,
-
-		keywordParam,
-		keywordParam,
-		keywordParam,
-		keywordParam,
-
-		limitInt,
-		offset,
-	)
-
-	if queryErr != nil {
-		return
-	}
-	defer queryRes.Close()
-
-	var docs []database.Document
-
-	for queryRes.Next() {
-		var doc database.Document
-		var tagsJson, ipJson string
-
-		scanErr := queryRes.Scan(
-			&doc.Id,
-			&doc.Title,
-			&doc.Description,
-			&tagsJson,
-			&doc.OriginalName,
-			&doc.OriginalPath,
-			&doc.SignedName,
-			&doc.SignedPath,
-			&doc.IsSigned,
-			&doc.SignedAt,
-			&doc.SignedByMetadata,
-			&doc.SignedByIp,
-			&ipJson,
-			&doc.DeletedAt,
-			&doc.Deleted,
-			&doc.CreatedAt,
-			&doc.UpdatedAt,
-		)
-
-		if scanErr != nil {
-			fmt.Println("ERROR AT DOC SCANNER", scanErr)
-			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not scan row")
-			return
-		}
-
-		tagsErr := json.Unmarshal([]byte(tagsJson), &doc.Tags)
-		if tagsErr != nil {
-			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not parse tags")
-			return
-		}
-		ipErr := json.Unmarshal([]byte(ipJson), &doc.IpWhitelist)
-		if ipErr != nil {
-			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not parse ip whitelist")
-			return
-		}
+	docs, total, listErr := database.Docs.List(database.DocumentFilter{
+		Keyword: keyword,
+		Signed:  signedParam,
+		Limit:   limitInt,
+		Offset:  offset,
+	})
 
-		docs = append(docs, doc)
+	if listErr != nil {
+		log.Println("Could not list documents", listErr)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not list documents")
+		return
 	}
 
 	lib.SuccessJSON(w, http.StatusOK, map[string]any{
@@ -207,9 +84,9 @@ func GetDocById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	doc, docErr := database.GetDocByID(id)
+	doc, docErr := database.Docs.Get(id)
 
-	if errors.Is(docErr, sql.ErrNoRows) {
+	if errors.Is(docErr, database.ErrDocNotFound) {
 		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
 		return
 	}
@@ -219,6 +96,13 @@ func GetDocById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	doc.OriginalUrl, _ = lib.SignedDownloadURL(doc.Id, lib.FileOriginal, "")
+	if doc.IsSigned {
+		doc.SignedUrl, _ = lib.SignedDownloadURL(doc.Id, lib.FileSigned, "")
+	} else {
+		doc.CurrentUrl, _ = lib.SignedDownloadURL(doc.Id, lib.FileCurrent, "")
+	}
+
 	lib.SuccessJSON(w, http.StatusOK, doc)
 }
 
@@ -234,20 +118,15 @@ func CreateDoc(w http.ResponseWriter, r *http.Request) {
 	description := r.FormValue("description")
 	tags := r.FormValue("tags")
 	ipWhitelist := r.FormValue("ipWhitelist")
+	signingMode := r.FormValue("signingMode")
 
-	tagsSlice, tagsSliceErr := json.Marshal(lib.CsvToSlice(tags))
-	if tagsSliceErr != nil {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Error parsing tags")
-		return
-	}
-	ipWhitelistSlice, ipWhitelistErr := json.Marshal(lib.CsvToSlice(ipWhitelist))
-	if ipWhitelistErr != nil {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Error parsing ip whitelist")
+	if title == "" || description == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required fields: title, description")
 		return
 	}
 
-	if title == "" || description == "" {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required fields: title, description")
+	if !validSigningMode(signingMode) {
+		lib.ErrorJSON(w, http.StatusBadRequest, "signingMode must be sequential or parallel")
 		return
 	}
 
@@ -284,51 +163,49 @@ func CreateDoc(w http.ResponseWriter, r *http.Request) {
 
 	safeName := uuid.New().String() + filepath.Ext(header.Filename)
 
-	multipartFilePath, saveErr := lib.SaveMultipartFile(file, header, "./docs/uploads", safeName)
+	docId := uuid.New().String()
 
-	if saveErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not save file")
+	dataKey, wrappedKey, keyErr := managers.NewDataKey(docId)
+
+	if keyErr != nil {
+		fmt.Println(keyErr)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create document key")
 		return
 	}
 
-	prepare, insertErr := database.DB.Prepare(`INSERT INTO DOCUMENTS (
-                       id,
-                       title,
-                       description,
-                       tags,
-                       original_name,
-                       original_path,
-                       ip_whitelist,
-                       created_at
-    ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	fileKey, fileHash, saveErr := lib.SaveMultipartFile(file, header, "uploads", safeName, dataKey)
 
-	if insertErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not prepare statement")
+	if saveErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not save file")
 		return
 	}
 
-	insertResult, insertErr := prepare.Exec(
-		uuid.New().String(),
-		title,
-		description,
-		string(tagsSlice),
-		header.Filename,
-		multipartFilePath,
-		string(ipWhitelistSlice),
-		time.Now(),
-	)
+	doc := database.Document{
+		Id:           docId,
+		Title:        title,
+		Description:  &description,
+		Tags:         lib.CsvToSlice(tags),
+		OriginalName: header.Filename,
+		OriginalKey:  fileKey,
+		OriginalHash: &fileHash,
+		IpWhitelist:  lib.CsvToSlice(ipWhitelist),
+		SigningMode:  signingMode,
+		DataKey:      wrappedKey,
+	}
 
-	if insertErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not insert document")
-		return
+	if userId := currentUserId(r); userId != "" {
+		doc.CreatedBy = &userId
 	}
 
-	_, insertErr = insertResult.RowsAffected()
-	if insertErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "No rows affected")
+	if insertErr := database.Docs.Create(&doc); insertErr != nil {
+		log.Println("Could not insert document", insertErr)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not insert document")
 		return
 	}
 
+	recordEvent(r, docId, database.EventCreated, fmt.Sprintf("%s (%s)", header.Filename, fileHash))
+	managers.QueueTextExtraction(docId)
+
 	lib.SuccessJSON(w, http.StatusOK, nil)
 }
 
@@ -351,82 +228,122 @@ func UpdateDoc(w http.ResponseWriter, r *http.Request) {
 	description := r.FormValue("description")
 	tags := r.FormValue("tags")
 	ipWhitelist := r.FormValue("ipWhitelist")
+	signingMode := r.FormValue("signingMode")
+
+	if title == "" || description == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required fields: title, description")
+		return
+	}
 
-	tagsSlice, tagsSliceErr := json.Marshal(lib.CsvToSlice(tags))
-	if tagsSliceErr != nil {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Error parsing tags")
+	if !validSigningMode(signingMode) {
+		lib.ErrorJSON(w, http.StatusBadRequest, "signingMode must be sequential or parallel")
 		return
 	}
-	ipWhitelistSlice, ipWhitelistErr := json.Marshal(lib.CsvToSlice(ipWhitelist))
-	if ipWhitelistErr != nil {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Error parsing ip whitelist")
+
+	doc, docErr := database.Docs.Get(id)
+
+	if errors.Is(docErr, database.ErrDocNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
 		return
 	}
 
-	if title == "" || description == "" {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required fields: title, description")
+	if docErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
 		return
 	}
 
+	originalName := doc.OriginalName
+	originalKey := doc.OriginalKey
+	originalHash := doc.OriginalHash
+
+	// The file is optional, without one only the details are updated
 	file, header, formFileErr := r.FormFile("file")
-	if formFileErr != nil {
+	if formFileErr != nil && !errors.Is(formFileErr, http.ErrMissingFile) {
 		lib.ErrorJSON(w, http.StatusBadRequest, "Error parsing multipart form")
 		return
 	}
 
-	docHandleErr := managers.HandleDocPdfErrors(&file, header)
-	if docHandleErr != nil {
-		lib.ErrorJSON(w, http.StatusBadRequest, docHandleErr.Error())
-		return
+	fileReplaced := formFileErr == nil
+	if fileReplaced {
+		defer file.Close()
 	}
 
-	defer file.Close()
+	modeChanged := signingMode != "" && signingMode != doc.SigningMode
 
-	safeName := uuid.New().String() + filepath.Ext(header.Filename)
+	if fileReplaced || modeChanged {
+		// The signers who signed have marked the current file, a new one would drop their signatures. The order
+		// they signed in can not change either.
+		signers, signersErr := database.ListSigners(id)
+		if signersErr != nil {
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signers")
+			return
+		}
+		if latestSigner(signers) != nil && fileReplaced {
+			lib.ErrorJSON(w, http.StatusConflict, "A signer has signed the document, its file can no longer be replaced")
+			return
+		}
+		if latestSigner(signers) != nil && modeChanged {
+			lib.ErrorJSON(w, http.StatusConflict, "A signer has signed the document, its signing mode can no longer be changed")
+			return
+		}
+	}
 
-	multipartFilePath, saveErr := lib.SaveMultipartFile(file, header, "./docs/uploads", safeName)
+	if fileReplaced {
+		docHandleErr := managers.HandleDocPdfErrors(&file, header)
+		if docHandleErr != nil {
+			lib.ErrorJSON(w, http.StatusBadRequest, docHandleErr.Error())
+			return
+		}
 
-	if saveErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not save file")
-		return
-	}
+		dataKey, keyErr := managers.DocDataKey(&doc)
 
-	prepare, updateErr := database.DB.Prepare(`UPDATE DOCUMENTS SET
-		title = ?,
-		description = ?,
-		tags = ?,
-		original_name = ?,
-		original_path = ?,
-		ip_whitelist = ?,
-		updated_at = ?
-		WHERE id = ?
-	`)
+		if keyErr != nil {
+			fmt.Println(keyErr)
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read document key")
+			return
+		}
 
-	if updateErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not prepare statement")
-		return
+		safeName := uuid.New().String() + filepath.Ext(header.Filename)
+
+		fileKey, fileHash, saveErr := lib.SaveMultipartFile(file, header, "uploads", safeName, dataKey)
+
+		if saveErr != nil {
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not save file")
+			return
+		}
+
+		originalName = header.Filename
+		originalKey = fileKey
+		originalHash = &fileHash
 	}
 
-	updateRes, updateErr := prepare.Exec(
-		title,
-		description,
-		string(tagsSlice),
-		header.Filename,
-		multipartFilePath,
-		string(ipWhitelistSlice),
-		time.Now(),
-		id,
-	)
+	updated := doc
+	updated.Title = title
+	updated.Description = &description
+	updated.Tags = lib.CsvToSlice(tags)
+	updated.OriginalName = originalName
+	updated.OriginalKey = originalKey
+	updated.OriginalHash = originalHash
+	updated.IpWhitelist = lib.CsvToSlice(ipWhitelist)
+	if signingMode != "" {
+		updated.SigningMode = signingMode
+	}
 
-	if updateErr != nil {
+	if updateErr := database.Docs.Update(&updated); updateErr != nil {
+		log.Println("Could not update document", updateErr)
 		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not update document")
 		return
 	}
 
-	_, updateErr = updateRes.RowsAffected()
-	if updateErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "No rows affected")
-		return
+	recordEvent(r, id, database.EventUpdated, "title: "+title)
+
+	if fileReplaced {
+		previousHash := ""
+		if doc.OriginalHash != nil {
+			previousHash = *doc.OriginalHash
+		}
+		recordEvent(r, id, database.EventFileReplaced, fmt.Sprintf("%s (%s) -> %s (%s)", doc.OriginalName, previousHash, originalName, *originalHash))
+		managers.QueueTextExtraction(id)
 	}
 
 	lib.SuccessJSON(w, http.StatusOK, nil)
@@ -440,59 +357,73 @@ func DeleteDoc(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleteRes, deleteErr := database.DB.Exec(`UPDATE DOCUMENTS SET deleted = 1 WHERE id = ?`, id)
+	deleted, deleteErr := database.Docs.SoftDelete(id)
 
 	if deleteErr != nil {
 		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not delete document")
 		return
 	}
 
-	_, rowsAffectedErr := deleteRes.RowsAffected()
-
-	if rowsAffectedErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "No rows affected")
+	if deleted {
+		recordEvent(r, id, database.EventDeleted, "")
 	}
 
 	lib.SuccessJSON(w, http.StatusOK, nil)
 }
 
+// validSigningMode tells whether mode is a signing mode, or empty for the default or current one
+func validSigningMode(mode string) bool {
+	return mode == "" || mode == database.SigningSequential || mode == database.SigningParallel
+}
+
+// SignDoc stores the signed file uploaded through a signing link. A document with signers is signed through
+// their links, each signer signing the file left by the previous ones, and is signed once all have signed.
 func SignDoc(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
 	metadata := r.FormValue("metadata")
 	remarks := r.FormValue("remarks")
 
-	if id == "" {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: id")
-		return
-	}
-
-	doc, docErr := database.GetDocByID(id)
-
-	if errors.Is(docErr, sql.ErrNoRows) {
-		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+	doc, link, ok := signingLinkDocument(w, r)
+	if !ok {
 		return
 	}
 
-	if docErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
-		return
-	}
+	id := doc.Id
 
 	if doc.Deleted {
+		recordEvent(r, id, database.EventSignRejected, "document is deleted")
 		lib.ErrorJSON(w, http.StatusBadRequest, "Document is deleted")
+		return
 	}
 
 	if doc.IsSigned {
+		recordEvent(r, id, database.EventSignRejected, "document is already signed")
 		lib.ErrorJSON(w, http.StatusBadRequest, "Document is already signed")
+		return
 	}
 
 	ip := lib.GetClientIP(r)
 
 	if !lib.IsIPAllowed(ip, doc.IpWhitelist) {
+		recordEvent(r, id, database.EventIpRejected, "sign")
 		lib.ErrorJSON(w, http.StatusBadRequest, "IP not allowed")
 		return
 	}
 
+	signers, signersErr := database.ListSigners(id)
+
+	if signersErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signers")
+		return
+	}
+
+	// The link of a removed signer can not sign either, even once no signer is left
+	var signer *database.Signer
+	if len(signers) > 0 || link.SignerId != nil {
+		if signer, ok = linkSigner(w, r, doc, link, signers); !ok {
+			return
+		}
+	}
+
 	parseErr := r.ParseMultipartForm(config.AppConfig.MaxFileSize)
 	if parseErr != nil {
 		lib.ErrorJSON(w, http.StatusBadRequest, "Error parsing multipart form")
@@ -515,67 +446,258 @@ func SignDoc(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dataKey, keyErr := managers.DocDataKey(&doc)
+	if keyErr != nil {
+		fmt.Println(keyErr)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read document key")
+		return
+	}
+
+	// The signed file must be derived from the file the signer was given, with the marks of the previous signers
+	baseKey := doc.OriginalKey
+	if latest := latestSigner(signers); latest != nil {
+		baseKey = *latest.FileKey
+	}
+
+	originalBytes, readErr := lib.ReadFile(baseKey, dataKey)
+	if readErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read original document")
+		return
+	}
+
+	signedBytes, readErr := io.ReadAll(file)
+	if readErr != nil {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Could not read uploaded file")
+		return
+	}
+
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read uploaded file")
+		return
+	}
+
+	_, derivationErr := managers.CheckSignedDerivation(originalBytes, signedBytes)
+
+	var notDerived *managers.DerivationError
+	if errors.As(derivationErr, &notDerived) {
+		recordEvent(r, id, database.EventSignRejected, notDerived.Code+": "+notDerived.Message)
+		lib.ErrorCodeJSON(w, http.StatusUnprocessableEntity, notDerived.Code, notDerived.Message)
+		return
+	}
+
 	safeName := "signed_" + uuid.New().String() + filepath.Ext(header.Filename)
 
-	savePath, saveErr := lib.SaveMultipartFile(file, header, "./docs/signed", safeName)
+	signedKey, submittedHash, saveErr := lib.SaveMultipartFile(file, header, "signed", safeName, dataKey)
 
 	if saveErr != nil {
 		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not save file")
 		return
 	}
 
-	exec, updateErr := database.DB.Exec(`
-		UPDATE DOCUMENTS SET 
-		                     signed_name = ?,
-		                     signed_path = ?,
-		                     is_signed = 1,
-		                     signed_at = ?,
-		                     signed_by_metadata = ?,
-		                     remarks = ?,
-		                     signed_by_ip = ?
-		                 WHERE id = ?	                 
-	`, safeName, savePath, time.Now(), metadata, remarks, ip, id)
+	if signer != nil {
+		signAsSigner(w, r, doc, link, *signer, signers, database.SignerSignature{
+			FileKey:       signedKey,
+			SubmittedHash: submittedHash,
+			Metadata:      metadata,
+			Remarks:       remarks,
+			IP:            ip,
+			UserAgent:     r.UserAgent(),
+			At:            time.Now(),
+		}, signedBytes, dataKey)
+		return
+	}
+
+	updateErr := completeSigning(r, doc, database.Signature{
+		Name:          safeName,
+		Key:           signedKey,
+		Hash:          submittedHash,
+		SubmittedHash: submittedHash,
+		Metadata:      metadata,
+		Remarks:       remarks,
+		IP:            ip,
+		At:            time.Now(),
+	}, signedBytes, dataKey, database.EventSigned, signedDetail(metadata, submittedHash)+" with "+linkDetail(link))
 
 	if updateErr != nil {
 		fmt.Println("Error updating document", updateErr)
 		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not update document")
+		return
 	}
 
-	_, updateErr = exec.RowsAffected()
+	lib.SuccessJSON(w, http.StatusOK, "Signed document")
+}
 
-	if updateErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "No rows affected")
+// signedDetail describes a signature in the history of its document, the name typed by the signer being left out
+// when none was given
+func signedDetail(name string, hash string) string {
+	if name == "" {
+		return "signed (" + hash + ")"
 	}
-
-	lib.SuccessJSON(w, http.StatusOK, "Signed document")
+	return fmt.Sprintf("signed by %q (%s)", name, hash)
 }
 
-func ViewDoc(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+// completeSigning seals the signed file of a document, records the document signed along with the given event,
+// then timestamps the document and creates its certificate. Only recording the document can fail, a file that
+// can not be sealed is kept unsealed.
+func completeSigning(
+	r *http.Request,
+	doc database.Document,
+	signature database.Signature,
+	signedBytes []byte,
+	dataKey []byte,
+	event string,
+	detail string,
+) error {
+	id := doc.Id
+
+	// Seal the submitted file with the server key, the unsealed file is kept if this fails
+	sealDetail := ""
+	sealed, sealErr := managers.SealPDF(signedBytes, "Signed via Inkra, document "+id)
+
+	if sealErr == nil {
+		sealedHash := lib.HashBytes(sealed)
+		if writeErr := lib.SaveFile(signature.Key, sealed, dataKey); writeErr != nil {
+			sealErr = writeErr
+		} else {
+			signature.Hash = sealedHash
+			sealDetail = sealedHash
+		}
+	}
 
-	if id == "" {
-		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: id")
-		return
+	if sealErr != nil && !errors.Is(sealErr, managers.ErrSealDisabled) {
+		fmt.Println("Could not seal document", sealErr)
 	}
 
-	doc, docErr := database.GetDocByID(id)
+	if updateErr := database.Docs.MarkSigned(id, signature); updateErr != nil {
+		return updateErr
+	}
 
-	if errors.Is(docErr, sql.ErrNoRows) {
-		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
-		return
+	recordEvent(r, id, event, detail)
+
+	if sealDetail != "" {
+		recordEvent(r, id, database.EventSealed, sealDetail)
+	} else if !errors.Is(sealErr, managers.ErrSealDisabled) {
+		recordEvent(r, id, database.EventSealFailed, sealErr.Error())
 	}
 
-	if docErr != nil {
-		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
+	signedDoc, docErr := database.Docs.Get(id)
+	if docErr == nil {
+		info, timestampErr := managers.TimestampDoc(signedDoc)
+		if timestampErr == nil {
+			recordEvent(r, id, database.EventTimestamped, fmt.Sprintf("%s by %s", info.GenTime.UTC().Format(time.RFC3339), config.AppConfig.TsaURL))
+			signedDoc, docErr = database.Docs.Get(id)
+		} else if !errors.Is(timestampErr, managers.ErrTimestampDisabled) {
+			fmt.Println("Could not timestamp document", timestampErr)
+			recordEvent(r, id, database.EventTimestampFailed, timestampErr.Error())
+		}
+	}
+
+	if docErr == nil {
+		// The certificate is generated again on download if this fails
+		_, certificateHash, certificateErr := managers.GenerateCertificate(signedDoc)
+		if certificateErr != nil {
+			log.Println("Could not generate certificate", certificateErr)
+		} else {
+			recordEvent(r, id, database.EventCertificateCreated, certificateHash)
+		}
+	}
+
+	return nil
+}
+
+// PublicDocument is what the signing link shows, without storage paths or signer details
+type PublicDocument struct {
+	Title        string    `json:"title"`
+	Description  *string   `json:"description,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+	OriginalName string    `json:"originalName"`
+	IsSigned     bool      `json:"isSigned"`
+	SignedAt     *string   `json:"signedAt,omitempty"`
+	Deleted      bool      `json:"deleted"`
+	CreatedAt    string    `json:"createdAt"`
+	OriginalUrl  string    `json:"originalUrl"`
+	SignedUrl    string    `json:"signedUrl,omitempty"`
+	UrlExpiresAt time.Time `json:"urlExpiresAt"`
+
+	// CurrentUrl is the file to sign, with the marks of the signers who signed already, and Version the number
+	// of those signatures, to send back when signing
+	CurrentUrl string `json:"currentUrl,omitempty"`
+	Version    int    `json:"version"`
+	// CanSign tells whether the link can sign the document now
+	CanSign bool `json:"canSign"`
+
+	// Set for a document with signers
+	SigningMode string         `json:"signingMode,omitempty"`
+	Signers     []PublicSigner `json:"signers,omitempty"`
+	// Signer is the signer of the link, unset for a link that can only view the document
+	Signer *PublicSigner `json:"signer,omitempty"`
+}
+
+// PublicSigner is what a signer sees of the signers of a document
+type PublicSigner struct {
+	Name     string  `json:"name"`
+	Order    int     `json:"order"`
+	Status   string  `json:"status"`
+	SignedAt *string `json:"signedAt,omitempty"`
+}
+
+// ViewDoc shows the document of a signing link to its recipient
+func ViewDoc(w http.ResponseWriter, r *http.Request) {
+	doc, link, ok := signingLinkDocument(w, r)
+	if !ok {
 		return
 	}
 
 	ip := lib.GetClientIP(r)
 
 	if !lib.IsIPAllowed(ip, doc.IpWhitelist) {
+		recordEvent(r, doc.Id, database.EventIpRejected, "view")
 		lib.ErrorJSON(w, http.StatusBadRequest, "IP not allowed")
 		return
 	}
 
-	lib.SuccessJSON(w, http.StatusOK, doc)
+	signers, signersErr := database.ListSigners(doc.Id)
+
+	if signersErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signers")
+		return
+	}
+
+	recordEvent(r, doc.Id, database.EventViewed, linkDetail(link))
+
+	view := PublicDocument{
+		Title:        doc.Title,
+		Description:  doc.Description,
+		Tags:         doc.Tags,
+		OriginalName: doc.OriginalName,
+		IsSigned:     doc.IsSigned,
+		SignedAt:     doc.SignedAt,
+		Deleted:      doc.Deleted,
+		CreatedAt:    doc.CreatedAt,
+		Version:      signedVersion(signers),
+	}
+
+	view.OriginalUrl, view.UrlExpiresAt = lib.SignedDownloadURL(doc.Id, lib.FileOriginal, link.Id)
+	if doc.IsSigned {
+		view.SignedUrl, _ = lib.SignedDownloadURL(doc.Id, lib.FileSigned, link.Id)
+	} else {
+		view.CurrentUrl, _ = lib.SignedDownloadURL(doc.Id, lib.FileCurrent, link.Id)
+	}
+
+	signer := findSigner(signers, link)
+	view.CanSign = !doc.Deleted && !doc.IsSigned && len(signers) == 0 && link.SignerId == nil
+
+	if len(signers) > 0 {
+		view.SigningMode = doc.SigningMode
+		for _, s := range signers {
+			public := PublicSigner{Name: s.Name, Order: s.Order, Status: s.Status, SignedAt: s.SignedAt}
+			view.Signers = append(view.Signers, public)
+			if signer != nil && s.Id == signer.Id {
+				view.Signer = &public
+			}
+		}
+		view.CanSign = !doc.Deleted && !doc.IsSigned && signer != nil && canSign(doc, signers, *signer)
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, view)
 }
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/go-chi/chi/v5"
+)
+
+// useMemoryDocuments makes the handlers use an in-memory repository holding the given documents, created in order
+func useMemoryDocuments(t *testing.T, docs ...database.Document) {
+	t.Helper()
+
+	previous := database.Docs
+	memory := database.NewMemoryDocuments()
+	for _, doc := range docs {
+		if err := memory.Create(&doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	database.Docs = memory
+	t.Cleanup(func() { database.Docs = previous })
+}
+
+// serve runs a request through the document routes and decodes the data of the response
+func serve(t *testing.T, target string, data any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	router := chi.NewRouter()
+	router.Get("/api/docs", GetAllDocs)
+	router.Get("/api/docs/{id}", GetDocById)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+	body := struct {
+		Success bool `json:"success"`
+		Data    any  `json:"data"`
+	}{Data: data}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s: %v in %s", target, err, rec.Body)
+	}
+	if body.Success != (rec.Code == http.StatusOK) {
+		t.Errorf("%s: success %v with status %d", target, body.Success, rec.Code)
+	}
+	return rec
+}
+
+func TestGetAllDocs(t *testing.T) {
+	var docs []database.Document
+	for _, title := range []string{"Lease", "Invoice", "Manual"} {
+		docs = append(docs, database.Document{Id: title, Title: title, OriginalName: title + ".pdf"})
+	}
+	useMemoryDocuments(t, docs...)
+	if err := database.Docs.MarkSigned("Invoice", database.Signature{Hash: "aa"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		ids    []string
+		total  int
+	}{
+		{"/api/docs", []string{"Manual", "Invoice", "Lease"}, 3},
+		{"/api/docs?limit=2&page=2", []string{"Lease"}, 3},
+		{"/api/docs?page=0&limit=1000", []string{"Manual", "Invoice", "Lease"}, 3},
+		{"/api/docs?signed=true", []string{"Invoice"}, 1},
+		{"/api/docs?signed=false", []string{"Manual", "Lease"}, 2},
+		{"/api/docs?keyword=lea", []string{"Lease"}, 1},
+		{"/api/docs?keyword=contract", []string{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			var page struct {
+				Total int                 `json:"total"`
+				Docs  []database.Document `json:"docs"`
+			}
+			if rec := serve(t, tt.target, &page); rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+
+			ids := []string{}
+			for _, doc := range page.Docs {
+				ids = append(ids, doc.Id)
+			}
+			if !slices.Equal(ids, tt.ids) || page.Total != tt.total {
+				t.Errorf("got %v of %d, want %v of %d", ids, page.Total, tt.ids, tt.total)
+			}
+		})
+	}
+}
+
+func TestGetDocById(t *testing.T) {
+	useMemoryDocuments(t,
+		database.Document{Id: "lease", Title: "Lease", OriginalName: "lease.pdf"},
+		database.Document{Id: "invoice", Title: "Invoice", OriginalName: "invoice.pdf"},
+	)
+	if err := database.Docs.MarkSigned("invoice", database.Signature{Hash: "aa"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id      string
+		status  int
+		signed  bool
+		current bool
+	}{
+		{"lease", http.StatusOK, false, true},
+		{"invoice", http.StatusOK, true, false},
+		{"missing", http.StatusNotFound, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			var doc database.Document
+			rec := serve(t, "/api/docs/"+tt.id, &doc)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if doc.Id != tt.id || doc.OriginalUrl == "" {
+				t.Errorf("got %+v", doc)
+			}
+			if (doc.SignedUrl != "") != tt.signed || (doc.CurrentUrl != "") != tt.current {
+				t.Errorf("signed url %q, current url %q", doc.SignedUrl, doc.CurrentUrl)
+			}
+		})
+	}
+}
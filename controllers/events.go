@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/go-chi/chi/v5"
+)
+
+// recordEvent logs a document event with the actor, ip and user agent of the request.
+// Admin requests are attributed to the logged-in user, public ones to "public".
+func recordEvent(r *http.Request, docId string, event string, detail string) {
+	actor := "public"
+	if email, ok := r.Context().Value("userEmail").(string); ok && email != "" {
+		actor = email
+	}
+
+	_ = database.LogDocEvent(docId, event, actor, lib.GetClientIP(r), r.UserAgent(), detail)
+}
+
+func GetDocEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: id")
+		return
+	}
+
+	_, docErr := database.Docs.Get(id)
+
+	if errors.Is(docErr, database.ErrDocNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	if docErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
+		return
+	}
+
+	events, eventsErr := database.GetDocEvents(id)
+
+	if eventsErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document events")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, events)
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+	"github.com/fbn776/inkra/middleware"
+	"github.com/fbn776/inkra/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetDocFile serves the original, signed or current file of a document. It needs either the token of a user or a
+// signed download url, see lib.SignedDownloadURL, whose signing link must still be active. Encrypted files are
+// decrypted on the fly and Range requests are supported.
+func GetDocFile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	kind := chi.URLParam(r, "kind")
+
+	if kind != lib.FileOriginal && kind != lib.FileSigned && kind != lib.FileCurrent {
+		lib.ErrorJSON(w, http.StatusNotFound, "Unknown file")
+		return
+	}
+
+	signedURL := r.URL.Query().Has("signature")
+
+	if signedURL {
+		if !lib.VerifyDownloadSignature(id, kind, r.URL.Query()) {
+			lib.ErrorJSON(w, http.StatusForbidden, "Download link is invalid or expired")
+			return
+		}
+	} else if _, err := middleware.Authenticate(r); err != nil {
+		lib.ErrorJSON(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	doc, docErr := database.Docs.Get(id)
+
+	if errors.Is(docErr, database.ErrDocNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	if docErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
+		return
+	}
+
+	if signedURL {
+		if doc.Deleted {
+			lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+			return
+		}
+
+		if linkId := r.URL.Query().Get("link"); linkId != "" {
+			_, linkErr := database.GetActiveSigningLink(doc.Id, linkId)
+			if errors.Is(linkErr, database.ErrSigningLinkNotFound) {
+				lib.ErrorJSON(w, http.StatusForbidden, "Signing link is invalid or expired")
+				return
+			}
+			if linkErr != nil {
+				lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signing link")
+				return
+			}
+		}
+
+		if !lib.IsIPAllowed(lib.GetClientIP(r), doc.IpWhitelist) {
+			lib.ErrorJSON(w, http.StatusBadRequest, "IP not allowed")
+			return
+		}
+	}
+
+	key, name, hash := doc.OriginalKey, doc.OriginalName, doc.OriginalHash
+	switch {
+	case kind == lib.FileSigned || kind == lib.FileCurrent && doc.IsSigned:
+		if !doc.IsSigned || doc.SignedKey == nil {
+			lib.ErrorJSON(w, http.StatusNotFound, "Document is not signed")
+			return
+		}
+		key, hash = *doc.SignedKey, doc.SignedHash
+		name = "signed-" + doc.OriginalName
+
+	case kind == lib.FileCurrent:
+		signers, signersErr := database.ListSigners(doc.Id)
+		if signersErr != nil {
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signers")
+			return
+		}
+		if latest := latestSigner(signers); latest != nil {
+			key, hash = *latest.FileKey, latest.SubmittedHash
+			name = "signing-" + doc.OriginalName
+		}
+	}
+
+	disposition := "inline"
+	if r.URL.Query().Get("download") == "1" {
+		disposition = "attachment"
+	}
+	disposition = mime.FormatMediaType(disposition, map[string]string{"filename": filepath.Base(name)})
+
+	dataKey, keyErr := managers.DocDataKey(&doc)
+	if keyErr != nil {
+		fmt.Println("Could not read document key", keyErr)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read file")
+		return
+	}
+
+	// Encrypted files are decrypted here, the bucket only holds ciphertext
+	if config.AppConfig.S3RedirectDownloads && dataKey == nil {
+		url, urlErr := config.AppConfig.Storage.SignedURL(key, time.Minute, storage.SignedURLOptions{
+			ContentType:        "application/pdf",
+			ContentDisposition: disposition,
+		})
+		if urlErr == nil {
+			http.Redirect(w, r, url, http.StatusFound)
+			return
+		}
+	}
+
+	file, info, openErr := lib.OpenFile(key, dataKey)
+	if errors.Is(openErr, storage.ErrNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if openErr != nil {
+		fmt.Println("Could not open file", openErr)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read file")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if hash != nil {
+		w.Header().Set("ETag", `"`+*hash+`"`)
+	}
+
+	http.ServeContent(w, r, "", info.ModTime, file)
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type CreateAPIKeyRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+// CreateAPIKeyResponse holds the key itself, which is only ever returned once
+type CreateAPIKeyResponse struct {
+	APIKey database.APIKey `json:"apiKey"`
+	Key    string          `json:"key"`
+}
+
+// apiKeyPrefixLength is how much of a key is kept to tell it apart
+const apiKeyPrefixLength = 12
+
+// GetAPIKeys lists the API keys of the logged-in user, revoked ones included
+func GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := database.ListAPIKeys(currentUserId(r))
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get API keys")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, keys)
+}
+
+// CreateAPIKey makes an API key for the logged-in user
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var body CreateAPIKeyRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: name")
+		return
+	}
+
+	if !database.IsValidScope(body.Scope) {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Scope must be read, upload or full")
+		return
+	}
+
+	secret, secretErr := lib.RandomToken(32)
+
+	if secretErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create API key")
+		return
+	}
+
+	key := database.APIKeyPrefix + secret
+	apiKey := database.APIKey{
+		Id:     uuid.New().String(),
+		UserId: currentUserId(r),
+		Name:   name,
+		Prefix: key[:apiKeyPrefixLength],
+		Scope:  body.Scope,
+	}
+
+	if err := database.CreateAPIKey(&apiKey, lib.HashToken(key)); err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create API key")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusCreated, CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// RevokeAPIKey revokes an API key of the logged-in user, requests made with it are refused from then on
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	revokeErr := database.RevokeAPIKey(currentUserId(r), chi.URLParam(r, "id"))
+
+	if errors.Is(revokeErr, database.ErrAPIKeyNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	if revokeErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not revoke API key")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetLoginAttempts lists the login attempts, most recent first, to spot credential stuffing. The next page
+// starts before the id of the last attempt of a page.
+func GetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := database.LoginAttemptFilter{
+		UserId:  query.Get("userId"),
+		Email:   query.Get("email"),
+		Ip:      query.Get("ip"),
+		Outcome: query.Get("outcome"),
+		Failed:  query.Get("failed") == "true",
+	}
+
+	filter.Limit, _ = strconv.Atoi(query.Get("limit"))
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	if before := query.Get("before"); before != "" {
+		var err error
+		if filter.Before, err = strconv.ParseInt(before, 10, 64); err != nil {
+			lib.ErrorJSON(w, http.StatusBadRequest, "before must be the id of an attempt")
+			return
+		}
+	}
+
+	// An RFC 3339 time, or a duration back from now such as 24h
+	if since := query.Get("since"); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.Since = t
+		} else if d, err := time.ParseDuration(since); err == nil && d > 0 {
+			filter.Since = time.Now().Add(-d)
+		} else {
+			lib.ErrorJSON(w, http.StatusBadRequest, "since must be an RFC 3339 time or a duration such as 24h")
+			return
+		}
+	}
+
+	attempts, err := database.ListLoginAttempts(filter)
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not list login attempts")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, attempts)
+}
+
+// UnlockUser ends the lockout of a user after failed logins
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	err := database.UnlockUser(chi.URLParam(r, "id"))
+
+	if errors.Is(err, database.ErrUserNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not unlock user")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, nil)
+}
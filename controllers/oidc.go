@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+)
+
+type OIDCInfo struct {
+	Enabled bool   `json:"enabled"`
+	Name    string `json:"name,omitempty"`
+}
+
+// GetOIDC tells the login page whether to offer single sign-on
+func GetOIDC(w http.ResponseWriter, r *http.Request) {
+	info := OIDCInfo{Enabled: managers.OIDCEnabled()}
+	if info.Enabled {
+		info.Name = config.AppConfig.OIDCName
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, info)
+}
+
+// oidcStateCookie ties a login to the browser that started it, so that nobody can have a victim complete a login
+// they started themselves and end up logged in to their account
+const oidcStateCookie = "inkra_oidc_state"
+
+// StartOIDCLogin sends the browser to the single sign-on provider
+func StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := managers.StartOIDCLogin()
+
+	if errors.Is(err, managers.ErrOIDCDisabled) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Single sign-on is not configured")
+		return
+	}
+
+	if err != nil {
+		fmt.Println("ERROR: ", err)
+		oidcLoginFailed(w, r, "Could not reach the single sign-on provider")
+		return
+	}
+
+	setOIDCStateCookie(w, state, int(managers.OIDCStateTTL.Seconds()))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// setOIDCStateCookie sets the state cookie for the callback, which the provider reaches with a top-level
+// navigation, hence SameSite=Lax. A negative maxAge deletes it.
+func setOIDCStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.AppConfig.OIDCRedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCCallback is where the provider sends the browser back to. The login page gets the refresh token of the new
+// session in the fragment of its url, which is not sent to servers, and exchanges it at once for its tokens. A
+// user who must still give a two-factor code gets the challenge token instead.
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// A login can only come back once
+	cookie, cookieErr := r.Cookie(oidcStateCookie)
+	setOIDCStateCookie(w, "", -1)
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		message := query.Get("error_description")
+		if message == "" {
+			message = providerErr
+		}
+		oidcLoginFailed(w, r, "Single sign-on failed: "+message)
+		return
+	}
+
+	state := query.Get("state")
+	if cookieErr != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		oidcLoginFailed(w, r, "Login was not started in this browser, try again")
+		return
+	}
+
+	_, tokens, challenge, err := managers.CompleteOIDCLogin(state, query.Get("code"), lib.GetClientIP(r), r.UserAgent())
+
+	switch {
+	case errors.Is(err, database.ErrOIDCStateNotFound):
+		oidcLoginFailed(w, r, "Login expired, try again")
+	case errors.Is(err, managers.ErrOIDCNoRole):
+		oidcLoginFailed(w, r, "Your account is not allowed to use Inkra")
+	case errors.Is(err, managers.ErrOIDCNoAccount):
+		oidcLoginFailed(w, r, "You have no Inkra account, ask an owner for an invite")
+	case errors.Is(err, managers.ErrOIDCEmailUnverified):
+		oidcLoginFailed(w, r, "Your email is not verified by the single sign-on provider")
+	case errors.Is(err, database.ErrEmailTaken):
+		oidcLoginFailed(w, r, "Your email belongs to another single sign-on account")
+	case errors.Is(err, managers.ErrAccountDisabled):
+		oidcLoginFailed(w, r, "Account is disabled")
+	case err != nil:
+		fmt.Println("ERROR: ", err)
+		oidcLoginFailed(w, r, "Single sign-on failed")
+	case challenge != nil:
+		http.Redirect(w, r, "/login#challengeToken="+url.QueryEscape(challenge.ChallengeToken), http.StatusFound)
+	default:
+		http.Redirect(w, r, "/login#refreshToken="+url.QueryEscape(tokens.RefreshToken), http.StatusFound)
+	}
+}
+
+// oidcLoginFailed sends the browser back to the login page, which shows the message
+func oidcLoginFailed(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/login?error="+url.QueryEscape(message), http.StatusFound)
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+	"github.com/go-chi/chi/v5"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// SessionInfo is a session as listed to its user
+type SessionInfo struct {
+	database.Session
+	// Device is the browser and system of the User-Agent
+	Device string `json:"device"`
+	// Current tells whether this is the session of the request
+	Current bool `json:"current"`
+}
+
+// tokenClaims are the claims of the access token of the request, nil for API keys
+func tokenClaims(r *http.Request) *lib.Claims {
+	claims, _ := r.Context().Value("tokenClaims").(*lib.Claims)
+	return claims
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var body RefreshTokenRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: refreshToken")
+		return
+	}
+
+	tokens, err := managers.RefreshSession(body.RefreshToken, lib.GetClientIP(r), r.UserAgent())
+
+	switch {
+	case errors.Is(err, database.ErrSessionNotFound):
+		lib.ErrorJSON(w, http.StatusUnauthorized, "Refresh token is invalid or expired")
+	case errors.Is(err, database.ErrRefreshReused):
+		lib.ErrorJSON(w, http.StatusUnauthorized, "Refresh token was already used, the session has been ended")
+	case errors.Is(err, managers.ErrAccountDisabled):
+		lib.ErrorJSON(w, http.StatusForbidden, "Account is disabled")
+	case err != nil:
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not refresh token")
+	default:
+		lib.SuccessJSON(w, http.StatusOK, tokens)
+	}
+}
+
+// Logout ends the session of the request and revokes its access token
+func Logout(w http.ResponseWriter, r *http.Request) {
+	claims := tokenClaims(r)
+
+	if claims == nil {
+		lib.ErrorJSON(w, http.StatusBadRequest, "API keys are revoked with DELETE /api/keys/:id")
+		return
+	}
+
+	if err := database.RevokeSession(claims.Subject, claims.SessionId); err != nil && !errors.Is(err, database.ErrSessionNotFound) {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not log out")
+		return
+	}
+
+	if err := database.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not log out")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, nil)
+}
+
+// LogoutAll ends every session of the logged-in user, on all devices
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	count, err := database.RevokeUserSessions(currentUserId(r))
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not log out")
+		return
+	}
+
+	if claims := tokenClaims(r); claims != nil {
+		if err := database.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not log out")
+			return
+		}
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, map[string]int64{"sessions": count})
+}
+
+// GetSessions lists the active sessions of the logged-in user
+func GetSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := database.ListSessions(currentUserId(r))
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get sessions")
+		return
+	}
+
+	claims := tokenClaims(r)
+	infos := make([]SessionInfo, len(sessions))
+	for i, session := range sessions {
+		userAgent := ""
+		if session.UserAgent != nil {
+			userAgent = *session.UserAgent
+		}
+
+		infos[i] = SessionInfo{
+			Session: session,
+			Device:  lib.DescribeUserAgent(userAgent),
+			Current: claims != nil && claims.SessionId == session.Id,
+		}
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, infos)
+}
+
+// DeleteSession ends one of the sessions of the logged-in user
+func DeleteSession(w http.ResponseWriter, r *http.Request) {
+	revokeErr := database.RevokeSession(currentUserId(r), chi.URLParam(r, "id"))
+
+	if errors.Is(revokeErr, database.ErrSessionNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if revokeErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not end session")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,385 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type SignerRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// ExpiresAt is an RFC 3339 time, the link of the signer does not expire without it
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// SignerResponse holds a new signer and its signing link, whose token is only ever returned once
+type SignerResponse struct {
+	Signer database.Signer `json:"signer"`
+	SigningLinkResponse
+}
+
+const documentChangedMessage = "The document was signed by another signer since it was loaded, load it again"
+
+// latestSigner returns the signer who signed last, whose file holds the marks of every signer so far, nil when
+// none signed yet
+func latestSigner(signers []database.Signer) *database.Signer {
+	var latest *database.Signer
+	for i := range signers {
+		if signers[i].Version != nil && (latest == nil || *signers[i].Version > *latest.Version) {
+			latest = &signers[i]
+		}
+	}
+	return latest
+}
+
+// signedVersion is the number of signatures on the file of a document, 0 before any signer signed
+func signedVersion(signers []database.Signer) int {
+	if latest := latestSigner(signers); latest != nil {
+		return *latest.Version
+	}
+	return 0
+}
+
+// nextSigner returns the first pending signer in order, nil when all signed
+func nextSigner(signers []database.Signer) *database.Signer {
+	for i := range signers {
+		if signers[i].Status == database.SignerPending {
+			return &signers[i]
+		}
+	}
+	return nil
+}
+
+// canSign tells whether a pending signer may sign now, in sequential mode only the next signer can
+func canSign(doc database.Document, signers []database.Signer, signer database.Signer) bool {
+	if signer.Status != database.SignerPending {
+		return false
+	}
+	if doc.SigningMode == database.SigningParallel {
+		return true
+	}
+	next := nextSigner(signers)
+	return next != nil && next.Id == signer.Id
+}
+
+// findSigner returns the signer of a signing link, nil for a link without one or whose signer was removed
+func findSigner(signers []database.Signer, link database.SigningLink) *database.Signer {
+	if link.SignerId == nil {
+		return nil
+	}
+	for i := range signers {
+		if signers[i].Id == *link.SignerId {
+			return &signers[i]
+		}
+	}
+	return nil
+}
+
+// linkSigner returns the signer signing a document with signers through a link, or writes the error response
+// when the link is not the link of a signer, the signer already signed, it is not their turn or the document was
+// signed by another signer since it was loaded
+func linkSigner(w http.ResponseWriter, r *http.Request, doc database.Document, link database.SigningLink, signers []database.Signer) (*database.Signer, bool) {
+	signer := findSigner(signers, link)
+
+	if signer == nil {
+		recordEvent(r, doc.Id, database.EventSignRejected, linkDetail(link)+" is not the link of a signer")
+		lib.ErrorJSON(w, http.StatusForbidden, "This link can only view the document, it is signed through the links of its signers")
+		return nil, false
+	}
+
+	if signer.Status == database.SignerSigned {
+		recordEvent(r, doc.Id, database.EventSignRejected, signerDetail(*signer)+" already signed")
+		lib.ErrorJSON(w, http.StatusBadRequest, "You have already signed this document")
+		return nil, false
+	}
+
+	if !canSign(doc, signers, *signer) {
+		next := nextSigner(signers)
+		recordEvent(r, doc.Id, database.EventSignRejected, signerDetail(*signer)+" signed before "+signerDetail(*next))
+		lib.ErrorCodeJSON(w, http.StatusConflict, "SIGNER_NOT_NEXT", "Waiting for "+next.Name+" to sign first")
+		return nil, false
+	}
+
+	// The version the signer loaded, so that a signer in parallel mode does not sign without the marks of another.
+	// Marks added as annotations are not caught by the derivation check, hence the version is required.
+	loaded := r.FormValue("version")
+	if loaded == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: version")
+		return nil, false
+	}
+
+	if loaded != strconv.Itoa(signedVersion(signers)) {
+		recordEvent(r, doc.Id, database.EventSignRejected, signerDetail(*signer)+" signed version "+loaded)
+		lib.ErrorCodeJSON(w, http.StatusConflict, "DOCUMENT_CHANGED", documentChangedMessage)
+		return nil, false
+	}
+
+	return signer, true
+}
+
+// signAsSigner records the signature of a signer of a document, and completes the document when every signer has
+// signed. signers are the signers of the document as read before this signature.
+func signAsSigner(
+	w http.ResponseWriter,
+	r *http.Request,
+	doc database.Document,
+	link database.SigningLink,
+	signer database.Signer,
+	signers []database.Signer,
+	signature database.SignerSignature,
+	signedBytes []byte,
+	dataKey []byte,
+) {
+	complete, err := database.SignSigner(doc.Id, signer.Id, signedVersion(signers), signature)
+
+	// The file submitted by the signer is only kept once the signature is recorded
+	if err != nil {
+		if deleteErr := lib.DeleteFile(signature.FileKey); deleteErr != nil {
+			log.Println("Could not delete the file of a rejected signature", deleteErr)
+		}
+	}
+
+	if errors.Is(err, database.ErrDocumentChanged) {
+		recordEvent(r, doc.Id, database.EventSignRejected, signerDetail(signer)+" signed an outdated version")
+		lib.ErrorCodeJSON(w, http.StatusConflict, "DOCUMENT_CHANGED", documentChangedMessage)
+		return
+	}
+
+	if err != nil {
+		fmt.Println("Error updating signer", err)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not update document")
+		return
+	}
+
+	recordEvent(r, doc.Id, database.EventSigned, signedDetail(signature.Metadata, signature.SubmittedHash)+" as "+signerDetail(signer)+" with "+linkDetail(link))
+
+	// Decided by SignSigner, as another signer may have signed since signers were read
+	if !complete {
+		lib.SuccessJSON(w, http.StatusOK, "Signed document, waiting for the other signers")
+		return
+	}
+
+	signed, err := database.ListSigners(doc.Id)
+	if err == nil {
+		err = completeSigners(r, doc, signed, signedBytes, dataKey)
+	}
+
+	if err != nil {
+		fmt.Println("Error updating document", err)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not update document")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, "Signed document")
+}
+
+// completeSigners completes a document once all its signers signed, the file of the last signer, signedBytes,
+// becoming the signed file of the document
+func completeSigners(r *http.Request, doc database.Document, signers []database.Signer, signedBytes []byte, dataKey []byte) error {
+	last := latestSigner(signers)
+
+	names := make([]string, 0, len(signers))
+	remarks := []string{}
+	for _, signer := range signers {
+		names = append(names, signer.Name)
+		if signer.Remarks != nil && *signer.Remarks != "" {
+			remarks = append(remarks, signer.Name+": "+*signer.Remarks)
+		}
+	}
+
+	signature := database.Signature{
+		Name:          path.Base(*last.FileKey),
+		Key:           *last.FileKey,
+		Hash:          *last.SubmittedHash,
+		SubmittedHash: *last.SubmittedHash,
+		Metadata:      strings.Join(names, ", "),
+		Remarks:       strings.Join(remarks, "\n"),
+		IP:            *last.Ip,
+		At:            time.Now(),
+	}
+
+	return completeSigning(r, doc, signature, signedBytes, dataKey, database.EventCompleted, fmt.Sprintf("signed by %d signers", len(signers)))
+}
+
+// GetSigners lists the signers of a document in their order
+func GetSigners(w http.ResponseWriter, r *http.Request) {
+	doc, ok := linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	signers, err := database.ListSigners(doc.Id)
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signers")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, signers)
+}
+
+// CreateSigner adds a signer to a document, after its other signers, along with the signing link of the signer
+func CreateSigner(w http.ResponseWriter, r *http.Request) {
+	doc, ok := linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	var body SignerRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Invalid request body, expiresAt must be an RFC 3339 time")
+		return
+	}
+
+	body.Name, body.Email = strings.TrimSpace(body.Name), strings.TrimSpace(body.Email)
+
+	if body.Name == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: name")
+		return
+	}
+
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		lib.ErrorJSON(w, http.StatusBadRequest, "expiresAt must be in the future")
+		return
+	}
+
+	if doc.Deleted {
+		lib.ErrorJSON(w, http.StatusConflict, "Document is deleted")
+		return
+	}
+
+	if doc.IsSigned {
+		lib.ErrorJSON(w, http.StatusConflict, "Document is already signed")
+		return
+	}
+
+	token, tokenHash, tokenErr := newSigningToken()
+
+	if tokenErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create signer")
+		return
+	}
+
+	signer := database.Signer{
+		Id:         uuid.New().String(),
+		DocumentId: doc.Id,
+		Name:       body.Name,
+	}
+	recipient := body.Name
+	if body.Email != "" {
+		signer.Email = &body.Email
+		recipient = body.Name + " <" + body.Email + ">"
+	}
+
+	link := database.SigningLink{Id: uuid.New().String(), Recipient: &recipient}
+	if userId := currentUserId(r); userId != "" {
+		link.CreatedBy = &userId
+	}
+
+	if err := database.CreateSigner(&signer, &link, tokenHash, body.ExpiresAt); err != nil {
+		log.Println("Could not create signer", err)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create signer")
+		return
+	}
+
+	recordEvent(r, doc.Id, database.EventSignerAdded, signerDetail(signer))
+	recordEvent(r, doc.Id, database.EventLinkCreated, linkDetail(link))
+
+	lib.SuccessJSON(w, http.StatusCreated, SignerResponse{
+		Signer:              signer,
+		SigningLinkResponse: SigningLinkResponse{Link: link, Token: token, Url: signingLinkPath + token},
+	})
+}
+
+// DeleteSigner removes a signer who has not signed yet and revokes its links. When every remaining signer has
+// signed, the document is completed.
+func DeleteSigner(w http.ResponseWriter, r *http.Request) {
+	doc, ok := linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	signer, err := database.GetSigner(doc.Id, chi.URLParam(r, "signerId"))
+
+	if errors.Is(err, database.ErrSignerNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Signer not found")
+		return
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signer")
+		return
+	}
+
+	if signer.Status == database.SignerSigned {
+		lib.ErrorJSON(w, http.StatusConflict, "Signer has already signed, the document holds their marks")
+		return
+	}
+
+	err = database.DeleteSigner(doc.Id, signer.Id)
+
+	if errors.Is(err, database.ErrSignerNotFound) {
+		// Signed or removed since it was read
+		lib.ErrorJSON(w, http.StatusConflict, "Signer has changed, reload the signers")
+		return
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not remove signer")
+		return
+	}
+
+	recordEvent(r, doc.Id, database.EventSignerRemoved, signerDetail(signer))
+
+	signers, err := database.ListSigners(doc.Id)
+	if err == nil && !doc.IsSigned && !doc.Deleted && len(signers) > 0 && nextSigner(signers) == nil {
+		err = completeRemainingSigners(r, doc, signers)
+	}
+
+	if err != nil {
+		fmt.Println("Error updating document", err)
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not update document")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, nil)
+}
+
+// completeRemainingSigners completes a document whose pending signers were removed, from the stored file of the
+// last signer
+func completeRemainingSigners(r *http.Request, doc database.Document, signers []database.Signer) error {
+	dataKey, err := managers.DocDataKey(&doc)
+	if err != nil {
+		return err
+	}
+
+	signedBytes, err := lib.ReadFile(*latestSigner(signers).FileKey, dataKey)
+	if err != nil {
+		return err
+	}
+
+	return completeSigners(r, doc, signers, signedBytes, dataKey)
+}
+
+// signerDetail names a signer in the history of its document
+func signerDetail(signer database.Signer) string {
+	detail := fmt.Sprintf("signer %d %s", signer.Order, signer.Name)
+	if signer.Email != nil {
+		detail += " <" + *signer.Email + ">"
+	}
+	return detail
+}
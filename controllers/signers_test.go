@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/fbn776/inkra/database"
+)
+
+// testSigners returns signers in order, "signed" ones having signed at the given versions and the others pending
+func testSigners(versions ...int) []database.Signer {
+	signers := make([]database.Signer, len(versions))
+	for i, version := range versions {
+		signers[i] = database.Signer{Id: string(rune('a' + i)), Order: i + 1, Status: database.SignerPending}
+		if version > 0 {
+			signers[i].Status = database.SignerSigned
+			signers[i].Version = &version
+		}
+	}
+	return signers
+}
+
+func TestCanSign(t *testing.T) {
+	sequential := database.Document{SigningMode: database.SigningSequential}
+	parallel := database.Document{SigningMode: database.SigningParallel}
+
+	tests := []struct {
+		name    string
+		doc     database.Document
+		signers []database.Signer
+		signer  int
+		want    bool
+	}{
+		{"first in order", sequential, testSigners(0, 0, 0), 0, true},
+		{"before their turn", sequential, testSigners(0, 0, 0), 1, false},
+		{"next in order", sequential, testSigners(1, 0, 0), 1, true},
+		{"after a skipped signer", sequential, testSigners(1, 0, 0), 2, false},
+		{"already signed", sequential, testSigners(1, 0, 0), 0, false},
+		{"any order", parallel, testSigners(0, 0, 0), 2, true},
+		{"already signed in any order", parallel, testSigners(0, 0, 1), 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canSign(tt.doc, tt.signers, tt.signers[tt.signer]); got != tt.want {
+				t.Errorf("canSign = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		signers []database.Signer
+		version int
+		latest  string
+		next    string
+	}{
+		{"none signed", testSigners(0, 0), 0, "", "a"},
+		{"in order", testSigners(1, 2, 0), 2, "b", "c"},
+		{"out of order", testSigners(0, 2, 1), 2, "b", "a"},
+		{"all signed", testSigners(3, 1, 2), 3, "a", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signedVersion(tt.signers); got != tt.version {
+				t.Errorf("signedVersion = %d, want %d", got, tt.version)
+			}
+
+			latest := ""
+			if signer := latestSigner(tt.signers); signer != nil {
+				latest = signer.Id
+			}
+			if latest != tt.latest {
+				t.Errorf("latestSigner = %q, want %q", latest, tt.latest)
+			}
+
+			next := ""
+			if signer := nextSigner(tt.signers); signer != nil {
+				next = signer.Id
+			}
+			if next != tt.next {
+				t.Errorf("nextSigner = %q, want %q", next, tt.next)
+			}
+		})
+	}
+}
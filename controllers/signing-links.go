@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type SigningLinkRequest struct {
+	Recipient string `json:"recipient"`
+	// ExpiresAt is an RFC 3339 time, the link does not expire without it
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// SigningLinkResponse holds the token of a signing link, which is only ever returned once
+type SigningLinkResponse struct {
+	Link  database.SigningLink `json:"link"`
+	Token string               `json:"token"`
+	Url   string               `json:"url"`
+}
+
+// signingLinkPath is the page of the web app where a recipient views and signs a document
+const signingLinkPath = "/doc/sign/"
+
+// linkDocument returns the document of the url of the signing link handlers, or writes the error response
+func linkDocument(w http.ResponseWriter, r *http.Request) (database.Document, bool) {
+	doc, err := database.Docs.Get(chi.URLParam(r, "id"))
+
+	if errors.Is(err, database.ErrDocNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+		return doc, false
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
+		return doc, false
+	}
+
+	return doc, true
+}
+
+// signingLinkDocument returns the document of the signing link token in the url of the public handlers, or writes
+// the error response. Revoked and expired links are answered as unknown ones.
+func signingLinkDocument(w http.ResponseWriter, r *http.Request) (database.Document, database.SigningLink, bool) {
+	link, err := database.GetSigningLinkByToken(lib.HashToken(chi.URLParam(r, "token")))
+
+	if errors.Is(err, database.ErrSigningLinkNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Signing link is invalid or expired")
+		return database.Document{}, link, false
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signing link")
+		return database.Document{}, link, false
+	}
+
+	doc, err := database.Docs.Get(link.DocumentId)
+
+	if errors.Is(err, database.ErrDocNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+		return doc, link, false
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
+		return doc, link, false
+	}
+
+	return doc, link, true
+}
+
+// decodeSigningLinkRequest reads the optional body of the create and regenerate handlers, or writes the error
+// response
+func decodeSigningLinkRequest(w http.ResponseWriter, r *http.Request) (SigningLinkRequest, bool) {
+	var body SigningLinkRequest
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			lib.ErrorJSON(w, http.StatusBadRequest, "Invalid request body, expiresAt must be an RFC 3339 time")
+			return body, false
+		}
+	}
+
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		lib.ErrorJSON(w, http.StatusBadRequest, "expiresAt must be in the future")
+		return body, false
+	}
+
+	return body, true
+}
+
+// newSigningToken returns a token for a signing link and its hash
+func newSigningToken() (string, string, error) {
+	token, err := lib.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, lib.HashToken(token), nil
+}
+
+// GetSigningLinks lists the signing links of a document, revoked and expired ones included
+func GetSigningLinks(w http.ResponseWriter, r *http.Request) {
+	doc, ok := linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := database.ListSigningLinks(doc.Id)
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signing links")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, links)
+}
+
+// CreateSigningLink makes a signing link of a document for a recipient
+func CreateSigningLink(w http.ResponseWriter, r *http.Request) {
+	doc, ok := linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	body, ok := decodeSigningLinkRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if doc.Deleted {
+		lib.ErrorJSON(w, http.StatusConflict, "Document is deleted")
+		return
+	}
+
+	token, tokenHash, tokenErr := newSigningToken()
+
+	if tokenErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create signing link")
+		return
+	}
+
+	link := database.SigningLink{
+		Id:         uuid.New().String(),
+		DocumentId: doc.Id,
+	}
+	if recipient := strings.TrimSpace(body.Recipient); recipient != "" {
+		link.Recipient = &recipient
+	}
+	if userId := currentUserId(r); userId != "" {
+		link.CreatedBy = &userId
+	}
+
+	if err := database.CreateSigningLink(&link, tokenHash, body.ExpiresAt); err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create signing link")
+		return
+	}
+
+	recordEvent(r, doc.Id, database.EventLinkCreated, linkDetail(link))
+
+	lib.SuccessJSON(w, http.StatusCreated, SigningLinkResponse{Link: link, Token: token, Url: signingLinkPath + token})
+}
+
+// RevokeSigningLink revokes a signing link of a document, it can no longer view nor sign the document
+func RevokeSigningLink(w http.ResponseWriter, r *http.Request) {
+	doc, ok := linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	link, err := database.GetSigningLink(doc.Id, chi.URLParam(r, "linkId"))
+	if err == nil {
+		err = database.RevokeSigningLink(doc.Id, link.Id)
+	}
+
+	if errors.Is(err, database.ErrSigningLinkNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Signing link not found")
+		return
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not revoke signing link")
+		return
+	}
+
+	if link.RevokedAt == nil {
+		recordEvent(r, doc.Id, database.EventLinkRevoked, linkDetail(link))
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, nil)
+}
+
+// RegenerateSigningLink issues a new token for a signing link, with a new expiry. The previous token stops
+// working and a revoked link is made active again.
+func RegenerateSigningLink(w http.ResponseWriter, r *http.Request) {
+	doc, ok := linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	body, ok := decodeSigningLinkRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if doc.Deleted {
+		lib.ErrorJSON(w, http.StatusConflict, "Document is deleted")
+		return
+	}
+
+	token, tokenHash, tokenErr := newSigningToken()
+
+	if tokenErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not regenerate signing link")
+		return
+	}
+
+	linkId := chi.URLParam(r, "linkId")
+	link, err := database.GetSigningLink(doc.Id, linkId)
+
+	// The link of a removed signer stays revoked
+	if err == nil && link.SignerId != nil {
+		if _, signerErr := database.GetSigner(doc.Id, *link.SignerId); errors.Is(signerErr, database.ErrSignerNotFound) {
+			lib.ErrorJSON(w, http.StatusConflict, "The signer of this link was removed")
+			return
+		} else if signerErr != nil {
+			err = signerErr
+		}
+	}
+
+	if err == nil {
+		err = database.RegenerateSigningLink(doc.Id, linkId, tokenHash, body.ExpiresAt)
+	}
+
+	if errors.Is(err, database.ErrSigningLinkNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Signing link not found")
+		return
+	}
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not regenerate signing link")
+		return
+	}
+
+	link, err = database.GetSigningLink(doc.Id, linkId)
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get signing link")
+		return
+	}
+
+	recordEvent(r, doc.Id, database.EventLinkRegenerated, linkDetail(link))
+
+	lib.SuccessJSON(w, http.StatusOK, SigningLinkResponse{Link: link, Token: token, Url: signingLinkPath + token})
+}
+
+// linkDetail names a signing link in the history of its document
+func linkDetail(link database.SigningLink) string {
+	if link.Recipient != nil {
+		return "link " + link.Id + " for " + *link.Recipient
+	}
+	return "link " + link.Id
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetDocTimestamp downloads the RFC 3161 timestamp token (DER) over the signed file of a document
+func GetDocTimestamp(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: id")
+		return
+	}
+
+	doc, docErr := database.Docs.Get(id)
+
+	if errors.Is(docErr, database.ErrDocNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document not found")
+		return
+	}
+
+	if docErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get document")
+		return
+	}
+
+	if doc.TimestampToken == nil {
+		lib.ErrorJSON(w, http.StatusNotFound, "Document has no timestamp")
+		return
+	}
+
+	token, decodeErr := base64.StdEncoding.DecodeString(*doc.TimestampToken)
+	if decodeErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not read timestamp")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="timestamp-%s.tst"`, doc.Id))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(token))
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+// RecoveryCodesResponse holds recovery codes, which are only ever returned once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// twoFactorError writes the response for an error of the two-factor managers
+func twoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, managers.ErrInvalidCode):
+		lib.ErrorJSON(w, http.StatusForbidden, "Invalid code")
+	case errors.Is(err, managers.ErrTwoFactorEnabled):
+		lib.ErrorJSON(w, http.StatusConflict, "Two-factor authentication is already enabled")
+	case errors.Is(err, managers.ErrTwoFactorDisabled):
+		lib.ErrorJSON(w, http.StatusConflict, "Two-factor authentication is not enabled")
+	case errors.Is(err, managers.ErrTwoFactorNotSetUp):
+		lib.ErrorJSON(w, http.StatusConflict, "Set up two-factor authentication first")
+	default:
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not update two-factor authentication")
+	}
+}
+
+// GetTwoFactor tells whether the logged-in user has two-factor authentication on
+func GetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := database.GetUser(currentUserId(r))
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	status := TwoFactorStatus{Enabled: user.TwoFactorEnabled}
+	if user.TwoFactorEnabled {
+		if status.RecoveryCodesLeft, err = database.CountRecoveryCodes(user.Id); err != nil {
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get recovery codes")
+			return
+		}
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, status)
+}
+
+// SetupTwoFactor generates the secret to add to an authenticator app, see EnableTwoFactor
+func SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := database.GetUser(currentUserId(r))
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	setup, err := managers.SetupTOTP(user)
+	if err != nil {
+		twoFactorError(w, err)
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, setup)
+}
+
+// EnableTwoFactor turns two-factor authentication on with a first code of the authenticator app
+func EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body TwoFactorCodeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Code == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: code")
+		return
+	}
+
+	user, err := database.GetUser(currentUserId(r))
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	codes, err := managers.EnableTOTP(user, body.Code)
+	if err != nil {
+		twoFactorError(w, err)
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off, given the password and a code or recovery code
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body DisableTwoFactorRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Password == "" || body.Code == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required fields: password, code")
+		return
+	}
+
+	user, err := database.GetUser(currentUserId(r))
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	if !managers.CheckPassword(user, body.Password) {
+		lib.ErrorJSON(w, http.StatusForbidden, "Invalid password")
+		return
+	}
+
+	if err := managers.VerifySecondFactor(user, body.Code); err != nil {
+		twoFactorError(w, err)
+		return
+	}
+
+	if err := database.DisableTOTP(user.Id); err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not disable two-factor authentication")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, nil)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the logged-in user, given a code or recovery code
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var body TwoFactorCodeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Code == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: code")
+		return
+	}
+
+	user, err := database.GetUser(currentUserId(r))
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	codes, err := managers.RegenerateRecoveryCodes(user, body.Code)
+	if err != nil {
+		twoFactorError(w, err)
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type InviteUserRequest struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+}
+
+type UpdateUserRequest struct {
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type AcceptInviteRequest struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// InviteResponse holds the invite token of a user, which is only ever returned once
+type InviteResponse struct {
+	User        database.User `json:"user"`
+	InviteToken string        `json:"inviteToken"`
+	InviteUrl   string        `json:"inviteUrl"`
+}
+
+// currentUserId is the id of the logged-in user, set by middleware.JWTAuthMiddleware
+func currentUserId(r *http.Request) string {
+	id, _ := r.Context().Value("userId").(string)
+	return id
+}
+
+// GetAccount returns the logged-in user
+func GetAccount(w http.ResponseWriter, r *http.Request) {
+	user, err := database.GetUser(currentUserId(r))
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, user)
+}
+
+// ChangePassword replaces the password of the logged-in user, who gives the current one. All the sessions of
+// the user end, and the tokens of a new one are returned so that the request's device stays logged in.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var body ChangePasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.CurrentPassword == "" || body.NewPassword == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required fields: currentPassword, newPassword")
+		return
+	}
+
+	if tokenClaims(r) == nil {
+		lib.ErrorJSON(w, http.StatusForbidden, "API keys can not change the password")
+		return
+	}
+
+	user, err := database.GetUser(currentUserId(r))
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	err = managers.ChangePassword(user, body.CurrentPassword, body.NewPassword, lib.GetClientIP(r), r.UserAgent())
+
+	var locked *managers.AccountLockedError
+	switch {
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+		lib.ErrorCodeJSON(w, http.StatusTooManyRequests, "ACCOUNT_LOCKED", "Too many failed logins, try again later")
+		return
+	case errors.Is(err, managers.ErrNoPassword):
+		lib.ErrorJSON(w, http.StatusConflict, "Your account logs in with single sign-on")
+		return
+	case errors.Is(err, managers.ErrWrongPassword):
+		lib.ErrorJSON(w, http.StatusForbidden, "Current password is wrong")
+		return
+	case errors.Is(err, managers.ErrPasswordTooShort):
+		lib.ErrorJSON(w, http.StatusBadRequest, "Password must be at least 8 characters")
+		return
+	case err != nil:
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not change password")
+		return
+	}
+
+	tokens, err := managers.StartSession(user, lib.GetClientIP(r), r.UserAgent())
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Password changed, but could not log in again")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, tokens)
+}
+
+func GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := database.ListUsers()
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get users")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, users)
+}
+
+// InviteUser adds a user without a password and returns the token with which it sets one, see AcceptInvite
+func InviteUser(w http.ResponseWriter, r *http.Request) {
+	var body InviteUserRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !strings.Contains(body.Email, "@") {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+
+	if !database.IsValidRole(body.Role) {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Role must be owner, editor or viewer")
+		return
+	}
+
+	invitedBy := currentUserId(r)
+	user := database.User{
+		Id:        uuid.New().String(),
+		Email:     body.Email,
+		Name:      strings.TrimSpace(body.Name),
+		Role:      body.Role,
+		InvitedBy: &invitedBy,
+	}
+
+	createErr := database.CreateUser(&user)
+
+	if errors.Is(createErr, database.ErrEmailTaken) {
+		lib.ErrorJSON(w, http.StatusConflict, "A user with this email already exists")
+		return
+	}
+
+	if createErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create user")
+		return
+	}
+
+	sendInvite(w, user, http.StatusCreated)
+}
+
+// ReinviteUser replaces the invite token of a user who has not accepted the invite, for instance once it expired
+func ReinviteUser(w http.ResponseWriter, r *http.Request) {
+	user, userErr := database.GetUser(chi.URLParam(r, "id"))
+
+	if errors.Is(userErr, database.ErrUserNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if userErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	if !user.InvitePending {
+		lib.ErrorJSON(w, http.StatusConflict, "User has already accepted the invite")
+		return
+	}
+
+	sendInvite(w, user, http.StatusOK)
+}
+
+func sendInvite(w http.ResponseWriter, user database.User, status int) {
+	token, tokenErr := lib.RandomToken(32)
+
+	if tokenErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create invite")
+		return
+	}
+
+	expiresAt := time.Now().Add(config.AppConfig.InviteTTL).UTC()
+
+	if err := database.SetUserInvite(user.Id, lib.HashToken(token), expiresAt); err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not create invite")
+		return
+	}
+
+	expires := expiresAt.Format(time.RFC3339)
+	user.InviteExpiresAt = &expires
+
+	lib.SuccessJSON(w, status, InviteResponse{
+		User:        user,
+		InviteToken: token,
+		InviteUrl:   "/invite/" + token,
+	})
+}
+
+// UpdateUser changes the role of a user or disables it. The last enabled owner can not be demoted or disabled.
+func UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var body UpdateUserRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, userErr := database.GetUser(chi.URLParam(r, "id"))
+
+	if errors.Is(userErr, database.ErrUserNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if userErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	if body.Role != nil {
+		if !database.IsValidRole(*body.Role) {
+			lib.ErrorJSON(w, http.StatusBadRequest, "Role must be owner, editor or viewer")
+			return
+		}
+		user.Role = *body.Role
+	}
+
+	if body.Disabled != nil {
+		user.Disabled = *body.Disabled
+	}
+
+	updateErr := database.UpdateUserAccess(user.Id, user.Role, user.Disabled)
+
+	if errors.Is(updateErr, database.ErrLastOwner) {
+		lib.ErrorJSON(w, http.StatusConflict, "There must be at least one enabled owner")
+		return
+	}
+
+	if updateErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not update user")
+		return
+	}
+
+	updated, err := database.GetUser(user.Id)
+
+	if err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, updated)
+}
+
+// AcceptInvite sets the password of an invited user, who can then log in
+func AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	var body AcceptInviteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(body.Password) < managers.MinPasswordLength {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Password must be at least 8 characters")
+		return
+	}
+
+	user, userErr := database.GetUserByInvite(lib.HashToken(body.Token), time.Now())
+
+	if errors.Is(userErr, database.ErrUserNotFound) {
+		lib.ErrorJSON(w, http.StatusNotFound, "Invite is invalid or expired")
+		return
+	}
+
+	if userErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get invite")
+		return
+	}
+
+	hash, hashErr := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+
+	if hashErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not set password")
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		name = user.Name
+	}
+
+	if err := database.AcceptInvite(user.Id, name, string(hash)); err != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not set password")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, map[string]string{"email": user.Email})
+}
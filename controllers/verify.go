@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+)
+
+type VerifyResult struct {
+	Hash      string  `json:"hash"`
+	Matched   bool    `json:"matched"`
+	Match     string  `json:"match,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	IsSigned  bool    `json:"isSigned"`
+	SignedAt  *string `json:"signedAt,omitempty"`
+	CreatedAt string  `json:"createdAt,omitempty"`
+
+	TimestampedAt *string `json:"timestampedAt,omitempty"`
+}
+
+// VerifyDoc tells whether an uploaded pdf (multipart field `file`) or a SHA-256 hash (field `hash`)
+// matches the original or the signed copy of any document.
+func VerifyDoc(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, config.AppConfig.MaxFileSize)
+
+	var hash string
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		parseErr := r.ParseMultipartForm(config.AppConfig.MaxFileSize)
+		if parseErr != nil {
+			lib.ErrorJSON(w, http.StatusBadRequest, "Error parsing multipart form")
+			return
+		}
+	}
+
+	file, _, formFileErr := r.FormFile("file")
+
+	if formFileErr == nil {
+		defer file.Close()
+
+		fileHash, hashErr := lib.HashReader(file)
+		if hashErr != nil {
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not hash file")
+			return
+		}
+
+		hash = fileHash
+	} else {
+		hash = strings.ToLower(strings.TrimSpace(r.FormValue("hash")))
+	}
+
+	if hash == "" {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Missing required field: file or hash")
+		return
+	}
+
+	if decoded, decodeErr := hex.DecodeString(hash); decodeErr != nil || len(decoded) != 32 {
+		lib.ErrorJSON(w, http.StatusBadRequest, "Invalid hash, expected a hex encoded SHA-256")
+		return
+	}
+
+	doc, match, docErr := database.Docs.FindByHash(hash)
+
+	if errors.Is(docErr, database.ErrDocNotFound) {
+		lib.SuccessJSON(w, http.StatusOK, VerifyResult{Hash: hash})
+		return
+	}
+
+	if docErr != nil {
+		lib.ErrorJSON(w, http.StatusInternalServerError, "Could not verify document")
+		return
+	}
+
+	lib.SuccessJSON(w, http.StatusOK, VerifyResult{
+		Hash:      hash,
+		Matched:   true,
+		Match:     match,
+		Title:     doc.Title,
+		IsSigned:  doc.IsSigned,
+		SignedAt:  doc.SignedAt,
+		CreatedAt: doc.CreatedAt,
+
+		TimestampedAt: doc.TimestampedAt,
+	})
+}
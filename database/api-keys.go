@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Scopes of the API keys, each allowing what the previous one does. A key never allows more than the role of
+// its user.
+const (
+	ScopeRead   = "read"
+	ScopeUpload = "upload"
+	ScopeFull   = "full"
+)
+
+// APIKeyPrefix starts every API key, telling them apart from login tokens
+const APIKeyPrefix = "ink_"
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKey struct {
+	Id     string `json:"id"`
+	UserId string `json:"userId"`
+	Name   string `json:"name"`
+	// Prefix is the start of the key, the key itself is only known to its owner
+	Prefix     string  `json:"prefix"`
+	Scope      string  `json:"scope"`
+	LastUsedAt *string `json:"lastUsedAt,omitempty"`
+	LastUsedIp *string `json:"lastUsedIp,omitempty"`
+	RevokedAt  *string `json:"revokedAt,omitempty"`
+	CreatedAt  string  `json:"createdAt"`
+}
+
+// IsValidScope tells whether scope is one of the scopes of the API keys
+func IsValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeUpload || scope == ScopeFull
+}
+
+var scopeRanks = map[string]int{ScopeRead: 1, ScopeUpload: 2, ScopeFull: 3}
+
+// ScopeAllows tells whether a key of scope may do what needs the scope required
+func ScopeAllows(scope string, required string) bool {
+	return scopeRanks[required] > 0 && scopeRanks[scope] >= scopeRanks[required]
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, scope, last_used_at, last_used_ip, revoked_at, created_at`
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	key := APIKey{}
+	err := row.Scan(
+		&key.Id,
+		&key.UserId,
+		&key.Name,
+		&key.Prefix,
+		&key.Scope,
+		&key.LastUsedAt,
+		&key.LastUsedIp,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// GetAPIKeyByHash returns the key, revoked or not, with the given hash
+func GetAPIKeyByHash(keyHash string) (APIKey, error) {
+	return scanAPIKey(DB.QueryRow(`SELECT `+apiKeyColumns+` FROM API_KEYS WHERE key_hash = ?`, keyHash))
+}
+
+// ListAPIKeys returns the keys of a user, newest first
+func ListAPIKeys(userId string) ([]APIKey, error) {
+	rows, err := DB.Query(`SELECT `+apiKeyColumns+` FROM API_KEYS WHERE user_id = ? ORDER BY created_at DESC, id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// CreateAPIKey stores a new key, of which only the hash is kept
+func CreateAPIKey(key *APIKey, keyHash string) error {
+	now := time.Now().UTC()
+	_, err := DB.Exec(`
+		INSERT INTO API_KEYS (id, user_id, name, prefix, key_hash, scope, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.Id, key.UserId, key.Name, key.Prefix, keyHash, key.Scope, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	key.CreatedAt = now.Format(time.RFC3339Nano)
+	return nil
+}
+
+// RevokeAPIKey revokes a key of a user, a key revoked already is left as is
+func RevokeAPIKey(userId string, id string) error {
+	res, err := DB.Exec(
+		`UPDATE API_KEYS SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ? AND user_id = ?`,
+		time.Now().UTC(), id, userId,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrAPIKeyNotFound)
+}
+
+// TouchAPIKey records when and from where a key was last used
+func TouchAPIKey(id string, ip string) error {
+	_, err := DB.Exec(`UPDATE API_KEYS SET last_used_at = ?, last_used_ip = ? WHERE id = ?`, time.Now().UTC(), ip, id)
+	return err
+}
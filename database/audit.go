@@ -0,0 +1,379 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// AuditKey keys the hashes of the audit events. It is kept outside the database, so that an event can not be
+	// rewritten along with the hashes after it.
+	AuditKey []byte
+	// AuditHeadPath is the file recording the newest event outside the database, so that deleting the last events
+	// is noticed
+	AuditHeadPath string
+)
+
+var ErrAuditKeyMissing = errors.New("audit key is not loaded")
+
+// eventContent is the part of an event covered by its hash
+type eventContent struct {
+	DocId     string
+	Event     string
+	Actor     string
+	Ip        string
+	UserAgent string
+	Detail    string
+	CreatedAt time.Time
+}
+
+// eventHash is an HMAC under the audit key for keyed events, and a plain SHA-256 for the events written before
+// the key existed
+func eventHash(e eventContent, prevHash string, docPrevHash string, keyed bool) string {
+	payload, _ := json.Marshal([]string{
+		e.DocId,
+		e.Event,
+		e.Actor,
+		e.Ip,
+		e.UserAgent,
+		e.Detail,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		prevHash,
+		docPrevHash,
+	})
+
+	if !keyed {
+		sum := sha256.Sum256(payload)
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, AuditKey)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// chainHead is the newest event as recorded in AuditHeadPath, along with the first keyed event, after which every
+// event must be keyed
+type chainHead struct {
+	EventId      int64  `json:"eventId"`
+	Hash         string `json:"hash"`
+	FirstKeyedId int64  `json:"firstKeyedId"`
+}
+
+// firstKeyedEvent returns the id of the first keyed event as recorded in the head file, or as found in the
+// database when the file does not record it yet, 0 when there is none
+func firstKeyedEvent(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}) (int64, error) {
+	head, err := readChainHead()
+	if err != nil {
+		return 0, err
+	}
+	if head != nil && head.FirstKeyedId != 0 {
+		return head.FirstKeyedId, nil
+	}
+
+	var id int64
+	err = q.QueryRow(`SELECT COALESCE(MIN(id), 0) FROM DOCUMENT_EVENTS WHERE keyed = TRUE`).Scan(&id)
+	return id, err
+}
+
+// writeChainHead records the newest event outside the database, replacing the file at once
+func writeChainHead(head chainHead) error {
+	if AuditHeadPath == "" {
+		return nil
+	}
+
+	data, _ := json.Marshal(head)
+
+	if err := os.MkdirAll(filepath.Dir(AuditHeadPath), 0755); err != nil {
+		return err
+	}
+	tmp := AuditHeadPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, AuditHeadPath)
+}
+
+// readChainHead returns the recorded head, nil when none was recorded yet
+func readChainHead() (*chainHead, error) {
+	if AuditHeadPath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(AuditHeadPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	head := &chainHead{}
+	if err := json.Unmarshal(data, head); err != nil {
+		return nil, fmt.Errorf("%s: %w", AuditHeadPath, err)
+	}
+	return head, nil
+}
+
+// RecordEventChainHead records the newest event of the database as the chain head, for a database whose events
+// were replaced as a whole, such as a restored backup
+func RecordEventChainHead() error {
+	var head chainHead
+	err := DB.QueryRow(`
+		SELECT id, hash, (SELECT COALESCE(MIN(id), 0) FROM DOCUMENT_EVENTS WHERE keyed = TRUE)
+		FROM DOCUMENT_EVENTS ORDER BY id DESC LIMIT 1`,
+	).Scan(&head.EventId, &head.Hash, &head.FirstKeyedId)
+	if errors.Is(err, sql.ErrNoRows) {
+		if AuditHeadPath == "" {
+			return nil
+		}
+		if err := os.Remove(AuditHeadPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeChainHead(head)
+}
+
+type ChainBreak struct {
+	EventId int64  `json:"eventId"`
+	DocId   string `json:"docId"`
+	Reason  string `json:"reason"`
+}
+
+type ChainReport struct {
+	Valid  bool        `json:"valid"`
+	Events int         `json:"events"`
+	Head   string      `json:"head"`
+	Break  *ChainBreak `json:"break,omitempty"`
+}
+
+type chainRow struct {
+	id          int64
+	content     eventContent
+	prevHash    sql.NullString
+	docPrevHash sql.NullString
+	hash        sql.NullString
+	keyed       bool
+}
+
+func scanChainRow(rows *sql.Rows) (chainRow, error) {
+	var row chainRow
+	var actor, ip, userAgent, detail sql.NullString
+
+	err := rows.Scan(
+		&row.id,
+		&row.content.DocId,
+		&row.content.Event,
+		&actor,
+		&ip,
+		&userAgent,
+		&detail,
+		&row.content.CreatedAt,
+		&row.prevHash,
+		&row.docPrevHash,
+		&row.hash,
+		&row.keyed,
+	)
+
+	row.content.Actor = actor.String
+	row.content.Ip = ip.String
+	row.content.UserAgent = userAgent.String
+	row.content.Detail = detail.String
+
+	return row, err
+}
+
+// VerifyEventChain walks the audit events in insertion order, recomputing every hash and checking
+// the links to the previous event. Every event from the first keyed one recorded in the head file on must be
+// keyed. With a docId only that document's chain is checked, along with the head event, otherwise the chain must
+// also reach the head recorded outside the database. The first broken link is reported.
+func VerifyEventChain(docId string) (ChainReport, error) {
+	report := ChainReport{Valid: true}
+
+	head, err := readChainHead()
+	if err != nil {
+		return report, err
+	}
+
+	if head != nil && docId != "" {
+		if reason, err := checkHeadEvent(*head); err != nil || reason != "" {
+			if reason != "" {
+				report.Valid = false
+				report.Break = &ChainBreak{EventId: head.EventId, Reason: reason}
+			}
+			return report, err
+		}
+	}
+
+	query := `
+		SELECT id, doc_id, event, actor, ip, user_agent, detail, created_at, prev_hash, doc_prev_hash, hash, keyed
+		FROM DOCUMENT_EVENTS
+		ORDER BY id ASC`
+	args := []any{}
+
+	if docId != "" {
+		query = `
+		SELECT id, doc_id, event, actor, ip, user_agent, detail, created_at, prev_hash, doc_prev_hash, hash, keyed
+		FROM DOCUMENT_EVENTS
+		WHERE doc_id = ?
+		ORDER BY id ASC`
+		args = append(args, docId)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	lastHash := ""
+	lastDocHash := map[string]string{}
+	keyed := false
+	headSeen := false
+
+	for rows.Next() {
+		row, scanErr := scanChainRow(rows)
+		if scanErr != nil {
+			return report, scanErr
+		}
+
+		report.Events++
+
+		reason := ""
+		switch {
+		case !row.hash.Valid:
+			reason = "event has no hash"
+		case keyed && !row.keyed:
+			reason = "event is not keyed but follows keyed events"
+		case head != nil && head.FirstKeyedId != 0 && row.id >= head.FirstKeyedId && !row.keyed:
+			reason = "event is not keyed but follows the first keyed event recorded outside the database"
+		case row.keyed && AuditKey == nil:
+			return report, ErrAuditKeyMissing
+		case docId == "" && row.prevHash.String != lastHash:
+			reason = fmt.Sprintf("previous hash %q does not match the preceding event %q", row.prevHash.String, lastHash)
+		case row.docPrevHash.String != lastDocHash[row.content.DocId]:
+			reason = fmt.Sprintf("previous document hash %q does not match the preceding document event %q", row.docPrevHash.String, lastDocHash[row.content.DocId])
+		case eventHash(row.content, row.prevHash.String, row.docPrevHash.String, row.keyed) != row.hash.String:
+			reason = "event contents do not match its hash"
+		case head != nil && row.id == head.EventId && row.hash.String != head.Hash:
+			reason = "event does not match the chain head recorded outside the database"
+		}
+
+		if reason != "" {
+			report.Valid = false
+			report.Break = &ChainBreak{EventId: row.id, DocId: row.content.DocId, Reason: reason}
+			return report, nil
+		}
+
+		lastHash = row.hash.String
+		lastDocHash[row.content.DocId] = row.hash.String
+		report.Head = row.hash.String
+		keyed = keyed || row.keyed
+		headSeen = headSeen || (head != nil && row.id == head.EventId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	if docId == "" && head != nil && !headSeen {
+		report.Valid = false
+		report.Break = &ChainBreak{EventId: head.EventId, Reason: "events up to the chain head recorded outside the database are missing"}
+	}
+
+	return report, nil
+}
+
+// checkHeadEvent checks the event recorded as the chain head, which must still be in the database, keyed and
+// unchanged. Returns the reason it does not match, empty when it does.
+func checkHeadEvent(head chainHead) (string, error) {
+	var hash string
+	var keyed bool
+	err := DB.QueryRow(`SELECT hash, keyed FROM DOCUMENT_EVENTS WHERE id = ?`, head.EventId).Scan(&hash, &keyed)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "events up to the chain head recorded outside the database are missing", nil
+	case err != nil:
+		return "", err
+	case !keyed || hash != head.Hash:
+		return "event does not match the chain head recorded outside the database", nil
+	}
+	return "", nil
+}
+
+// chainLegacyEvents hashes events written before the chain existed. Only events older than every
+// hashed event are chained, a missing hash after that is reported as tampering instead.
+func chainLegacyEvents() error {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Runs before the migrations, so before the keyed column exists. Legacy events are never keyed.
+	rows, err := tx.Query(`
+		SELECT id, doc_id, event, actor, ip, user_agent, detail, created_at, prev_hash, doc_prev_hash, hash, 0 AS keyed
+		FROM DOCUMENT_EVENTS
+		WHERE hash IS NULL AND id < COALESCE((SELECT MIN(id) FROM DOCUMENT_EVENTS WHERE hash IS NOT NULL), 9223372036854775807)
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return err
+	}
+
+	var legacy []chainRow
+	for rows.Next() {
+		row, scanErr := scanChainRow(rows)
+		if scanErr != nil {
+			rows.Close()
+			return scanErr
+		}
+		legacy = append(legacy, row)
+	}
+	rows.Close()
+
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	lastHash := ""
+	lastDocHash := map[string]string{}
+
+	for _, row := range legacy {
+		prevHash := lastHash
+		docPrevHash := lastDocHash[row.content.DocId]
+		hash := eventHash(row.content, prevHash, docPrevHash, false)
+
+		_, err = tx.Exec(
+			`UPDATE DOCUMENT_EVENTS SET prev_hash = ?, doc_prev_hash = ?, hash = ? WHERE id = ?`,
+			prevHash, docPrevHash, hash, row.id,
+		)
+		if err != nil {
+			return err
+		}
+
+		lastHash = hash
+		lastDocHash[row.content.DocId] = hash
+	}
+
+	fmt.Println("Chained", len(legacy), "audit events written before hashing was enabled")
+
+	return tx.Commit()
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var ErrBackupUnsupported = errors.New("backups need the SQLite database, back up Postgres with pg_dump")
+
+// backupStepPages is the number of pages copied at a time, letting writers in between
+const backupStepPages = 512
+
+// BackupSQLite copies the database into a new SQLite file at dest with the online backup API, while the
+// server keeps using it. The copy is made on a connection of its own, so it does not hold the one of DB,
+// and it starts over when the database is written by another connection before it is done.
+func BackupSQLite(dest string) error {
+	if DB == nil || DB.Dialect != SQLite {
+		return ErrBackupUnsupported
+	}
+
+	src, err := sql.Open("sqlite3", DB.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstDriver any) error {
+		return srcConn.Raw(func(srcDriver any) error {
+			backup, err := dstDriver.(*sqlite3.SQLiteConn).Backup("main", srcDriver.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+
+			for {
+				remaining := backup.Remaining()
+				done, err := backup.Step(backupStepPages)
+				if err != nil {
+					backup.Close()
+					return err
+				}
+				if done {
+					return backup.Finish()
+				}
+				if backup.Remaining() == remaining {
+					// Busy or locked by a writer
+					time.Sleep(50 * time.Millisecond)
+				}
+			}
+		})
+	})
+}
+
+// Snapshot describes a SQLite database file made by BackupSQLite
+type Snapshot struct {
+	SchemaVersion int
+	Documents     int
+	// FileKeys are the storage keys of the files of the documents
+	FileKeys []string
+}
+
+// ReadSnapshot opens a SQLite database file read-only and lists what a backup has to hold along with it
+func ReadSnapshot(path string) (Snapshot, error) {
+	snapshot := Snapshot{}
+
+	if _, err := os.Stat(path); err != nil {
+		return snapshot, err
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return snapshot, err
+	}
+	defer conn.Close()
+
+	if err := conn.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&snapshot.SchemaVersion); err != nil {
+		return snapshot, fmt.Errorf("not an Inkra database: %w", err)
+	}
+
+	rows, err := conn.Query(`SELECT original_key, signed_key, certificate_key FROM DOCUMENTS`)
+	if err != nil {
+		return snapshot, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var original string
+		var signed, certificate *string
+		if err := rows.Scan(&original, &signed, &certificate); err != nil {
+			return snapshot, err
+		}
+
+		snapshot.Documents++
+		snapshot.FileKeys = append(snapshot.FileKeys, original)
+		for _, key := range []*string{signed, certificate} {
+			if key != nil && *key != "" {
+				snapshot.FileKeys = append(snapshot.FileKeys, *key)
+			}
+		}
+	}
+
+	return snapshot, rows.Err()
+}
+
+// IsEmpty tells whether the database holds no documents and no login yet, so that a backup can be restored
+// into it. A database without tables is empty.
+func IsEmpty() (bool, error) {
+	for _, table := range []string{"DOCUMENTS", "USERS"} {
+		exists, err := tableExists(table)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			continue
+		}
+
+		var count int
+		if err := DB.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// LatestSchemaVersion is the version of the last migration known to this build
+func LatestSchemaVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
@@ -2,76 +2,70 @@ package database
 
 import (
 	"database/sql"
-	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var DB *sql.DB
+var DB *Database
 
-func InitDB() error {
-	var err error
-
-	// If data directory does not exist, create it
-	dataDir := "./data"
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatalf("failed to create data directory: %v", err)
-	}
-
-	dbPath := filepath.Join(dataDir, "app.db")
-
-	DB, err = sql.Open("sqlite3", dbPath)
+// Open connects to the database of url without touching its schema, see Migrate. An empty url is the
+// SQLite file ./data/app.db.
+func Open(url string) error {
+	dialect, dsn, err := parseDatabaseURL(url)
 	if err != nil {
 		return err
 	}
 
-	DB.SetMaxOpenConns(1)
+	switch dialect {
+	case SQLite:
+		// If data directory does not exist, create it
+		if err := os.MkdirAll(filepath.Dir(dsn), 0755); err != nil {
+			log.Fatalf("failed to create data directory: %v", err)
+		}
 
-	query := `
-	CREATE TABLE IF NOT EXISTS SETTINGS (
-	    id INTEGER PRIMARY KEY CHECK (id = 1),
-		login_email TEXT UNIQUE NOT NULL,
-		login_password_hash TEXT NOT NULL
-	);
+		conn, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			return err
+		}
 
-	CREATE TABLE IF NOT EXISTS DOCUMENTS (
-		id TEXT PRIMARY KEY, -- uuid
-		
-		title TEXT NOT NULL,
-		description TEXT NOT NULL ,
-		tags TEXT DEFAULT '[]' NOT NULL ,
-		
-		original_name TEXT NOT NULL,
-		original_path TEXT NOT NULL,
+		// SQLite allows a single writer
+		conn.SetMaxOpenConns(1)
+		DB = &Database{DB: conn, Dialect: SQLite, Path: dsn}
+	case Postgres:
+		conn, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return err
+		}
+		if err := conn.Ping(); err != nil {
+			return err
+		}
 
-		signed_name TEXT UNIQUE,
-		signed_path TEXT,
-		
-		is_signed BOOLEAN NOT NULL DEFAULT 0 CHECK (is_signed IN (0, 1)),
-		signed_at DATETIME,
-		signed_by_metadata TEXT,
-		remarks TEXT,
-		signed_by_ip TEXT,
-		
-		ip_whitelist TEXT DEFAULT '[]' NOT NULL,
-	    
-	    deleted_at DATETIME,
-	    deleted BOOLEAN NOT NULL DEFAULT 0 CHECK (deleted_at IN (0, 1)),
-	    
-	    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
-	    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
-	);
-	`
+		DB = &Database{DB: conn, Dialect: Postgres}
+	}
 
-	_, err = DB.Exec(query)
+	Docs = NewSQLDocuments(DB)
 
+	return nil
+}
+
+// InitDB opens the database and applies the pending migrations
+func InitDB(url string) error {
+	if err := Open(url); err != nil {
+		return err
+	}
+
+	applied, err := MigrateUp(0)
+	for _, m := range applied {
+		log.Printf("Applied migration %04d %s", m.Version, m.Name)
+	}
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
+	DB.fullText, err = prepareSearchIndex()
 	return err
 }
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+var ErrUnsupportedDatabaseURL = errors.New("DATABASE_URL must start with postgres://, postgresql:// or sqlite:")
+
+// Database is the connection pool. Queries are written with ? placeholders, which are rewritten to
+// the numbered form when the dialect needs it, so the same statements run on SQLite and Postgres.
+// Other differences are kept out of the queries: booleans are compared with TRUE and FALSE, and
+// parameters only tested for NULL are cast so Postgres knows their type.
+type Database struct {
+	*sql.DB
+	Dialect Dialect
+	// Path is the file of a SQLite database
+	Path string
+	// fullText tells whether documents are searched through a full text index, which a SQLite built without FTS5
+	// lacks
+	fullText bool
+}
+
+func (d *Database) Exec(query string, args ...any) (sql.Result, error) {
+	return d.DB.Exec(rebind(d.Dialect, query), args...)
+}
+
+func (d *Database) Query(query string, args ...any) (*sql.Rows, error) {
+	return d.DB.Query(rebind(d.Dialect, query), args...)
+}
+
+func (d *Database) QueryRow(query string, args ...any) *sql.Row {
+	return d.DB.QueryRow(rebind(d.Dialect, query), args...)
+}
+
+func (d *Database) Prepare(query string) (*sql.Stmt, error) {
+	return d.DB.Prepare(rebind(d.Dialect, query))
+}
+
+func (d *Database) Begin() (*Tx, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: d.Dialect}, nil
+}
+
+// Tx is a transaction of Database, rewriting placeholders the same way
+type Tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (t *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.Tx.Exec(rebind(t.dialect, query), args...)
+}
+
+func (t *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.Query(rebind(t.dialect, query), args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.Tx.QueryRow(rebind(t.dialect, query), args...)
+}
+
+// rebind turns ? placeholders into $1, $2... for Postgres, leaving quoted text alone
+func rebind(dialect Dialect, query string) string {
+	if dialect != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// parseDatabaseURL tells the dialect of DATABASE_URL and returns what to pass to sql.Open. An empty
+// url is the SQLite database in ./data.
+func parseDatabaseURL(url string) (Dialect, string, error) {
+	switch {
+	case url == "":
+		return SQLite, "./data/app.db", nil
+	case strings.HasPrefix(url, "sqlite:"):
+		return SQLite, strings.TrimPrefix(strings.TrimPrefix(url, "sqlite:"), "//"), nil
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return Postgres, url, nil
+	}
+	return "", "", ErrUnsupportedDatabaseURL
+}
+
+// timestampType is the column type of times in tables created outside migrations
+func timestampType(dialect Dialect) string {
+	if dialect == Postgres {
+		return "TIMESTAMPTZ"
+	}
+	return "DATETIME"
+}
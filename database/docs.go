@@ -1,27 +1,47 @@
 package database
 
-import (
-	"database/sql"
-	"encoding/json"
-	"errors"
-	"fmt"
-)
-
 type Document struct {
-	Id               string   `json:"id"`
-	Title            string   `json:"title"`
-	Description      *string  `json:"description,omitempty"`
-	Tags             []string `json:"tags,omitempty"`
-	OriginalName     string   `json:"originalName"`
-	OriginalPath     string   `json:"originalPath"`
-	SignedName       *string  `json:"signedName,omitempty"`
-	SignedPath       *string  `json:"signedPath,omitempty"`
-	IsSigned         bool     `json:"isSigned"`
-	SignedAt         *string  `json:"signedAt,omitempty"`
-	SignedByMetadata *string  `json:"signedByMetadata,omitempty"`
-	Remarks          *string  `json:"remarks,omitempty"`
-	SignedByIp       *string  `json:"signedByIp,omitempty"`
-	IpWhitelist      []string `json:"ipWhitelist"`
+	Id                 string   `json:"id"`
+	Title              string   `json:"title"`
+	Description        *string  `json:"description,omitempty"`
+	Tags               []string `json:"tags,omitempty"`
+	OriginalName       string   `json:"originalName"`
+	OriginalKey        string   `json:"originalKey"`
+	OriginalHash       *string  `json:"originalHash,omitempty"`
+	SignedName         *string  `json:"signedName,omitempty"`
+	SignedKey          *string  `json:"signedKey,omitempty"`
+	SignedHash         *string  `json:"signedHash,omitempty"`
+	SubmittedHash      *string  `json:"submittedHash,omitempty"`
+	CertificateKey     *string  `json:"certificateKey,omitempty"`
+	CertificateHash    *string  `json:"certificateHash,omitempty"`
+	TimestampToken     *string  `json:"-"`
+	TimestampedAt      *string  `json:"timestampedAt,omitempty"`
+	TimestampAuthority *string  `json:"timestampAuthority,omitempty"`
+	DataKey            *string  `json:"-"`
+	TextStatus         string   `json:"textStatus"`
+	IsSigned           bool     `json:"isSigned"`
+	SignedAt           *string  `json:"signedAt,omitempty"`
+	SignedByMetadata   *string  `json:"signedByMetadata,omitempty"`
+	Remarks            *string  `json:"remarks,omitempty"`
+	SignedByIp         *string  `json:"signedByIp,omitempty"`
+	IpWhitelist        []string `json:"ipWhitelist"`
+	// SigningMode tells whether the signers of the document sign in their order or in any order
+	SigningMode string `json:"signingMode"`
+	// Id of the user who uploaded the document, unset for documents uploaded before users existed
+	CreatedBy *string `json:"createdBy,omitempty"`
+
+	// Signed download urls, set by the handlers that return a single document
+	OriginalUrl string `json:"originalUrl,omitempty"`
+	SignedUrl   string `json:"signedUrl,omitempty"`
+	// CurrentUrl is set until the document is signed, see lib.FileCurrent
+	CurrentUrl string `json:"currentUrl,omitempty"`
+
+	// Set when listing by keyword: how well the document matches, higher is better, and the matching
+	// text as HTML, with the matched words in <mark>
+	SearchRank float64 `json:"searchRank,omitempty"`
+	Snippet    string  `json:"snippet,omitempty"`
+	// Pages of the extracted text matching the keyword, in page order
+	PageHits []PageHit `json:"pageHits,omitempty"`
 
 	DeletedAt *string `json:"deletedAt,omitempty"`
 	Deleted   bool    `json:"deleted"`
@@ -29,67 +49,27 @@ type Document struct {
 	UpdatedAt string  `json:"updatedAt"`
 }
 
-func GetDocByID(id string) (Document, error) {
-	doc := Document{}
-	var tagsJson, ipJson string
-
-	scanErr := DB.QueryRow(`
-		SELECT 
-		    id,
-		    title,
-		    description,
-		    tags,
-		    original_name,
-		    original_path,
-		    signed_name,
-		    signed_path,
-		    is_signed,
-		    signed_at,
-		    signed_by_metadata,
-		    signed_by_ip,
-		    ip_whitelist,
-		    deleted_at,
-		    deleted,
-		    created_at,
-		    updated_at
-		FROM DOCUMENTS
-		WHERE id = ?
-	`, id).Scan(
-		&doc.Id,
-		&doc.Title,
-		&doc.Description,
-		&tagsJson,
-		&doc.OriginalName,
-		&doc.OriginalPath,
-		&doc.SignedName,
-		&doc.SignedPath,
-		&doc.IsSigned,
-		&doc.SignedAt,
-		&doc.SignedByMetadata,
-		&doc.SignedByIp,
-		&ipJson,
-		&doc.DeletedAt,
-		&doc.Deleted,
-		&doc.CreatedAt,
-		&doc.UpdatedAt,
-	)
-
-	if errors.Is(scanErr, sql.ErrNoRows) {
-		fmt.Println("No row found")
-		return doc, scanErr
-	} else if scanErr != nil {
-		fmt.Println(scanErr)
-		return doc, scanErr
-	}
+// States of the text extraction of the original file of a document
+const (
+	TextPending   = "pending"
+	TextExtracted = "extracted"
+	TextFailed    = "failed"
+)
 
-	tagsErr := json.Unmarshal([]byte(tagsJson), &doc.Tags)
-	if tagsErr != nil {
-		return doc, tagsErr
-	}
-	ipErr := json.Unmarshal([]byte(ipJson), &doc.IpWhitelist)
-	if ipErr != nil {
-		return doc, ipErr
-	}
+// Signing modes of a document with several signers
+const (
+	SigningSequential = "sequential"
+	SigningParallel   = "parallel"
+)
 
-	return doc, nil
+// PageHit is a page of the text of a document matching a search, with the matched words in <mark>
+type PageHit struct {
+	Page    int    `json:"page"`
+	Snippet string `json:"snippet"`
 }
+
+const (
+	HashMatchOriginal  = "original"
+	HashMatchSigned    = "signed"
+	HashMatchSubmitted = "submitted"
+)
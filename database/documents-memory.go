@@ -0,0 +1,291 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryDocuments is a DocumentRepository kept in memory, for tests and tools that should not need
+// a database file
+type MemoryDocuments struct {
+	mu    sync.RWMutex
+	docs  map[string]Document
+	pages map[string][]string
+}
+
+// memoryTimeFormat keeps every digit of the fraction of a second so that the times sort as text
+const memoryTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func NewMemoryDocuments() *MemoryDocuments {
+	return &MemoryDocuments{docs: map[string]Document{}, pages: map[string][]string{}}
+}
+
+// copyDocument keeps callers from changing the stored slices
+func copyDocument(doc Document) Document {
+	doc.Tags = append([]string{}, doc.Tags...)
+	doc.IpWhitelist = append([]string{}, doc.IpWhitelist...)
+	return doc
+}
+
+func (m *MemoryDocuments) Get(id string) (Document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	doc, ok := m.docs[id]
+	if !ok {
+		return Document{}, ErrDocNotFound
+	}
+	return copyDocument(doc), nil
+}
+
+func (m *MemoryDocuments) FindByHash(hash string) (Document, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found Document
+	var match string
+	for _, doc := range m.docs {
+		if doc.Deleted || match != "" && doc.CreatedAt <= found.CreatedAt {
+			continue
+		}
+
+		switch {
+		case sameHash(doc.SignedHash, hash):
+			found, match = doc, HashMatchSigned
+		case sameHash(doc.SubmittedHash, hash):
+			found, match = doc, HashMatchSubmitted
+		case sameHash(doc.OriginalHash, hash):
+			found, match = doc, HashMatchOriginal
+		}
+	}
+
+	if match == "" {
+		return Document{}, "", ErrDocNotFound
+	}
+	return copyDocument(found), match, nil
+}
+
+func sameHash(stored *string, hash string) bool {
+	return stored != nil && *stored == hash
+}
+
+func (m *MemoryDocuments) List(filter DocumentFilter) ([]Document, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := searchTerms(filter.Keyword)
+
+	var matched []Document
+	for _, doc := range m.docs {
+		if doc.Deleted && !filter.IncludeDeleted {
+			continue
+		}
+		if filter.Signed != nil && doc.IsSigned != *filter.Signed {
+			continue
+		}
+
+		doc = copyDocument(doc)
+		if len(terms) > 0 {
+			rank := matchTerms(terms, documentText(doc))
+			for i, text := range m.pages[doc.Id] {
+				if pageRank := matchTerms(terms, text); pageRank > 0 {
+					rank = max(rank, pageRank)
+					if len(doc.PageHits) < maxPageHits {
+						doc.PageHits = append(doc.PageHits, PageHit{Page: i + 1})
+					}
+				}
+			}
+			if rank == 0 {
+				continue
+			}
+			doc.SearchRank = float64(rank)
+		}
+		matched = append(matched, doc)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].SearchRank != matched[j].SearchRank {
+			return matched[i].SearchRank > matched[j].SearchRank
+		}
+		return matched[i].CreatedAt > matched[j].CreatedAt
+	})
+
+	total := len(matched)
+	start := min(filter.Offset, total)
+	end := total
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, total)
+	}
+
+	return append([]Document{}, matched[start:end]...), total, nil
+}
+
+func (m *MemoryDocuments) Create(doc *Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC().Format(memoryTimeFormat)
+	doc.CreatedAt, doc.UpdatedAt = now, now
+	doc.Tags, doc.IpWhitelist = nonNil(doc.Tags), nonNil(doc.IpWhitelist)
+	doc.TextStatus = TextPending
+	if doc.SigningMode == "" {
+		doc.SigningMode = SigningSequential
+	}
+
+	m.docs[doc.Id] = copyDocument(*doc)
+	return nil
+}
+
+func (m *MemoryDocuments) Update(doc *Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.docs[doc.Id]
+	if !ok {
+		return ErrDocNotFound
+	}
+
+	doc.UpdatedAt = time.Now().UTC().Format(memoryTimeFormat)
+
+	if stored.OriginalKey != doc.OriginalKey {
+		stored.TextStatus = TextPending
+	}
+	stored.Title = doc.Title
+	stored.Description = doc.Description
+	stored.Tags = nonNil(doc.Tags)
+	stored.OriginalName = doc.OriginalName
+	stored.OriginalKey = doc.OriginalKey
+	stored.OriginalHash = doc.OriginalHash
+	stored.IpWhitelist = nonNil(doc.IpWhitelist)
+	stored.SigningMode = doc.SigningMode
+	stored.UpdatedAt = doc.UpdatedAt
+
+	m.docs[doc.Id] = copyDocument(stored)
+	return nil
+}
+
+func (m *MemoryDocuments) SoftDelete(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, ok := m.docs[id]
+	if !ok || doc.Deleted {
+		return false, nil
+	}
+
+	now := time.Now().UTC().Format(memoryTimeFormat)
+	doc.Deleted, doc.DeletedAt = true, &now
+	m.docs[id] = doc
+	return true, nil
+}
+
+func (m *MemoryDocuments) MarkSigned(id string, signature Signature) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, ok := m.docs[id]
+	if !ok {
+		return ErrDocNotFound
+	}
+
+	signedAt := signature.At.UTC().Format(memoryTimeFormat)
+	doc.IsSigned = true
+	doc.SignedName = &signature.Name
+	doc.SignedKey = &signature.Key
+	doc.SignedHash = &signature.Hash
+	doc.SubmittedHash = &signature.SubmittedHash
+	doc.SignedAt = &signedAt
+	doc.SignedByMetadata = &signature.Metadata
+	doc.Remarks = &signature.Remarks
+	doc.SignedByIp = &signature.IP
+
+	m.docs[id] = doc
+	return nil
+}
+
+func (m *MemoryDocuments) SaveText(id string, originalKey string, pages []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, ok := m.docs[id]
+	if !ok || doc.OriginalKey != originalKey {
+		return nil
+	}
+
+	doc.TextStatus = TextExtracted
+	m.docs[id] = doc
+	m.pages[id] = append([]string{}, pages...)
+	return nil
+}
+
+func (m *MemoryDocuments) MarkTextFailed(id string, originalKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if doc, ok := m.docs[id]; ok && doc.OriginalKey == originalKey {
+		doc.TextStatus = TextFailed
+		m.docs[id] = doc
+	}
+	return nil
+}
+
+func (m *MemoryDocuments) PendingText(limit int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var pending []Document
+	for _, doc := range m.docs {
+		if doc.TextStatus == TextPending && !doc.Deleted {
+			pending = append(pending, doc)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt < pending[j].CreatedAt })
+
+	var ids []string
+	for _, doc := range pending[:min(limit, len(pending))] {
+		ids = append(ids, doc.Id)
+	}
+	return ids, nil
+}
+
+// documentText joins the details of a document indexed for search
+func documentText(doc Document) string {
+	return strings.Join([]string{
+		doc.Title,
+		valueOf(doc.Description),
+		strings.Join(doc.Tags, " "),
+		doc.OriginalName,
+		valueOf(doc.SignedByMetadata),
+		valueOf(doc.Remarks),
+	}, " ")
+}
+
+// matchTerms counts the words of a text starting with one of the terms, or returns 0 when a term matches none
+// of them. It stands in for the full text index, with a plainer rank and no snippets.
+func matchTerms(terms []string, text string) int {
+	words := searchTerms(text)
+
+	count := 0
+	for _, term := range terms {
+		found := false
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				found = true
+				count++
+			}
+		}
+		if !found {
+			return 0
+		}
+	}
+	return count
+}
+
+func valueOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
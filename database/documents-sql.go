@@ -0,0 +1,581 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// SQLDocuments is the DocumentRepository backed by the DOCUMENTS table, on SQLite or Postgres
+type SQLDocuments struct {
+	db *Database
+}
+
+func NewSQLDocuments(db *Database) *SQLDocuments {
+	return &SQLDocuments{db: db}
+}
+
+const documentColumns = `
+	id,
+	title,
+	description,
+	tags,
+	original_name,
+	original_key,
+	original_hash,
+	signed_name,
+	signed_key,
+	signed_hash,
+	submitted_hash,
+	certificate_key,
+	certificate_hash,
+	timestamp_token,
+	timestamped_at,
+	timestamp_authority,
+	data_key,
+	text_status,
+	is_signed,
+	signed_at,
+	signed_by_metadata,
+	remarks,
+	signed_by_ip,
+	ip_whitelist,
+	signing_mode,
+	created_by,
+	deleted_at,
+	deleted,
+	created_at,
+	updated_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanDocument reads the documentColumns of a row, followed by any extra columns into extra
+func scanDocument(row rowScanner, extra ...any) (Document, error) {
+	doc := Document{}
+	var tagsJson, ipJson string
+
+	err := row.Scan(append([]any{
+		&doc.Id,
+		&doc.Title,
+		&doc.Description,
+		&tagsJson,
+		&doc.OriginalName,
+		&doc.OriginalKey,
+		&doc.OriginalHash,
+		&doc.SignedName,
+		&doc.SignedKey,
+		&doc.SignedHash,
+		&doc.SubmittedHash,
+		&doc.CertificateKey,
+		&doc.CertificateHash,
+		&doc.TimestampToken,
+		&doc.TimestampedAt,
+		&doc.TimestampAuthority,
+		&doc.DataKey,
+		&doc.TextStatus,
+		&doc.IsSigned,
+		&doc.SignedAt,
+		&doc.SignedByMetadata,
+		&doc.Remarks,
+		&doc.SignedByIp,
+		&ipJson,
+		&doc.SigningMode,
+		&doc.CreatedBy,
+		&doc.DeletedAt,
+		&doc.Deleted,
+		&doc.CreatedAt,
+		&doc.UpdatedAt,
+	}, extra...)...)
+	if err != nil {
+		return doc, err
+	}
+
+	if err := json.Unmarshal([]byte(tagsJson), &doc.Tags); err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal([]byte(ipJson), &doc.IpWhitelist); err != nil {
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+func (s *SQLDocuments) Get(id string) (Document, error) {
+	doc, err := scanDocument(s.db.QueryRow(`SELECT `+documentColumns+` FROM DOCUMENTS WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return doc, ErrDocNotFound
+	}
+	return doc, err
+}
+
+func (s *SQLDocuments) FindByHash(hash string) (Document, string, error) {
+	var id, match string
+
+	err := s.db.QueryRow(`
+		SELECT id, CASE WHEN signed_hash = ? THEN 'signed' WHEN submitted_hash = ? THEN 'submitted' ELSE 'original' END
+		FROM DOCUMENTS
+		WHERE deleted = FALSE AND (original_hash = ? OR signed_hash = ? OR submitted_hash = ?)
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, hash, hash, hash, hash, hash).Scan(&id, &match)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Document{}, "", ErrDocNotFound
+	}
+	if err != nil {
+		return Document{}, "", err
+	}
+
+	doc, err := s.Get(id)
+	return doc, match, err
+}
+
+func (s *SQLDocuments) List(filter DocumentFilter) ([]Document, int, error) {
+	where := `
+		(? OR deleted = FALSE) AND
+		(CAST(? AS BOOLEAN) IS NULL OR is_signed = ?)`
+	args := []any{filter.IncludeDeleted, filter.Signed, filter.Signed}
+
+	terms := searchTerms(filter.Keyword)
+	search := s.search(terms)
+
+	var total int
+	countErr := s.db.QueryRow(`SELECT COUNT(*) FROM DOCUMENTS`+search.join+` WHERE `+where, append(search.joinArgs, args...)...).Scan(&total)
+	if countErr != nil {
+		return nil, 0, countErr
+	}
+
+	queryArgs := append(append(append(search.columnArgs, search.joinArgs...), args...), filter.Limit, filter.Offset)
+	rows, err := s.db.Query(
+		`SELECT `+documentColumns+search.columns+` FROM DOCUMENTS`+search.join+` WHERE `+where+` ORDER BY `+search.order+` LIMIT ? OFFSET ?`,
+		queryArgs...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	docs := []Document{}
+	for rows.Next() {
+		var rank float64
+		var snippet *string
+		doc, err := scanDocument(rows, &rank, &snippet)
+		if err != nil {
+			return nil, 0, err
+		}
+		if search.join != "" {
+			doc.SearchRank = rank
+			if snippet != nil {
+				doc.Snippet = highlightSnippet(*snippet)
+			}
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if search.join != "" && len(docs) > 0 {
+		if err := s.addPageHits(docs, terms); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return docs, total, nil
+}
+
+// documentSearch holds the parts of the List query that rank and filter documents by keyword
+type documentSearch struct {
+	// columns selects the rank, higher is better, and the snippet of the details of each document, null
+	// when only its text matches
+	columns    string
+	columnArgs []any
+	join       string
+	joinArgs   []any
+	order      string
+}
+
+// search matches the keyword against the details of the documents and the pages of their text, a document
+// taking the best rank of the two
+func (s *SQLDocuments) search(terms []string) documentSearch {
+	if len(terms) == 0 {
+		return documentSearch{columns: `, 0, NULL`, order: `created_at DESC`}
+	}
+
+	query := searchQuery(s.db.Dialect, terms)
+
+	if s.db.Dialect == Postgres {
+		return documentSearch{
+			columns: `,
+				search_match.search_rank,
+				CASE WHEN search @@ to_tsquery('simple', ?) THEN ts_headline('simple', concat_ws(' … ',
+					title,
+					description,
+					(SELECT string_agg(tag, ' ') FROM jsonb_array_elements_text(tags) AS tag),
+					original_name,
+					signed_by_metadata,
+					remarks
+				), to_tsquery('simple', ?), ?) END`,
+			columnArgs: []any{query, query, headlineOptions},
+			join: ` JOIN (
+					SELECT match_id, MAX(match_rank) AS search_rank FROM (
+						SELECT id AS match_id, ts_rank(search, to_tsquery('simple', ?)) AS match_rank
+						FROM DOCUMENTS
+						WHERE search @@ to_tsquery('simple', ?)
+						UNION ALL
+						SELECT document_id, ts_rank(search, to_tsquery('simple', ?))
+						FROM DOCUMENT_PAGES
+						WHERE search @@ to_tsquery('simple', ?)
+					) AS matches
+					GROUP BY match_id
+				) AS search_match ON search_match.match_id = DOCUMENTS.id`,
+			joinArgs: []any{query, query, query, query},
+			order:    `search_match.search_rank DESC, created_at DESC`,
+		}
+	}
+
+	if !s.db.fullText {
+		return likeSearch(terms)
+	}
+
+	// bm25 is lower for better matches and takes a weight per column of DOCUMENTS_SEARCH:
+	// title, description, tags, original_name, signer and remarks
+	return documentSearch{
+		columns: `, search_match.search_rank, search_match.search_snippet`,
+		join: ` JOIN (
+				SELECT match_id, MAX(match_rank) AS search_rank, MAX(match_snippet) AS search_snippet FROM (
+					SELECT
+						DOCUMENTS.id AS match_id,
+						-bm25(DOCUMENTS_SEARCH, 10.0, 4.0, 6.0, 6.0, 3.0, 2.0) AS match_rank,
+						snippet(DOCUMENTS_SEARCH, -1, ?, ?, '…', 16) AS match_snippet
+					FROM DOCUMENTS_SEARCH
+					JOIN DOCUMENTS ON DOCUMENTS.rowid = DOCUMENTS_SEARCH.rowid
+					WHERE DOCUMENTS_SEARCH MATCH ?
+					UNION ALL
+					SELECT DOCUMENT_PAGES.document_id, -bm25(DOCUMENT_PAGES_SEARCH), NULL
+					FROM DOCUMENT_PAGES_SEARCH
+					JOIN DOCUMENT_PAGES ON DOCUMENT_PAGES.rowid = DOCUMENT_PAGES_SEARCH.rowid
+					WHERE DOCUMENT_PAGES_SEARCH MATCH ?
+				)
+				GROUP BY match_id
+			) AS search_match ON search_match.match_id = DOCUMENTS.id`,
+		joinArgs: []any{snippetStart, snippetEnd, query, query},
+		order:    `search_match.search_rank DESC, created_at DESC`,
+	}
+}
+
+// likeSearch matches documents having every term anywhere in their details or text, for SQLite built without FTS5.
+// LIKE only ignores the case of ASCII letters, and the documents are neither ranked nor given snippets.
+func likeSearch(terms []string) documentSearch {
+	conditions := make([]string, len(terms))
+	var args []any
+	for i, term := range terms {
+		conditions[i] = `(
+			title LIKE ? OR description LIKE ? OR tags LIKE ? OR original_name LIKE ? OR
+			signed_by_metadata LIKE ? OR remarks LIKE ? OR
+			id IN (SELECT document_id FROM DOCUMENT_PAGES WHERE text LIKE ?)
+		)`
+		for range 7 {
+			args = append(args, likePattern(term))
+		}
+	}
+
+	return documentSearch{
+		columns: `, 0, NULL`,
+		join: ` JOIN (
+				SELECT id AS match_id FROM DOCUMENTS WHERE ` + strings.Join(conditions, " AND ") + `
+			) AS search_match ON search_match.match_id = DOCUMENTS.id`,
+		joinArgs: args,
+		order:    `created_at DESC`,
+	}
+}
+
+// headlineOptions makes ts_headline mark the matched words like the SQLite snippets
+const headlineOptions = `StartSel="` + snippetStart + `", StopSel="` + snippetEnd + `", MaxWords=24, MinWords=8, MaxFragments=2, FragmentDelimiter=" … "`
+
+// maxPageHits bounds the pages returned per document
+const maxPageHits = 10
+
+// addPageHits sets the pages having every term on each document
+func (s *SQLDocuments) addPageHits(docs []Document, terms []string) error {
+	byId := map[string]*Document{}
+	ids := make([]any, len(docs))
+	for i := range docs {
+		byId[docs[i].Id] = &docs[i]
+		ids[i] = docs[i].Id
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	query := searchQuery(s.db.Dialect, terms)
+	var rows *sql.Rows
+	var err error
+	if s.db.Dialect == Postgres {
+		rows, err = s.db.Query(`
+			SELECT document_id, page, ts_headline('simple', text, to_tsquery('simple', ?), ?)
+			FROM (
+				SELECT document_id, page, text, ROW_NUMBER() OVER (PARTITION BY document_id ORDER BY page) AS hit
+				FROM DOCUMENT_PAGES
+				WHERE search @@ to_tsquery('simple', ?) AND document_id IN (`+in+`)
+			) AS hits
+			WHERE hit <= ?
+			ORDER BY document_id, page`,
+			append(append([]any{query, headlineOptions, query}, ids...), maxPageHits)...,
+		)
+	} else if !s.db.fullText {
+		args := append([]any{}, ids...)
+		for _, term := range terms {
+			args = append(args, likePattern(term))
+		}
+		rows, err = s.db.Query(`
+			SELECT document_id, page, text
+			FROM DOCUMENT_PAGES
+			WHERE document_id IN (`+in+`)`+strings.Repeat(` AND text LIKE ?`, len(terms))+`
+			ORDER BY document_id, page`,
+			args...,
+		)
+	} else {
+		rows, err = s.db.Query(`
+			SELECT DOCUMENT_PAGES.document_id, DOCUMENT_PAGES.page, snippet(DOCUMENT_PAGES_SEARCH, 0, ?, ?, '…', 24)
+			FROM DOCUMENT_PAGES_SEARCH
+			JOIN DOCUMENT_PAGES ON DOCUMENT_PAGES.rowid = DOCUMENT_PAGES_SEARCH.rowid
+			WHERE DOCUMENT_PAGES_SEARCH MATCH ? AND DOCUMENT_PAGES.document_id IN (`+in+`)
+			ORDER BY DOCUMENT_PAGES.document_id, DOCUMENT_PAGES.page`,
+			append([]any{snippetStart, snippetEnd, query}, ids...)...,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, snippet string
+		var page int
+		if err := rows.Scan(&id, &page, &snippet); err != nil {
+			return err
+		}
+		if doc := byId[id]; doc != nil && len(doc.PageHits) < maxPageHits {
+			if s.db.Dialect == SQLite && !s.db.fullText {
+				snippet = likeSnippet(snippet, terms, 24)
+			}
+			doc.PageHits = append(doc.PageHits, PageHit{Page: page, Snippet: highlightSnippet(snippet)})
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *SQLDocuments) Create(doc *Document) error {
+	tags, err := json.Marshal(nonNil(doc.Tags))
+	if err != nil {
+		return err
+	}
+	ipWhitelist, err := json.Marshal(nonNil(doc.IpWhitelist))
+	if err != nil {
+		return err
+	}
+	if doc.SigningMode == "" {
+		doc.SigningMode = SigningSequential
+	}
+
+	now := time.Now()
+	_, err = s.db.Exec(`
+		INSERT INTO DOCUMENTS (
+			id,
+			title,
+			description,
+			tags,
+			original_name,
+			original_key,
+			original_hash,
+			ip_whitelist,
+			signing_mode,
+			data_key,
+			created_by,
+			created_at,
+			updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.Id,
+		doc.Title,
+		doc.Description,
+		string(tags),
+		doc.OriginalName,
+		doc.OriginalKey,
+		doc.OriginalHash,
+		string(ipWhitelist),
+		doc.SigningMode,
+		doc.DataKey,
+		doc.CreatedBy,
+		now,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	doc.CreatedAt = now.Format(time.RFC3339Nano)
+	doc.UpdatedAt = doc.CreatedAt
+	return nil
+}
+
+func (s *SQLDocuments) Update(doc *Document) error {
+	tags, err := json.Marshal(nonNil(doc.Tags))
+	if err != nil {
+		return err
+	}
+	ipWhitelist, err := json.Marshal(nonNil(doc.IpWhitelist))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	res, err := s.db.Exec(`
+		UPDATE DOCUMENTS SET
+			title = ?,
+			description = ?,
+			tags = ?,
+			original_name = ?,
+			original_key = ?,
+			original_hash = ?,
+			ip_whitelist = ?,
+			signing_mode = ?,
+			text_status = CASE WHEN original_key = ? THEN text_status ELSE 'pending' END,
+			updated_at = ?
+		WHERE id = ?`,
+		doc.Title,
+		doc.Description,
+		string(tags),
+		doc.OriginalName,
+		doc.OriginalKey,
+		doc.OriginalHash,
+		string(ipWhitelist),
+		doc.SigningMode,
+		doc.OriginalKey,
+		now,
+		doc.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrDocNotFound
+	}
+
+	doc.UpdatedAt = now.Format(time.RFC3339Nano)
+	return nil
+}
+
+func (s *SQLDocuments) SoftDelete(id string) (bool, error) {
+	res, err := s.db.Exec(`UPDATE DOCUMENTS SET deleted = TRUE, deleted_at = ? WHERE id = ? AND deleted = FALSE`, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *SQLDocuments) MarkSigned(id string, signature Signature) error {
+	res, err := s.db.Exec(`
+		UPDATE DOCUMENTS SET
+			signed_name = ?,
+			signed_key = ?,
+			signed_hash = ?,
+			submitted_hash = ?,
+			is_signed = TRUE,
+			signed_at = ?,
+			signed_by_metadata = ?,
+			remarks = ?,
+			signed_by_ip = ?
+		WHERE id = ?`,
+		signature.Name,
+		signature.Key,
+		signature.Hash,
+		signature.SubmittedHash,
+		signature.At,
+		signature.Metadata,
+		signature.Remarks,
+		signature.IP,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrDocNotFound
+	}
+	return nil
+}
+
+func (s *SQLDocuments) SaveText(id string, originalKey string, pages []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE DOCUMENTS SET text_status = 'extracted' WHERE id = ? AND original_key = ?`, id, originalKey)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM DOCUMENT_PAGES WHERE document_id = ?`, id); err != nil {
+		return err
+	}
+	for i, text := range pages {
+		if _, err := tx.Exec(`INSERT INTO DOCUMENT_PAGES (document_id, page, text) VALUES (?, ?, ?)`, id, i+1, text); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLDocuments) MarkTextFailed(id string, originalKey string) error {
+	_, err := s.db.Exec(`UPDATE DOCUMENTS SET text_status = 'failed' WHERE id = ? AND original_key = ?`, id, originalKey)
+	return err
+}
+
+func (s *SQLDocuments) PendingText(limit int) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT id FROM DOCUMENTS WHERE text_status = 'pending' AND deleted = FALSE ORDER BY created_at LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// nonNil keeps empty lists stored as [] rather than null
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
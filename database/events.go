@@ -0,0 +1,180 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	EventCreated      = "created"
+	EventUpdated      = "updated"
+	EventFileReplaced = "file_replaced"
+	EventViewed       = "viewed"
+	EventIpRejected   = "ip_rejected"
+	EventSigned       = "signed"
+	EventSignRejected = "sign_rejected"
+	EventDeleted      = "deleted"
+
+	EventLinkCreated     = "link_created"
+	EventLinkRevoked     = "link_revoked"
+	EventLinkRegenerated = "link_regenerated"
+
+	EventSignerAdded   = "signer_added"
+	EventSignerRemoved = "signer_removed"
+	// EventCompleted is recorded when the last of the signers of a document signs
+	EventCompleted = "completed"
+
+	EventSealed             = "sealed"
+	EventSealFailed         = "seal_failed"
+	EventTimestamped        = "timestamped"
+	EventTimestampFailed    = "timestamp_failed"
+	EventCertificateCreated = "certificate_created"
+)
+
+type DocEvent struct {
+	Id        int64   `json:"id"`
+	DocId     string  `json:"docId"`
+	Event     string  `json:"event"`
+	Actor     *string `json:"actor,omitempty"`
+	Ip        *string `json:"ip,omitempty"`
+	UserAgent *string `json:"userAgent,omitempty"`
+	Detail    *string `json:"detail,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// eventsMu serializes inserts so every event links to the one written before it
+var eventsMu sync.Mutex
+
+// LogDocEvent appends an entry to the history of a document, chained to the previous event
+// globally and to the previous event of the same document
+func LogDocEvent(docId, event, actor, ip, userAgent, detail string) error {
+	err := insertDocEvent(docId, event, actor, ip, userAgent, detail)
+
+	if err != nil {
+		fmt.Println("Could not log document event", err)
+	}
+
+	return err
+}
+
+func insertDocEvent(docId, event, actor, ip, userAgent, detail string) error {
+	if AuditKey == nil {
+		return ErrAuditKeyMissing
+	}
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
+	// Truncated so the value hashed is exactly the value read back from the database
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// eventsMu only orders the writers of this process, Postgres may be shared by several instances
+	if DB.Dialect == Postgres {
+		if _, err := tx.Exec(`LOCK TABLE DOCUMENT_EVENTS IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+	}
+
+	prevHash, err := lastEventHash(tx, "")
+	if err != nil {
+		return err
+	}
+
+	docPrevHash, err := lastEventHash(tx, docId)
+	if err != nil {
+		return err
+	}
+
+	hash := eventHash(eventContent{
+		DocId:     docId,
+		Event:     event,
+		Actor:     actor,
+		Ip:        ip,
+		UserAgent: userAgent,
+		Detail:    detail,
+		CreatedAt: createdAt,
+	}, prevHash, docPrevHash, true)
+
+	firstKeyedId, err := firstKeyedEvent(tx)
+	if err != nil {
+		return err
+	}
+
+	head := chainHead{Hash: hash, FirstKeyedId: firstKeyedId}
+	err = tx.QueryRow(`
+		INSERT INTO DOCUMENT_EVENTS (doc_id, event, actor, ip, user_agent, detail, created_at, prev_hash, doc_prev_hash, hash, keyed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		docId, event, actor, ip, userAgent, detail, createdAt, prevHash, docPrevHash, hash, true,
+	).Scan(&head.EventId)
+	if err != nil {
+		return err
+	}
+	if head.FirstKeyedId == 0 {
+		head.FirstKeyedId = head.EventId
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// The event is stored even when its head can not be recorded, the next event records it again
+	if err := writeChainHead(head); err != nil {
+		fmt.Println("Could not record audit chain head", err)
+	}
+
+	return nil
+}
+
+// lastEventHash returns the hash of the newest event, of one document when docId is set
+func lastEventHash(tx *Tx, docId string) (string, error) {
+	var hash sql.NullString
+	var err error
+
+	if docId == "" {
+		err = tx.QueryRow(`SELECT hash FROM DOCUMENT_EVENTS ORDER BY id DESC LIMIT 1`).Scan(&hash)
+	} else {
+		err = tx.QueryRow(`SELECT hash FROM DOCUMENT_EVENTS WHERE doc_id = ? ORDER BY id DESC LIMIT 1`, docId).Scan(&hash)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return hash.String, err
+}
+
+// GetDocEvents returns the history of a document, oldest first
+func GetDocEvents(docId string) ([]DocEvent, error) {
+	rows, err := DB.Query(`
+		SELECT id, doc_id, event, actor, ip, user_agent, detail, created_at
+		FROM DOCUMENT_EVENTS
+		WHERE doc_id = ?
+		ORDER BY id ASC
+	`, docId)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []DocEvent{}
+	for rows.Next() {
+		var e DocEvent
+		if err := rows.Scan(&e.Id, &e.DocId, &e.Event, &e.Actor, &e.Ip, &e.UserAgent, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,97 @@
+package database
+
+import "fmt"
+
+// adoptLegacySchema upgrades a database created before versioned migrations to the schema of the
+// first migration. Those releases created the tables with CREATE TABLE IF NOT EXISTS and added
+// columns on start, so a database may be at any of their versions.
+func adoptLegacySchema() error {
+	if err := migrateFilePathsToKeys(); err != nil {
+		return err
+	}
+
+	legacyColumns := [][3]string{
+		{"DOCUMENTS", "original_hash", "TEXT"},
+		{"DOCUMENTS", "signed_hash", "TEXT"},
+		{"DOCUMENTS", "submitted_hash", "TEXT"},
+		{"DOCUMENTS", "certificate_key", "TEXT"},
+		{"DOCUMENTS", "certificate_hash", "TEXT"},
+		{"DOCUMENTS", "timestamp_token", "TEXT"},
+		{"DOCUMENTS", "timestamped_at", "DATETIME"},
+		{"DOCUMENTS", "timestamp_authority", "TEXT"},
+		{"DOCUMENTS", "data_key", "TEXT"},
+	}
+
+	for _, c := range legacyColumns {
+		if err := addColumnIfMissing(c[0], c[1], c[2]); err != nil {
+			return err
+		}
+	}
+
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS DOCUMENT_EVENTS (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		doc_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		actor TEXT,
+		ip TEXT,
+		user_agent TEXT,
+		detail TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+		prev_hash TEXT,
+		doc_prev_hash TEXT,
+		hash TEXT
+	);
+	`)
+	if err != nil {
+		return err
+	}
+
+	eventColumns := []string{"actor", "ip", "user_agent", "prev_hash", "doc_prev_hash", "hash"}
+	for _, column := range eventColumns {
+		if err := addColumnIfMissing("DOCUMENT_EVENTS", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+
+	_, err = DB.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_document_events_doc_id ON DOCUMENT_EVENTS (doc_id);
+	CREATE INDEX IF NOT EXISTS idx_documents_original_hash ON DOCUMENTS (original_hash);
+	CREATE INDEX IF NOT EXISTS idx_documents_signed_hash ON DOCUMENTS (signed_hash);
+	CREATE INDEX IF NOT EXISTS idx_documents_submitted_hash ON DOCUMENTS (submitted_hash);
+	`)
+	if err != nil {
+		return err
+	}
+
+	return chainLegacyEvents()
+}
+
+// migrateFilePathsToKeys renames the *_path columns of databases created before storage backends and
+// turns the stored paths, such as docs/uploads/x.pdf, into object keys relative to the docs directory
+func migrateFilePathsToKeys() error {
+	for _, column := range []string{"original", "signed", "certificate"} {
+		renamed, err := renameColumnIfExists("DOCUMENTS", column+"_path", column+"_key")
+		if err != nil {
+			return err
+		}
+		if !renamed {
+			continue
+		}
+
+		key := column + "_key"
+		_, err = DB.Exec(fmt.Sprintf(`
+			UPDATE DOCUMENTS SET %[1]s = CASE
+				WHEN %[1]s LIKE './docs/%%' THEN substr(%[1]s, 8)
+				WHEN %[1]s LIKE 'docs/%%' THEN substr(%[1]s, 6)
+				ELSE %[1]s
+			END
+			WHERE %[1]s IS NOT NULL
+		`, key))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
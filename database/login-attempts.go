@@ -0,0 +1,169 @@
+package database
+
+import (
+	"time"
+)
+
+// Outcomes of the login attempts
+const (
+	LoginSuccess       = "success"
+	LoginWrongPassword = "wrong_password"
+	LoginWrongCode     = "wrong_code"
+	LoginUnknownEmail  = "unknown_email"
+	LoginLocked        = "locked"
+	LoginDisabled      = "disabled"
+	// LoginCodeRequired is a right password for a user with two-factor authentication, the login goes on with
+	// a code
+	LoginCodeRequired = "code_required"
+)
+
+type LoginAttempt struct {
+	Id        int64   `json:"id"`
+	UserId    *string `json:"userId,omitempty"`
+	Email     string  `json:"email"`
+	Ip        *string `json:"ip,omitempty"`
+	UserAgent *string `json:"userAgent,omitempty"`
+	Outcome   string  `json:"outcome"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// LoginAttemptFilter narrows ListLoginAttempts, empty fields match everything
+type LoginAttemptFilter struct {
+	UserId  string
+	Email   string
+	Ip      string
+	Outcome string
+	// Failed keeps the attempts that did not log in nor get to the second factor
+	Failed bool
+	Since  time.Time
+	// Before is the id of the last attempt of the previous page
+	Before int64
+	Limit  int
+}
+
+const loginAttemptColumns = `id, user_id, email, ip, user_agent, outcome, created_at`
+
+func scanLoginAttempt(row rowScanner) (LoginAttempt, error) {
+	attempt := LoginAttempt{}
+	err := row.Scan(
+		&attempt.Id,
+		&attempt.UserId,
+		&attempt.Email,
+		&attempt.Ip,
+		&attempt.UserAgent,
+		&attempt.Outcome,
+		&attempt.CreatedAt,
+	)
+	return attempt, err
+}
+
+// RecordLoginAttempt logs a login attempt. Attempts older than retention are deleted on the way.
+func RecordLoginAttempt(attempt LoginAttempt, retention time.Duration) error {
+	now := time.Now().UTC()
+	if _, err := DB.Exec(`DELETE FROM LOGIN_ATTEMPTS WHERE created_at < ?`, now.Add(-retention)); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(
+		`INSERT INTO LOGIN_ATTEMPTS (user_id, email, ip, user_agent, outcome, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		attempt.UserId, NormalizeEmail(attempt.Email), attempt.Ip, attempt.UserAgent, attempt.Outcome, now,
+	)
+	return err
+}
+
+// ListLoginAttempts returns the login attempts matching filter, most recent first
+func ListLoginAttempts(filter LoginAttemptFilter) ([]LoginAttempt, error) {
+	query := `SELECT ` + loginAttemptColumns + ` FROM LOGIN_ATTEMPTS WHERE 1 = 1`
+	args := []any{}
+
+	if filter.UserId != "" {
+		query += ` AND user_id = ?`
+		args = append(args, filter.UserId)
+	}
+	if filter.Email != "" {
+		query += ` AND email = ?`
+		args = append(args, NormalizeEmail(filter.Email))
+	}
+	if filter.Ip != "" {
+		query += ` AND ip = ?`
+		args = append(args, filter.Ip)
+	}
+	if filter.Outcome != "" {
+		query += ` AND outcome = ?`
+		args = append(args, filter.Outcome)
+	}
+	if filter.Failed {
+		query += ` AND outcome NOT IN (?, ?)`
+		args = append(args, LoginSuccess, LoginCodeRequired)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Before > 0 {
+		query += ` AND id < ?`
+		args = append(args, filter.Before)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []LoginAttempt{}
+	for rows.Next() {
+		attempt, err := scanLoginAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+// AddLoginFailure counts a failed login of a user and returns its number of failures since the last successful
+// login
+func AddLoginFailure(userId string) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE USERS SET failed_logins = failed_logins + 1 WHERE id = ?`, userId)
+	if err != nil {
+		return 0, err
+	}
+	if err := expectOneRow(res, ErrUserNotFound); err != nil {
+		return 0, err
+	}
+
+	var failures int
+	if err := tx.QueryRow(`SELECT failed_logins FROM USERS WHERE id = ?`, userId).Scan(&failures); err != nil {
+		return 0, err
+	}
+
+	return failures, tx.Commit()
+}
+
+// LockUser refuses the logins of a user until the given time
+func LockUser(userId string, until time.Time) error {
+	res, err := DB.Exec(`UPDATE USERS SET locked_until = ? WHERE id = ?`, until.UTC(), userId)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// UnlockUser forgets the failed logins of a user and ends its lockout
+func UnlockUser(userId string) error {
+	res, err := DB.Exec(`UPDATE USERS SET failed_logins = 0, locked_until = NULL WHERE id = ?`, userId)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
@@ -0,0 +1,385 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each dialect has its own scripts, numbered alike so a version means the same schema on both
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var (
+	ErrMigrationLocked   = errors.New("another process is migrating the database")
+	ErrIrreversible      = errors.New("migration has no down script")
+	ErrSchemaTooNew      = errors.New("database was migrated by a newer version of inkra")
+	errBadMigrationFile  = errors.New("bad migration file name")
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+const (
+	// A lock older than this was left by a process that died while migrating
+	staleMigrationLock = 10 * time.Minute
+	migrationLockWait  = time.Minute
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *string
+}
+
+// loadMigrations reads the migrations embedded in the binary, ordered by version
+func loadMigrations() ([]Migration, error) {
+	dir := path.Join("migrations", string(DB.Dialect))
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", errBadMigrationFile, entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: %s, version %d is also named %s", errBadMigrationFile, entry.Name(), version, m.Name)
+		}
+
+		script, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up script", errBadMigrationFile, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func ensureMigrationTables() error {
+	_, err := DB.Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at %[1]s DEFAULT CURRENT_TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		holder TEXT NOT NULL,
+		locked_at %[1]s NOT NULL
+	);
+	`, timestampType(DB.Dialect)))
+	return err
+}
+
+// lockMigrations takes the migration lock, so processes started together do not apply the same
+// migration twice. The returned func releases it.
+func lockMigrations() (func(), error) {
+	holder := fmt.Sprintf("%s:%d", hostname(), os.Getpid())
+	deadline := time.Now().Add(migrationLockWait)
+
+	for {
+		// A stale lock is taken over
+		_, err := DB.Exec(`DELETE FROM schema_migrations_lock WHERE locked_at < ?`, time.Now().UTC().Add(-staleMigrationLock))
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := DB.Exec(`INSERT INTO schema_migrations_lock (id, holder, locked_at) VALUES (1, ?, ?) ON CONFLICT DO NOTHING`, holder, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+
+		if n, _ := res.RowsAffected(); n == 1 {
+			return func() {
+				if _, err := DB.Exec(`DELETE FROM schema_migrations_lock WHERE holder = ?`, holder); err != nil {
+					fmt.Println("Could not release the migration lock", err)
+				}
+			}, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrMigrationLocked
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
+// appliedMigrations returns the applied versions with their time of application
+func appliedMigrations() (map[int]string, error) {
+	rows, err := DB.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func tableExists(table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if DB.Dialect == Postgres {
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = LOWER(?)`
+	}
+
+	var count int
+	err := DB.QueryRow(query, table).Scan(&count)
+	return count > 0, err
+}
+
+// prepareMigrations creates the bookkeeping tables, takes the lock and records databases created
+// before versioned migrations at the first version. The returned func releases the lock.
+func prepareMigrations() (migrations []Migration, applied map[int]string, unlock func(), err error) {
+	if migrations, err = loadMigrations(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err = ensureMigrationTables(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if unlock, err = lockMigrations(); err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			unlock()
+		}
+	}()
+
+	if applied, err = appliedMigrations(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Only SQLite databases predate migrations
+	if len(applied) == 0 && DB.Dialect == SQLite {
+		legacy, err := tableExists("DOCUMENTS")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if legacy {
+			if err := adoptLegacySchema(); err != nil {
+				return nil, nil, nil, err
+			}
+			if err := recordMigration(migrations[0]); err != nil {
+				return nil, nil, nil, err
+			}
+			fmt.Println("Existing database recorded at schema version", migrations[0].Version)
+
+			if applied, err = appliedMigrations(); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			err = fmt.Errorf("%w: schema version %d, this binary knows up to %d", ErrSchemaTooNew, version, latest)
+			return nil, nil, nil, err
+		}
+	}
+
+	return migrations, applied, unlock, nil
+}
+
+func recordMigration(m Migration) error {
+	_, err := DB.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, m.Version, m.Name, time.Now().UTC())
+	return err
+}
+
+// runMigration runs a script and records the change of version in the same transaction
+func runMigration(m Migration, up bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+
+	if !emptyScript(script) {
+		if _, err := tx.Exec(script); err != nil {
+			if strings.Contains(err.Error(), "no such module: fts5") {
+				return fmt.Errorf("migration %04d %s: %w (build inkra with -tags sqlite_fts5)", m.Version, m.Name, err)
+			}
+			return fmt.Errorf("migration %04d %s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	step := migrationSteps[m.Version]
+	run := step.up
+	if !up {
+		run = step.down
+	}
+	if run != nil {
+		if err := run(tx); err != nil {
+			return fmt.Errorf("migration %04d %s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, m.Version, m.Name, time.Now().UTC())
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// emptyScript tells whether a script only holds comments, for versions with nothing to do in a dialect
+func emptyScript(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// MigrateUp applies up to steps pending migrations, all of them when steps is 0, and returns those applied
+func MigrateUp(steps int) ([]Migration, error) {
+	migrations, applied, unlock, err := prepareMigrations()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+
+		if err := runMigration(m, true); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, the last one when steps is 0, and returns those reverted
+func MigrateDown(steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	migrations, applied, unlock, err := prepareMigrations()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("%w: %04d %s", ErrIrreversible, m.Version, m.Name)
+		}
+
+		if err := runMigration(m, false); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// MigrationsStatus lists the migrations known to the binary and when they were applied
+func MigrationsStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureMigrationTables(); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if appliedAt, ok := applied[m.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
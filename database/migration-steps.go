@@ -0,0 +1,113 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// migrationStep is the part of a migration written in Go, run in its transaction after its script, for changes
+// that SQL can not make alike on both dialects
+type migrationStep struct {
+	up   func(tx *Tx) error
+	down func(tx *Tx) error
+}
+
+var migrationSteps = map[int]migrationStep{
+	3:  {up: searchIndexStep(documentsSearchIndex), down: dropSearchIndexStep(dropDocumentsSearchIndex)},
+	4:  {up: searchIndexStep(pagesSearchIndex), down: dropSearchIndexStep(dropPagesSearchIndex)},
+	15: {up: createLegacySigningLinks, down: deleteLegacySigningLinks},
+}
+
+// legacyLinkRecipient is the recipient of the links created by createLegacySigningLinks
+const legacyLinkRecipient = "Public link from before signing links"
+
+// legacyTokenHash is the hash of the token of a link, as lib.HashToken computes it, which can not be imported here
+func legacyTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createLegacySigningLinks gives the documents that were not signed yet when signing links were added a link
+// whose token is the document id, the one of their public link before, so that the links sent then keep working
+func createLegacySigningLinks(tx *Tx) error {
+	rows, err := tx.Query(`SELECT id FROM DOCUMENTS WHERE is_signed = FALSE AND deleted = FALSE`)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Databases migrated by a development build got these links with a placeholder instead of the hash
+	if _, err := tx.Exec(`DELETE FROM SIGNING_LINKS WHERE token_hash LIKE 'document:%'`); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, id := range ids {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM SIGNING_LINKS WHERE token_hash = ?`, legacyTokenHash(id)).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO SIGNING_LINKS (id, document_id, token_hash, recipient, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), id, legacyTokenHash(id), legacyLinkRecipient, now, now,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteLegacySigningLinks deletes the links created by createLegacySigningLinks that still have the document id
+// as their token
+func deleteLegacySigningLinks(tx *Tx) error {
+	rows, err := tx.Query(`SELECT id, document_id, token_hash FROM SIGNING_LINKS WHERE recipient = ? AND signer_id IS NULL`, legacyLinkRecipient)
+	if err != nil {
+		return err
+	}
+
+	var legacy []string
+	for rows.Next() {
+		var id, documentId, tokenHash string
+		if err := rows.Scan(&id, &documentId, &tokenHash); err != nil {
+			rows.Close()
+			return err
+		}
+		if tokenHash == legacyTokenHash(documentId) {
+			legacy = append(legacy, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range legacy {
+		if _, err := tx.Exec(`DELETE FROM SIGNING_LINKS WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+CREATE TABLE SETTINGS (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    login_email TEXT UNIQUE NOT NULL,
+    login_password_hash TEXT NOT NULL
+);
+
+CREATE TABLE DOCUMENTS (
+    id TEXT PRIMARY KEY, -- uuid
+
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    tags JSONB DEFAULT '[]' NOT NULL,
+
+    original_name TEXT NOT NULL,
+    original_key TEXT NOT NULL,
+    original_hash TEXT,
+
+    signed_name TEXT UNIQUE,
+    signed_key TEXT,
+    signed_hash TEXT,
+    submitted_hash TEXT,
+
+    certificate_key TEXT,
+    certificate_hash TEXT,
+
+    timestamp_token TEXT,
+    timestamped_at TIMESTAMPTZ,
+    timestamp_authority TEXT,
+
+    data_key TEXT,
+
+    is_signed BOOLEAN NOT NULL DEFAULT FALSE,
+    signed_at TIMESTAMPTZ,
+    signed_by_metadata TEXT,
+    remarks TEXT,
+    signed_by_ip TEXT,
+
+    ip_whitelist JSONB DEFAULT '[]' NOT NULL,
+
+    deleted_at TIMESTAMPTZ,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE DOCUMENT_EVENTS (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    doc_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    actor TEXT,
+    ip TEXT,
+    user_agent TEXT,
+    detail TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    prev_hash TEXT,
+    doc_prev_hash TEXT,
+    hash TEXT
+);
+
+CREATE INDEX idx_document_events_doc_id ON DOCUMENT_EVENTS (doc_id);
+CREATE INDEX idx_documents_original_hash ON DOCUMENTS (original_hash);
+CREATE INDEX idx_documents_signed_hash ON DOCUMENTS (signed_hash);
+CREATE INDEX idx_documents_submitted_hash ON DOCUMENTS (submitted_hash);
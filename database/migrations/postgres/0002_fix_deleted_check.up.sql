@@ -0,0 +1 @@
+-- The Postgres schema has a boolean deleted column from the start, nothing to fix
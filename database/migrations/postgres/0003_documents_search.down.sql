@@ -0,0 +1,2 @@
+DROP INDEX idx_documents_search;
+ALTER TABLE DOCUMENTS DROP COLUMN search;
@@ -0,0 +1,13 @@
+-- Full text index of the documents, a weighted tsvector kept up to date by Postgres itself.
+-- The weights follow those given to the SQLite index: title, then tags and file name, then the rest.
+
+ALTER TABLE DOCUMENTS ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(jsonb_to_tsvector('simple', tags, '["string"]'), 'B') ||
+    setweight(to_tsvector('simple', coalesce(original_name, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'C') ||
+    setweight(to_tsvector('simple', coalesce(signed_by_metadata, '')), 'C') ||
+    setweight(to_tsvector('simple', coalesce(remarks, '')), 'D')
+) STORED;
+
+CREATE INDEX idx_documents_search ON DOCUMENTS USING GIN (search);
@@ -0,0 +1,2 @@
+DROP TABLE DOCUMENT_PAGES;
+ALTER TABLE DOCUMENTS DROP COLUMN text_status;
@@ -0,0 +1,15 @@
+-- Text extracted from the original file of each document, one row per page, and its full text index.
+-- text_status tells whether the text of the current original file has been extracted.
+
+ALTER TABLE DOCUMENTS ADD COLUMN text_status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (text_status IN ('pending', 'extracted', 'failed'));
+
+CREATE TABLE DOCUMENT_PAGES (
+    document_id TEXT NOT NULL,
+    page INTEGER NOT NULL, -- from 1
+    text TEXT NOT NULL,
+    search TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', text)) STORED,
+    PRIMARY KEY (document_id, page)
+);
+
+CREATE INDEX idx_document_pages_search ON DOCUMENT_PAGES USING GIN (search);
@@ -0,0 +1,26 @@
+-- Logins move from the single SETTINGS row to USERS, each with a role. The existing login becomes the owner.
+
+CREATE TABLE USERS (
+    id TEXT PRIMARY KEY, -- uuid
+    email TEXT UNIQUE NOT NULL, -- lowercase
+    name TEXT NOT NULL DEFAULT '',
+    password_hash TEXT, -- null until the invite is accepted
+    role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+
+    invite_hash TEXT UNIQUE, -- SHA-256 of the invite token
+    invite_expires_at TIMESTAMPTZ,
+    invited_by TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+INSERT INTO USERS (id, email, password_hash, role)
+SELECT gen_random_uuid()::TEXT, lower(login_email), login_password_hash, 'owner'
+FROM SETTINGS;
+
+DROP TABLE SETTINGS;
+
+-- Id of the user who uploaded the document, null for documents uploaded before users existed
+ALTER TABLE DOCUMENTS ADD COLUMN created_by TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE REVOKED_TOKENS;
+DROP TABLE SESSIONS;
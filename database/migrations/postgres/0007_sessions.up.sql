@@ -0,0 +1,25 @@
+-- Logins, each holding a refresh token that is replaced on every use, and the access tokens revoked before
+-- they expire
+
+CREATE TABLE SESSIONS (
+    id TEXT PRIMARY KEY, -- uuid, the sid claim of its access tokens
+    user_id TEXT NOT NULL,
+    refresh_hash TEXT UNIQUE NOT NULL, -- SHA-256 of the current refresh token
+    previous_refresh_hash TEXT, -- of the one it replaced, using it again revokes the session
+
+    user_agent TEXT,
+    ip TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_sessions_user_id ON SESSIONS (user_id);
+CREATE INDEX idx_sessions_previous_refresh_hash ON SESSIONS (previous_refresh_hash);
+
+CREATE TABLE REVOKED_TOKENS (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL -- the entry is useless once the token has expired
+);
@@ -0,0 +1,7 @@
+DROP TABLE LOGIN_CHALLENGES;
+DROP TABLE RECOVERY_CODES;
+
+ALTER TABLE USERS DROP COLUMN totp_last_step;
+ALTER TABLE USERS DROP COLUMN totp_enabled_at;
+ALTER TABLE USERS DROP COLUMN totp_pending_secret;
+ALTER TABLE USERS DROP COLUMN totp_secret;
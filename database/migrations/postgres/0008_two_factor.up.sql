@@ -0,0 +1,22 @@
+-- Two-factor authentication with TOTP codes, the recovery codes used when the authenticator is lost, and the
+-- challenges given by the password step of a login to be completed with a code
+
+ALTER TABLE USERS ADD COLUMN totp_secret TEXT; -- base32, wrapped by the master key when encryption is on
+ALTER TABLE USERS ADD COLUMN totp_pending_secret TEXT; -- set up but not yet confirmed with a code
+ALTER TABLE USERS ADD COLUMN totp_enabled_at TIMESTAMPTZ;
+ALTER TABLE USERS ADD COLUMN totp_last_step BIGINT DEFAULT 0 NOT NULL; -- time step of the last code used, codes are single use
+
+CREATE TABLE RECOVERY_CODES (
+    user_id TEXT NOT NULL,
+    code_hash TEXT NOT NULL, -- SHA-256 of the code
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+CREATE TABLE LOGIN_CHALLENGES (
+    token_hash TEXT PRIMARY KEY, -- SHA-256 of the challenge token
+    user_id TEXT NOT NULL,
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
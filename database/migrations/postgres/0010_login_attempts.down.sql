@@ -0,0 +1,4 @@
+DROP TABLE LOGIN_ATTEMPTS;
+
+ALTER TABLE USERS DROP COLUMN locked_until;
+ALTER TABLE USERS DROP COLUMN failed_logins;
@@ -0,0 +1,16 @@
+-- Public signing links, one per recipient of a document, given by a random token rather than the document id so
+-- that they can expire, be revoked and be issued again
+
+CREATE TABLE SIGNING_LINKS (
+    id TEXT PRIMARY KEY, -- uuid, used by the admin API
+    document_id TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL, -- SHA-256 of the token of the link
+    recipient TEXT, -- who the link is sent to, a name or an email
+    expires_at TIMESTAMPTZ, -- null for a link that does not expire
+    revoked_at TIMESTAMPTZ,
+    created_by TEXT, -- id of the user who created the link
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_signing_links_document_id ON SIGNING_LINKS (document_id);
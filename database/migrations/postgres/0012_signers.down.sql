@@ -0,0 +1,5 @@
+ALTER TABLE SIGNING_LINKS DROP COLUMN signer_id;
+
+DROP TABLE SIGNERS;
+
+ALTER TABLE DOCUMENTS DROP COLUMN signing_mode;
@@ -0,0 +1,4 @@
+-- Events are hashed with an HMAC under the audit key, kept outside the database. Events hashed before that keep
+-- their plain SHA-256 hash and are covered by the keyed events that follow them.
+
+ALTER TABLE DOCUMENT_EVENTS ADD COLUMN keyed BOOLEAN NOT NULL DEFAULT FALSE;
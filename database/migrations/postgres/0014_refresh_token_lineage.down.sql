@@ -0,0 +1,11 @@
+ALTER TABLE SESSIONS ADD COLUMN previous_refresh_hash TEXT;
+CREATE INDEX idx_sessions_previous_refresh_hash ON SESSIONS (previous_refresh_hash);
+
+UPDATE SESSIONS SET previous_refresh_hash = (
+    SELECT token_hash FROM RETIRED_REFRESH_TOKENS
+    WHERE session_id = SESSIONS.id
+    ORDER BY retired_at DESC
+    LIMIT 1
+);
+
+DROP TABLE RETIRED_REFRESH_TOKENS;
@@ -0,0 +1 @@
+-- The links are deleted by deleteLegacySigningLinks, in database/migration-steps.go, unless they were regenerated
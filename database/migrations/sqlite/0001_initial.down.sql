@@ -0,0 +1,3 @@
+DROP TABLE DOCUMENT_EVENTS;
+DROP TABLE DOCUMENTS;
+DROP TABLE SETTINGS;
@@ -0,0 +1,67 @@
+-- Schema of the last release before versioned migrations. Databases created by that release are
+-- upgraded to it by adoptLegacySchema and recorded at this version without running this file.
+
+CREATE TABLE SETTINGS (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    login_email TEXT UNIQUE NOT NULL,
+    login_password_hash TEXT NOT NULL
+);
+
+CREATE TABLE DOCUMENTS (
+    id TEXT PRIMARY KEY, -- uuid
+
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    tags TEXT DEFAULT '[]' NOT NULL,
+
+    original_name TEXT NOT NULL,
+    original_key TEXT NOT NULL,
+    original_hash TEXT,
+
+    signed_name TEXT UNIQUE,
+    signed_key TEXT,
+    signed_hash TEXT,
+    submitted_hash TEXT,
+
+    certificate_key TEXT,
+    certificate_hash TEXT,
+
+    timestamp_token TEXT,
+    timestamped_at DATETIME,
+    timestamp_authority TEXT,
+
+    data_key TEXT,
+
+    is_signed BOOLEAN NOT NULL DEFAULT 0 CHECK (is_signed IN (0, 1)),
+    signed_at DATETIME,
+    signed_by_metadata TEXT,
+    remarks TEXT,
+    signed_by_ip TEXT,
+
+    ip_whitelist TEXT DEFAULT '[]' NOT NULL,
+
+    deleted_at DATETIME,
+    deleted BOOLEAN NOT NULL DEFAULT 0 CHECK (deleted_at IN (0, 1)),
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE DOCUMENT_EVENTS (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doc_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    actor TEXT,
+    ip TEXT,
+    user_agent TEXT,
+    detail TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    prev_hash TEXT,
+    doc_prev_hash TEXT,
+    hash TEXT
+);
+
+CREATE INDEX idx_document_events_doc_id ON DOCUMENT_EVENTS (doc_id);
+CREATE INDEX idx_documents_original_hash ON DOCUMENTS (original_hash);
+CREATE INDEX idx_documents_signed_hash ON DOCUMENTS (signed_hash);
+CREATE INDEX idx_documents_submitted_hash ON DOCUMENTS (submitted_hash);
@@ -0,0 +1,52 @@
+-- The CHECK of deleted tested deleted_at, which kept deleted_at from ever being set. SQLite cannot alter
+-- a constraint, so the table is rebuilt.
+
+CREATE TABLE DOCUMENTS_new (
+    id TEXT PRIMARY KEY, -- uuid
+
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    tags TEXT DEFAULT '[]' NOT NULL,
+
+    original_name TEXT NOT NULL,
+    original_key TEXT NOT NULL,
+    original_hash TEXT,
+
+    signed_name TEXT UNIQUE,
+    signed_key TEXT,
+    signed_hash TEXT,
+    submitted_hash TEXT,
+
+    certificate_key TEXT,
+    certificate_hash TEXT,
+
+    timestamp_token TEXT,
+    timestamped_at DATETIME,
+    timestamp_authority TEXT,
+
+    data_key TEXT,
+
+    is_signed BOOLEAN NOT NULL DEFAULT 0 CHECK (is_signed IN (0, 1)),
+    signed_at DATETIME,
+    signed_by_metadata TEXT,
+    remarks TEXT,
+    signed_by_ip TEXT,
+
+    ip_whitelist TEXT DEFAULT '[]' NOT NULL,
+
+    deleted_at DATETIME,
+    deleted BOOLEAN NOT NULL DEFAULT 0 CHECK (deleted IN (0, 1)),
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+INSERT INTO DOCUMENTS_new (id, title, description, tags, original_name, original_key, original_hash, signed_name, signed_key, signed_hash, submitted_hash, certificate_key, certificate_hash, timestamp_token, timestamped_at, timestamp_authority, data_key, is_signed, signed_at, signed_by_metadata, remarks, signed_by_ip, ip_whitelist, deleted_at, deleted, created_at, updated_at)
+SELECT id, title, description, tags, original_name, original_key, original_hash, signed_name, signed_key, signed_hash, submitted_hash, certificate_key, certificate_hash, timestamp_token, timestamped_at, timestamp_authority, data_key, is_signed, signed_at, signed_by_metadata, remarks, signed_by_ip, ip_whitelist, deleted_at, deleted, created_at, updated_at FROM DOCUMENTS;
+
+DROP TABLE DOCUMENTS;
+ALTER TABLE DOCUMENTS_new RENAME TO DOCUMENTS;
+
+CREATE INDEX idx_documents_original_hash ON DOCUMENTS (original_hash);
+CREATE INDEX idx_documents_signed_hash ON DOCUMENTS (signed_hash);
+CREATE INDEX idx_documents_submitted_hash ON DOCUMENTS (submitted_hash);
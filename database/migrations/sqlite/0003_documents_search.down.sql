@@ -0,0 +1 @@
+-- The Go step of this migration drops the full text index of the documents
@@ -0,0 +1,2 @@
+-- Full text index of the documents, created by the Go step of this migration (see search-index.go) when SQLite is
+-- built with FTS5, the sqlite_fts5 build tag of go-sqlite3. Without it the documents are searched with LIKE.
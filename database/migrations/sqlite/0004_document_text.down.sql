@@ -0,0 +1,3 @@
+-- Dropping DOCUMENT_PAGES drops the triggers of its full text index, the Go step of this migration the index
+DROP TABLE DOCUMENT_PAGES;
+ALTER TABLE DOCUMENTS DROP COLUMN text_status;
@@ -0,0 +1,13 @@
+-- Text extracted from the original file of each document, one row per page. Its full text index is created by
+-- the Go step of this migration when SQLite has FTS5, see search-index.go.
+-- text_status tells whether the text of the current original file has been extracted.
+
+ALTER TABLE DOCUMENTS ADD COLUMN text_status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (text_status IN ('pending', 'extracted', 'failed'));
+
+CREATE TABLE DOCUMENT_PAGES (
+    document_id TEXT NOT NULL,
+    page INTEGER NOT NULL, -- from 1
+    text TEXT NOT NULL,
+    PRIMARY KEY (document_id, page)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE SETTINGS (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    login_email TEXT UNIQUE NOT NULL,
+    login_password_hash TEXT NOT NULL
+);
+
+-- Only the oldest owner who can log in is kept
+INSERT INTO SETTINGS (id, login_email, login_password_hash)
+SELECT 1, email, password_hash
+FROM USERS
+WHERE role = 'owner' AND disabled = 0 AND password_hash IS NOT NULL
+ORDER BY created_at, id
+LIMIT 1;
+
+DROP TABLE USERS;
+ALTER TABLE DOCUMENTS DROP COLUMN created_by;
@@ -0,0 +1,18 @@
+-- Long-lived keys for scripts, acting as the user who created them within their scope
+
+CREATE TABLE API_KEYS (
+    id TEXT PRIMARY KEY, -- uuid
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL, -- start of the key, to tell keys apart
+    key_hash TEXT UNIQUE NOT NULL, -- SHA-256 of the key
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'upload', 'full')),
+
+    last_used_at DATETIME,
+    last_used_ip TEXT,
+    revoked_at DATETIME,
+
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_api_keys_user_id ON API_KEYS (user_id);
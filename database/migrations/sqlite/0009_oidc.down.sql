@@ -0,0 +1,6 @@
+DROP TABLE OIDC_STATES;
+
+DROP INDEX idx_users_oidc;
+
+ALTER TABLE USERS DROP COLUMN oidc_subject;
+ALTER TABLE USERS DROP COLUMN oidc_issuer;
@@ -0,0 +1,14 @@
+-- Single sign-on with an OpenID Connect provider: users are linked to their subject at the provider, and each
+-- login started with the provider keeps its state and PKCE verifier until the provider sends the user back
+
+ALTER TABLE USERS ADD COLUMN oidc_issuer TEXT;
+ALTER TABLE USERS ADD COLUMN oidc_subject TEXT;
+
+CREATE UNIQUE INDEX idx_users_oidc ON USERS (oidc_issuer, oidc_subject);
+
+CREATE TABLE OIDC_STATES (
+    state_hash TEXT PRIMARY KEY, -- SHA-256 of the state parameter
+    code_verifier TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
@@ -0,0 +1,18 @@
+-- Password login throttling: the consecutive failures of each account, which lock it for a growing time, and
+-- the log of login attempts
+
+ALTER TABLE USERS ADD COLUMN failed_logins INTEGER DEFAULT 0 NOT NULL; -- since the last successful login
+ALTER TABLE USERS ADD COLUMN locked_until DATETIME;
+
+CREATE TABLE LOGIN_ATTEMPTS (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT, -- null when the email matches no user
+    email TEXT NOT NULL, -- as given, normalized
+    ip TEXT,
+    user_agent TEXT,
+    outcome TEXT NOT NULL, -- success, wrong_password, wrong_code, unknown_email, locked, disabled
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_created_at ON LOGIN_ATTEMPTS (created_at);
+CREATE INDEX idx_login_attempts_user_id ON LOGIN_ATTEMPTS (user_id);
@@ -0,0 +1 @@
+DROP TABLE SIGNING_LINKS;
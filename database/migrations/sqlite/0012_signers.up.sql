@@ -0,0 +1,30 @@
+-- Several signers per document, each signing through their own link. In sequential mode they sign in their
+-- order, in parallel mode in any order. Each signer signs the file left by the previous one, so their marks add
+-- up, and the document is signed once every signer has signed.
+
+ALTER TABLE DOCUMENTS ADD COLUMN signing_mode TEXT DEFAULT 'sequential' NOT NULL; -- sequential or parallel
+
+CREATE TABLE SIGNERS (
+    id TEXT PRIMARY KEY, -- uuid
+    document_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    email TEXT,
+    sign_order INTEGER NOT NULL, -- from 1, the order of signing in sequential mode
+    status TEXT DEFAULT 'pending' NOT NULL, -- pending or signed
+    version INTEGER, -- number of signatures on the file once this signer signed, 1 for the first to sign
+    file_key TEXT, -- file submitted by the signer, sealed in place when it completes the document
+    submitted_hash TEXT, -- SHA-256 of the file as submitted
+    metadata TEXT, -- name as typed by the signer
+    remarks TEXT,
+    ip TEXT,
+    user_agent TEXT,
+    signed_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_signers_document_id ON SIGNERS (document_id);
+-- Two signers can not both sign on top of the same version of the file
+CREATE UNIQUE INDEX idx_signers_document_version ON SIGNERS (document_id, version);
+
+ALTER TABLE SIGNING_LINKS ADD COLUMN signer_id TEXT; -- set for the link of a signer
@@ -0,0 +1 @@
+ALTER TABLE DOCUMENT_EVENTS DROP COLUMN keyed;
@@ -0,0 +1,16 @@
+-- Every refresh token a session has replaced, not only the last one, so that using any of them again revokes the
+-- session
+
+CREATE TABLE RETIRED_REFRESH_TOKENS (
+    token_hash TEXT PRIMARY KEY, -- SHA-256 of the refresh token
+    session_id TEXT NOT NULL,
+    retired_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_retired_refresh_tokens_session_id ON RETIRED_REFRESH_TOKENS (session_id);
+
+INSERT INTO RETIRED_REFRESH_TOKENS (token_hash, session_id, retired_at)
+SELECT previous_refresh_hash, id, last_used_at FROM SESSIONS WHERE previous_refresh_hash IS NOT NULL;
+
+DROP INDEX idx_sessions_previous_refresh_hash;
+ALTER TABLE SESSIONS DROP COLUMN previous_refresh_hash;
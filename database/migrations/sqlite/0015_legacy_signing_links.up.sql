@@ -0,0 +1,3 @@
+-- Documents that were not signed yet keep the public link they had before signing links, whose token was the
+-- document id. The links are created with the hash of that token by createLegacySigningLinks, in
+-- database/migration-steps.go, as SQLite can not hash.
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrOIDCStateNotFound is returned for a single sign-on login that is unknown, expired or already completed
+var ErrOIDCStateNotFound = errors.New("single sign-on login not found")
+
+// GetUserByOIDC returns the user linked to a subject of the single sign-on provider
+func GetUserByOIDC(issuer string, subject string) (User, error) {
+	return scanUser(DB.QueryRow(`SELECT `+userColumns+` FROM USERS WHERE oidc_issuer = ? AND oidc_subject = ?`, issuer, subject))
+}
+
+// LinkOIDC links a user, not yet linked, to its subject at the single sign-on provider. A pending invite is
+// ended, the user logs in with the provider from then on.
+func LinkOIDC(id string, issuer string, subject string) error {
+	res, err := DB.Exec(`
+		UPDATE USERS SET oidc_issuer = ?, oidc_subject = ?, invite_hash = NULL, invite_expires_at = NULL, updated_at = ?
+		WHERE id = ? AND oidc_subject IS NULL`,
+		issuer, subject, time.Now(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// CreateOIDCState stores a login started with the provider, given by the hash of its state parameter, along
+// with its PKCE verifier and nonce. Expired states are deleted on the way.
+func CreateOIDCState(stateHash string, codeVerifier string, nonce string, expiresAt time.Time) error {
+	if _, err := DB.Exec(`DELETE FROM OIDC_STATES WHERE expires_at <= ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(
+		`INSERT INTO OIDC_STATES (state_hash, code_verifier, nonce, expires_at) VALUES (?, ?, ?, ?)`,
+		stateHash, codeVerifier, nonce, expiresAt.UTC(),
+	)
+	return err
+}
+
+// TakeOIDCState returns the PKCE verifier and the nonce of a login that has not expired, and deletes it so that
+// it is only completed once
+func TakeOIDCState(stateHash string) (string, string, error) {
+	var codeVerifier, nonce string
+	err := DB.QueryRow(
+		`SELECT code_verifier, nonce FROM OIDC_STATES WHERE state_hash = ? AND expires_at > ?`,
+		stateHash, time.Now().UTC(),
+	).Scan(&codeVerifier, &nonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrOIDCStateNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	res, err := DB.Exec(`DELETE FROM OIDC_STATES WHERE state_hash = ?`, stateHash)
+	if err != nil {
+		return "", "", err
+	}
+	if err := expectOneRow(res, ErrOIDCStateNotFound); err != nil {
+		return "", "", err
+	}
+
+	return codeVerifier, nonce, nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrDocNotFound = errors.New("document not found")
+
+// DocumentRepository stores documents. Docs holds the one used by the handlers, the SQL
+// implementation unless it is replaced, for instance by NewMemoryDocuments in tests.
+type DocumentRepository interface {
+	Get(id string) (Document, error)
+	// List returns a page of documents, newest first or best match first when given a keyword, along with
+	// the number of documents matching the filter
+	List(filter DocumentFilter) ([]Document, int, error)
+	// Create stores a new document, setting its creation and update times
+	Create(doc *Document) error
+	// Update saves the editable details and the original file of a document, a new original file making
+	// its text pending
+	Update(doc *Document) error
+	// FindByHash returns the newest document, deleted ones excepted, whose original, signed or submitted file
+	// has the given SHA-256 hash, and which of them matched: HashMatchOriginal, HashMatchSigned or
+	// HashMatchSubmitted. The submitted file is the one uploaded by the signer, before the server seal was applied.
+	FindByHash(hash string) (Document, string, error)
+	// SoftDelete marks a document deleted and tells whether it was not already
+	SoftDelete(id string) (bool, error)
+	// MarkSigned records the signed file and the signer of a document
+	MarkSigned(id string, signature Signature) error
+	// SaveText replaces the extracted text of a document, one entry per page, and marks it extracted. Nothing
+	// is saved when the original file is no longer originalKey, as the text is then stale.
+	SaveText(id string, originalKey string, pages []string) error
+	// MarkTextFailed records that the text of the original file originalKey could not be extracted
+	MarkTextFailed(id string, originalKey string) error
+	// PendingText returns the ids of up to limit documents, deleted ones excepted, whose text is to be extracted
+	PendingText(limit int) ([]string, error)
+}
+
+var Docs DocumentRepository
+
+var (
+	_ DocumentRepository = (*SQLDocuments)(nil)
+	_ DocumentRepository = (*MemoryDocuments)(nil)
+)
+
+type DocumentFilter struct {
+	// Keyword keeps the documents having every word of it, or a word starting with it, in their title,
+	// description, tags, original file name, signer details and remarks, or in a page of their text
+	Keyword string
+	// Signed keeps only signed or unsigned documents when set
+	Signed         *bool
+	IncludeDeleted bool
+	Limit          int
+	Offset         int
+}
+
+// Signature is what MarkSigned stores
+type Signature struct {
+	Name          string
+	Key           string
+	Hash          string
+	SubmittedHash string
+	Metadata      string
+	Remarks       string
+	IP            string
+	At            time.Time
+}
@@ -0,0 +1,279 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// openTestDB migrates a new SQLite database in a temporary directory and makes it DB
+func openTestDB(t *testing.T) *Database {
+	t.Helper()
+
+	if err := InitDB("sqlite:" + filepath.Join(t.TempDir(), "app.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DB.Close() })
+	return DB
+}
+
+// forEachRepository runs a test against the in-memory repository and the SQL one on a new SQLite database, which
+// has to behave alike
+func forEachRepository(t *testing.T, test func(t *testing.T, docs DocumentRepository)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryDocuments())
+	})
+	t.Run("sql", func(t *testing.T) {
+		test(t, NewSQLDocuments(openTestDB(t)))
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// createDocs stores the documents in order, so that the last one is the newest
+func createDocs(t *testing.T, docs DocumentRepository, fixtures ...Document) {
+	t.Helper()
+
+	for _, doc := range fixtures {
+		// Uploads always have a description, which the SQL schema requires
+		if doc.Description == nil {
+			doc.Description = ptr("")
+		}
+		if err := docs.Create(&doc); err != nil {
+			t.Fatal(err)
+		}
+		// Creation times order the documents, keep them apart on clocks of a coarse resolution
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func docIds(docs []Document) []string {
+	ids := []string{}
+	for _, doc := range docs {
+		ids = append(ids, doc.Id)
+	}
+	return ids
+}
+
+func TestDocumentCreateAndGet(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, docs DocumentRepository) {
+		createDocs(t, docs, Document{
+			Id:           "lease",
+			Title:        "Lease agreement",
+			Description:  ptr("Flat on the second floor"),
+			Tags:         []string{"rental", "2026"},
+			OriginalName: "lease.pdf",
+			OriginalKey:  "uploads/lease.pdf",
+			OriginalHash: ptr("aa11"),
+		})
+
+		doc, err := docs.Get("lease")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Title != "Lease agreement" || *doc.Description != "Flat on the second floor" || doc.OriginalKey != "uploads/lease.pdf" {
+			t.Errorf("got %+v", doc)
+		}
+		if !slices.Equal(doc.Tags, []string{"rental", "2026"}) {
+			t.Errorf("tags = %v", doc.Tags)
+		}
+		if doc.IpWhitelist == nil || len(doc.IpWhitelist) != 0 {
+			t.Errorf("ip whitelist = %#v, want empty", doc.IpWhitelist)
+		}
+		if doc.SigningMode != SigningSequential || doc.TextStatus != TextPending || doc.IsSigned || doc.Deleted {
+			t.Errorf("defaults = %q %q signed %v deleted %v", doc.SigningMode, doc.TextStatus, doc.IsSigned, doc.Deleted)
+		}
+
+		if _, err := docs.Get("missing"); !errors.Is(err, ErrDocNotFound) {
+			t.Errorf("get missing = %v, want ErrDocNotFound", err)
+		}
+	})
+}
+
+func TestDocumentList(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, docs DocumentRepository) {
+		createDocs(t, docs,
+			Document{Id: "lease", Title: "Lease agreement", Tags: []string{"rental"}, OriginalName: "lease.pdf", OriginalKey: "uploads/lease.pdf"},
+			Document{Id: "invoice", Title: "Invoice March", OriginalName: "invoice.pdf", OriginalKey: "uploads/invoice.pdf"},
+			Document{Id: "old", Title: "Old lease", OriginalName: "old.pdf", OriginalKey: "uploads/old.pdf"},
+			Document{Id: "manual", Title: "Manual", Description: ptr("Washing machine"), OriginalName: "manual.pdf", OriginalKey: "uploads/manual.pdf"},
+		)
+		if err := docs.MarkSigned("invoice", Signature{Name: "Ada Lovelace", Metadata: "Ada Lovelace, Analytical Engines", Key: "signed/invoice.pdf", Hash: "bb22", At: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := docs.SoftDelete("old"); err != nil {
+			t.Fatal(err)
+		}
+		if err := docs.SaveText("manual", "uploads/manual.pdf", []string{"Contents", "Warranty of two years", "Warranty claims"}); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			filter DocumentFilter
+			want   []string
+			total  int
+		}{
+			{"newest first", DocumentFilter{}, []string{"manual", "invoice", "lease"}, 3},
+			{"with deleted", DocumentFilter{IncludeDeleted: true}, []string{"manual", "old", "invoice", "lease"}, 4},
+			{"signed", DocumentFilter{Signed: ptr(true)}, []string{"invoice"}, 1},
+			{"unsigned", DocumentFilter{Signed: ptr(false)}, []string{"manual", "lease"}, 2},
+			{"page", DocumentFilter{Limit: 2, Offset: 1}, []string{"invoice", "lease"}, 3},
+			{"past the end", DocumentFilter{Limit: 2, Offset: 3}, []string{}, 3},
+			{"title", DocumentFilter{Keyword: "lease"}, []string{"lease"}, 1},
+			{"deleted", DocumentFilter{Keyword: "old"}, []string{}, 0},
+			{"deleted match", DocumentFilter{Keyword: "old", IncludeDeleted: true}, []string{"old"}, 1},
+			{"tag prefix", DocumentFilter{Keyword: "rent"}, []string{"lease"}, 1},
+			{"every word", DocumentFilter{Keyword: "Invoice, MARCH"}, []string{"invoice"}, 1},
+			{"not every word", DocumentFilter{Keyword: "invoice april"}, []string{}, 0},
+			{"signer", DocumentFilter{Keyword: "analytic"}, []string{"invoice"}, 1},
+			{"description", DocumentFilter{Keyword: "washing"}, []string{"manual"}, 1},
+			{"page text", DocumentFilter{Keyword: "warranty"}, []string{"manual"}, 1},
+			{"keyword and signed", DocumentFilter{Keyword: "invoice", Signed: ptr(false)}, []string{}, 0},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if tt.filter.Limit == 0 {
+					tt.filter.Limit = 10
+				}
+				got, total, err := docs.List(tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ids := docIds(got); !slices.Equal(ids, tt.want) {
+					t.Errorf("ids = %v, want %v", ids, tt.want)
+				}
+				if total != tt.total {
+					t.Errorf("total = %d, want %d", total, tt.total)
+				}
+			})
+		}
+
+		got, _, err := docs.List(DocumentFilter{Keyword: "warranty", Limit: 10})
+		if err != nil || len(got) != 1 {
+			t.Fatalf("got %d documents, %v", len(got), err)
+		}
+		var pages []int
+		for _, hit := range got[0].PageHits {
+			pages = append(pages, hit.Page)
+		}
+		if !slices.Equal(pages, []int{2, 3}) {
+			t.Errorf("page hits = %v, want [2 3]", pages)
+		}
+	})
+}
+
+func TestDocumentFindByHash(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, docs DocumentRepository) {
+		createDocs(t, docs,
+			Document{Id: "signed", Title: "Signed", OriginalName: "a.pdf", OriginalKey: "uploads/a.pdf", OriginalHash: ptr("a1")},
+			Document{Id: "deleted", Title: "Deleted", OriginalName: "b.pdf", OriginalKey: "uploads/b.pdf", OriginalHash: ptr("b1")},
+			Document{Id: "reupload", Title: "Uploaded again", OriginalName: "a.pdf", OriginalKey: "uploads/c.pdf", OriginalHash: ptr("a1")},
+		)
+		if err := docs.MarkSigned("signed", Signature{Key: "signed/a.pdf", Hash: "a3", SubmittedHash: "a2", At: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := docs.SoftDelete("deleted"); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name  string
+			hash  string
+			id    string
+			match string
+		}{
+			{"signed file", "a3", "signed", HashMatchSigned},
+			{"submitted file", "a2", "signed", HashMatchSubmitted},
+			{"newest original", "a1", "reupload", HashMatchOriginal},
+			{"deleted document", "b1", "", ""},
+			{"unknown", "ff", "", ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				doc, match, err := docs.FindByHash(tt.hash)
+				if tt.id == "" {
+					if !errors.Is(err, ErrDocNotFound) {
+						t.Errorf("err = %v, want ErrDocNotFound", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if doc.Id != tt.id || match != tt.match {
+					t.Errorf("got %s %s, want %s %s", doc.Id, match, tt.id, tt.match)
+				}
+			})
+		}
+	})
+}
+
+func TestDocumentUpdateAndDelete(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, docs DocumentRepository) {
+		createDocs(t, docs, Document{Id: "lease", Title: "Lease", OriginalName: "lease.pdf", OriginalKey: "uploads/v1.pdf"})
+
+		if err := docs.SaveText("lease", "uploads/v1.pdf", []string{"First version"}); err != nil {
+			t.Fatal(err)
+		}
+		doc, _ := docs.Get("lease")
+		if doc.TextStatus != TextExtracted {
+			t.Fatalf("text status = %q after saving the text", doc.TextStatus)
+		}
+
+		doc.Title = "Lease 2026"
+		doc.OriginalKey = "uploads/v2.pdf"
+		doc.SigningMode = SigningParallel
+		if err := docs.Update(&doc); err != nil {
+			t.Fatal(err)
+		}
+		doc, _ = docs.Get("lease")
+		if doc.Title != "Lease 2026" || doc.SigningMode != SigningParallel || doc.TextStatus != TextPending {
+			t.Errorf("after update %q %q %q", doc.Title, doc.SigningMode, doc.TextStatus)
+		}
+
+		// The text of the file replaced above is stale
+		if err := docs.SaveText("lease", "uploads/v1.pdf", []string{"First version"}); err != nil {
+			t.Fatal(err)
+		}
+		if pending, err := docs.PendingText(10); err != nil || !slices.Equal(pending, []string{"lease"}) {
+			t.Errorf("pending text = %v %v, want [lease]", pending, err)
+		}
+		if err := docs.MarkTextFailed("lease", "uploads/v2.pdf"); err != nil {
+			t.Fatal(err)
+		}
+		if doc, _ = docs.Get("lease"); doc.TextStatus != TextFailed {
+			t.Errorf("text status = %q, want failed", doc.TextStatus)
+		}
+
+		if err := docs.Update(&Document{Id: "missing"}); !errors.Is(err, ErrDocNotFound) {
+			t.Errorf("update missing = %v, want ErrDocNotFound", err)
+		}
+		if err := docs.MarkSigned("missing", Signature{At: time.Now()}); !errors.Is(err, ErrDocNotFound) {
+			t.Errorf("mark missing signed = %v, want ErrDocNotFound", err)
+		}
+
+		for i, want := range []bool{true, false} {
+			deleted, err := docs.SoftDelete("lease")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != want {
+				t.Errorf("delete %d = %v, want %v", i+1, deleted, want)
+			}
+		}
+		doc, err := docs.Get("lease")
+		if err != nil || !doc.Deleted || doc.DeletedAt == nil {
+			t.Errorf("deleted document = %+v, %v", doc, err)
+		}
+		if pending, _ := docs.PendingText(10); len(pending) != 0 {
+			t.Errorf("pending text of deleted documents = %v", pending)
+		}
+	})
+}
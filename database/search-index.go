@@ -0,0 +1,191 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrSearchIndexUnreadable is returned on start when a SQLite database has a full text index that this build of
+// SQLite can not read, as every change of a document goes through it
+var ErrSearchIndexUnreadable = errors.New("database has a full text search index but inkra was built without the sqlite_fts5 tag")
+
+// documentsSearchIndex is the full text index of the documents on SQLite. Rows share the rowid of their document
+// and are kept in sync by the triggers, so a migration that rebuilds DOCUMENTS has to rebuild this index as well.
+const documentsSearchIndex = `
+CREATE VIRTUAL TABLE DOCUMENTS_SEARCH USING fts5(
+    title,
+    description,
+    tags,
+    original_name,
+    signer,
+    remarks,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER documents_search_insert AFTER INSERT ON DOCUMENTS BEGIN
+    INSERT INTO DOCUMENTS_SEARCH (rowid, title, description, tags, original_name, signer, remarks)
+    VALUES (
+        new.rowid,
+        new.title,
+        new.description,
+        (SELECT group_concat(value, ' ') FROM json_each(new.tags)),
+        new.original_name,
+        new.signed_by_metadata,
+        new.remarks
+    );
+END;
+
+CREATE TRIGGER documents_search_update
+AFTER UPDATE OF title, description, tags, original_name, signed_by_metadata, remarks ON DOCUMENTS BEGIN
+    DELETE FROM DOCUMENTS_SEARCH WHERE rowid = old.rowid;
+    INSERT INTO DOCUMENTS_SEARCH (rowid, title, description, tags, original_name, signer, remarks)
+    VALUES (
+        new.rowid,
+        new.title,
+        new.description,
+        (SELECT group_concat(value, ' ') FROM json_each(new.tags)),
+        new.original_name,
+        new.signed_by_metadata,
+        new.remarks
+    );
+END;
+
+CREATE TRIGGER documents_search_delete AFTER DELETE ON DOCUMENTS BEGIN
+    DELETE FROM DOCUMENTS_SEARCH WHERE rowid = old.rowid;
+END;
+
+INSERT INTO DOCUMENTS_SEARCH (rowid, title, description, tags, original_name, signer, remarks)
+SELECT
+    rowid,
+    title,
+    description,
+    (SELECT group_concat(value, ' ') FROM json_each(DOCUMENTS.tags)),
+    original_name,
+    signed_by_metadata,
+    remarks
+FROM DOCUMENTS;
+`
+
+const dropDocumentsSearchIndex = `
+DROP TRIGGER IF EXISTS documents_search_delete;
+DROP TRIGGER IF EXISTS documents_search_update;
+DROP TRIGGER IF EXISTS documents_search_insert;
+DROP TABLE IF EXISTS DOCUMENTS_SEARCH;
+`
+
+// pagesSearchIndex is the full text index of the text of the pages on SQLite, reading the text from DOCUMENT_PAGES
+const pagesSearchIndex = `
+CREATE VIRTUAL TABLE DOCUMENT_PAGES_SEARCH USING fts5(
+    text,
+    content = 'DOCUMENT_PAGES',
+    content_rowid = 'rowid',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER document_pages_search_insert AFTER INSERT ON DOCUMENT_PAGES BEGIN
+    INSERT INTO DOCUMENT_PAGES_SEARCH (rowid, text) VALUES (new.rowid, new.text);
+END;
+
+CREATE TRIGGER document_pages_search_update AFTER UPDATE OF text ON DOCUMENT_PAGES BEGIN
+    INSERT INTO DOCUMENT_PAGES_SEARCH (DOCUMENT_PAGES_SEARCH, rowid, text) VALUES ('delete', old.rowid, old.text);
+    INSERT INTO DOCUMENT_PAGES_SEARCH (rowid, text) VALUES (new.rowid, new.text);
+END;
+
+CREATE TRIGGER document_pages_search_delete AFTER DELETE ON DOCUMENT_PAGES BEGIN
+    INSERT INTO DOCUMENT_PAGES_SEARCH (DOCUMENT_PAGES_SEARCH, rowid, text) VALUES ('delete', old.rowid, old.text);
+END;
+
+INSERT INTO DOCUMENT_PAGES_SEARCH (DOCUMENT_PAGES_SEARCH) VALUES ('rebuild');
+`
+
+const dropPagesSearchIndex = `
+DROP TRIGGER IF EXISTS document_pages_search_delete;
+DROP TRIGGER IF EXISTS document_pages_search_update;
+DROP TRIGGER IF EXISTS document_pages_search_insert;
+DROP TABLE IF EXISTS DOCUMENT_PAGES_SEARCH;
+`
+
+// fts5Available tells whether SQLite was built with FTS5, which go-sqlite3 only does with the sqlite_fts5 tag
+func fts5Available(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}) (bool, error) {
+	var used int
+	err := q.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used)
+	return used == 1, err
+}
+
+// searchIndexStep creates an index in a migration when SQLite has FTS5. Without it the documents are searched with
+// LIKE instead, and the index is created on the first start of a build that has it, see prepareSearchIndex.
+func searchIndexStep(index string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		if tx.dialect != SQLite {
+			return nil
+		}
+		available, err := fts5Available(tx)
+		if err != nil || !available {
+			return err
+		}
+		_, err = tx.Exec(index)
+		return err
+	}
+}
+
+// dropSearchIndexStep drops an index in the migration reverting searchIndexStep
+func dropSearchIndexStep(drop string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		if tx.dialect != SQLite {
+			return nil
+		}
+		_, err := tx.Exec(drop)
+		return err
+	}
+}
+
+// prepareSearchIndex creates the full text indexes of a migrated SQLite database that lacks them when FTS5 is
+// available, and tells whether documents are searched through them
+func prepareSearchIndex() (bool, error) {
+	if DB.Dialect != SQLite {
+		return true, nil
+	}
+
+	available, err := fts5Available(DB)
+	if err != nil {
+		return false, err
+	}
+	exists, err := tableExists("DOCUMENTS_SEARCH")
+	if err != nil {
+		return false, err
+	}
+
+	if !available {
+		if exists {
+			return false, fmt.Errorf("%w, rebuild it with -tags sqlite_fts5", ErrSearchIndexUnreadable)
+		}
+		log.Println("SQLite was built without FTS5 (the sqlite_fts5 build tag), documents are searched without ranking")
+		return false, nil
+	}
+	if exists {
+		return true, nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(documentsSearchIndex); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(pagesSearchIndex); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	log.Println("Created the full text search index")
+	return true, nil
+}
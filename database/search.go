@@ -0,0 +1,111 @@
+package database
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Markers put around the matched words of a snippet by the database, turned into <mark> by highlightSnippet
+const (
+	snippetStart = "\x02"
+	snippetEnd   = "\x03"
+)
+
+// searchTerms splits a keyword into lower case words, dropping punctuation, so that it can be given to the
+// full text index without being read as its query syntax
+func searchTerms(keyword string) []string {
+	return strings.FieldsFunc(strings.ToLower(keyword), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// searchQuery builds the full text query matching documents having every term, each one as a word prefix
+func searchQuery(dialect Dialect, terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		if dialect == Postgres {
+			parts[i] = term + ":*"
+		} else {
+			parts[i] = `"` + term + `"*`
+		}
+	}
+
+	if dialect == Postgres {
+		return strings.Join(parts, " & ")
+	}
+	return strings.Join(parts, " ")
+}
+
+// likePattern matches text containing a term, which holds no LIKE wildcard as searchTerms drops punctuation
+func likePattern(term string) string {
+	return "%" + term + "%"
+}
+
+// likeSnippet cuts about words words of text around the first one containing a term and marks those that contain
+// one, like the snippets of the full text index, for searches made without it
+func likeSnippet(text string, terms []string, words int) string {
+	matches := func(word string) bool {
+		word = strings.ToLower(word)
+		for _, term := range terms {
+			if strings.Contains(word, term) {
+				return true
+			}
+		}
+		return false
+	}
+
+	fields := strings.Fields(text)
+	first := 0
+	for i, word := range fields {
+		if matches(word) {
+			first = i
+			break
+		}
+	}
+	start := max(first-words/4, 0)
+	end := min(start+words, len(fields))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i, word := range fields[start:end] {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		if matches(word) {
+			word = snippetStart + word + snippetEnd
+		}
+		b.WriteString(word)
+	}
+	if end < len(fields) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// highlightSnippet escapes a snippet for HTML and wraps its matched words in <mark>
+func highlightSnippet(snippet string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(snippet, snippetStart)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(snippet[start:], snippetEnd)
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(html.EscapeString(snippet[:start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(snippet[start+len(snippetStart) : end]))
+		b.WriteString("</mark>")
+		snippet = snippet[end+len(snippetEnd):]
+	}
+
+	b.WriteString(html.EscapeString(strings.ReplaceAll(strings.ReplaceAll(snippet, snippetStart, ""), snippetEnd, "")))
+	return b.String()
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrRefreshReused is returned for a refresh token that was already exchanged, its session is then revoked
+	// as the token has likely been stolen
+	ErrRefreshReused = errors.New("refresh token reused")
+)
+
+type Session struct {
+	Id         string  `json:"id"`
+	UserId     string  `json:"userId"`
+	UserAgent  *string `json:"userAgent,omitempty"`
+	Ip         *string `json:"ip,omitempty"`
+	CreatedAt  string  `json:"createdAt"`
+	LastUsedAt string  `json:"lastUsedAt"`
+	ExpiresAt  string  `json:"expiresAt"`
+	RevokedAt  *string `json:"revokedAt,omitempty"`
+}
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row rowScanner) (Session, error) {
+	session := Session{}
+	err := row.Scan(
+		&session.Id,
+		&session.UserId,
+		&session.UserAgent,
+		&session.Ip,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// CreateSession stores a new session with the hash of its first refresh token. The retired refresh tokens of
+// expired sessions are dropped on the way.
+func CreateSession(session *Session, refreshHash string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	if _, err := DB.Exec(
+		`DELETE FROM RETIRED_REFRESH_TOKENS WHERE session_id IN (SELECT id FROM SESSIONS WHERE expires_at <= ?)`,
+		now,
+	); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(`
+		INSERT INTO SESSIONS (id, user_id, refresh_hash, user_agent, ip, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.Id, session.UserId, refreshHash, session.UserAgent, session.Ip, now, now, expiresAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	session.CreatedAt = now.Format(time.RFC3339Nano)
+	session.LastUsedAt = session.CreatedAt
+	session.ExpiresAt = expiresAt.UTC().Format(time.RFC3339Nano)
+	return nil
+}
+
+// RotateSession exchanges a refresh token, given by its hash, for a new one and extends the session to
+// expiresAt. Every refresh token the session replaced is remembered: presenting any of them again revokes
+// the session and returns ErrRefreshReused.
+func RotateSession(refreshHash string, newRefreshHash string, ip string, userAgent string, expiresAt time.Time) (Session, error) {
+	now := time.Now().UTC()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return Session{}, err
+	}
+	defer tx.Rollback()
+
+	session, err := scanSession(tx.QueryRow(
+		`SELECT `+sessionColumns+` FROM SESSIONS WHERE refresh_hash = ? AND revoked_at IS NULL AND expires_at > ?`,
+		refreshHash, now,
+	))
+
+	if errors.Is(err, ErrSessionNotFound) {
+		res, err := tx.Exec(`
+			UPDATE SESSIONS SET revoked_at = ?
+			WHERE id = (SELECT session_id FROM RETIRED_REFRESH_TOKENS WHERE token_hash = ?) AND revoked_at IS NULL`,
+			now, refreshHash,
+		)
+		if err != nil {
+			return session, err
+		}
+		if err := expectOneRow(res, ErrSessionNotFound); err != nil {
+			return session, err
+		}
+		if err := tx.Commit(); err != nil {
+			return session, err
+		}
+		return session, ErrRefreshReused
+	}
+	if err != nil {
+		return session, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE SESSIONS SET refresh_hash = ?, ip = ?, user_agent = ?, last_used_at = ?, expires_at = ?
+		WHERE id = ?`,
+		newRefreshHash, ip, userAgent, now, expiresAt.UTC(), session.Id,
+	)
+	if err != nil {
+		return session, err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO RETIRED_REFRESH_TOKENS (token_hash, session_id, retired_at) VALUES (?, ?, ?)`,
+		refreshHash, session.Id, now,
+	)
+	if err != nil {
+		return session, err
+	}
+
+	session.Ip, session.UserAgent = &ip, &userAgent
+	session.LastUsedAt = now.Format(time.RFC3339Nano)
+	session.ExpiresAt = expiresAt.UTC().Format(time.RFC3339Nano)
+	return session, tx.Commit()
+}
+
+// IsSessionActive tells whether a session exists and is neither revoked nor expired
+func IsSessionActive(id string) (bool, error) {
+	var count int
+	err := DB.QueryRow(
+		`SELECT COUNT(*) FROM SESSIONS WHERE id = ? AND revoked_at IS NULL AND expires_at > ?`,
+		id, time.Now().UTC(),
+	).Scan(&count)
+	return count > 0, err
+}
+
+// ListSessions returns the active sessions of a user, most recently used first
+func ListSessions(userId string) ([]Session, error) {
+	rows, err := DB.Query(
+		`SELECT `+sessionColumns+` FROM SESSIONS WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_used_at DESC, id`,
+		userId, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends a session of a user
+func RevokeSession(userId string, id string) error {
+	res, err := DB.Exec(
+		`UPDATE SESSIONS SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), id, userId,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrSessionNotFound)
+}
+
+// RevokeUserSessions ends all sessions of a user and returns how many there were
+func RevokeUserSessions(userId string) (int64, error) {
+	res, err := DB.Exec(
+		`UPDATE SESSIONS SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), userId,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RevokeToken adds the jti of an access token to the revocation list until the token expires. The entries of
+// expired tokens are dropped on the way.
+func RevokeToken(jti string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	if _, err := DB.Exec(`DELETE FROM REVOKED_TOKENS WHERE expires_at <= ?`, now); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(
+		`INSERT INTO REVOKED_TOKENS (jti, expires_at) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		jti, expiresAt.UTC(),
+	)
+	return err
+}
+
+// IsTokenRevoked tells whether the jti of an access token is on the revocation list
+func IsTokenRevoked(jti string) (bool, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM REVOKED_TOKENS WHERE jti = ?`, jti).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSignerNotFound = errors.New("signer not found")
+	// ErrDocumentChanged is returned when another signer signed the document since the given version
+	ErrDocumentChanged = errors.New("document was signed by another signer since")
+)
+
+// States of a signer
+const (
+	SignerPending = "pending"
+	SignerSigned  = "signed"
+)
+
+// Signer is one of the people who must sign a document, through their own signing link
+type Signer struct {
+	Id         string  `json:"id"`
+	DocumentId string  `json:"documentId"`
+	Name       string  `json:"name"`
+	Email      *string `json:"email,omitempty"`
+	Order      int     `json:"order"`
+	Status     string  `json:"status"`
+	// Version is the number of signatures on the file once this signer signed, 1 for the first to sign
+	Version       *int    `json:"version,omitempty"`
+	FileKey       *string `json:"-"`
+	SubmittedHash *string `json:"submittedHash,omitempty"`
+	Metadata      *string `json:"metadata,omitempty"`
+	Remarks       *string `json:"remarks,omitempty"`
+	Ip            *string `json:"ip,omitempty"`
+	UserAgent     *string `json:"userAgent,omitempty"`
+	SignedAt      *string `json:"signedAt,omitempty"`
+	CreatedAt     string  `json:"createdAt"`
+	UpdatedAt     string  `json:"updatedAt"`
+}
+
+// SignerSignature is what SignSigner stores
+type SignerSignature struct {
+	FileKey       string
+	SubmittedHash string
+	Metadata      string
+	Remarks       string
+	IP            string
+	UserAgent     string
+	At            time.Time
+}
+
+const signerColumns = `id, document_id, name, email, sign_order, status, version, file_key, submitted_hash, metadata,
+	remarks, ip, user_agent, signed_at, created_at, updated_at`
+
+func scanSigner(row rowScanner) (Signer, error) {
+	signer := Signer{}
+	err := row.Scan(
+		&signer.Id,
+		&signer.DocumentId,
+		&signer.Name,
+		&signer.Email,
+		&signer.Order,
+		&signer.Status,
+		&signer.Version,
+		&signer.FileKey,
+		&signer.SubmittedHash,
+		&signer.Metadata,
+		&signer.Remarks,
+		&signer.Ip,
+		&signer.UserAgent,
+		&signer.SignedAt,
+		&signer.CreatedAt,
+		&signer.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return signer, ErrSignerNotFound
+	}
+	return signer, err
+}
+
+// CreateSigner adds a pending signer to a document, after its other signers, and its signing link with the hash
+// of its token. The link is filled in with its recipient and times.
+func CreateSigner(signer *Signer, link *SigningLink, tokenHash string, expiresAt *time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var last int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(sign_order), 0) FROM SIGNERS WHERE document_id = ?`, signer.DocumentId).Scan(&last); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	signer.Order = last + 1
+	signer.Status = SignerPending
+
+	if _, err := tx.Exec(`
+		INSERT INTO SIGNERS (id, document_id, name, email, sign_order, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		signer.Id, signer.DocumentId, signer.Name, signer.Email, signer.Order, signer.Status, now, now,
+	); err != nil {
+		return err
+	}
+
+	link.DocumentId, link.SignerId = signer.DocumentId, &signer.Id
+	if _, err := tx.Exec(`
+		INSERT INTO SIGNING_LINKS (id, document_id, signer_id, token_hash, recipient, expires_at, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		link.Id, link.DocumentId, link.SignerId, tokenHash, link.Recipient, utcOrNil(expiresAt), link.CreatedBy, now, now,
+	); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	signer.CreatedAt = now.Format(time.RFC3339Nano)
+	signer.UpdatedAt = signer.CreatedAt
+	link.ExpiresAt = formatOrNil(expiresAt)
+	link.CreatedAt, link.UpdatedAt = signer.CreatedAt, signer.CreatedAt
+	return nil
+}
+
+// GetSigner returns a signer of a document
+func GetSigner(documentId string, id string) (Signer, error) {
+	return scanSigner(DB.QueryRow(`SELECT `+signerColumns+` FROM SIGNERS WHERE id = ? AND document_id = ?`, id, documentId))
+}
+
+// ListSigners returns the signers of a document in their order
+func ListSigners(documentId string) ([]Signer, error) {
+	rows, err := DB.Query(`SELECT `+signerColumns+` FROM SIGNERS WHERE document_id = ? ORDER BY sign_order`, documentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	signers := []Signer{}
+	for rows.Next() {
+		signer, err := scanSigner(rows)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	return signers, rows.Err()
+}
+
+// SignSigner records the signature of a pending signer on top of the given version of the file of its
+// document, and tells whether every signer of the document has now signed. Returns ErrDocumentChanged when
+// another signature came first, the signer then has to sign the newer version.
+func SignSigner(documentId string, id string, version int, signature SignerSignature) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Signatures of a document are taken one at a time, so that the check of the version below and the count of
+	// pending signers see the signatures committed before. SQLite has a single writer already.
+	if DB.Dialect == Postgres {
+		if _, err := tx.Exec(`SELECT id FROM DOCUMENTS WHERE id = ? FOR UPDATE`, documentId); err != nil {
+			return false, err
+		}
+	}
+
+	res, err := tx.Exec(`
+		UPDATE SIGNERS SET
+			status = ?,
+			version = ?,
+			file_key = ?,
+			submitted_hash = ?,
+			metadata = ?,
+			remarks = ?,
+			ip = ?,
+			user_agent = ?,
+			signed_at = ?,
+			updated_at = ?
+		WHERE id = ? AND document_id = ? AND status = ?
+			AND (SELECT COALESCE(MAX(version), 0) FROM SIGNERS WHERE document_id = ?) = ?`,
+		SignerSigned,
+		version+1,
+		signature.FileKey,
+		signature.SubmittedHash,
+		signature.Metadata,
+		signature.Remarks,
+		signature.IP,
+		signature.UserAgent,
+		signature.At.UTC(),
+		time.Now().UTC(),
+		id,
+		documentId,
+		SignerPending,
+		documentId,
+		version,
+	)
+	if err != nil {
+		return false, err
+	}
+	if err := expectOneRow(res, ErrDocumentChanged); err != nil {
+		return false, err
+	}
+
+	var pending int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM SIGNERS WHERE document_id = ? AND status = ?`, documentId, SignerPending).Scan(&pending); err != nil {
+		return false, err
+	}
+
+	return pending == 0, tx.Commit()
+}
+
+// DeleteSigner removes a pending signer of a document and revokes its links. A signer who signed is kept, as the
+// file holds their marks.
+func DeleteSigner(documentId string, id string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM SIGNERS WHERE id = ? AND document_id = ? AND status = ?`, id, documentId, SignerPending)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(res, ErrSignerNotFound); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(
+		`UPDATE SIGNING_LINKS SET revoked_at = COALESCE(revoked_at, ?), updated_at = ? WHERE signer_id = ?`,
+		now, now, id,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// createSigners adds signers with the given ids to a document, in order
+func createSigners(t *testing.T, documentId string, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		signer := Signer{Id: id, DocumentId: documentId, Name: "Signer " + id}
+		link := SigningLink{Id: "link-" + id, Recipient: &signer.Name}
+		if err := CreateSigner(&signer, &link, "hash-"+id, nil); err != nil {
+			t.Fatal(err)
+		}
+		if signer.Status != SignerPending || *link.SignerId != id || link.DocumentId != documentId {
+			t.Fatalf("created signer %+v with link %+v", signer, link)
+		}
+	}
+}
+
+func TestSignerFlow(t *testing.T) {
+	docs := NewSQLDocuments(openTestDB(t))
+	createDocs(t, docs, Document{Id: "contract", Title: "Contract", OriginalName: "contract.pdf", OriginalKey: "uploads/contract.pdf"})
+	createSigners(t, "contract", "a", "b", "c")
+
+	signers, err := ListSigners("contract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, signer := range signers {
+		if signer.Order != i+1 || signer.Status != SignerPending || signer.Version != nil {
+			t.Errorf("signer %d = %+v", i, signer)
+		}
+	}
+
+	steps := []struct {
+		name     string
+		signer   string
+		version  int
+		err      error
+		complete bool
+	}{
+		{"first signature", "a", 0, nil, false},
+		{"on a stale version", "b", 0, ErrDocumentChanged, false},
+		{"again", "a", 1, ErrDocumentChanged, false},
+		{"on a version not signed yet", "b", 2, ErrDocumentChanged, false},
+		{"second signature", "b", 1, nil, false},
+		{"unknown signer", "z", 2, ErrDocumentChanged, false},
+		{"last signature", "c", 2, nil, true},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			complete, err := SignSigner("contract", step.signer, step.version, SignerSignature{
+				FileKey:       "signed/" + step.signer + ".pdf",
+				SubmittedHash: "submitted-" + step.signer,
+				IP:            "127.0.0.1",
+				At:            time.Now(),
+			})
+			if !errors.Is(err, step.err) {
+				t.Fatalf("err = %v, want %v", err, step.err)
+			}
+			if complete != step.complete {
+				t.Errorf("complete = %v, want %v", complete, step.complete)
+			}
+		})
+	}
+
+	signers, err = ListSigners("contract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, signer := range signers {
+		if signer.Status != SignerSigned || signer.Version == nil || *signer.Version != i+1 {
+			t.Errorf("signer %s signed %q at version %v", signer.Id, signer.Status, signer.Version)
+		}
+		if signer.FileKey == nil || *signer.FileKey != "signed/"+signer.Id+".pdf" || signer.SignedAt == nil {
+			t.Errorf("signature of %s = %+v", signer.Id, signer)
+		}
+	}
+}
+
+func TestDeleteSigner(t *testing.T) {
+	docs := NewSQLDocuments(openTestDB(t))
+	createDocs(t, docs,
+		Document{Id: "contract", Title: "Contract", OriginalName: "contract.pdf", OriginalKey: "uploads/contract.pdf"},
+		Document{Id: "other", Title: "Other", OriginalName: "other.pdf", OriginalKey: "uploads/other.pdf"},
+	)
+	createSigners(t, "contract", "a", "b")
+	if _, err := SignSigner("contract", "a", 0, SignerSignature{FileKey: "signed/a.pdf", At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		signer   string
+		err      error
+	}{
+		{"of another document", "other", "b", ErrSignerNotFound},
+		{"who signed", "contract", "a", ErrSignerNotFound},
+		{"pending", "contract", "b", nil},
+		{"twice", "contract", "b", ErrSignerNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := DeleteSigner(tt.document, tt.signer); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := GetSigner("contract", "b"); !errors.Is(err, ErrSignerNotFound) {
+		t.Errorf("deleted signer = %v, want ErrSignerNotFound", err)
+	}
+	if _, err := GetSigningLinkByToken("hash-b"); !errors.Is(err, ErrSigningLinkNotFound) {
+		t.Errorf("link of the deleted signer = %v, want ErrSigningLinkNotFound", err)
+	}
+	if _, err := GetSigningLinkByToken("hash-a"); err != nil {
+		t.Errorf("link of the signer who signed = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrSigningLinkNotFound = errors.New("signing link not found")
+
+// SigningLink gives a recipient access to view and sign a document. The token of the link is only known to
+// whoever it was sent to, the link is looked up by its hash.
+type SigningLink struct {
+	Id         string `json:"id"`
+	DocumentId string `json:"documentId"`
+	// SignerId is set for the link of a signer, see Signer
+	SignerId  *string `json:"signerId,omitempty"`
+	Recipient *string `json:"recipient,omitempty"`
+	ExpiresAt *string `json:"expiresAt,omitempty"`
+	RevokedAt *string `json:"revokedAt,omitempty"`
+	CreatedBy *string `json:"createdBy,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+const signingLinkColumns = `id, document_id, signer_id, recipient, expires_at, revoked_at, created_by, created_at, updated_at`
+
+func scanSigningLink(row rowScanner) (SigningLink, error) {
+	link := SigningLink{}
+	err := row.Scan(
+		&link.Id,
+		&link.DocumentId,
+		&link.SignerId,
+		&link.Recipient,
+		&link.ExpiresAt,
+		&link.RevokedAt,
+		&link.CreatedBy,
+		&link.CreatedAt,
+		&link.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return link, ErrSigningLinkNotFound
+	}
+	return link, err
+}
+
+// CreateSigningLink stores a new link of a document with the hash of its token
+func CreateSigningLink(link *SigningLink, tokenHash string, expiresAt *time.Time) error {
+	now := time.Now().UTC()
+	_, err := DB.Exec(`
+		INSERT INTO SIGNING_LINKS (id, document_id, token_hash, recipient, expires_at, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		link.Id, link.DocumentId, tokenHash, link.Recipient, utcOrNil(expiresAt), link.CreatedBy, now, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	link.ExpiresAt = formatOrNil(expiresAt)
+	link.CreatedAt = now.Format(time.RFC3339Nano)
+	link.UpdatedAt = link.CreatedAt
+	return nil
+}
+
+// GetSigningLinkByToken returns the link of a token, given by its hash, unless it is revoked or expired
+func GetSigningLinkByToken(tokenHash string) (SigningLink, error) {
+	return scanSigningLink(DB.QueryRow(
+		`SELECT `+signingLinkColumns+` FROM SIGNING_LINKS
+		WHERE token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+		tokenHash, time.Now().UTC(),
+	))
+}
+
+// GetActiveSigningLink returns a link of a document unless it is revoked or expired
+func GetActiveSigningLink(documentId string, id string) (SigningLink, error) {
+	return scanSigningLink(DB.QueryRow(
+		`SELECT `+signingLinkColumns+` FROM SIGNING_LINKS
+		WHERE id = ? AND document_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+		id, documentId, time.Now().UTC(),
+	))
+}
+
+// GetSigningLink returns a link of a document, revoked and expired ones included
+func GetSigningLink(documentId string, id string) (SigningLink, error) {
+	return scanSigningLink(DB.QueryRow(
+		`SELECT `+signingLinkColumns+` FROM SIGNING_LINKS WHERE id = ? AND document_id = ?`,
+		id, documentId,
+	))
+}
+
+// ListSigningLinks returns the links of a document, revoked and expired ones included, oldest first
+func ListSigningLinks(documentId string) ([]SigningLink, error) {
+	rows, err := DB.Query(
+		`SELECT `+signingLinkColumns+` FROM SIGNING_LINKS WHERE document_id = ? ORDER BY created_at, id`,
+		documentId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []SigningLink{}
+	for rows.Next() {
+		link, err := scanSigningLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// RevokeSigningLink revokes a link of a document, a link revoked already is left as is
+func RevokeSigningLink(documentId string, id string) error {
+	now := time.Now().UTC()
+	res, err := DB.Exec(
+		`UPDATE SIGNING_LINKS SET revoked_at = COALESCE(revoked_at, ?), updated_at = ? WHERE id = ? AND document_id = ?`,
+		now, now, id, documentId,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrSigningLinkNotFound)
+}
+
+// RegenerateSigningLink gives a link of a document a new token and expiry, the previous token stops working. A
+// revoked link is made active again.
+func RegenerateSigningLink(documentId string, id string, tokenHash string, expiresAt *time.Time) error {
+	res, err := DB.Exec(
+		`UPDATE SIGNING_LINKS SET token_hash = ?, expires_at = ?, revoked_at = NULL, updated_at = ? WHERE id = ? AND document_id = ?`,
+		tokenHash, utcOrNil(expiresAt), time.Now().UTC(), id, documentId,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrSigningLinkNotFound)
+}
+
+func utcOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func formatOrNil(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.UTC().Format(time.RFC3339Nano)
+	return &formatted
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrChallengeNotFound is returned for a login challenge that is unknown, expired or out of attempts
+var ErrChallengeNotFound = errors.New("login challenge not found")
+
+// SetPendingTOTP stores a secret being set up, it only protects logins once confirmed with EnableTOTP
+func SetPendingTOTP(userId string, secret string) error {
+	res, err := DB.Exec(`UPDATE USERS SET totp_pending_secret = ?, updated_at = ? WHERE id = ?`, secret, time.Now(), userId)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// EnableTOTP makes the pending secret of a user the one asked for at login, step being the time step of the
+// code that confirmed it, and replaces the recovery codes of the user
+func EnableTOTP(userId string, step int64, recoveryHashes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	res, err := tx.Exec(`
+		UPDATE USERS SET totp_secret = totp_pending_secret, totp_pending_secret = NULL, totp_enabled_at = ?,
+			totp_last_step = ?, updated_at = ?
+		WHERE id = ? AND totp_pending_secret IS NOT NULL`,
+		now, step, now, userId,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(res, ErrUserNotFound); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userId, recoveryHashes, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTOTP turns two-factor authentication off for a user and deletes its recovery codes
+func DisableTOTP(userId string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE USERS SET totp_secret = NULL, totp_pending_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0,
+			updated_at = ?
+		WHERE id = ?`,
+		time.Now(), userId,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(res, ErrUserNotFound); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM RECOVERY_CODES WHERE user_id = ?`, userId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM LOGIN_CHALLENGES WHERE user_id = ?`, userId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateTOTPSecret replaces the stored secret of a user, when it is wrapped again by a new master key
+func UpdateTOTPSecret(userId string, secret string) error {
+	res, err := DB.Exec(`UPDATE USERS SET totp_secret = ? WHERE id = ? AND totp_secret IS NOT NULL`, secret, userId)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// UseTOTPStep records that the code of a time step was used. It returns false when a code of this step or of
+// a later one was already used, so that the code must be refused.
+func UseTOTPStep(userId string, step int64) (bool, error) {
+	res, err := DB.Exec(`UPDATE USERS SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`, step, userId, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReplaceRecoveryCodes makes the codes, given by their hashes, the only recovery codes of a user
+func ReplaceRecoveryCodes(userId string, recoveryHashes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userId, recoveryHashes, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *Tx, userId string, recoveryHashes []string, now time.Time) error {
+	if _, err := tx.Exec(`DELETE FROM RECOVERY_CODES WHERE user_id = ?`, userId); err != nil {
+		return err
+	}
+
+	for _, hash := range recoveryHashes {
+		_, err := tx.Exec(`INSERT INTO RECOVERY_CODES (user_id, code_hash, created_at) VALUES (?, ?, ?)`, userId, hash, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks a recovery code, given by its hash, as used. It returns false for a code the user does
+// not have or already used.
+func UseRecoveryCode(userId string, codeHash string) (bool, error) {
+	res, err := DB.Exec(
+		`UPDATE RECOVERY_CODES SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now().UTC(), userId, codeHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// CountRecoveryCodes is the number of recovery codes a user has not used yet
+func CountRecoveryCodes(userId string) (int, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM RECOVERY_CODES WHERE user_id = ? AND used_at IS NULL`, userId).Scan(&count)
+	return count, err
+}
+
+// CreateLoginChallenge stores the challenge, given by the hash of its token, that a user completes with a code
+// after the password. Expired challenges are deleted on the way.
+func CreateLoginChallenge(tokenHash string, userId string, expiresAt time.Time) error {
+	if _, err := DB.Exec(`DELETE FROM LOGIN_CHALLENGES WHERE expires_at <= ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(
+		`INSERT INTO LOGIN_CHALLENGES (token_hash, user_id, expires_at) VALUES (?, ?, ?)`,
+		tokenHash, userId, expiresAt.UTC(),
+	)
+	return err
+}
+
+// UseChallengeAttempt counts an attempt at a challenge and returns its user. It fails with ErrChallengeNotFound
+// once the challenge has expired or had maxAttempts attempts.
+func UseChallengeAttempt(tokenHash string, maxAttempts int) (string, error) {
+	res, err := DB.Exec(
+		`UPDATE LOGIN_CHALLENGES SET attempts = attempts + 1 WHERE token_hash = ? AND expires_at > ? AND attempts < ?`,
+		tokenHash, time.Now().UTC(), maxAttempts,
+	)
+	if err != nil {
+		return "", err
+	}
+	if err := expectOneRow(res, ErrChallengeNotFound); err != nil {
+		return "", err
+	}
+
+	var userId string
+	err = DB.QueryRow(`SELECT user_id FROM LOGIN_CHALLENGES WHERE token_hash = ?`, tokenHash).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrChallengeNotFound
+	}
+	return userId, err
+}
+
+// DeleteLoginChallenge ends a challenge once completed. It fails with ErrChallengeNotFound when the challenge
+// was already completed, by a concurrent request.
+func DeleteLoginChallenge(tokenHash string) error {
+	res, err := DB.Exec(`DELETE FROM LOGIN_CHALLENGES WHERE token_hash = ?`, tokenHash)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrChallengeNotFound)
+}
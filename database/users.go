@@ -0,0 +1,293 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Roles of the users. Owners manage the users, editors manage the documents and viewers only read them.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("a user with this email already exists")
+	ErrLastOwner    = errors.New("there must be at least one active owner")
+)
+
+type User struct {
+	Id           string  `json:"id"`
+	Email        string  `json:"email"`
+	Name         string  `json:"name"`
+	Role         string  `json:"role"`
+	Disabled     bool    `json:"disabled"`
+	PasswordHash *string `json:"-"`
+	// InvitePending tells that the user has not accepted the invite yet, so has no password, nor logged in
+	// with single sign-on
+	InvitePending   bool    `json:"invitePending"`
+	InviteExpiresAt *string `json:"inviteExpiresAt,omitempty"`
+	InvitedBy       *string `json:"invitedBy,omitempty"`
+	// OIDCSubject is the id of the user at the single sign-on provider, set on its first login there
+	OIDCSubject *string `json:"oidcSubject,omitempty"`
+	OIDCIssuer  *string `json:"-"`
+	// TwoFactorEnabled tells that logging in also takes a code of an authenticator app
+	TwoFactorEnabled  bool    `json:"twoFactorEnabled"`
+	TOTPSecret        *string `json:"-"`
+	TOTPPendingSecret *string `json:"-"`
+	TOTPLastStep      int64   `json:"-"`
+	// FailedLogins counts the failed logins since the last successful one, they lock the user out until
+	// LockedUntil, which is only set while the lock lasts
+	FailedLogins int     `json:"-"`
+	LockedUntil  *string `json:"lockedUntil,omitempty"`
+	CreatedAt    string  `json:"createdAt"`
+	UpdatedAt    string  `json:"updatedAt"`
+}
+
+// IsValidRole tells whether role is one of the roles of the users
+func IsValidRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// NormalizeEmail is the form in which emails are stored and looked up
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+const userColumns = `id, email, name, password_hash, role, disabled, invite_expires_at, invited_by,
+	oidc_issuer, oidc_subject, totp_secret, totp_pending_secret, totp_last_step, failed_logins, locked_until,
+	created_at, updated_at`
+
+func scanUser(row rowScanner) (User, error) {
+	user := User{}
+	var lockedUntil sql.NullTime
+	err := row.Scan(
+		&user.Id,
+		&user.Email,
+		&user.Name,
+		&user.PasswordHash,
+		&user.Role,
+		&user.Disabled,
+		&user.InviteExpiresAt,
+		&user.InvitedBy,
+		&user.OIDCIssuer,
+		&user.OIDCSubject,
+		&user.TOTPSecret,
+		&user.TOTPPendingSecret,
+		&user.TOTPLastStep,
+		&user.FailedLogins,
+		&lockedUntil,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrUserNotFound
+	}
+
+	user.InvitePending = user.PasswordHash == nil && user.OIDCSubject == nil
+	user.TwoFactorEnabled = user.TOTPSecret != nil
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		until := lockedUntil.Time.UTC().Format(time.RFC3339)
+		user.LockedUntil = &until
+	}
+	return user, err
+}
+
+func GetUser(id string) (User, error) {
+	return scanUser(DB.QueryRow(`SELECT `+userColumns+` FROM USERS WHERE id = ?`, id))
+}
+
+func GetUserByEmail(email string) (User, error) {
+	return scanUser(DB.QueryRow(`SELECT `+userColumns+` FROM USERS WHERE email = ?`, NormalizeEmail(email)))
+}
+
+// GetUserByInvite returns the user whose invite, given by the hash of its token, has not expired
+func GetUserByInvite(inviteHash string, now time.Time) (User, error) {
+	return scanUser(DB.QueryRow(
+		`SELECT `+userColumns+` FROM USERS WHERE invite_hash = ? AND invite_expires_at > ? AND password_hash IS NULL AND oidc_subject IS NULL`,
+		inviteHash, now.UTC(),
+	))
+}
+
+// ListUsers returns all users, oldest first
+func ListUsers() ([]User, error) {
+	rows, err := DB.Query(`SELECT ` + userColumns + ` FROM USERS ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// CountUsers is the number of users, disabled ones included
+func CountUsers() (int, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM USERS`).Scan(&count)
+	return count, err
+}
+
+// CreateUser stores a new user. A user without a password hash is to be invited with SetUserInvite, unless it
+// comes from the single sign-on provider.
+func CreateUser(user *User) error {
+	user.Email = NormalizeEmail(user.Email)
+
+	if _, err := GetUserByEmail(user.Email); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	_, err := DB.Exec(`
+		INSERT INTO USERS (id, email, name, password_hash, role, invited_by, oidc_issuer, oidc_subject, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.Id, user.Email, user.Name, user.PasswordHash, user.Role, user.InvitedBy, user.OIDCIssuer, user.OIDCSubject, now, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	user.InvitePending = user.PasswordHash == nil && user.OIDCSubject == nil
+	user.CreatedAt = now.Format(time.RFC3339Nano)
+	user.UpdatedAt = user.CreatedAt
+	return nil
+}
+
+// SetUserInvite replaces the hash of the invite token of a user who has not accepted an invite yet
+func SetUserInvite(id string, inviteHash string, expiresAt time.Time) error {
+	res, err := DB.Exec(
+		`UPDATE USERS SET invite_hash = ?, invite_expires_at = ?, updated_at = ? WHERE id = ? AND password_hash IS NULL AND oidc_subject IS NULL`,
+		inviteHash, expiresAt.UTC(), time.Now(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// AcceptInvite sets the password and the name of an invited user and ends the invite
+func AcceptInvite(id string, name string, passwordHash string) error {
+	res, err := DB.Exec(`
+		UPDATE USERS SET name = ?, password_hash = ?, invite_hash = NULL, invite_expires_at = NULL, updated_at = ?
+		WHERE id = ? AND password_hash IS NULL AND oidc_subject IS NULL`,
+		name, passwordHash, time.Now(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// SetUserPassword replaces the password hash of a user, and ends its invite if it had not accepted it and its
+// lockout after failed logins
+func SetUserPassword(id string, passwordHash string) error {
+	res, err := DB.Exec(`
+		UPDATE USERS SET password_hash = ?, invite_hash = NULL, invite_expires_at = NULL, failed_logins = 0, locked_until = NULL, updated_at = ?
+		WHERE id = ?`,
+		passwordHash, time.Now(), id,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// SetUserEmail changes the email a user logs in with
+func SetUserEmail(id string, email string) error {
+	email = NormalizeEmail(email)
+
+	if other, err := GetUserByEmail(email); err == nil && other.Id != id {
+		return ErrEmailTaken
+	} else if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	res, err := DB.Exec(`UPDATE USERS SET email = ?, updated_at = ? WHERE id = ?`, email, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, ErrUserNotFound)
+}
+
+// ListOwners returns the enabled owners, oldest first
+func ListOwners() ([]User, error) {
+	users, err := ListUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := []User{}
+	for _, user := range users {
+		if user.Role == RoleOwner && !user.Disabled {
+			owners = append(owners, user)
+		}
+	}
+	return owners, nil
+}
+
+// UpdateUserAccess changes the role of a user and whether it is disabled. It fails with ErrLastOwner rather
+// than leaving no enabled owner able to log in, with a password or single sign-on.
+func UpdateUserAccess(id string, role string, disabled bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Two owners removed at once would each still count the other. Locking the owners makes the second change
+	// wait for the first, and count without it. SQLite has a single writer already.
+	if DB.Dialect == Postgres {
+		if _, err := tx.Exec(`SELECT id FROM USERS WHERE role = ? AND disabled = FALSE FOR UPDATE`, RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.Exec(`UPDATE USERS SET role = ?, disabled = ?, updated_at = ? WHERE id = ?`, role, disabled, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(res, ErrUserNotFound); err != nil {
+		return err
+	}
+
+	var owners int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM USERS
+		WHERE role = ? AND disabled = FALSE AND (password_hash IS NOT NULL OR oidc_subject IS NOT NULL)`,
+		RoleOwner,
+	).Scan(&owners)
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+
+	return tx.Commit()
+}
+
+// expectOneRow returns notFound when an update changed no row
+func expectOneRow(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
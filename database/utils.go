@@ -1,12 +1,64 @@
 package database
 
-func CheckIfInit() bool {
-	var count int
+import "fmt"
 
-	err := DB.QueryRow("SELECT COUNT(*) FROM settings").Scan(&count)
+func CheckIfInit() bool {
+	count, err := CountUsers()
 	if err != nil {
 		return false
 	}
 
 	return count > 0
 }
+
+// columnExists tells whether a table has a column
+func columnExists(table, column string) (bool, error) {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal any
+			pk         int
+		)
+
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// addColumnIfMissing adds a column to an existing table, SQLite has no ADD COLUMN IF NOT EXISTS
+func addColumnIfMissing(table, column, definition string) error {
+	found, err := columnExists(table, column)
+	if err != nil || found {
+		return err
+	}
+
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// renameColumnIfExists renames a column of an existing table and tells whether it did
+func renameColumnIfExists(table, column, newName string) (bool, error) {
+	found, err := columnExists(table, column)
+	if err != nil || !found {
+		return false, err
+	}
+
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", table, column, newName))
+	return err == nil, err
+}
@@ -0,0 +1,317 @@
+// Command mock-oidc is a small OpenID Connect provider for trying single sign-on locally. It serves discovery,
+// keys, an authorization page where any email and groups can be entered, and a token endpoint checking the client
+// secret and PKCE. Nothing is persisted, keys and codes live for the life of the process.
+//
+//	go run ./dev/mock-oidc -addr :9090
+//
+// and start Inkra with OIDC_ISSUER=http://localhost:9090 OIDC_CLIENT_ID=inkra OIDC_CLIENT_SECRET=secret
+// OIDC_REDIRECT_URL=http://localhost:8080/api/auth/oidc/callback. With -auto the authorization page is skipped
+// and the user given by -email, -name and -groups logs in at once, with -mfa as after a multi-factor login.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// authorization is what a code stands for until it is exchanged
+type authorization struct {
+	clientId      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	email         string
+	name          string
+	groups        []string
+	mfa           bool
+	expiresAt     time.Time
+}
+
+type provider struct {
+	issuer       string
+	clientId     string
+	clientSecret string
+	auto         bool
+	email        string
+	name         string
+	groups       string
+	unverified   bool
+	mfa          bool
+
+	key   *rsa.PrivateKey
+	keyId string
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!doctype html>
+<title>Mock OIDC login</title>
+<h1>Mock OIDC login</h1>
+<form method="post">
+  {{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{index $value 0}}">{{end}}
+  <p><label>Email <input name="email" value="{{.Email}}"></label></p>
+  <p><label>Name <input name="name" value="{{.Name}}"></label></p>
+  <p><label>Groups, comma separated <input name="groups" value="{{.Groups}}"></label></p>
+  <p><label><input type="checkbox" name="mfa" {{if .MFA}}checked{{end}}> Logged in with several factors</label></p>
+  <button>Log in</button>
+</form>`))
+
+func main() {
+	p := &provider{codes: map[string]authorization{}}
+
+	addr := flag.String("addr", ":9090", "listen address")
+	flag.StringVar(&p.issuer, "issuer", "http://localhost:9090", "issuer url, as Inkra reaches it")
+	flag.StringVar(&p.clientId, "client-id", "inkra", "client id")
+	flag.StringVar(&p.clientSecret, "client-secret", "secret", "client secret, empty for a public client")
+	flag.BoolVar(&p.auto, "auto", false, "log in the default user without showing the authorization page")
+	flag.StringVar(&p.email, "email", "alice@example.com", "default email")
+	flag.StringVar(&p.name, "name", "Alice", "default name")
+	flag.StringVar(&p.groups, "groups", "inkra-editors", "default groups, comma separated")
+	flag.BoolVar(&p.unverified, "unverified", false, "send email_verified false")
+	flag.BoolVar(&p.mfa, "mfa", false, "send amr [\"pwd\", \"mfa\"] by default, as after a multi-factor login")
+	flag.Parse()
+
+	if err := p.newKey(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Mock OIDC provider", p.issuer, "listening on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, p.handler()))
+}
+
+// newKey generates the signing key, on every start under a new id as a provider rotating its keys would do
+func (p *provider) newKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	keySum := sha256.Sum256(key.N.Bytes())
+
+	p.mu.Lock()
+	p.key, p.keyId = key, hex.EncodeToString(keySum[:8])
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *provider) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func tokenError(w http.ResponseWriter, code string, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	key, keyId := p.key, p.keyId
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyId,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize shows the login form, or with -auto or on its submission sends the browser back with a code
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := r.Form
+	if params.Get("client_id") != p.clientId || params.Get("response_type") != "code" {
+		http.Error(w, "unknown client_id or response_type is not code", http.StatusBadRequest)
+		return
+	}
+	if params.Get("code_challenge") == "" || params.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet && !p.auto {
+		query := r.URL.Query()
+		authorizePage.Execute(w, map[string]any{"Params": query, "Email": p.email, "Name": p.name, "Groups": p.groups, "MFA": p.mfa})
+		return
+	}
+
+	auth := authorization{
+		clientId:      p.clientId,
+		redirectURI:   params.Get("redirect_uri"),
+		codeChallenge: params.Get("code_challenge"),
+		nonce:         params.Get("nonce"),
+		email:         p.email,
+		name:          p.name,
+		groups:        splitGroups(p.groups),
+		mfa:           p.mfa,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	if r.Method == http.MethodPost {
+		auth.email, auth.name, auth.groups = r.PostForm.Get("email"), r.PostForm.Get("name"), splitGroups(r.PostForm.Get("groups"))
+		auth.mfa = r.PostForm.Get("mfa") != ""
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = auth
+	p.mu.Unlock()
+
+	back, err := url.Parse(auth.redirectURI)
+	if err != nil || back.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	query := back.Query()
+	query.Set("code", code)
+	query.Set("state", params.Get("state"))
+	back.RawQuery = query.Encode()
+
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+
+	clientId, secret, ok := r.BasicAuth()
+	if ok {
+		clientId, _ = url.QueryUnescape(clientId)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientId, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientId != p.clientId || subtle.ConstantTimeCompare([]byte(secret), []byte(p.clientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	auth, found := p.codes[code]
+	delete(p.codes, code)
+	key, keyId := p.key, p.keyId
+	p.mu.Unlock()
+
+	if !found || time.Now().After(auth.expiresAt) {
+		tokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	}
+	if r.PostForm.Get("redirect_uri") != auth.redirectURI {
+		tokenError(w, "invalid_grant", "redirect_uri does not match")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		tokenError(w, "invalid_grant", "code_verifier does not match the code_challenge")
+		return
+	}
+
+	amr := []string{"pwd"}
+	if auth.mfa {
+		amr = append(amr, "mfa")
+	}
+
+	subject := sha256.Sum256([]byte(auth.email))
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.issuer,
+		"aud":            p.clientId,
+		"sub":            hex.EncodeToString(subject[:8]),
+		"email":          auth.email,
+		"email_verified": !p.unverified,
+		"name":           auth.name,
+		"groups":         auth.groups,
+		"amr":            amr,
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = keyId
+
+	signed, err := idToken.SignedString(key)
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func splitGroups(groups string) []string {
+	list := []string{}
+	for _, group := range strings.Split(groups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			list = append(list, group)
+		}
+	}
+	return list
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprint("mock-oidc: ", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
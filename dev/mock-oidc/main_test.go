@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fbn776/inkra/oidc"
+)
+
+const testRedirectURL = "http://localhost:8080/api/auth/oidc/callback"
+
+// newTestProvider starts the mock provider with the defaults of its flags
+func newTestProvider(t *testing.T, auto bool) *provider {
+	p := &provider{clientId: "inkra", clientSecret: "secret", auto: auto, email: "alice@example.com", name: "Alice", groups: "inkra-editors", codes: map[string]authorization{}}
+	if err := p.newKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(p.handler())
+	t.Cleanup(server.Close)
+	p.issuer = server.URL
+	return p
+}
+
+// noRedirects is a browser stopping at the redirect back to Inkra
+var noRedirects = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+// authorize logs in at the authorization url, with the form when it is given, and returns the code sent back
+func authorize(t *testing.T, authURL string, form url.Values) string {
+	t.Helper()
+
+	var res *http.Response
+	var err error
+	if form == nil {
+		res, err = noRedirects.Get(authURL)
+	} else {
+		u, _ := url.Parse(authURL)
+		for name, values := range u.Query() {
+			form[name] = values
+		}
+		u.RawQuery = ""
+		res, err = noRedirects.PostForm(u.String(), form)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize = %s", res.Status)
+	}
+
+	back, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(back.String(), testRedirectURL+"?") || back.Query().Get("state") != "s-1" {
+		t.Fatalf("redirected to %s", res.Header.Get("Location"))
+	}
+	return back.Query().Get("code")
+}
+
+// login runs the authorization code flow of Inkra against the mock provider
+func login(t *testing.T, rp *oidc.Provider, form url.Values) (*oidc.IDToken, error) {
+	t.Helper()
+
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := rp.AuthCodeURL("s-1", "n-1", oidc.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp.Exchange(authorize(t, authURL, form), verifier, "n-1")
+}
+
+func TestLogin(t *testing.T) {
+	p := newTestProvider(t, true)
+	rp := oidc.NewProvider(p.issuer, "inkra", "secret", testRedirectURL, []string{"openid", "email", "groups"})
+
+	token, err := login(t, rp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Email != "alice@example.com" || token.Name != "Alice" || !token.EmailVerified || token.Subject == "" {
+		t.Errorf("token = %+v", token)
+	}
+	if groups := token.Strings("groups"); !slices.Equal(groups, []string{"inkra-editors"}) {
+		t.Errorf("groups = %v", groups)
+	}
+	if amr := token.Strings("amr"); !slices.Equal(amr, []string{"pwd"}) {
+		t.Errorf("amr = %v", amr)
+	}
+
+	// Tokens are signed with the new key after a restart, which the keys published by the provider follow
+	if err := p.newKey(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(t, oidc.NewProvider(p.issuer, "inkra", "secret", testRedirectURL, nil), nil); err != nil {
+		t.Errorf("login after a key rotation = %v", err)
+	}
+}
+
+func TestLoginForm(t *testing.T) {
+	p := newTestProvider(t, false)
+	rp := oidc.NewProvider(p.issuer, "inkra", "secret", testRedirectURL, []string{"openid"})
+
+	authURL, err := rp.AuthCodeURL("s-1", "n-1", oidc.CodeChallenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(page), `name="code_challenge"`) {
+		t.Errorf("authorization page = %s\n%s", res.Status, page)
+	}
+
+	token, err := login(t, rp, url.Values{"email": {"bob@example.com"}, "name": {"Bob"}, "groups": {"inkra-admins, ,inkra-editors"}, "mfa": {"on"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Email != "bob@example.com" || token.Name != "Bob" {
+		t.Errorf("token = %+v", token)
+	}
+	if groups := token.Strings("groups"); !slices.Equal(groups, []string{"inkra-admins", "inkra-editors"}) {
+		t.Errorf("groups = %v", groups)
+	}
+	if amr := token.Strings("amr"); !slices.Equal(amr, []string{"pwd", "mfa"}) {
+		t.Errorf("amr = %v", amr)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	p := newTestProvider(t, true)
+	rp := oidc.NewProvider(p.issuer, "inkra", "secret", testRedirectURL, nil)
+
+	tests := []struct {
+		name     string
+		rp       *oidc.Provider
+		verifier func(verifier string) string
+		nonce    string
+		err      error
+	}{
+		{"other verifier", rp, func(string) string { return "other" }, "n-1", oidc.ErrExchange},
+		{"other nonce", rp, nil, "n-2", oidc.ErrNonceMismatch},
+		{"other secret", oidc.NewProvider(p.issuer, "inkra", "guess", testRedirectURL, nil), nil, "n-1", oidc.ErrExchange},
+		{"public client", oidc.NewProvider(p.issuer, "inkra", "", testRedirectURL, nil), nil, "n-1", oidc.ErrExchange},
+		{"other redirect url", oidc.NewProvider(p.issuer, "inkra", "secret", testRedirectURL+"?x=1", nil), nil, "n-1", oidc.ErrExchange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, _ := oidc.NewCodeVerifier()
+			authURL, err := rp.AuthCodeURL("s-1", "n-1", oidc.CodeChallenge(verifier))
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := authorize(t, authURL, nil)
+
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+			if _, err := tt.rp.Exchange(code, verifier, tt.nonce); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	verifier, _ := oidc.NewCodeVerifier()
+	authURL, _ := rp.AuthCodeURL("s-1", "n-1", oidc.CodeChallenge(verifier))
+	code := authorize(t, authURL, nil)
+	if _, err := rp.Exchange(code, verifier, "n-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.Exchange(code, verifier, "n-1"); !errors.Is(err, oidc.ErrExchange) {
+		t.Errorf("code used twice = %v, want ErrExchange", err)
+	}
+}
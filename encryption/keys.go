@@ -0,0 +1,122 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidMasterKey = errors.New("encryption: master key must be 32 bytes, base64 or hex encoded")
+	ErrInvalidWrapped   = errors.New("encryption: invalid wrapped key")
+	ErrUnknownMasterKey = errors.New("encryption: data key was wrapped with an unknown master key")
+)
+
+const wrapVersion = "v1"
+
+// MasterKey wraps the data keys. Its id is stored next to every wrapped key so a rotated key
+// can still be found.
+type MasterKey struct {
+	ID  string
+	Key []byte
+}
+
+// NewMasterKey returns a master key for 32 raw bytes
+func NewMasterKey(key []byte) (*MasterKey, error) {
+	if len(key) != DataKeySize {
+		return nil, ErrInvalidMasterKey
+	}
+	sum := sha256.Sum256(append([]byte("inkra-master-key:"), key...))
+	return &MasterKey{ID: hex.EncodeToString(sum[:8]), Key: key}, nil
+}
+
+// ParseMasterKey decodes a base64 or hex encoded master key
+func ParseMasterKey(encoded string) (*MasterKey, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == DataKeySize {
+		return NewMasterKey(key)
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(encoded); err == nil && len(key) == DataKeySize {
+			return NewMasterKey(key)
+		}
+	}
+	return nil, ErrInvalidMasterKey
+}
+
+// GenerateMasterKey returns a new random master key, base64 encoded
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Wrap encrypts a data key for the document it belongs to, as "v1.<master key id>.<base64>"
+func (m *MasterKey) Wrap(dataKey []byte, docId string) (string, error) {
+	aead, err := newAEAD(m.Key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, dataKey, wrapAAD(docId))
+	return fmt.Sprintf("%s.%s.%s", wrapVersion, m.ID, base64.RawStdEncoding.EncodeToString(sealed)), nil
+}
+
+// WrappedKeyID returns the id of the master key a data key was wrapped with
+func WrappedKeyID(wrapped string) (string, error) {
+	parts := strings.Split(wrapped, ".")
+	if len(parts) != 3 || parts[0] != wrapVersion {
+		return "", ErrInvalidWrapped
+	}
+	return parts[1], nil
+}
+
+// Unwrap decrypts a data key with whichever of the master keys wrapped it
+func Unwrap(wrapped string, docId string, keys ...*MasterKey) ([]byte, error) {
+	id, err := WrappedKeyID(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.SplitN(wrapped, ".", 3)[2])
+	if err != nil {
+		return nil, ErrInvalidWrapped
+	}
+
+	for _, key := range keys {
+		if key == nil || key.ID != id {
+			continue
+		}
+
+		aead, err := newAEAD(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, ErrInvalidWrapped
+		}
+
+		dataKey, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], wrapAAD(docId))
+		if err != nil {
+			return nil, ErrInvalidWrapped
+		}
+		return dataKey, nil
+	}
+
+	return nil, ErrUnknownMasterKey
+}
+
+func wrapAAD(docId string) []byte {
+	return []byte("inkra-data-key:" + docId)
+}
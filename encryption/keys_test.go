@@ -0,0 +1,112 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testWrapped is the data key a0 a1 .. bf of the document "doc-1", wrapped with testKey as master key. Wrapped
+// keys are stored in the database, those of earlier releases have to unwrap with every later one.
+const testWrapped = "v1.d5d0ecc1334241a5.no6YnVk+4NoAYugC0r8na5JzsD2PTu5JJGMLdxNyftTzTr9x+nHQdJ7nY2W7K/aXFbDQYTzJS20Guvn4"
+
+func TestParseMasterKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		err     error
+	}{
+		{"hex", hex.EncodeToString(testKey), nil},
+		{"base64", base64.StdEncoding.EncodeToString(testKey), nil},
+		{"base64 without padding", base64.RawStdEncoding.EncodeToString(testKey), nil},
+		{"base64 url", base64.URLEncoding.EncodeToString(testKey), nil},
+		{"with a newline", hex.EncodeToString(testKey) + "\n", nil},
+		{"short", hex.EncodeToString(testKey[:16]), ErrInvalidMasterKey},
+		{"long", base64.StdEncoding.EncodeToString(append(testKey, 0)), ErrInvalidMasterKey},
+		{"passphrase", "correct horse battery staple", ErrInvalidMasterKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseMasterKey(tt.encoded)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			// The id is stored with every wrapped key, it must not change
+			if err == nil && (!bytes.Equal(key.Key, testKey) || key.ID != "d5d0ecc1334241a5") {
+				t.Errorf("key = %x %s", key.Key, key.ID)
+			}
+		})
+	}
+
+	generated, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseMasterKey(generated); err != nil {
+		t.Errorf("generated key %s: %v", generated, err)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	master, _ := NewMasterKey(testKey)
+	rotated, _ := NewMasterKey(bytes.Repeat([]byte{7}, DataKeySize))
+	dataKey := make([]byte, DataKeySize)
+	for i := range dataKey {
+		dataKey[i] = 0xa0 + byte(i)
+	}
+
+	wrapped, err := master.Wrap(dataKey, "doc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := WrappedKeyID(wrapped); err != nil || id != master.ID {
+		t.Errorf("wrapped key id = %s, %v", id, err)
+	}
+	if again, _ := master.Wrap(dataKey, "doc-1"); again == wrapped {
+		t.Error("two wrappings of the same key are equal")
+	}
+
+	parts := strings.Split(wrapped, ".")
+	sealed, _ := base64.RawStdEncoding.DecodeString(parts[2])
+	sealed[len(sealed)-1] ^= 1
+	tampered := parts[0] + "." + parts[1] + "." + base64.RawStdEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name    string
+		wrapped string
+		docId   string
+		keys    []*MasterKey
+		err     error
+	}{
+		{"wrapped by an earlier release", testWrapped, "doc-1", []*MasterKey{master}, nil},
+		{"new", wrapped, "doc-1", []*MasterKey{master}, nil},
+		{"after a rotation", wrapped, "doc-1", []*MasterKey{rotated, nil, master}, nil},
+		{"other document", wrapped, "doc-2", []*MasterKey{master}, ErrInvalidWrapped},
+		{"unknown master key", wrapped, "doc-1", []*MasterKey{rotated}, ErrUnknownMasterKey},
+		{"tampered", tampered, "doc-1", []*MasterKey{master}, ErrInvalidWrapped},
+		{"short", parts[0] + "." + parts[1] + ".AAAA", "doc-1", []*MasterKey{master}, ErrInvalidWrapped},
+		{"not base64", parts[0] + "." + parts[1] + ".!", "doc-1", []*MasterKey{master}, ErrInvalidWrapped},
+		{"other version", "v2." + parts[1] + "." + parts[2], "doc-1", []*MasterKey{master}, ErrInvalidWrapped},
+		{"raw key", hex.EncodeToString(dataKey), "doc-1", []*MasterKey{master}, ErrInvalidWrapped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unwrap(tt.wrapped, tt.docId, tt.keys...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && !bytes.Equal(got, dataKey) {
+				t.Errorf("data key = %x", got)
+			}
+		})
+	}
+
+	if _, err := NewMasterKey(testKey[:31]); !errors.Is(err, ErrInvalidMasterKey) {
+		t.Errorf("short master key = %v, want ErrInvalidMasterKey", err)
+	}
+}
@@ -0,0 +1,331 @@
+// Package encryption encrypts document files with per-document data keys. Files are split in chunks
+// sealed with AES-256-GCM, so they can be encrypted as a stream and decrypted from any offset.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Layout: magic | chunk size (uint32) | nonce prefix (7 bytes), then the chunks. The nonce of a chunk is
+// the prefix, its index (uint32) and a byte set on the final chunk, so chunks cannot be reordered or
+// dropped. The header is authenticated with every chunk.
+const (
+	magic       = "INKENC01"
+	prefixSize  = 7
+	headerSize  = len(magic) + 4 + prefixSize
+	ChunkSize   = 64 << 10
+	tagSize     = 16
+	DataKeySize = 32
+)
+
+var (
+	ErrInvalidKey    = errors.New("encryption: key must be 32 bytes")
+	ErrCorrupted     = errors.New("encryption: file is corrupted or was encrypted with another key")
+	ErrNotEncrypted  = errors.New("encryption: file is not encrypted")
+	ErrNegativeSeek  = errors.New("encryption: negative seek offset")
+	errTooManyChunks = errors.New("encryption: file too large")
+)
+
+// GenerateDataKey returns a new random data key
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+// IsEncrypted tells whether data starts with the header of an encrypted file
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// MagicSize is the number of leading bytes IsEncrypted needs
+const MagicSize = len(magic)
+
+// EncryptedSize is the size of the encrypted form of size bytes
+func EncryptedSize(size int64) int64 {
+	chunks := (size + ChunkSize - 1) / ChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return int64(headerSize) + size + chunks*tagSize
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != DataKeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index uint64, last bool) ([]byte, error) {
+	if index > 0xffffffff {
+		return nil, errTooManyChunks
+	}
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], uint32(index))
+	if last {
+		nonce[11] = 1
+	}
+	return nonce, nil
+}
+
+type encryptReader struct {
+	aead   cipher.AEAD
+	src    io.Reader
+	header []byte
+	prefix []byte
+	index  uint64
+
+	next []byte // plaintext read ahead, to know which chunk is the last one
+	eof  bool
+	out  []byte
+	done bool
+	err  error
+}
+
+// NewEncryptReader returns a reader producing the encrypted form of src
+func NewEncryptReader(key []byte, src io.Reader) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[len(magic):], ChunkSize)
+	prefix := header[len(magic)+4:]
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	r := &encryptReader{aead: aead, src: src, header: header, prefix: prefix}
+	r.out = append([]byte{}, header...)
+	return r, nil
+}
+
+func (r *encryptReader) readChunk() ([]byte, error) {
+	buf := make([]byte, ChunkSize)
+	n, err := io.ReadFull(r.src, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.eof = true
+		err = nil
+	}
+	return buf[:n], err
+}
+
+func (r *encryptReader) fill() error {
+	if r.next == nil && !r.eof {
+		chunk, err := r.readChunk()
+		if err != nil {
+			return err
+		}
+		r.next = chunk
+	}
+
+	current := r.next
+	r.next = nil
+
+	// A full chunk is only the last one when nothing follows it
+	if !r.eof {
+		chunk, err := r.readChunk()
+		if err != nil {
+			return err
+		}
+		if len(chunk) > 0 {
+			r.next = chunk
+		} else {
+			r.eof = true
+		}
+	}
+
+	last := r.next == nil && r.eof
+	nonce, err := chunkNonce(r.prefix, r.index, last)
+	if err != nil {
+		return err
+	}
+	r.index++
+
+	r.out = r.aead.Seal(r.out[:0], nonce, current, r.header)
+	r.done = last
+	return nil
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			r.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// Encrypt encrypts a whole file in memory
+func Encrypt(key []byte, data []byte) ([]byte, error) {
+	r, err := NewEncryptReader(key, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+type decryptReader struct {
+	aead   cipher.AEAD
+	src    io.ReadSeeker
+	header []byte
+	prefix []byte
+
+	chunkSize int64
+	chunks    int64
+	size      int64
+	offset    int64
+
+	cached      []byte
+	cachedIndex int64
+}
+
+// NewDecryptReader decrypts an encrypted file of encryptedSize bytes. The result is seekable and
+// only reads the chunks it needs, so Range requests do not decrypt the whole file.
+func NewDecryptReader(key []byte, src io.ReadSeeker, encryptedSize int64) (io.ReadSeeker, int64, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, 0, ErrNotEncrypted
+	}
+	if !IsEncrypted(header) {
+		return nil, 0, ErrNotEncrypted
+	}
+
+	chunkSize := int64(binary.BigEndian.Uint32(header[len(magic):]))
+	body := encryptedSize - int64(headerSize)
+	full := chunkSize + tagSize
+	if chunkSize == 0 || body < tagSize {
+		return nil, 0, ErrCorrupted
+	}
+
+	chunks := (body + full - 1) / full
+	lastPlain := body - (chunks-1)*full - tagSize
+	if lastPlain < 0 {
+		return nil, 0, ErrCorrupted
+	}
+
+	size := (chunks-1)*chunkSize + lastPlain
+	return &decryptReader{
+		aead:        aead,
+		src:         src,
+		header:      header,
+		prefix:      header[len(magic)+4:],
+		chunkSize:   chunkSize,
+		chunks:      chunks,
+		size:        size,
+		cachedIndex: -1,
+	}, size, nil
+}
+
+func (r *decryptReader) chunk(index int64) ([]byte, error) {
+	if index == r.cachedIndex {
+		return r.cached, nil
+	}
+
+	full := r.chunkSize + tagSize
+	length := full
+	last := index == r.chunks-1
+	if last {
+		length = r.size - index*r.chunkSize + tagSize
+	}
+
+	if _, err := r.src.Seek(int64(headerSize)+index*full, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(r.src, sealed); err != nil {
+		return nil, ErrCorrupted
+	}
+
+	nonce, err := chunkNonce(r.prefix, uint64(index), last)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := r.aead.Open(sealed[:0], nonce, sealed, r.header)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+
+	r.cached, r.cachedIndex = plain, index
+	return plain, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		// The final chunk is still checked for empty files, so truncation is detected
+		if r.size == 0 {
+			if _, err := r.chunk(0); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+
+	index := r.offset / r.chunkSize
+	plain, err := r.chunk(index)
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, plain[r.offset-index*r.chunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, ErrNegativeSeek
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Decrypt decrypts a whole file in memory
+func Decrypt(key []byte, data []byte) ([]byte, error) {
+	r, _, err := NewDecryptReader(key, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,211 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// testKey is the data key 00 01 .. 1f
+var testKey = func() []byte {
+	key := make([]byte, DataKeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}()
+
+// testContent returns size bytes that differ from chunk to chunk
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i*7 + i/ChunkSize)
+	}
+	return content
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"under a chunk", ChunkSize - 1},
+		{"one chunk", ChunkSize},
+		{"over a chunk", ChunkSize + 1},
+		{"three chunks", 3 * ChunkSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testContent(tt.size)
+
+			// Read a byte at a time, as from a slow upload
+			r, err := NewEncryptReader(testKey, iotest.OneByteReader(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			encrypted, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncrypted(encrypted) || int64(len(encrypted)) != EncryptedSize(int64(tt.size)) {
+				t.Errorf("encrypted %d bytes, want %d", len(encrypted), EncryptedSize(int64(tt.size)))
+			}
+
+			decrypted, err := Decrypt(testKey, encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, content) {
+				t.Error("decrypted content differs")
+			}
+
+			if again, _ := Encrypt(testKey, content); bytes.Equal(again, encrypted) {
+				t.Error("two encryptions of the same content are equal")
+			}
+		})
+	}
+}
+
+// TestLayout decrypts a file sealed chunk by chunk as the layout describes it, with AES-GCM directly
+func TestLayout(t *testing.T) {
+	content := testContent(2*ChunkSize + 10)
+	prefix := []byte{1, 2, 3, 4, 5, 6, 7}
+
+	header := append([]byte("INKENC01"), 0, 1, 0, 0)
+	header = append(header, prefix...)
+
+	block, _ := aes.NewCipher(testKey)
+	aead, _ := cipher.NewGCM(block)
+
+	file := append([]byte{}, header...)
+	for index := 0; index*ChunkSize < len(content); index++ {
+		nonce := make([]byte, 12)
+		copy(nonce, prefix)
+		binary.BigEndian.PutUint32(nonce[7:], uint32(index))
+		end := min((index+1)*ChunkSize, len(content))
+		if end == len(content) {
+			nonce[11] = 1
+		}
+		file = aead.Seal(file, nonce, content[index*ChunkSize:end], header)
+	}
+
+	decrypted, err := Decrypt(testKey, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, content) {
+		t.Error("decrypted content differs")
+	}
+}
+
+func TestDecryptSeek(t *testing.T) {
+	content := testContent(3*ChunkSize + 100)
+	encrypted, err := Encrypt(testKey, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, size, err := NewDecryptReader(testKey, bytes.NewReader(encrypted), int64(len(encrypted)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(content)) {
+		t.Fatalf("size = %d, want %d", size, len(content))
+	}
+
+	tests := []struct {
+		offset int64
+		whence int
+		length int
+		want   int64
+	}{
+		{ChunkSize - 5, io.SeekStart, 10, ChunkSize - 5},
+		{ChunkSize, io.SeekCurrent, ChunkSize + 10, 2*ChunkSize + 5},
+		{-50, io.SeekEnd, 50, 3*ChunkSize + 50},
+		{0, io.SeekStart, 100, 0},
+	}
+
+	for _, tt := range tests {
+		pos, err := r.Seek(tt.offset, tt.whence)
+		if err != nil || pos != tt.want {
+			t.Fatalf("seek %d %d = %d, %v, want %d", tt.offset, tt.whence, pos, err, tt.want)
+		}
+		buf := make([]byte, tt.length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, content[pos:pos+int64(tt.length)]) {
+			t.Errorf("read %d bytes at %d differs", tt.length, pos)
+		}
+	}
+
+	if _, err := r.Seek(10, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("read past the end = %d, %v", n, err)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); !errors.Is(err, ErrNegativeSeek) {
+		t.Errorf("negative seek = %v, want ErrNegativeSeek", err)
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	small, _ := Encrypt(testKey, []byte("%PDF-1.7"))
+	empty, _ := Encrypt(testKey, nil)
+	twoChunks, _ := Encrypt(testKey, testContent(2*ChunkSize))
+	threeChunks, _ := Encrypt(testKey, testContent(3*ChunkSize))
+	full := ChunkSize + tagSize
+	otherKey := bytes.Repeat([]byte{1}, DataKeySize)
+
+	flip := func(data []byte, i int) []byte {
+		data = append([]byte{}, data...)
+		data[i] ^= 1
+		return data
+	}
+	swapped := append([]byte{}, threeChunks[:headerSize]...)
+	swapped = append(swapped, threeChunks[headerSize+full:headerSize+2*full]...)
+	swapped = append(swapped, threeChunks[headerSize:headerSize+full]...)
+	swapped = append(swapped, threeChunks[headerSize+2*full:]...)
+
+	tests := []struct {
+		name string
+		key  []byte
+		data []byte
+		err  error
+	}{
+		{"plaintext", testKey, []byte("%PDF-1.7 not encrypted"), ErrNotEncrypted},
+		{"shorter than the header", testKey, small[:10], ErrNotEncrypted},
+		{"other key", otherKey, small, ErrCorrupted},
+		{"changed header", testKey, flip(small, headerSize-1), ErrCorrupted},
+		{"changed chunk size", testKey, flip(small, len(magic)+3), ErrCorrupted},
+		{"changed content", testKey, flip(small, headerSize), ErrCorrupted},
+		{"changed tag", testKey, flip(small, len(small)-1), ErrCorrupted},
+		{"truncated", testKey, small[:len(small)-1], ErrCorrupted},
+		{"empty file truncated to its header", testKey, empty[:headerSize], ErrCorrupted},
+		{"last chunk dropped", testKey, twoChunks[:headerSize+full], ErrCorrupted},
+		{"chunks swapped", testKey, swapped, ErrCorrupted},
+		{"chunk appended", testKey, append(append([]byte{}, twoChunks...), twoChunks[headerSize:headerSize+full]...), ErrCorrupted},
+		{"short key", testKey[:16], small, ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decrypt(tt.key, tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := Decrypt(testKey, empty); err != nil {
+		t.Errorf("empty file = %v", err)
+	}
+}
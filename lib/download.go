@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+)
+
+// Files of a document that can be downloaded
+const (
+	FileOriginal = "original"
+	FileSigned   = "signed"
+	// FileCurrent is the file as left by the signers who signed so far, the original until one signs and the
+	// signed file once all have
+	FileCurrent = "current"
+)
+
+func downloadSignature(docId, kind, linkId string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.DownloadSecret))
+	mac.Write([]byte("download\n" + docId + "\n" + kind + "\n" + linkId + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedDownloadURL returns a url to a file of a document that works without a token until it expires. A url
+// given through a signing link carries its id, and stops working with the link.
+func SignedDownloadURL(docId, kind, linkId string) (string, time.Time) {
+	expiresAt := time.Now().Add(config.AppConfig.DownloadURLTTL).Truncate(time.Second)
+	expires := expiresAt.Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	if linkId != "" {
+		query.Set("link", linkId)
+	}
+	query.Set("signature", downloadSignature(docId, kind, linkId, expires))
+
+	return "/api/files/" + url.PathEscape(docId) + "/" + kind + "?" + query.Encode(), expiresAt
+}
+
+// VerifyDownloadSignature checks the expires, link and signature query parameters of a signed download url
+func VerifyDownloadSignature(docId, kind string, query url.Values) bool {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	expected := downloadSignature(docId, kind, query.Get("link"), expires)
+	return hmac.Equal([]byte(expected), []byte(query.Get("signature")))
+}
@@ -1,29 +1,40 @@
 package lib
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
 
 	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/encryption"
+	"github.com/fbn776/inkra/storage"
 )
 
+var (
+	ErrMissingDataKey = errors.New("file is encrypted but the document has no data key")
+	// ErrNotEncrypted is returned for a plaintext file of a document with a data key, which may have been put in
+	// place of the encrypted one, or left behind by an interrupted encrypt-files run
+	ErrNotEncrypted = errors.New("file is not encrypted but the document has a data key")
+)
+
+// SaveMultipartFile stores the uploaded file as prefix/dstName in the configured storage and returns
+// the object key along with the hex encoded SHA-256 of the uploaded bytes. The file is encrypted with
+// dataKey as it is streamed, unless dataKey is nil.
 func SaveMultipartFile(
 	file multipart.File,
 	header *multipart.FileHeader,
-	dstDir string,
+	prefix string,
 	dstName string,
-) (string, error) {
+	dataKey []byte,
+) (string, string, error) {
 
 	if header.Size > config.AppConfig.MaxFileSize {
-		return "", errors.New("file too large")
-	}
-
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		return "", err
+		return "", "", errors.New("file too large")
 	}
 
 	filename := header.Filename
@@ -31,19 +42,131 @@ func SaveMultipartFile(
 		filename = dstName
 	}
 
-	dstPath := filepath.Join(dstDir, filename)
+	key := path.Join(prefix, path.Base(filename))
+
+	hasher := sha256.New()
+
+	if err := putFile(key, io.TeeReader(file, hasher), header.Size, dataKey); err != nil {
+		return "", "", err
+	}
+
+	return key, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// SaveFile stores data under key in the configured storage, encrypted with dataKey unless it is nil
+func SaveFile(key string, data []byte, dataKey []byte) error {
+	return putFile(key, bytes.NewReader(data), int64(len(data)), dataKey)
+}
+
+func putFile(key string, r io.Reader, size int64, dataKey []byte) error {
+	if dataKey == nil {
+		return config.AppConfig.Storage.Put(key, r, size)
+	}
+
+	encrypted, err := encryption.NewEncryptReader(dataKey, r)
+	if err != nil {
+		return err
+	}
+	return config.AppConfig.Storage.Put(key, encrypted, encryption.EncryptedSize(size))
+}
+
+// DeleteFile removes an object from the configured storage
+func DeleteFile(key string) error {
+	return config.AppConfig.Storage.Delete(key)
+}
 
-	dst, err := os.Create(dstPath)
+// OpenFile opens an object of the configured storage, decrypting it with dataKey. Files are only returned
+// as they are for documents without a data key, stored before encryption was enabled. The size in the
+// returned info is the size of the decrypted content.
+func OpenFile(key string, dataKey []byte) (io.ReadSeekCloser, storage.ObjectInfo, error) {
+	file, info, err := config.AppConfig.Storage.Get(key)
 	if err != nil {
-		return "", err
+		return nil, info, err
+	}
+
+	head := make([]byte, encryption.MagicSize)
+	n, _ := io.ReadFull(file, head)
+
+	if !encryption.IsEncrypted(head[:n]) {
+		if dataKey != nil {
+			file.Close()
+			return nil, info, ErrNotEncrypted
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			file.Close()
+			return nil, info, err
+		}
+		return file, info, nil
+	}
+
+	if dataKey == nil {
+		file.Close()
+		return nil, info, ErrMissingDataKey
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
+	plain, size, err := encryption.NewDecryptReader(dataKey, file, info.Size)
+	if err != nil {
+		file.Close()
+		return nil, info, err
+	}
+
+	info.Size = size
+	return decryptedFile{ReadSeeker: plain, Closer: file}, info, nil
+}
+
+type decryptedFile struct {
+	io.ReadSeeker
+	io.Closer
+}
+
+// EncryptFileInPlace replaces a plaintext object with its encrypted form, streaming it back into the
+// same key. It reports false when the object was already encrypted.
+func EncryptFileInPlace(key string, dataKey []byte) (bool, error) {
+	file, info, err := config.AppConfig.Storage.Get(key)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	head := make([]byte, encryption.MagicSize)
+	n, _ := io.ReadFull(file, head)
+	if encryption.IsEncrypted(head[:n]) {
+		return false, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	return true, putFile(key, file, info.Size, dataKey)
+}
+
+// ReadFile reads a whole object from the configured storage, decrypting it with dataKey when it is encrypted
+func ReadFile(key string, dataKey []byte) ([]byte, error) {
+	file, _, err := OpenFile(key, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// HashReader returns the hex encoded SHA-256 of everything read from r.
+func HashReader(r io.Reader) (string, error) {
+	hasher := sha256.New()
+
+	if _, err := io.Copy(hasher, r); err != nil {
 		return "", err
 	}
 
-	return dstPath, nil
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// HashBytes returns the hex encoded SHA-256 of data.
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 func IsPDF(file multipart.File) (bool, error) {
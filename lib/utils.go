@@ -28,23 +28,65 @@ func ErrorJSON(w http.ResponseWriter, status int, message string) {
 	SendJSON(w, status, map[string]any{"success": false, "message": message})
 }
 
+// ErrorCodeJSON is ErrorJSON with a machine readable error code
+func ErrorCodeJSON(w http.ResponseWriter, status int, code string, message string) {
+	SendJSON(w, status, map[string]any{"success": false, "code": code, "message": message})
+}
+
+// Claims of the access tokens, the subject is the id of the user and the jti is what gets revoked on logout
 type Claims struct {
-	Email string `json:"email"`
+	Email     string `json:"email"`
+	SessionId string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(email string) (string, error) {
+// GenerateJWT issues an access token of a session, valid for ACCESS_TOKEN_TTL, and returns when it expires
+func GenerateJWT(userId string, email string, sessionId string) (string, time.Time, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(config.AppConfig.AccessTokenTTL)
+
 	claims := Claims{
-		Email: email,
+		Email:     email,
+		SessionId: sessionId,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			Subject:   userId,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	return token.SignedString([]byte(config.AppConfig.JwtSecret))
+	signed, err := token.SignedString([]byte(config.AppConfig.JwtSecret))
+	return signed, expiresAt, err
+}
+
+// ParseJWT validates a token issued by GenerateJWT and returns its claims
+func ParseJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return []byte(config.AppConfig.JwtSecret), nil
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	return claims, nil
 }
 
 func CsvToSlice(input string) []string {
@@ -7,9 +7,11 @@ import (
 	"os"
 	"time"
 
+	"github.com/fbn776/inkra/commands"
 	"github.com/fbn776/inkra/config"
 	"github.com/fbn776/inkra/database"
 	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
 	"github.com/fbn776/inkra/routes"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -26,10 +28,21 @@ func main() {
 
 	config.Load()
 
-	if err := database.InitDB(); err != nil {
+	if len(os.Args) > 1 && !commands.AppliesMigrations(os.Args[1:]) {
+		if err := database.Open(config.AppConfig.DatabaseURL); err != nil {
+			log.Fatal(err)
+		}
+	} else if err := database.InitDB(config.AppConfig.DatabaseURL); err != nil {
 		log.Fatal(err)
 	}
 
+	if len(os.Args) > 1 {
+		if err := commands.Run(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if database.CheckIfInit() == false {
 		fmt.Println("Application is not initialized")
 		if err := config.RunInit(); err != nil {
@@ -37,6 +50,31 @@ func main() {
 		}
 	}
 
+	// Installs initialized before sealing was added get their key pair here
+	if err := config.EnsureSealKeyPair(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := config.EnsureMasterKey(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := config.EnsureAuditKey(); err != nil {
+		log.Fatal(err)
+	}
+
+	// One-time migration of the files stored before encryption was enabled
+	report, err := managers.EncryptStoredFiles(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report.Files > 0 {
+		fmt.Println("Encrypted", report.Files, "stored files of", report.Documents, "documents")
+	}
+
+	managers.StartTextExtraction()
+	managers.StartScheduledBackups()
+
 	r := chi.NewRouter()
 
 	// CORS
@@ -53,12 +91,15 @@ func main() {
 	r.Use(middleware.Logger)
 	r.Use(httprate.LimitByIP(100, 1*time.Minute))
 
-	fileServer := http.FileServer(http.Dir("./docs"))
-	r.Handle("/docs/*", http.StripPrefix("/docs", fileServer))
-
 	r.Route("/api", func(r chi.Router) {
 		routes.AuthRouter(r)
+		routes.OIDCRoutes(r)
+		routes.UsersRoutes(r)
+		routes.KeysRoutes(r)
 		routes.DocsRoutes(r)
+		routes.VerifyRoutes(r)
+		routes.AuditRoutes(r)
+		routes.FilesRoutes(r)
 	})
 
 	r.Handle("/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,468 @@
+package managers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/storage"
+)
+
+var (
+	ErrRestoreNotEmpty = errors.New("the instance already has documents or a login, restore into an empty one")
+	ErrBadBackup       = errors.New("invalid backup")
+)
+
+const backupFormat = 1
+
+// Paths inside a backup archive. The manifest is the last entry, written once the hashes are known.
+const (
+	backupManifestPath = "manifest.json"
+	backupDatabasePath = "database/app.db"
+	backupFilesDir     = "files/"
+	backupKeysDir      = "keys/"
+)
+
+// BackupManifest lists the content of a backup archive with the SHA-256 of every entry
+type BackupManifest struct {
+	Format        int           `json:"format"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	SchemaVersion int           `json:"schemaVersion"`
+	Documents     int           `json:"documents"`
+	Database      BackupEntry   `json:"database"`
+	Files         []BackupEntry `json:"files"`
+	Keys          []BackupEntry `json:"keys,omitempty"`
+	// Missing are the storage keys referenced by documents that were not found in storage
+	Missing []string `json:"missing,omitempty"`
+}
+
+type BackupEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type backupKeyFile struct {
+	name    string
+	path    string
+	private bool
+}
+
+// backupKeyFiles are the key and certificate files of ./data. The certificates are always backed up, the
+// private keys only when asked: an archive holding the master key can decrypt every document.
+func backupKeyFiles() []backupKeyFile {
+	return []backupKeyFile{
+		{name: "master.key", path: config.AppConfig.MasterKeyPath, private: true},
+		{name: "seal-key.pem", path: config.AppConfig.SealKeyPath, private: true},
+		{name: "audit.key", path: config.AppConfig.AuditKeyPath, private: true},
+		{name: "seal-cert.pem", path: config.AppConfig.SealCertPath},
+		{name: "tsa-cert.pem", path: config.AppConfig.TsaCertPath},
+	}
+}
+
+// CreateBackup writes a backup archive to path while the server may be running: a copy of the SQLite database
+// made with its online backup API, then the stored files of the documents in that copy, as stored (so still
+// encrypted), and the key files. Files are only ever added under new keys, so the copy and the files agree.
+func CreateBackup(path string, includeKeys bool) (BackupManifest, error) {
+	manifest := BackupManifest{Format: backupFormat, CreatedAt: time.Now().UTC()}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return manifest, err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(path), ".inkra-backup-")
+	if err != nil {
+		return manifest, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshotPath := filepath.Join(tmpDir, "app.db")
+	if err := database.BackupSQLite(snapshotPath); err != nil {
+		return manifest, err
+	}
+
+	snapshot, err := database.ReadSnapshot(snapshotPath)
+	if err != nil {
+		return manifest, err
+	}
+	manifest.SchemaVersion = snapshot.SchemaVersion
+	manifest.Documents = snapshot.Documents
+
+	partial := path + ".partial"
+	out, err := os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return manifest, err
+	}
+	defer os.Remove(partial)
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	manifest.Database, err = addBackupFile(tw, backupDatabasePath, snapshotPath)
+	if err != nil {
+		return manifest, err
+	}
+
+	keys := uniqueSorted(snapshot.FileKeys)
+	manifest.Files = []BackupEntry{}
+	for _, key := range keys {
+		entry, err := addBackupObject(tw, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			manifest.Missing = append(manifest.Missing, key)
+			continue
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("%s: %w", key, err)
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	for _, keyFile := range backupKeyFiles() {
+		if keyFile.private && !includeKeys {
+			continue
+		}
+		entry, err := addBackupFile(tw, backupKeysDir+keyFile.name, keyFile.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Keys = append(manifest.Keys, entry)
+	}
+
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := tw.WriteHeader(backupHeader(backupManifestPath, int64(len(manifestJson)))); err != nil {
+		return manifest, err
+	}
+	if _, err := tw.Write(manifestJson); err != nil {
+		return manifest, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	if err := gz.Close(); err != nil {
+		return manifest, err
+	}
+	if err := out.Sync(); err != nil {
+		return manifest, err
+	}
+	if err := out.Close(); err != nil {
+		return manifest, err
+	}
+
+	return manifest, os.Rename(partial, path)
+}
+
+func backupHeader(name string, size int64) *tar.Header {
+	return &tar.Header{Name: name, Size: size, Mode: 0600, ModTime: time.Now(), Typeflag: tar.TypeReg}
+}
+
+// addBackupEntry copies size bytes of r into the archive and hashes them
+func addBackupEntry(tw *tar.Writer, name string, r io.Reader, size int64) (BackupEntry, error) {
+	if err := tw.WriteHeader(backupHeader(name, size)); err != nil {
+		return BackupEntry{}, err
+	}
+
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(tw, h), r, size); err != nil {
+		return BackupEntry{}, err
+	}
+
+	return BackupEntry{Path: name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func addBackupFile(tw *tar.Writer, name string, path string) (BackupEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BackupEntry{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return BackupEntry{}, err
+	}
+
+	return addBackupEntry(tw, name, f, info.Size())
+}
+
+func addBackupObject(tw *tar.Writer, key string) (BackupEntry, error) {
+	obj, info, err := config.AppConfig.Storage.Get(key)
+	if err != nil {
+		return BackupEntry{}, err
+	}
+	defer obj.Close()
+
+	return addBackupEntry(tw, backupFilesDir+key, obj, info.Size)
+}
+
+func uniqueSorted(list []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, item := range list {
+		if !seen[item] {
+			seen[item] = true
+			out = append(out, item)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// readBackup calls visit for each file of an archive, in archive order
+func readBackup(path string, visit func(header *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadBackup, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBadBackup, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := visit(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// VerifyBackup reads a whole archive and checks every entry against the hashes of its manifest
+func VerifyBackup(path string) (BackupManifest, error) {
+	var manifest BackupManifest
+	hasManifest := false
+	found := map[string]BackupEntry{}
+
+	err := readBackup(path, func(header *tar.Header, r io.Reader) error {
+		if header.Name == backupManifestPath {
+			hasManifest = true
+			if err := json.NewDecoder(io.LimitReader(r, 64<<20)).Decode(&manifest); err != nil {
+				return fmt.Errorf("%w: manifest: %v", ErrBadBackup, err)
+			}
+			return nil
+		}
+
+		h := sha256.New()
+		n, err := io.Copy(h, r)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBadBackup, err)
+		}
+		found[header.Name] = BackupEntry{Path: header.Name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}
+		return nil
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	if !hasManifest {
+		return manifest, fmt.Errorf("%w: no manifest, the archive is incomplete", ErrBadBackup)
+	}
+	if manifest.Format != backupFormat {
+		return manifest, fmt.Errorf("%w: unknown format %d", ErrBadBackup, manifest.Format)
+	}
+
+	expected := append([]BackupEntry{manifest.Database}, append(manifest.Files, manifest.Keys...)...)
+	for _, entry := range expected {
+		got, ok := found[entry.Path]
+		if !ok {
+			return manifest, fmt.Errorf("%w: %s is missing", ErrBadBackup, entry.Path)
+		}
+		if got.Size != entry.Size || got.SHA256 != entry.SHA256 {
+			return manifest, fmt.Errorf("%w: %s does not match its hash", ErrBadBackup, entry.Path)
+		}
+	}
+
+	return manifest, nil
+}
+
+// RestoreBackup loads a verified archive into an empty instance: the stored files first, then the key
+// files and last the database, which is then migrated to the schema of this version
+func RestoreBackup(path string) (BackupManifest, error) {
+	manifest, err := VerifyBackup(path)
+	if err != nil {
+		return manifest, err
+	}
+
+	if database.DB.Dialect != database.SQLite {
+		return manifest, database.ErrBackupUnsupported
+	}
+
+	empty, err := database.IsEmpty()
+	if err != nil {
+		return manifest, err
+	}
+	if !empty {
+		return manifest, ErrRestoreNotEmpty
+	}
+
+	latest, err := database.LatestSchemaVersion()
+	if err != nil {
+		return manifest, err
+	}
+	if manifest.SchemaVersion > latest {
+		return manifest, database.ErrSchemaTooNew
+	}
+
+	// Only the verified entries are restored
+	listed := map[string]bool{manifest.Database.Path: true}
+	for _, entry := range append(manifest.Files, manifest.Keys...) {
+		listed[entry.Path] = true
+	}
+
+	keyPaths := map[string]string{}
+	for _, keyFile := range backupKeyFiles() {
+		keyPaths[backupKeysDir+keyFile.name] = keyFile.path
+	}
+
+	dbPath := database.DB.Path
+	restoredDb := dbPath + ".restore"
+	defer os.Remove(restoredDb)
+
+	err = readBackup(path, func(header *tar.Header, r io.Reader) error {
+		if !listed[header.Name] {
+			return nil
+		}
+
+		switch {
+		case header.Name == backupDatabasePath:
+			return writeRestoredFile(restoredDb, r, 0644)
+		case strings.HasPrefix(header.Name, backupFilesDir):
+			return config.AppConfig.Storage.Put(strings.TrimPrefix(header.Name, backupFilesDir), r, header.Size)
+		case keyPaths[header.Name] != "":
+			if err := os.MkdirAll(filepath.Dir(keyPaths[header.Name]), 0755); err != nil {
+				return err
+			}
+			return writeRestoredFile(keyPaths[header.Name], r, 0600)
+		}
+		return nil
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	if err := database.DB.Close(); err != nil {
+		return manifest, err
+	}
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return manifest, err
+		}
+	}
+	if err := os.Rename(restoredDb, dbPath); err != nil {
+		return manifest, err
+	}
+
+	if err := database.InitDB(config.AppConfig.DatabaseURL); err != nil {
+		return manifest, err
+	}
+
+	// The chain head recorded for the empty instance does not apply to the restored events
+	return manifest, database.RecordEventChainHead()
+}
+
+func writeRestoredFile(path string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// BackupFileName names the archives made by the backup command and the scheduled backups
+func BackupFileName(t time.Time) string {
+	return "inkra-backup-" + t.UTC().Format("20060102T150405Z") + ".tar.gz"
+}
+
+// StartScheduledBackups makes a backup in BACKUP_DIR every BACKUP_INTERVAL, keeping the last BACKUP_RETENTION
+// archives. Nothing runs when no interval is set.
+func StartScheduledBackups() {
+	interval := config.AppConfig.BackupInterval
+	if interval <= 0 {
+		return
+	}
+	if database.DB.Dialect != database.SQLite {
+		fmt.Println("Scheduled backups are disabled:", database.ErrBackupUnsupported)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			path := filepath.Join(config.AppConfig.BackupDir, BackupFileName(time.Now()))
+			manifest, err := CreateBackup(path, config.AppConfig.BackupIncludeKeys)
+			if err != nil {
+				fmt.Println("Scheduled backup failed", err)
+				continue
+			}
+			fmt.Println("Backed up", manifest.Documents, "documents to", path)
+
+			if err := pruneBackups(config.AppConfig.BackupDir, config.AppConfig.BackupRetention); err != nil {
+				fmt.Println("Could not remove old backups", err)
+			}
+		}
+	}()
+}
+
+// pruneBackups removes the oldest archives of dir beyond the keep most recent ones
+func pruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	archives, err := filepath.Glob(filepath.Join(dir, "inkra-backup-*.tar.gz"))
+	if err != nil {
+		return err
+	}
+
+	// The names sort by time
+	sort.Strings(archives)
+	for len(archives) > keep {
+		if err := os.Remove(archives[0]); err != nil {
+			return err
+		}
+		archives = archives[1:]
+	}
+	return nil
+}
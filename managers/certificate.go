@@ -0,0 +1,163 @@
+package managers
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/pdf"
+)
+
+func valueOrEmpty(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func formatTimestamp(value string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format("2006-01-02 15:04:05 UTC")
+		}
+	}
+	return value
+}
+
+// BuildCertificate renders the certificate of completion for a signed document: document details,
+// file hashes, signer details and the audit event timeline
+func BuildCertificate(doc database.Document) ([]byte, error) {
+	events, err := database.GetDocEvents(doc.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := database.VerifyEventChain(doc.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, err := database.ListSigners(doc.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	d := pdf.NewTextDocument("Certificate of Completion - " + doc.Title)
+
+	d.Text(pdf.HelveticaBold, 20, 0, "Certificate of Completion")
+	d.Text(pdf.Helvetica, 10, 0, "Issued by Inkra on "+time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+	d.Space(16)
+
+	d.Text(pdf.HelveticaBold, 13, 0, "Document")
+	d.Space(4)
+	d.Field("Title", doc.Title, pdf.Helvetica)
+	d.Field("Document ID", doc.Id, pdf.Courier)
+	d.Field("Original file name", doc.OriginalName, pdf.Helvetica)
+	d.Field("Created at", formatTimestamp(doc.CreatedAt), pdf.Helvetica)
+	d.Space(8)
+
+	d.Text(pdf.HelveticaBold, 13, 0, "File hashes (SHA-256)")
+	d.Space(4)
+	d.Field("Original document", valueOrEmpty(doc.OriginalHash), pdf.Courier)
+	if doc.SubmittedHash != nil && valueOrEmpty(doc.SubmittedHash) != valueOrEmpty(doc.SignedHash) {
+		d.Field("Submitted by signer", valueOrEmpty(doc.SubmittedHash), pdf.Courier)
+		d.Field("Signed document (sealed)", valueOrEmpty(doc.SignedHash), pdf.Courier)
+	} else {
+		d.Field("Signed document", valueOrEmpty(doc.SignedHash), pdf.Courier)
+	}
+	d.Space(8)
+
+	if len(signers) == 0 {
+		d.Text(pdf.HelveticaBold, 13, 0, "Signer")
+		d.Space(4)
+		d.Field("Name", valueOrEmpty(doc.SignedByMetadata), pdf.Helvetica)
+		d.Field("IP address", valueOrEmpty(doc.SignedByIp), pdf.Helvetica)
+		d.Field("Signed at", formatTimestamp(valueOrEmpty(doc.SignedAt)), pdf.Helvetica)
+		d.Field("Remarks", valueOrEmpty(doc.Remarks), pdf.Helvetica)
+		d.Space(8)
+	}
+
+	for _, signer := range signers {
+		d.Text(pdf.HelveticaBold, 13, 0, fmt.Sprintf("Signer %d of %d", signer.Order, len(signers)))
+		d.Space(4)
+		d.Field("Name", signer.Name, pdf.Helvetica)
+		d.Field("Email", valueOrEmpty(signer.Email), pdf.Helvetica)
+		d.Field("Signed as", valueOrEmpty(signer.Metadata), pdf.Helvetica)
+		d.Field("IP address", valueOrEmpty(signer.Ip), pdf.Helvetica)
+		d.Field("Signed at", formatTimestamp(valueOrEmpty(signer.SignedAt)), pdf.Helvetica)
+		d.Field("Submitted file", valueOrEmpty(signer.SubmittedHash), pdf.Courier)
+		d.Field("Remarks", valueOrEmpty(signer.Remarks), pdf.Helvetica)
+		d.Space(8)
+	}
+
+	if doc.TimestampedAt != nil {
+		d.Text(pdf.HelveticaBold, 13, 0, "Trusted timestamp (RFC 3161)")
+		d.Space(4)
+		d.Field("Time", formatTimestamp(valueOrEmpty(doc.TimestampedAt)), pdf.Helvetica)
+		d.Field("Authority", valueOrEmpty(doc.TimestampAuthority), pdf.Helvetica)
+		d.Space(8)
+	}
+
+	d.Text(pdf.HelveticaBold, 13, 0, "Event timeline")
+	d.Space(4)
+	for _, e := range events {
+		d.Text(pdf.HelveticaBold, 9, 0, fmt.Sprintf("%s  %s", formatTimestamp(e.CreatedAt), e.Event))
+
+		line := "by " + valueOrEmpty(e.Actor)
+		if ip := valueOrEmpty(e.Ip); ip != "" {
+			line += " from " + ip
+		}
+		if detail := valueOrEmpty(e.Detail); detail != "" {
+			line += " - " + detail
+		}
+		d.Text(pdf.Helvetica, 9, 12, line)
+		if ua := valueOrEmpty(e.UserAgent); ua != "" {
+			d.Text(pdf.Helvetica, 8, 12, ua)
+		}
+		d.Space(4)
+	}
+	d.Space(8)
+
+	d.Text(pdf.HelveticaBold, 13, 0, "Audit chain")
+	d.Space(4)
+	status := "Intact"
+	if !chain.Valid {
+		status = "Broken at event " + fmt.Sprint(chain.Break.EventId) + ": " + chain.Break.Reason
+	}
+	d.Field("Status", status, pdf.Helvetica)
+	d.Field("Latest event hash", chain.Head, pdf.Courier)
+
+	return d.Bytes(), nil
+}
+
+// GenerateCertificate stores the certificate of completion next to the signed file and records it on the document
+func GenerateCertificate(doc database.Document) (string, string, error) {
+	data, err := BuildCertificate(doc)
+	if err != nil {
+		return "", "", err
+	}
+
+	dataKey, err := DocDataKey(&doc)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := "signed/certificate_" + doc.Id + ".pdf"
+	if err := lib.SaveFile(key, data, dataKey); err != nil {
+		return "", "", err
+	}
+
+	hash, err := lib.HashReader(bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = database.DB.Exec(
+		`UPDATE DOCUMENTS SET certificate_key = ?, certificate_hash = ? WHERE id = ?`,
+		key, hash, doc.Id,
+	)
+
+	return key, hash, err
+}
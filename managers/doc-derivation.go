@@ -0,0 +1,146 @@
+package managers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/fbn776/inkra/pdf"
+)
+
+// Error codes returned when a signed pdf is not derived from the stored original
+const (
+	ErrCodeSignedPdfUnreadable  = "SIGNED_PDF_UNREADABLE"
+	ErrCodeSignedPdfNotDerived  = "SIGNED_PDF_NOT_DERIVED"
+	ErrCodeSignedPdfPageCount   = "SIGNED_PDF_PAGE_COUNT_CHANGED"
+	ErrCodeSignedPdfPageAltered = "SIGNED_PDF_PAGE_ALTERED"
+)
+
+type DerivationError struct {
+	Code    string
+	Message string
+}
+
+func (e *DerivationError) Error() string {
+	return e.Message
+}
+
+// resourceCategories are compared key by key, the signed copy may add entries (fonts and
+// appearance xobjects for the signature) but must keep the original ones unchanged
+var resourceCategories = []pdf.Name{"Font", "XObject", "ExtGState", "ColorSpace", "Pattern", "Shading", "Properties"}
+
+// CheckSignedDerivation makes sure the signed pdf is the original with additions only. Every original
+// page must still be present with unchanged content. Content streams may only be appended after the
+// original ones when the original bytes are a prefix of the signed file (incremental update), other
+// files may only add annotations. Returns whether the signed file is an incremental update.
+func CheckSignedDerivation(original, signed []byte) (bool, error) {
+	incremental := len(signed) > len(original) && bytes.Equal(signed[:len(original)], original)
+
+	origReader, origErr := pdf.Open(original)
+	if origErr != nil {
+		// The original can not be inspected, so only an incremental update can be trusted
+		if incremental {
+			return true, nil
+		}
+		return false, &DerivationError{
+			Code:    ErrCodeSignedPdfNotDerived,
+			Message: "Signed pdf is not an incremental update of the original document",
+		}
+	}
+
+	signedReader, signedErr := pdf.Open(signed)
+	if signedErr != nil {
+		return incremental, &DerivationError{
+			Code:    ErrCodeSignedPdfUnreadable,
+			Message: "Signed pdf could not be read",
+		}
+	}
+
+	origPages, origPagesErr := origReader.Pages()
+	if origPagesErr != nil {
+		if incremental {
+			return true, nil
+		}
+		return false, &DerivationError{
+			Code:    ErrCodeSignedPdfNotDerived,
+			Message: "Signed pdf is not an incremental update of the original document",
+		}
+	}
+
+	signedPages, signedPagesErr := signedReader.Pages()
+	if signedPagesErr != nil {
+		return incremental, &DerivationError{
+			Code:    ErrCodeSignedPdfUnreadable,
+			Message: "Signed pdf pages could not be read",
+		}
+	}
+
+	if len(signedPages) != len(origPages) {
+		return incremental, &DerivationError{
+			Code:    ErrCodeSignedPdfPageCount,
+			Message: fmt.Sprintf("Signed pdf has %d pages, the original has %d", len(signedPages), len(origPages)),
+		}
+	}
+
+	for i := range origPages {
+		if err := comparePage(origReader, origPages[i], signedReader, signedPages[i], incremental); err != nil {
+			return incremental, &DerivationError{
+				Code:    ErrCodeSignedPdfPageAltered,
+				Message: fmt.Sprintf("Page %d of the signed pdf differs from the original: %s", i+1, err.Error()),
+			}
+		}
+	}
+
+	return incremental, nil
+}
+
+func comparePage(origReader *pdf.Reader, orig pdf.Page, signedReader *pdf.Reader, signed pdf.Page, incremental bool) error {
+	for _, key := range []pdf.Name{"MediaBox", "Rotate"} {
+		if !bytes.Equal(origReader.Fingerprint(orig.Dict[key]), signedReader.Fingerprint(signed.Dict[key])) {
+			return fmt.Errorf("%s changed", key)
+		}
+	}
+
+	origContents, origErr := origReader.Contents(orig)
+	if origErr != nil {
+		return origErr
+	}
+	signedContents, signedErr := signedReader.Contents(signed)
+	if signedErr != nil {
+		return errors.New("content could not be read")
+	}
+
+	// The original streams must come first and unchanged, anything drawn before them could hide or move the
+	// original content
+	if len(signedContents) < len(origContents) {
+		return errors.New("content removed")
+	}
+	for j := range origContents {
+		if !bytes.Equal(origContents[j], signedContents[j]) {
+			return errors.New("content changed")
+		}
+	}
+	if len(signedContents) > len(origContents) && !incremental {
+		return errors.New("content added outside an incremental update")
+	}
+
+	origResources, _ := origReader.Resolve(orig.Dict["Resources"]).(pdf.Dict)
+	signedResources, _ := signedReader.Resolve(signed.Dict["Resources"]).(pdf.Dict)
+
+	for _, category := range resourceCategories {
+		origEntries, _ := origReader.Resolve(origResources[category]).(pdf.Dict)
+		signedEntries, _ := signedReader.Resolve(signedResources[category]).(pdf.Dict)
+
+		for name, value := range origEntries {
+			signedValue, ok := signedEntries[name]
+			if !ok {
+				return fmt.Errorf("resource %s/%s removed", category, name)
+			}
+			if !bytes.Equal(origReader.Fingerprint(value), signedReader.Fingerprint(signedValue)) {
+				return fmt.Errorf("resource %s/%s changed", category, name)
+			}
+		}
+	}
+
+	return nil
+}
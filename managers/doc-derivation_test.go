@@ -0,0 +1,154 @@
+package managers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fbn776/inkra/pdf"
+)
+
+// testPages are the parts of a document of two pages, changed by the cases to make a copy that is not derived
+type testPages struct {
+	texts    []string
+	mediaBox pdf.Array
+	baseFont pdf.Name
+	// prepend and append are content streams drawn on the first page before and after its text
+	prepend, append []byte
+	annotation      bool
+}
+
+func defaultPages() testPages {
+	return testPages{
+		texts:    []string{"Lease agreement", "Signatures"},
+		mediaBox: pdf.Array{int64(0), int64(0), int64(595), int64(842)},
+		baseFont: "Helvetica",
+	}
+}
+
+func (p testPages) bytes() []byte {
+	w := pdf.NewWriter()
+	catalog, tree := w.Alloc(), w.Alloc()
+	resources := w.Add(pdf.Dict{"Font": pdf.Dict{"F1": w.Add(pdf.Dict{"Type": pdf.Name("Font"), "Subtype": pdf.Name("Type1"), "BaseFont": p.baseFont})}})
+
+	kids := pdf.Array{}
+	for i, text := range p.texts {
+		contents := pdf.Array{w.AddStream(pdf.Dict{}, []byte("BT /F1 12 Tf 72 720 Td ("+text+") Tj ET"), true)}
+		if i == 0 && p.prepend != nil {
+			contents = append(pdf.Array{w.AddStream(pdf.Dict{}, p.prepend, false)}, contents...)
+		}
+		if i == 0 && p.append != nil {
+			contents = append(contents, w.AddStream(pdf.Dict{}, p.append, false))
+		}
+
+		page := pdf.Dict{"Type": pdf.Name("Page"), "Parent": tree, "MediaBox": p.mediaBox, "Resources": resources, "Contents": contents}
+		if p.annotation {
+			page["Annots"] = pdf.Array{w.Add(pdf.Dict{"Type": pdf.Name("Annot"), "Subtype": pdf.Name("Text"), "Rect": pdf.Array{int64(0), int64(0), int64(10), int64(10)}})}
+		}
+		kids = append(kids, w.Add(page))
+	}
+
+	w.Set(tree, pdf.Dict{"Type": pdf.Name("Pages"), "Kids": kids, "Count": int64(len(kids))})
+	w.Set(catalog, pdf.Dict{"Type": pdf.Name("Catalog"), "Pages": tree})
+	return w.Finish(pdf.Dict{"Root": catalog})
+}
+
+// update appends an incremental update to the original, changing its first page
+func update(t *testing.T, original []byte, change func(w *pdf.Writer, r *pdf.Reader, page pdf.Dict)) []byte {
+	t.Helper()
+
+	r, err := pdf.Open(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := r.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := r.Resolve(pages[0].Ref).(pdf.Dict)
+
+	w := pdf.NewIncrementalWriter(r)
+	changed := pdf.Dict{}
+	for k, v := range page {
+		changed[k] = v
+	}
+	change(w, r, changed)
+	w.Set(pages[0].Ref, changed)
+
+	catalog, catalogRef := r.Catalog()
+	w.Set(catalogRef, catalog)
+	return w.Finish(pdf.Dict{"Root": catalogRef})
+}
+
+func TestCheckSignedDerivation(t *testing.T) {
+	original := defaultPages().bytes()
+	stamp := []byte("BT /F9 8 Tf 72 72 Td (Signed by Ada Lovelace) Tj ET")
+
+	variant := func(change func(p *testPages)) []byte {
+		p := defaultPages()
+		change(&p)
+		return p.bytes()
+	}
+
+	signed, err := pdf.Sign(original, pdf.SignatureOptions{Name: "Inkra"}, func([]byte) ([]byte, error) {
+		return []byte{0x30, 0x00}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		original    []byte
+		signed      []byte
+		incremental bool
+		code        string
+	}{
+		{"unchanged", original, original, false, ""},
+		{"rewritten with annotations", original, variant(func(p *testPages) { p.annotation = true }), false, ""},
+		{"signature field", original, signed, true, ""},
+		{"stamp and font appended", original, update(t, original, func(w *pdf.Writer, r *pdf.Reader, page pdf.Dict) {
+			contents, _ := r.Resolve(page["Contents"]).(pdf.Array)
+			page["Contents"] = append(append(pdf.Array{}, contents...), w.AddStream(pdf.Dict{}, stamp, true))
+			resources, _ := r.Resolve(page["Resources"]).(pdf.Dict)
+			fonts, _ := r.Resolve(resources["Font"]).(pdf.Dict)
+			page["Resources"] = pdf.Dict{"Font": pdf.Dict{"F1": fonts["F1"], "F9": w.Add(pdf.Dict{"Type": pdf.Name("Font"), "Subtype": pdf.Name("Type1"), "BaseFont": pdf.Name("Courier")})}}
+		}), true, ""},
+		{"stamp drawn first", original, update(t, original, func(w *pdf.Writer, r *pdf.Reader, page pdf.Dict) {
+			contents, _ := r.Resolve(page["Contents"]).(pdf.Array)
+			page["Contents"] = append(pdf.Array{w.AddStream(pdf.Dict{}, stamp, true)}, contents...)
+		}), true, ErrCodeSignedPdfPageAltered},
+		{"font replaced", original, update(t, original, func(w *pdf.Writer, r *pdf.Reader, page pdf.Dict) {
+			page["Resources"] = pdf.Dict{"Font": pdf.Dict{"F1": w.Add(pdf.Dict{"Type": pdf.Name("Font"), "Subtype": pdf.Name("Type1"), "BaseFont": pdf.Name("Symbol")})}}
+		}), true, ErrCodeSignedPdfPageAltered},
+		{"rotated", original, update(t, original, func(w *pdf.Writer, r *pdf.Reader, page pdf.Dict) {
+			page["Rotate"] = int64(180)
+		}), true, ErrCodeSignedPdfPageAltered},
+		{"rewritten with a stamp", original, variant(func(p *testPages) { p.append = stamp }), false, ErrCodeSignedPdfPageAltered},
+		{"other text", original, variant(func(p *testPages) { p.texts[0] = "Lease agreement, amended" }), false, ErrCodeSignedPdfPageAltered},
+		{"other media box", original, variant(func(p *testPages) { p.mediaBox = pdf.Array{int64(0), int64(0), int64(612), int64(792)} }), false, ErrCodeSignedPdfPageAltered},
+		{"other font", original, variant(func(p *testPages) { p.baseFont = "Courier" }), false, ErrCodeSignedPdfPageAltered},
+		{"page added", original, variant(func(p *testPages) { p.texts = append(p.texts, "Annex") }), false, ErrCodeSignedPdfPageCount},
+		{"page removed", original, variant(func(p *testPages) { p.texts = p.texts[:1] }), false, ErrCodeSignedPdfPageCount},
+		{"not a pdf", original, []byte("signed.pdf"), false, ErrCodeSignedPdfUnreadable},
+		{"unreadable original, incremental update", []byte("%PDF-1.7 broken"), []byte("%PDF-1.7 broken and more"), true, ""},
+		{"unreadable original, other file", []byte("%PDF-1.7 broken"), original, false, ErrCodeSignedPdfNotDerived},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incremental, err := CheckSignedDerivation(tt.original, tt.signed)
+			if incremental != tt.incremental {
+				t.Errorf("incremental = %v, want %v", incremental, tt.incremental)
+			}
+
+			var derivationErr *DerivationError
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+			} else if !errors.As(err, &derivationErr) || derivationErr.Code != tt.code {
+				t.Errorf("err = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/encryption"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/storage"
+)
+
+var (
+	ErrNoMasterKey      = errors.New("encryption is enabled but no master key is configured")
+	ErrRewrapIncomplete = errors.New("some data keys could not be re-wrapped")
+)
+
+// masterKeys lists the current master key first, then the previous ones
+func masterKeys() []*encryption.MasterKey {
+	return append([]*encryption.MasterKey{config.AppConfig.MasterKey}, config.AppConfig.PreviousMasterKeys...)
+}
+
+// NewDataKey generates the data key of a new document and returns it along with its wrapped form to
+// store. Both are nil when encryption is disabled.
+func NewDataKey(docId string) ([]byte, *string, error) {
+	if !config.AppConfig.EncryptionEnabled {
+		return nil, nil, nil
+	}
+	if config.AppConfig.MasterKey == nil {
+		return nil, nil, ErrNoMasterKey
+	}
+
+	key, err := encryption.GenerateDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrapped, err := config.AppConfig.MasterKey.Wrap(key, docId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, &wrapped, nil
+}
+
+// DocDataKey unwraps the data key of a document. Documents stored while encryption was disabled have
+// none and their files are kept in plaintext until EncryptStoredFiles runs.
+func DocDataKey(doc *database.Document) ([]byte, error) {
+	if doc.DataKey == nil {
+		return nil, nil
+	}
+	if config.AppConfig.MasterKey == nil {
+		return nil, ErrNoMasterKey
+	}
+
+	return encryption.Unwrap(*doc.DataKey, doc.Id, masterKeys()...)
+}
+
+// EncryptReport counts the documents and files handled by EncryptStoredFiles
+type EncryptReport struct {
+	Documents int
+	Files     int
+	Missing   int
+}
+
+// EncryptStoredFiles encrypts in place the files of documents stored before encryption was enabled.
+// Only documents without a data key are handled unless all is set, in which case the files of every
+// document are checked. The data key is stored before any file is rewritten, so no key is lost when a
+// run is interrupted. The files left in plaintext can not be read until a run with all set encrypts them.
+func EncryptStoredFiles(all bool) (EncryptReport, error) {
+	report := EncryptReport{}
+
+	if !config.AppConfig.EncryptionEnabled {
+		return report, nil
+	}
+	if config.AppConfig.MasterKey == nil {
+		return report, ErrNoMasterKey
+	}
+
+	query := "SELECT id FROM DOCUMENTS WHERE data_key IS NULL"
+	if all {
+		query = "SELECT id FROM DOCUMENTS"
+	}
+
+	rows, err := database.DB.Query(query)
+	if err != nil {
+		return report, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return report, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	for _, id := range ids {
+		doc, err := database.Docs.Get(id)
+		if err != nil {
+			return report, err
+		}
+
+		if doc.DataKey == nil {
+			_, wrapped, err := NewDataKey(doc.Id)
+			if err != nil {
+				return report, err
+			}
+
+			// Another process may have keyed the document in the meantime, the stored key wins
+			if _, err := database.DB.Exec("UPDATE DOCUMENTS SET data_key = ? WHERE id = ? AND data_key IS NULL", *wrapped, doc.Id); err != nil {
+				return report, err
+			}
+			if doc, err = database.Docs.Get(id); err != nil {
+				return report, err
+			}
+		}
+
+		key, err := DocDataKey(&doc)
+		if err != nil {
+			return report, fmt.Errorf("document %s: %w", doc.Id, err)
+		}
+
+		changed := false
+		for _, fileKey := range []*string{&doc.OriginalKey, doc.SignedKey, doc.CertificateKey} {
+			if fileKey == nil || *fileKey == "" {
+				continue
+			}
+
+			encrypted, err := lib.EncryptFileInPlace(*fileKey, key)
+			if errors.Is(err, storage.ErrNotFound) {
+				fmt.Println("Missing file", *fileKey, "of document", doc.Id)
+				report.Missing++
+				continue
+			}
+			if err != nil {
+				return report, fmt.Errorf("document %s: %w", doc.Id, err)
+			}
+			if encrypted {
+				report.Files++
+				changed = true
+			}
+		}
+
+		if changed {
+			report.Documents++
+		}
+	}
+
+	return report, nil
+}
+
+// RewrapReport counts the data keys and two-factor secrets handled by RewrapDataKeys
+type RewrapReport struct {
+	Rewrapped int
+	Current   int
+	Failed    int
+}
+
+// RewrapDataKeys wraps every data key, and the two-factor secrets of the users, with the current master key.
+// Keys wrapped by a previous master key are unwrapped with the matching key from MASTER_KEY_PREVIOUS, which
+// can be dropped afterwards. The files themselves are not rewritten.
+func RewrapDataKeys() (RewrapReport, error) {
+	report := RewrapReport{}
+
+	if config.AppConfig.MasterKey == nil {
+		return report, ErrNoMasterKey
+	}
+
+	rows, err := database.DB.Query("SELECT id, data_key FROM DOCUMENTS WHERE data_key IS NOT NULL")
+	if err != nil {
+		return report, err
+	}
+
+	wrappedKeys := map[string]string{}
+	for rows.Next() {
+		var id, wrapped string
+		if err := rows.Scan(&id, &wrapped); err != nil {
+			rows.Close()
+			return report, err
+		}
+		wrappedKeys[id] = wrapped
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	for id, wrapped := range wrappedKeys {
+		if keyId, _ := encryption.WrappedKeyID(wrapped); keyId == config.AppConfig.MasterKey.ID {
+			report.Current++
+			continue
+		}
+
+		key, err := encryption.Unwrap(wrapped, id, masterKeys()...)
+		if err != nil {
+			fmt.Println("Cannot unwrap the data key of document", id+":", err)
+			report.Failed++
+			continue
+		}
+
+		rewrapped, err := config.AppConfig.MasterKey.Wrap(key, id)
+		if err != nil {
+			return report, err
+		}
+
+		if _, err := database.DB.Exec("UPDATE DOCUMENTS SET data_key = ? WHERE id = ? AND data_key = ?", rewrapped, id, wrapped); err != nil {
+			return report, err
+		}
+		report.Rewrapped++
+	}
+
+	if err := rewrapTOTPSecrets(&report); err != nil {
+		return report, err
+	}
+
+	if report.Failed > 0 {
+		return report, ErrRewrapIncomplete
+	}
+	return report, nil
+}
@@ -0,0 +1,105 @@
+package managers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+)
+
+// Longest email and user agent kept in the login attempts, anything can be sent for an unknown user
+const (
+	maxAttemptEmail     = 320
+	maxAttemptUserAgent = 512
+)
+
+// AccountLockedError is returned for a login to a user locked out after too many failed logins
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account locked after too many failed logins, until %s", e.Until.Format(time.RFC3339))
+}
+
+// CheckLoginLock returns an AccountLockedError while the user is locked out. The password of a locked user is
+// not checked at all, so guessing goes no faster from many IPs.
+func CheckLoginLock(user database.User) error {
+	if user.LockedUntil == nil {
+		return nil
+	}
+
+	until, err := time.Parse(time.RFC3339, *user.LockedUntil)
+	if err != nil || !until.After(time.Now()) {
+		return nil
+	}
+	return &AccountLockedError{Until: until}
+}
+
+// lockoutDuration is how long a user is locked out after the given number of failed logins in a row, none below
+// LOGIN_LOCKOUT_THRESHOLD, then LOGIN_LOCKOUT_DURATION doubled with each further failure up to LOGIN_LOCKOUT_MAX
+func lockoutDuration(failures int) time.Duration {
+	if failures < config.AppConfig.LoginLockoutThreshold {
+		return 0
+	}
+
+	duration := config.AppConfig.LoginLockoutDuration
+	for i := config.AppConfig.LoginLockoutThreshold; i < failures && duration < config.AppConfig.LoginLockoutMax; i++ {
+		duration *= 2
+	}
+	return min(duration, config.AppConfig.LoginLockoutMax)
+}
+
+// RecordLogin logs a login attempt with its outcome, user is nil when the email matches no user. A wrong password
+// or code counts toward the lockout of the user, a successful login forgets its failures. Errors are only
+// printed, they must not change the answer to the login.
+func RecordLogin(user *database.User, email string, ip string, userAgent string, outcome string) {
+	userAgent = truncate(userAgent, maxAttemptUserAgent)
+	attempt := database.LoginAttempt{
+		Email:     truncate(email, maxAttemptEmail),
+		Ip:        &ip,
+		UserAgent: &userAgent,
+		Outcome:   outcome,
+	}
+	if user != nil {
+		attempt.UserId = &user.Id
+		attempt.Email = user.Email
+	}
+
+	if err := database.RecordLoginAttempt(attempt, config.AppConfig.LoginAttemptsRetention); err != nil {
+		fmt.Println("ERROR: ", err)
+	}
+
+	if user == nil {
+		return
+	}
+
+	switch outcome {
+	case database.LoginWrongPassword, database.LoginWrongCode:
+		failures, err := database.AddLoginFailure(user.Id)
+		if err != nil {
+			fmt.Println("ERROR: ", err)
+			return
+		}
+		if duration := lockoutDuration(failures); duration > 0 {
+			if err := database.LockUser(user.Id, time.Now().Add(duration)); err != nil {
+				fmt.Println("ERROR: ", err)
+			}
+		}
+
+	case database.LoginSuccess:
+		if user.FailedLogins > 0 || user.LockedUntil != nil {
+			if err := database.UnlockUser(user.Id); err != nil {
+				fmt.Println("ERROR: ", err)
+			}
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
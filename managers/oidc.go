@@ -0,0 +1,228 @@
+package managers
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/oidc"
+	"github.com/google/uuid"
+)
+
+// OIDCStateTTL is how long the user has to log in at the provider
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrOIDCDisabled        = errors.New("single sign-on is not configured")
+	ErrOIDCNoRole          = errors.New("the user is in no group allowed to use Inkra")
+	ErrOIDCNoAccount       = errors.New("the user has no Inkra account")
+	ErrOIDCEmailUnverified = errors.New("the provider gave no verified email for the user")
+)
+
+// roleRanks orders the roles, a user in several mapped groups gets the highest of their roles
+var roleRanks = map[string]int{database.RoleViewer: 1, database.RoleEditor: 2, database.RoleOwner: 3}
+
+var (
+	oidcProviderOnce sync.Once
+	oidcProvider     *oidc.Provider
+)
+
+// OIDCEnabled tells whether users can log in with the single sign-on provider
+func OIDCEnabled() bool {
+	return config.AppConfig.OIDCIssuer != ""
+}
+
+// provider is the configured provider, whose metadata and keys are cached for the life of the process
+func provider() *oidc.Provider {
+	oidcProviderOnce.Do(func() {
+		oidcProvider = oidc.NewProvider(
+			config.AppConfig.OIDCIssuer,
+			config.AppConfig.OIDCClientID,
+			config.AppConfig.OIDCClientSecret,
+			config.AppConfig.OIDCRedirectURL,
+			config.AppConfig.OIDCScopes,
+		)
+	})
+	return oidcProvider
+}
+
+// StartOIDCLogin returns the url of the provider to send the user to, and the state of the login, which the
+// browser must give back along with it. The state, the nonce and the PKCE verifier of the login are kept until the
+// provider sends the user back to CompleteOIDCLogin.
+func StartOIDCLogin() (string, string, error) {
+	if !OIDCEnabled() {
+		return "", "", ErrOIDCDisabled
+	}
+
+	state, err := lib.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := lib.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	url, err := provider().AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := database.CreateOIDCState(lib.HashToken(state), verifier, nonce, time.Now().Add(OIDCStateTTL)); err != nil {
+		return "", "", err
+	}
+
+	return url, state, nil
+}
+
+// CompleteOIDCLogin exchanges the authorization code the provider sent the user back with, and opens a session
+// for the user of the ID token. A user with two-factor authentication who did not log in with several factors at
+// the provider gets a challenge instead, completed with CompleteLoginChallenge. It fails with
+// database.ErrOIDCStateNotFound for an unknown, expired or completed login.
+func CompleteOIDCLogin(state string, code string, ip string, userAgent string) (database.User, SessionTokens, *LoginChallenge, error) {
+	if !OIDCEnabled() {
+		return database.User{}, SessionTokens{}, nil, ErrOIDCDisabled
+	}
+
+	verifier, nonce, err := database.TakeOIDCState(lib.HashToken(state))
+	if err != nil {
+		return database.User{}, SessionTokens{}, nil, err
+	}
+
+	token, err := provider().Exchange(code, verifier, nonce)
+	if err != nil {
+		return database.User{}, SessionTokens{}, nil, err
+	}
+
+	user, err := oidcUser(token)
+	if err != nil {
+		return database.User{}, SessionTokens{}, nil, err
+	}
+	if user.Disabled {
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginDisabled)
+		return database.User{}, SessionTokens{}, nil, ErrAccountDisabled
+	}
+
+	// The session is only opened once a code is given to POST /login/2fa, as for a password login
+	if user.TwoFactorEnabled && !oidcMultiFactor(token) {
+		challenge, err := NewLoginChallenge(user)
+		if err != nil {
+			return database.User{}, SessionTokens{}, nil, err
+		}
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginCodeRequired)
+		return user, SessionTokens{}, &challenge, nil
+	}
+
+	tokens, err := StartSession(user, ip, userAgent)
+	if err == nil {
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginSuccess)
+	}
+	return user, tokens, nil, err
+}
+
+// oidcMultiFactor tells whether the provider reports that the user logged in with several factors, through an amr
+// claim holding "mfa" (RFC 8176) or one of the acr values of OIDC_MFA_ACR_VALUES
+func oidcMultiFactor(token *oidc.IDToken) bool {
+	if slices.Contains(token.Strings("amr"), "mfa") {
+		return true
+	}
+	acr, _ := token.Claims["acr"].(string)
+	return acr != "" && slices.Contains(config.AppConfig.OIDCMFAAcrValues, acr)
+}
+
+// groupsRole is the highest role mapped to the groups of a user, empty when none of them is mapped
+func groupsRole(groups []string) string {
+	role := ""
+	for _, group := range groups {
+		if mapped, ok := config.AppConfig.OIDCRoleMapping[group]; ok && roleRanks[mapped] > roleRanks[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// oidcUser returns the user of an ID token. A user logging in for the first time is linked to the existing
+// user with the same email, or created when OIDC_AUTO_PROVISION allows it. The role follows the groups of the
+// user at each login, users in no mapped group keep theirs or are created with OIDC_DEFAULT_ROLE.
+func oidcUser(token *oidc.IDToken) (database.User, error) {
+	role := groupsRole(token.Strings(config.AppConfig.OIDCGroupsClaim))
+	if role == "" && config.AppConfig.OIDCDefaultRole == "" {
+		return database.User{}, ErrOIDCNoRole
+	}
+
+	user, err := database.GetUserByOIDC(config.AppConfig.OIDCIssuer, token.Subject)
+	if errors.Is(err, database.ErrUserNotFound) {
+		user, err = linkOIDCUser(token, role)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if role != "" && role != user.Role && !user.Disabled {
+		err := database.UpdateUserAccess(user.Id, role, user.Disabled)
+		// The last owner keeps its role rather than nobody being able to manage the users
+		if err != nil && !errors.Is(err, database.ErrLastOwner) {
+			return database.User{}, err
+		}
+		if err == nil {
+			user.Role = role
+		}
+	}
+
+	return user, nil
+}
+
+// linkOIDCUser links the user with the email of the token to its subject, or creates it
+func linkOIDCUser(token *oidc.IDToken, role string) (database.User, error) {
+	// Linking trusts the email, so it must have been verified by the provider
+	if token.Email == "" || !token.EmailVerified {
+		return database.User{}, ErrOIDCEmailUnverified
+	}
+
+	issuer := config.AppConfig.OIDCIssuer
+
+	user, err := database.GetUserByEmail(token.Email)
+	if err == nil {
+		if user.OIDCSubject != nil {
+			// Linked to another subject of the provider
+			return database.User{}, database.ErrEmailTaken
+		}
+		if err := database.LinkOIDC(user.Id, issuer, token.Subject); err != nil {
+			return database.User{}, err
+		}
+		return database.GetUser(user.Id)
+	}
+	if !errors.Is(err, database.ErrUserNotFound) {
+		return database.User{}, err
+	}
+
+	if !config.AppConfig.OIDCAutoProvision {
+		return database.User{}, ErrOIDCNoAccount
+	}
+
+	if role == "" {
+		role = config.AppConfig.OIDCDefaultRole
+	}
+
+	user = database.User{
+		Id:          uuid.New().String(),
+		Email:       token.Email,
+		Name:        token.Name,
+		Role:        role,
+		OIDCIssuer:  &issuer,
+		OIDCSubject: &token.Subject,
+	}
+	if err := database.CreateUser(&user); err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,62 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fbn776/inkra/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password accepted for a user
+const MinPasswordLength = 8
+
+var (
+	ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	ErrWrongPassword    = errors.New("current password is wrong")
+	// ErrNoPassword is returned for users who only log in with single sign-on
+	ErrNoPassword = errors.New("the user has no password")
+)
+
+// CheckPassword tells whether password is the one of a user
+func CheckPassword(user database.User, password string) bool {
+	return user.PasswordHash != nil && bcrypt.CompareHashAndPassword([]byte(*user.PasswordHash), []byte(password)) == nil
+}
+
+// ChangePassword replaces the password of a user who knows the current one, and ends all its sessions. A wrong
+// current password counts toward the lockout as a failed login, so a stolen session can not be used to guess it.
+func ChangePassword(user database.User, currentPassword string, newPassword string, ip string, userAgent string) error {
+	if user.PasswordHash == nil {
+		return ErrNoPassword
+	}
+	if err := CheckLoginLock(user); err != nil {
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginLocked)
+		return err
+	}
+	if !CheckPassword(user, currentPassword) {
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginWrongPassword)
+		return ErrWrongPassword
+	}
+
+	_, err := SetPassword(user.Id, newPassword)
+	return err
+}
+
+// SetPassword replaces the password of a user, ending a pending invite, and ends all its sessions so that
+// tokens obtained with the old password stop working. It returns the number of sessions ended.
+func SetPassword(userId string, password string) (int64, error) {
+	if len(password) < MinPasswordLength {
+		return 0, ErrPasswordTooShort
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := database.SetUserPassword(userId, string(hash)); err != nil {
+		return 0, err
+	}
+
+	return database.RevokeUserSessions(userId)
+}
@@ -0,0 +1,110 @@
+package managers
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fbn776/inkra/cms"
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/pdf"
+)
+
+var ErrSealDisabled = errors.New("seal is disabled")
+
+type sealKey struct {
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+	key   crypto.Signer
+}
+
+var (
+	sealMu     sync.Mutex
+	cachedSeal *sealKey
+)
+
+// loadSealKey reads the configured seal certificate and key once
+func loadSealKey() (*sealKey, error) {
+	sealMu.Lock()
+	defer sealMu.Unlock()
+
+	if cachedSeal != nil {
+		return cachedSeal, nil
+	}
+
+	pair, err := tls.LoadX509KeyPair(config.AppConfig.SealCertPath, config.AppConfig.SealKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, cms.ErrUnsupportedKey
+	}
+
+	certs := make([]*x509.Certificate, 0, len(pair.Certificate))
+	for _, der := range pair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	cachedSeal = &sealKey{cert: certs[0], chain: certs[1:], key: signer}
+	return cachedSeal, nil
+}
+
+// SealPDF applies a PAdES signature made with the server key as an incremental update, so the
+// bytes submitted by the signer are kept unchanged at the start of the file. The signature is
+// PAdES-B-T when a timestamp authority is configured and PAdES-B-B otherwise.
+func SealPDF(data []byte, reason string) ([]byte, error) {
+	if !config.AppConfig.SealEnabled {
+		return nil, ErrSealDisabled
+	}
+
+	seal, err := loadSealKey()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := pdf.SignatureOptions{
+		Name:   config.AppConfig.SealName,
+		Reason: reason,
+		Time:   time.Now(),
+	}
+
+	sign := func(signatureTimestamp func([]byte) ([]byte, error)) ([]byte, error) {
+		return pdf.Sign(data, opts, func(covered []byte) ([]byte, error) {
+			return cms.Sign(covered, cms.SignOptions{
+				Certificate:        seal.cert,
+				Chain:              seal.chain,
+				Key:                seal.key,
+				Detached:           true,
+				SignatureTimestamp: signatureTimestamp,
+			})
+		})
+	}
+
+	if config.AppConfig.TsaURL == "" {
+		return sign(nil)
+	}
+
+	// PAdES-B-T: the signature value is timestamped, an unreachable authority only drops the timestamp
+	var timestampErr error
+	sealed, err := sign(func(signature []byte) ([]byte, error) {
+		token, err := TimestampData(signature)
+		timestampErr = err
+		return token, err
+	})
+	if err != nil && timestampErr != nil {
+		fmt.Println("Could not timestamp seal, sealing without timestamp", timestampErr)
+		return sign(nil)
+	}
+
+	return sealed, err
+}
@@ -0,0 +1,85 @@
+package managers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/google/uuid"
+)
+
+var ErrAccountDisabled = errors.New("account is disabled")
+
+// SessionTokens is what a login or a refresh returns: a short-lived access token, sent as the Bearer token, and
+// the refresh token that gets the next one
+type SessionTokens struct {
+	Token            string `json:"token"`
+	ExpiresAt        string `json:"expiresAt"`
+	RefreshToken     string `json:"refreshToken"`
+	RefreshExpiresAt string `json:"refreshExpiresAt"`
+}
+
+// StartSession opens a session for a user who just logged in
+func StartSession(user database.User, ip string, userAgent string) (SessionTokens, error) {
+	refreshToken, err := lib.RandomToken(32)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	session := database.Session{
+		Id:        uuid.New().String(),
+		UserId:    user.Id,
+		UserAgent: &userAgent,
+		Ip:        &ip,
+	}
+	refreshExpiresAt := time.Now().Add(config.AppConfig.RefreshTokenTTL)
+
+	if err := database.CreateSession(&session, lib.HashToken(refreshToken), refreshExpiresAt); err != nil {
+		return SessionTokens{}, err
+	}
+
+	return sessionTokens(user, session.Id, refreshToken, refreshExpiresAt)
+}
+
+// RefreshSession exchanges a refresh token for new tokens of the same session. It fails with
+// database.ErrSessionNotFound for an unknown, revoked or expired token, and with database.ErrRefreshReused,
+// having revoked the session, for a token that was already exchanged.
+func RefreshSession(refreshToken string, ip string, userAgent string) (SessionTokens, error) {
+	next, err := lib.RandomToken(32)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	refreshExpiresAt := time.Now().Add(config.AppConfig.RefreshTokenTTL)
+
+	session, err := database.RotateSession(lib.HashToken(refreshToken), lib.HashToken(next), ip, userAgent, refreshExpiresAt)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	user, err := database.GetUser(session.UserId)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	if user.Disabled {
+		return SessionTokens{}, ErrAccountDisabled
+	}
+
+	return sessionTokens(user, session.Id, next, refreshExpiresAt)
+}
+
+func sessionTokens(user database.User, sessionId string, refreshToken string, refreshExpiresAt time.Time) (SessionTokens, error) {
+	token, expiresAt, err := lib.GenerateJWT(user.Id, user.Email, sessionId)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	return SessionTokens{
+		Token:            token,
+		ExpiresAt:        expiresAt.UTC().Format(time.RFC3339),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,128 @@
+package managers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/pdf"
+)
+
+// textQueue holds the documents whose text is to be extracted by the worker of StartTextExtraction
+var textQueue = make(chan string, 256)
+
+const pendingTextBatch = 50
+
+// StartTextExtraction runs the worker that extracts the text of the original files in the background. Besides
+// the documents queued by QueueTextExtraction, it picks up every minute those still pending, such as the ones
+// uploaded before text extraction existed or queued when the queue was full.
+func StartTextExtraction() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		extractPendingText()
+		for {
+			select {
+			case id := <-textQueue:
+				if err := ExtractDocText(id); err != nil {
+					fmt.Println("Could not extract the text of document", id, err)
+				}
+			case <-ticker.C:
+				extractPendingText()
+			}
+		}
+	}()
+}
+
+// QueueTextExtraction asks the worker for the text of a document, after its original file was uploaded
+func QueueTextExtraction(docId string) {
+	select {
+	case textQueue <- docId:
+	default:
+		// Left pending for the next sweep
+	}
+}
+
+func extractPendingText() {
+	for {
+		ids, err := database.Docs.PendingText(pendingTextBatch)
+		if err != nil {
+			fmt.Println("Could not list the documents pending text extraction", err)
+			return
+		}
+
+		for _, id := range ids {
+			if err := ExtractDocText(id); err != nil {
+				// Stop the sweep rather than retrying the same documents, the next one will try again
+				fmt.Println("Could not extract the text of document", id, err)
+				return
+			}
+		}
+
+		if len(ids) < pendingTextBatch {
+			return
+		}
+	}
+}
+
+// ExtractDocText extracts and stores the text of the original file of a document, one entry per page.
+// A file that can not be read or parsed marks the text failed; the error returned is that of the database.
+func ExtractDocText(docId string) error {
+	doc, err := database.Docs.Get(docId)
+	if err != nil {
+		return err
+	}
+
+	pages, extractErr := readDocText(&doc)
+	if extractErr != nil {
+		fmt.Println("Could not extract the text of document", docId, extractErr)
+		return database.Docs.MarkTextFailed(docId, doc.OriginalKey)
+	}
+
+	return database.Docs.SaveText(docId, doc.OriginalKey, pages)
+}
+
+func readDocText(doc *database.Document) (pages []string, err error) {
+	// The pdf comes from the uploader, keep a parser bug on a crafted file from taking the server down
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pdf text extraction panicked: %v", r)
+		}
+	}()
+
+	dataKey, err := DocDataKey(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := lib.ReadFile(doc.OriginalKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExtractPDFText(data)
+}
+
+// ExtractPDFText returns the text of each page of a pdf. Pages whose content can not be read are left empty.
+func ExtractPDFText(data []byte) ([]string, error) {
+	reader, err := pdf.Open(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pdfPages, err := reader.Pages()
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]string, len(pdfPages))
+	for i, page := range pdfPages {
+		if text, err := reader.PageText(page); err == nil {
+			pages[i] = text
+		}
+	}
+
+	return pages, nil
+}
@@ -0,0 +1,114 @@
+package managers
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/tsa"
+)
+
+var ErrTimestampDisabled = errors.New("timestamping is disabled")
+
+const builtinTsa = "builtin"
+
+// timestamper returns the configured timestamp authority
+func timestamper() (tsa.Timestamper, error) {
+	switch config.AppConfig.TsaURL {
+	case "":
+		return nil, ErrTimestampDisabled
+	case builtinTsa:
+		return builtinAuthority()
+	}
+
+	client := tsa.NewClient(config.AppConfig.TsaURL)
+	client.Username = config.AppConfig.TsaUsername
+	client.Password = config.AppConfig.TsaPassword
+	return client, nil
+}
+
+var (
+	authorityMu     sync.Mutex
+	cachedAuthority *tsa.Authority
+)
+
+// builtinAuthority signs timestamps with the seal key, under its own timeStamping certificate
+func builtinAuthority() (*tsa.Authority, error) {
+	authorityMu.Lock()
+	defer authorityMu.Unlock()
+
+	if cachedAuthority != nil {
+		return cachedAuthority, nil
+	}
+
+	seal, err := loadSealKey()
+	if err != nil {
+		return nil, err
+	}
+
+	certPem, err := os.ReadFile(config.AppConfig.TsaCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return nil, errors.New("invalid timestamp authority certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedAuthority = &tsa.Authority{Certificate: cert, Key: seal.key}
+	return cachedAuthority, nil
+}
+
+// TimestampData requests a token over the SHA-256 of data
+func TimestampData(data []byte) ([]byte, error) {
+	t, err := timestamper()
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	return t.Timestamp(digest[:])
+}
+
+// TimestampDoc timestamps the hash of the signed file and stores the token on the document
+func TimestampDoc(doc database.Document) (tsa.Info, error) {
+	t, err := timestamper()
+	if err != nil {
+		return tsa.Info{}, err
+	}
+
+	digest, err := hex.DecodeString(valueOrEmpty(doc.SignedHash))
+	if err != nil || len(digest) != sha256.Size {
+		return tsa.Info{}, errors.New("document has no signed file hash")
+	}
+
+	token, err := t.Timestamp(digest)
+	if err != nil {
+		return tsa.Info{}, err
+	}
+
+	info, err := tsa.ParseToken(token)
+	if err != nil {
+		return tsa.Info{}, err
+	}
+
+	_, err = database.DB.Exec(
+		`UPDATE DOCUMENTS SET timestamp_token = ?, timestamped_at = ?, timestamp_authority = ? WHERE id = ?`,
+		base64.StdEncoding.EncodeToString(token), info.GenTime.UTC(), config.AppConfig.TsaURL, doc.Id,
+	)
+
+	return info, err
+}
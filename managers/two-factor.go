@@ -0,0 +1,325 @@
+package managers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/encryption"
+	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/totp"
+)
+
+const (
+	totpIssuer         = "Inkra"
+	recoveryCodeCount  = 10
+	loginChallengeTTL  = 5 * time.Minute
+	challengeAttempts  = 5
+	recoveryCodeLength = 10
+)
+
+var (
+	ErrInvalidCode          = errors.New("invalid two-factor code")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorDisabled    = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp    = errors.New("two-factor authentication has not been set up")
+	ErrTwoFactorUnavailable = errors.New("the two-factor secret can not be read with the configured master keys")
+)
+
+// TOTPSetup is what an authenticator app needs: the uri to show as a QR code, and the secret to type in
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// LoginChallenge is returned by the password step of a login when the user has two-factor authentication on
+type LoginChallenge struct {
+	TwoFactorRequired  bool   `json:"twoFactorRequired"`
+	ChallengeToken     string `json:"challengeToken"`
+	ChallengeExpiresAt string `json:"challengeExpiresAt"`
+}
+
+// totpWrapId binds a wrapped TOTP secret to its user, as the document id does for data keys
+func totpWrapId(userId string) string {
+	return "totp:" + userId
+}
+
+// sealTOTPSecret is the form a TOTP secret is stored in: wrapped by the master key when encryption is enabled,
+// as is otherwise
+func sealTOTPSecret(userId string, secret string) (string, error) {
+	if !config.AppConfig.EncryptionEnabled {
+		return secret, nil
+	}
+	if config.AppConfig.MasterKey == nil {
+		return "", ErrNoMasterKey
+	}
+
+	return config.AppConfig.MasterKey.Wrap([]byte(secret), totpWrapId(userId))
+}
+
+// openTOTPSecret reads a secret stored by sealTOTPSecret. Base32 has no dots, so a wrapped secret is told apart
+// by its form.
+func openTOTPSecret(userId string, stored string) (string, error) {
+	if _, err := encryption.WrappedKeyID(stored); err != nil {
+		return stored, nil
+	}
+	if config.AppConfig.MasterKey == nil {
+		return "", ErrNoMasterKey
+	}
+
+	secret, err := encryption.Unwrap(stored, totpWrapId(userId), masterKeys()...)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTwoFactorUnavailable, err)
+	}
+	return string(secret), nil
+}
+
+// SetupTOTP generates a new secret for a user. It replaces any previous setup not confirmed yet and is only
+// asked for at login once confirmed with EnableTOTP.
+func SetupTOTP(user database.User) (TOTPSetup, error) {
+	if user.TwoFactorEnabled {
+		return TOTPSetup{}, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TOTPSetup{}, err
+	}
+
+	sealed, err := sealTOTPSecret(user.Id, secret)
+	if err != nil {
+		return TOTPSetup{}, err
+	}
+
+	if err := database.SetPendingTOTP(user.Id, sealed); err != nil {
+		return TOTPSetup{}, err
+	}
+
+	return TOTPSetup{Secret: secret, URI: totp.ProvisioningURI(totpIssuer, user.Email, secret)}, nil
+}
+
+// EnableTOTP turns two-factor authentication on once the user proves, with a code, that the authenticator app
+// has the secret of SetupTOTP. It returns the recovery codes, which are only ever shown here.
+func EnableTOTP(user database.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPPendingSecret == nil {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	secret, err := openTOTPSecret(user.Id, *user.TOTPPendingSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.EnableTOTP(user.Id, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifySecondFactor checks a code of the authenticator app or an unused recovery code of a user, either being
+// accepted only once
+func VerifySecondFactor(user database.User, code string) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorDisabled
+	}
+
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		secret, err := openTOTPSecret(user.Id, *user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+
+		step, ok := totp.Validate(secret, code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		// The stored step is checked again, another request may have used the code in the meantime
+		used, err := database.UseTOTPStep(user.Id, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	used, err := database.UseRecoveryCode(user.Id, lib.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user, who proves to still have the second factor
+func RegenerateRecoveryCodes(user database.User, code string) ([]string, error) {
+	if err := VerifySecondFactor(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.ReplaceRecoveryCodes(user.Id, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// NewLoginChallenge starts the second step of the login of a user who gave the right password
+func NewLoginChallenge(user database.User) (LoginChallenge, error) {
+	token, err := lib.RandomToken(32)
+	if err != nil {
+		return LoginChallenge{}, err
+	}
+
+	expiresAt := time.Now().Add(loginChallengeTTL)
+	if err := database.CreateLoginChallenge(lib.HashToken(token), user.Id, expiresAt); err != nil {
+		return LoginChallenge{}, err
+	}
+
+	return LoginChallenge{
+		TwoFactorRequired:  true,
+		ChallengeToken:     token,
+		ChallengeExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// CompleteLoginChallenge checks the code given for a challenge and opens the session of its user. A challenge
+// allows a few attempts, then fails with database.ErrChallengeNotFound as if it had expired.
+func CompleteLoginChallenge(token string, code string, ip string, userAgent string) (database.User, SessionTokens, error) {
+	tokenHash := lib.HashToken(token)
+
+	userId, err := database.UseChallengeAttempt(tokenHash, challengeAttempts)
+	if err != nil {
+		return database.User{}, SessionTokens{}, err
+	}
+
+	user, err := database.GetUser(userId)
+	if err != nil {
+		return database.User{}, SessionTokens{}, err
+	}
+	if user.Disabled {
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginDisabled)
+		return database.User{}, SessionTokens{}, ErrAccountDisabled
+	}
+	// Wrong codes count toward the lockout, as wrong passwords do
+	if err := CheckLoginLock(user); err != nil {
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginLocked)
+		return database.User{}, SessionTokens{}, err
+	}
+
+	if err := VerifySecondFactor(user, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			RecordLogin(&user, user.Email, ip, userAgent, database.LoginWrongCode)
+		}
+		return database.User{}, SessionTokens{}, err
+	}
+
+	if err := database.DeleteLoginChallenge(tokenHash); err != nil {
+		return database.User{}, SessionTokens{}, err
+	}
+
+	tokens, err := StartSession(user, ip, userAgent)
+	if err == nil {
+		RecordLogin(&user, user.Email, ip, userAgent, database.LoginSuccess)
+	}
+	return user, tokens, err
+}
+
+// newRecoveryCodes returns new recovery codes, formatted as "xxxxx-xxxxx", along with the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw))[:recoveryCodeLength]
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i] = lib.HashToken(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts a recovery code typed in upper case or without its dash
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// rewrapTOTPSecrets wraps the TOTP secrets of the users with the current master key, for RewrapDataKeys
+func rewrapTOTPSecrets(report *RewrapReport) error {
+	users, err := database.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user.TOTPSecret == nil {
+			continue
+		}
+
+		keyId, err := encryption.WrappedKeyID(*user.TOTPSecret)
+		if err != nil {
+			// Stored while encryption was disabled
+			continue
+		}
+		if keyId == config.AppConfig.MasterKey.ID {
+			report.Current++
+			continue
+		}
+
+		secret, err := encryption.Unwrap(*user.TOTPSecret, totpWrapId(user.Id), masterKeys()...)
+		if err != nil {
+			fmt.Println("Cannot unwrap the two-factor secret of user", user.Email+":", err)
+			report.Failed++
+			continue
+		}
+
+		rewrapped, err := config.AppConfig.MasterKey.Wrap(secret, totpWrapId(user.Id))
+		if err != nil {
+			return err
+		}
+
+		if err := database.UpdateTOTPSecret(user.Id, rewrapped); err != nil {
+			return err
+		}
+		report.Rewrapped++
+	}
+
+	return nil
+}
@@ -2,41 +2,174 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
-	"github.com/fbn776/inkra/config"
+	"github.com/fbn776/inkra/database"
 	"github.com/fbn776/inkra/lib"
-	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	ErrMissingToken = errors.New("authorization header is missing")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUserDisabled = errors.New("user is disabled")
+)
+
+// Auth is who makes a request
+type Auth struct {
+	User database.User
+	// APIKey is the key the request is made with, unset for access tokens
+	APIKey *database.APIKey
+	// Claims of the access token, unset for API keys
+	Claims *lib.Claims
+}
+
+// Authenticate returns the user whose access token or API key is in the Authorization header. The user, the
+// session of the token and the revocation list are read on each request, so a disabled user, a changed role or a
+// logout applies at once.
+func Authenticate(r *http.Request) (Auth, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return Auth{}, ErrMissingToken
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+
+	if strings.HasPrefix(token, database.APIKeyPrefix) {
+		return authenticateAPIKey(r, token)
+	}
+
+	claims, err := lib.ParseJWT(token)
+	if err != nil || claims.Subject == "" || claims.SessionId == "" || claims.ID == "" {
+		// Tokens issued before sessions existed have no session
+		return Auth{}, ErrInvalidToken
+	}
+
+	revoked, err := database.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return Auth{}, err
+	}
+	active, err := database.IsSessionActive(claims.SessionId)
+	if err != nil {
+		return Auth{}, err
+	}
+	if revoked || !active {
+		return Auth{}, ErrInvalidToken
+	}
+
+	user, err := activeUser(claims.Subject)
+	return Auth{User: user, Claims: claims}, err
+}
+
+func authenticateAPIKey(r *http.Request, token string) (Auth, error) {
+	key, err := database.GetAPIKeyByHash(lib.HashToken(token))
+	if errors.Is(err, database.ErrAPIKeyNotFound) || (err == nil && key.RevokedAt != nil) {
+		return Auth{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Auth{}, err
+	}
+
+	user, err := activeUser(key.UserId)
+	if err != nil {
+		return Auth{}, err
+	}
+
+	if err := database.TouchAPIKey(key.Id, lib.GetClientIP(r)); err != nil {
+		fmt.Println("Could not record the use of api key", key.Id, err)
+	}
+
+	return Auth{User: user, APIKey: &key}, nil
+}
+
+// activeUser returns the user of a token, which must exist and be enabled
+func activeUser(id string) (database.User, error) {
+	user, err := database.GetUser(id)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return user, ErrInvalidToken
+	}
+	if err != nil {
+		return user, err
+	}
+	if user.Disabled {
+		return user, ErrUserDisabled
+	}
+
+	return user, nil
+}
+
+// JWTAuthMiddleware lets through requests with the access token or API key of an enabled user, with its email,
+// id and role in the context as userEmail, userId and userRole, the claims of the token as tokenClaims and the
+// scope of the key as apiKeyScope.
+// Read-only keys are refused for anything but reading, the routes needing more set it with RequireScope.
 func JWTAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
+		auth, err := Authenticate(r)
 
-		if authHeader == "" {
+		switch {
+		case errors.Is(err, ErrMissingToken):
 			lib.ErrorJSON(w, http.StatusUnauthorized, "Authorization header is missing")
 			return
+		case errors.Is(err, ErrInvalidToken):
+			lib.ErrorJSON(w, http.StatusUnauthorized, "Invalid token")
+			return
+		case errors.Is(err, ErrUserDisabled):
+			lib.ErrorJSON(w, http.StatusForbidden, "Account is disabled")
+			return
+		case err != nil:
+			lib.ErrorJSON(w, http.StatusInternalServerError, "Could not get user")
+			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-		claims := &lib.Claims{}
+		ctx := context.WithValue(r.Context(), "userEmail", auth.User.Email)
+		ctx = context.WithValue(ctx, "userId", auth.User.Id)
+		ctx = context.WithValue(ctx, "userRole", auth.User.Role)
 
-		token, err := jwt.ParseWithClaims(
-			tokenString,
-			claims,
-			func(token *jwt.Token) (interface{}, error) {
-				return []byte(config.AppConfig.JwtSecret), nil
-			},
-		)
+		if auth.Claims != nil {
+			ctx = context.WithValue(ctx, "tokenClaims", auth.Claims)
+		}
 
-		if err != nil || !token.Valid {
-			lib.ErrorJSON(w, http.StatusUnauthorized, "Invalid token")
-			return
+		if auth.APIKey != nil {
+			if auth.APIKey.Scope == database.ScopeRead && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				lib.ErrorJSON(w, http.StatusForbidden, "The scope of the API key does not allow this")
+				return
+			}
+			ctx = context.WithValue(ctx, "apiKeyId", auth.APIKey.Id)
+			ctx = context.WithValue(ctx, "apiKeyScope", auth.APIKey.Scope)
 		}
 
-		ctx := context.WithValue(r.Context(), "userEmail", claims.Email)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequireScope lets through the requests made with an access token or an API key whose scope allows scope, it goes
+// after JWTAuthMiddleware
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keyScope, isKey := r.Context().Value("apiKeyScope").(string)
+			if isKey && !database.ScopeAllows(keyScope, scope) {
+				lib.ErrorJSON(w, http.StatusForbidden, "The scope of the API key does not allow this")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole lets through the requests of users having one of roles, it goes after JWTAuthMiddleware
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value("userRole").(string)
+			if !slices.Contains(roles, role) {
+				lib.ErrorJSON(w, http.StatusForbidden, "Your role does not allow this")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package oidc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIssuer serves the discovery document and the keys of a provider, which the tests sign tokens with
+type testIssuer struct {
+	server *httptest.Server
+	issuer string
+
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	fetches int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	ti := &testIssuer{keys: map[string]crypto.Signer{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                ti.issuer,
+			AuthorizationEndpoint: ti.server.URL + "/authorize",
+			TokenEndpoint:         ti.server.URL + "/token",
+			JWKSURI:               ti.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		ti.mu.Lock()
+		defer ti.mu.Unlock()
+
+		ti.fetches++
+		keys := []jsonWebKey{{Kty: "oct", Kid: "hmac"}}
+		for kid, key := range ti.keys {
+			keys = append(keys, toJSONWebKey(kid, key.Public()))
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+
+	ti.server = httptest.NewServer(mux)
+	ti.issuer = ti.server.URL
+	t.Cleanup(ti.server.Close)
+	return ti
+}
+
+func toJSONWebKey(kid string, public crypto.PublicKey) jsonWebKey {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig", N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		point, _ := key.Bytes()
+		size := (len(point) - 1) / 2
+		return jsonWebKey{Kty: "EC", Kid: kid, Crv: key.Curve.Params().Name, X: encode(point[1 : 1+size]), Y: encode(point[1+size:])}
+	}
+	panic("unsupported key")
+}
+
+func (ti *testIssuer) addKey(t *testing.T, kid string, key crypto.Signer) {
+	t.Helper()
+
+	ti.mu.Lock()
+	ti.keys[kid] = key
+	ti.mu.Unlock()
+}
+
+// sign makes a token with the key of the given id, for the claims of a valid login of the client "inkra" with
+// the nonce "n-1" overridden by claims. A nil claim is removed.
+func (ti *testIssuer) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	all := jwt.MapClaims{
+		"iss":   ti.issuer,
+		"aud":   "inkra",
+		"sub":   "alice",
+		"email": "alice@example.com",
+		"nonce": "n-1",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(all, name)
+		} else {
+			all[name] = value
+		}
+	}
+
+	ti.mu.Lock()
+	key := ti.keys[kid]
+	ti.mu.Unlock()
+
+	var method jwt.SigningMethod
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		method = map[string]jwt.SigningMethod{"P-256": jwt.SigningMethodES256, "P-521": jwt.SigningMethodES512}[key.Curve.Params().Name]
+	}
+
+	token := jwt.NewWithClaims(method, all)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (ti *testIssuer) provider() *Provider {
+	return NewProvider(ti.issuer, "inkra", "secret", "http://localhost:8080/api/auth/oidc/callback", []string{"openid", "email"})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ti := newTestIssuer(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p521Key, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	ti.addKey(t, "rsa", rsaKey)
+	ti.addKey(t, "p256", p256Key)
+	ti.addKey(t, "p521", p521Key)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": ti.issuer, "aud": "inkra", "sub": "alice", "nonce": "n-1", "exp": time.Now().Add(time.Minute).Unix()})
+	hmac.Header["kid"] = "hmac"
+	hmacToken, _ := hmac.SignedString([]byte("secret"))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"rsa", ti.sign(t, "rsa", nil), nil},
+		{"P-256", ti.sign(t, "p256", nil), nil},
+		{"P-521", ti.sign(t, "p521", nil), nil},
+		{"several audiences issued to the client", ti.sign(t, "rsa", jwt.MapClaims{"aud": []string{"inkra", "other"}, "azp": "inkra"}), nil},
+		{"several audiences issued to another", ti.sign(t, "rsa", jwt.MapClaims{"aud": []string{"inkra", "other"}, "azp": "other"}), ErrInvalidIDToken},
+		{"other audience", ti.sign(t, "rsa", jwt.MapClaims{"aud": "other"}), ErrInvalidIDToken},
+		{"other issuer", ti.sign(t, "rsa", jwt.MapClaims{"iss": "https://evil.example.com"}), ErrInvalidIDToken},
+		{"expired", ti.sign(t, "rsa", jwt.MapClaims{"exp": time.Now().Add(-2 * time.Minute).Unix()}), ErrInvalidIDToken},
+		{"without expiry", ti.sign(t, "rsa", jwt.MapClaims{"exp": nil}), ErrInvalidIDToken},
+		{"issued in the future", ti.sign(t, "rsa", jwt.MapClaims{"iat": time.Now().Add(2 * time.Minute).Unix()}), ErrInvalidIDToken},
+		{"other nonce", ti.sign(t, "rsa", jwt.MapClaims{"nonce": "n-2"}), ErrNonceMismatch},
+		{"without nonce", ti.sign(t, "rsa", jwt.MapClaims{"nonce": nil}), ErrNonceMismatch},
+		{"without subject", ti.sign(t, "rsa", jwt.MapClaims{"sub": nil}), ErrInvalidIDToken},
+		{"hmac", hmacToken, ErrInvalidIDToken},
+		{"tampered", ti.sign(t, "rsa", nil) + "A", ErrInvalidIDToken},
+	}
+
+	p := ti.provider()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := p.VerifyIDToken(tt.token, "n-1")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && (token.Subject != "alice" || token.Email != "alice@example.com") {
+				t.Errorf("token = %+v", token)
+			}
+		})
+	}
+}
+
+func TestIDTokenClaims(t *testing.T) {
+	ti := newTestIssuer(t)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ti.addKey(t, "p256", key)
+	p := ti.provider()
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		verified bool
+		groups   []string
+	}{
+		{"verified", jwt.MapClaims{"email_verified": true, "groups": []string{"editors", "admins"}}, true, []string{"editors", "admins"}},
+		{"verified as a string", jwt.MapClaims{"email_verified": "true", "groups": "editors"}, true, []string{"editors"}},
+		{"not verified", jwt.MapClaims{"email_verified": false, "groups": []any{"editors", 7}}, false, []string{"editors"}},
+		{"no claims", nil, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := p.VerifyIDToken(ti.sign(t, "p256", tt.claims), "n-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.EmailVerified != tt.verified {
+				t.Errorf("email verified = %v, want %v", token.EmailVerified, tt.verified)
+			}
+			if groups := token.Strings("groups"); !slices.Equal(groups, tt.groups) || (groups == nil) != (tt.groups == nil) {
+				t.Errorf("groups = %#v, want %#v", groups, tt.groups)
+			}
+		})
+	}
+}
+
+// TestKeyRotation checks that the keys are fetched again for a token signed with a new key, at most once per
+// keysRefreshInterval
+func TestKeyRotation(t *testing.T) {
+	ti := newTestIssuer(t)
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ti.addKey(t, "old", oldKey)
+	p := ti.provider()
+
+	if _, err := p.VerifyIDToken(ti.sign(t, "old", nil), "n-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ti.addKey(t, "new", newKey)
+	if _, err := p.VerifyIDToken(ti.sign(t, "new", nil), "n-1"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("new key within the refresh interval = %v, want ErrUnknownKey", err)
+	}
+	if ti.fetches != 1 {
+		t.Errorf("keys fetched %d times, want 1", ti.fetches)
+	}
+
+	p.keys.fetchedAt = time.Now().Add(-keysRefreshInterval - time.Second)
+	if _, err := p.VerifyIDToken(ti.sign(t, "new", nil), "n-1"); err != nil {
+		t.Errorf("new key after the refresh interval = %v", err)
+	}
+	if _, err := p.VerifyIDToken(ti.sign(t, "old", nil), "n-1"); err != nil {
+		t.Errorf("old key after the rotation = %v", err)
+	}
+	if ti.fetches != 2 {
+		t.Errorf("keys fetched %d times, want 2", ti.fetches)
+	}
+}
+
+func TestJSONWebKey(t *testing.T) {
+	// The example public key of RFC 7517 appendix A.1
+	example := jsonWebKey{Kty: "EC", Crv: "P-256", X: "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", Y: "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}
+	if _, err := example.publicKey(); err != nil {
+		t.Errorf("RFC 7517 example: %v", err)
+	}
+
+	// Some providers drop the leading zeros of the coordinates
+	var key *ecdsa.PrivateKey
+	var point []byte
+	for point == nil || point[1] != 0 {
+		key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		point, _ = key.PublicKey.Bytes()
+	}
+	stripped := toJSONWebKey("", key.Public())
+	stripped.X = base64.RawURLEncoding.EncodeToString(bytes.TrimLeft(point[1:33], "\x00"))
+	if public, err := stripped.publicKey(); err != nil || !key.PublicKey.Equal(public) {
+		t.Errorf("stripped coordinates = %v, %v", public, err)
+	}
+
+	offCurve := example
+	offCurve.Y = offCurve.X
+	tests := []struct {
+		name string
+		jwk  jsonWebKey
+	}{
+		{"not on the curve", offCurve},
+		{"unsupported curve", jsonWebKey{Kty: "EC", Crv: "secp256k1", X: example.X, Y: example.Y}},
+		{"coordinates too large", jsonWebKey{Kty: "EC", Crv: "P-256", X: example.X + "AAAA", Y: example.Y}},
+		{"rsa exponent too large", jsonWebKey{Kty: "RSA", N: "AQAB", E: "AQAAAAAAAAAA"}},
+		{"unsupported type", jsonWebKey{Kty: "OKP"}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.jwk.publicKey(); err == nil {
+			t.Errorf("%s: parsed", tt.name)
+		}
+	}
+}
+
+// TestCodeChallenge is the example of RFC 7636 appendix B
+func TestCodeChallenge(t *testing.T) {
+	if got, want := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"), "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("challenge = %s, want %s", got, want)
+	}
+
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RFC 7636 section 4.1, 43 to 128 unreserved characters
+	if len(verifier) != 43 || url.QueryEscape(verifier) != verifier {
+		t.Errorf("verifier = %q", verifier)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	ti := newTestIssuer(t)
+
+	authURL, err := ti.provider().AuthCodeURL("s-1", "n-1", "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme+"://"+u.Host+u.Path != ti.server.URL+"/authorize" {
+		t.Errorf("endpoint = %s", authURL)
+	}
+
+	want := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"inkra"},
+		"redirect_uri":          {"http://localhost:8080/api/auth/oidc/callback"},
+		"scope":                 {"openid email"},
+		"state":                 {"s-1"},
+		"nonce":                 {"n-1"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+	}
+	if got := u.Query(); got.Encode() != want.Encode() {
+		t.Errorf("query = %v\nwant %v", got, want)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ti := newTestIssuer(t)
+
+	other := NewProvider(ti.issuer+"/", "inkra", "", "", nil)
+	if _, err := other.Metadata(); !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("other issuer = %v, want ErrIssuerMismatch", err)
+	}
+
+	missing := NewProvider(ti.issuer+"/missing", "inkra", "", "", nil)
+	if _, err := missing.Metadata(); !errors.Is(err, ErrDiscovery) {
+		t.Errorf("no discovery document = %v, want ErrDiscovery", err)
+	}
+}
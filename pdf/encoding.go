@@ -0,0 +1,128 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Characters of WinAnsiEncoding between 0x80 and 0x9f, the other codes are those of Latin-1
+var winAnsiHigh = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ',
+	0x89: '‰', 0x8a: 'Š', 0x8b: '‹', 0x8c: 'Œ', 0x8e: 'Ž', 0x91: '‘', 0x92: '’', 0x93: '“',
+	0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜', 0x99: '™', 0x9a: 'š', 0x9b: '›',
+	0x9c: 'œ', 0x9e: 'ž', 0x9f: 'Ÿ',
+}
+
+var winAnsiEncoding = func() *[256]string {
+	var enc [256]string
+	for c := 32; c < 256; c++ {
+		if r, ok := winAnsiHigh[byte(c)]; ok {
+			enc[c] = string(r)
+		} else if c < 0x7f || c >= 0xa0 {
+			enc[c] = string(rune(c))
+		}
+	}
+	return &enc
+}()
+
+// Glyph names that are not a single character, an accented letter or a uniXXXX name
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "quoteleft": "‘", "parenleft": "(", "parenright": ")",
+	"asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/", "colon": ":",
+	"semicolon": ";", "less": "<", "equal": "=", "greater": ">", "question": "?", "at": "@",
+	"bracketleft": "[", "backslash": "\\", "bracketright": "]", "asciicircum": "^", "underscore": "_",
+	"grave": "`", "braceleft": "{", "bar": "|", "braceright": "}", "asciitilde": "~",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6", "seven": "7",
+	"eight": "8", "nine": "9",
+	"endash": "–", "emdash": "—", "quotedblleft": "“", "quotedblright": "”", "quotesinglbase": "‚",
+	"quotedblbase": "„", "bullet": "•", "ellipsis": "…", "dagger": "†", "daggerdbl": "‡", "section": "§",
+	"paragraph": "¶", "copyright": "©", "registered": "®", "trademark": "™", "degree": "°", "Euro": "€",
+	"sterling": "£", "yen": "¥", "cent": "¢", "currency": "¤", "guillemotleft": "«", "guillemotright": "»",
+	"minus": "−", "multiply": "×", "divide": "÷", "plusminus": "±", "germandbls": "ß", "ae": "æ", "AE": "Æ",
+	"oe": "œ", "OE": "Œ", "oslash": "ø", "Oslash": "Ø", "dotlessi": "ı", "exclamdown": "¡",
+	"questiondown": "¿", "nbspace": " ", "periodcentered": "·", "fi": "fi", "fl": "fl", "ff": "ff",
+	"ffi": "ffi", "ffl": "ffl",
+}
+
+// Combining marks of the accented letter glyph names, such as eacute
+var glyphAccents = []struct {
+	suffix string
+	mark   string
+}{
+	{"acute", "\u0301"}, {"grave", "\u0300"}, {"circumflex", "\u0302"}, {"dieresis", "\u0308"},
+	{"tilde", "\u0303"}, {"ring", "\u030A"}, {"cedilla", "\u0327"}, {"caron", "\u030C"},
+}
+
+// glyphText returns the text of a glyph name, or "" when it is not known
+func glyphText(name string) string {
+	// Variants such as a.sc and ligatures such as f_i
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if strings.Contains(name, "_") {
+		var sb strings.Builder
+		for _, part := range strings.Split(name, "_") {
+			sb.WriteString(glyphText(part))
+		}
+		return sb.String()
+	}
+
+	if len(name) == 1 {
+		return name
+	}
+	if text, ok := glyphNames[name]; ok {
+		return text
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		var sb strings.Builder
+		for i := 3; i < len(name); i += 4 {
+			v, err := strconv.ParseUint(name[i:i+4], 16, 16)
+			if err != nil {
+				return ""
+			}
+			sb.WriteRune(rune(v))
+		}
+		return sb.String()
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return string(rune(v))
+		}
+	}
+	for _, accent := range glyphAccents {
+		if base, ok := strings.CutSuffix(name, accent.suffix); ok && len(base) == 1 {
+			return base + accent.mark
+		}
+	}
+	return ""
+}
+
+// simpleEncoding returns the text of each code of a simple font, from its Encoding and Differences. Other base
+// encodings than WinAnsiEncoding are read as it, which is right for the letters and digits.
+func (r *Reader) simpleEncoding(font Dict) *[256]string {
+	encoding, ok := r.Resolve(font["Encoding"]).(Dict)
+	if !ok {
+		return winAnsiEncoding
+	}
+
+	differences, ok := r.Resolve(encoding["Differences"]).(Array)
+	if !ok {
+		return winAnsiEncoding
+	}
+
+	enc := *winAnsiEncoding
+	code := 0
+	for _, item := range differences {
+		switch v := r.Resolve(item).(type) {
+		case int64:
+			code = int(v)
+		case Name:
+			if code >= 0 && code < 256 {
+				enc[code] = glyphText(string(v))
+			}
+			code++
+		}
+	}
+	return &enc
+}
@@ -0,0 +1,563 @@
+package pdf
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// PageText extracts the text drawn on a page, in drawing order, with a line break where the text moves to another
+// line. Fonts are decoded through their ToUnicode map or their encoding; glyphs that map to no character, as in
+// fonts subset without a ToUnicode map, are left out.
+func (r *Reader) PageText(p Page) (string, error) {
+	contents, err := r.Contents(p)
+	if err != nil {
+		return "", err
+	}
+
+	e := &textExtractor{r: r, fonts: map[Ref]*textFont{}}
+	resources, _ := r.Resolve(p.Dict["Resources"]).(Dict)
+	for _, content := range contents {
+		e.run(content, resources, 0)
+	}
+
+	return e.text(), nil
+}
+
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns m × n
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+type textExtractor struct {
+	r     *Reader
+	fonts map[Ref]*textFont
+	out   strings.Builder
+
+	// Text state; positions are those of the text matrix, the current transformation is not followed
+	font       *textFont
+	fontSize   float64
+	charSpace  float64
+	wordSpace  float64
+	scale      float64
+	leading    float64
+	tm, tlm    matrix
+	lastX      float64
+	lastY      float64
+	hasLast    bool
+	lastHeight float64
+}
+
+func (e *textExtractor) text() string {
+	var lines []string
+	for _, line := range strings.Split(e.out.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// run interprets a content stream, only following the operators that place and show text
+func (e *textExtractor) run(content []byte, resources Dict, depth int) {
+	if depth > 8 {
+		return
+	}
+	if e.scale == 0 {
+		e.scale = 1
+	}
+
+	l := &lexer{data: content}
+	var operands []Object
+
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return
+		}
+
+		c := l.data[l.pos]
+		if isDelimiter(c) && c != '/' && c != '(' && c != '<' && c != '[' {
+			// Stray closing delimiters and braces
+			l.pos++
+			continue
+		}
+		if c == '/' || c == '(' || c == '<' || c == '[' || c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+			obj, err := l.readObject()
+			if err != nil {
+				return
+			}
+			operands = append(operands, obj)
+			continue
+		}
+
+		op := l.keyword()
+		switch op {
+		case "true", "false", "null":
+			operands = append(operands, nil)
+			continue
+		case "BI":
+			skipInlineImage(l)
+		case "BT":
+			e.tm, e.tlm = identity, identity
+		case "Tf":
+			if len(operands) == 2 {
+				name, _ := operands[0].(Name)
+				e.font = e.loadFont(resources, name)
+				e.fontSize, _ = Number(operands[1])
+			}
+		case "Tc":
+			e.charSpace = numberOperand(operands, 0)
+		case "Tw":
+			e.wordSpace = numberOperand(operands, 0)
+		case "Tz":
+			e.scale = numberOperand(operands, 0) / 100
+		case "TL":
+			e.leading = numberOperand(operands, 0)
+		case "Td":
+			e.moveLine(numberOperand(operands, 0), numberOperand(operands, 1))
+		case "TD":
+			e.leading = -numberOperand(operands, 1)
+			e.moveLine(numberOperand(operands, 0), numberOperand(operands, 1))
+		case "Tm":
+			if len(operands) == 6 {
+				for i := range e.tm {
+					e.tm[i], _ = Number(operands[i])
+				}
+				e.tlm = e.tm
+			}
+		case "T*":
+			e.moveLine(0, -e.leading)
+		case "Tj":
+			if len(operands) == 1 {
+				e.show(operands[0])
+			}
+		case "'":
+			e.moveLine(0, -e.leading)
+			if len(operands) == 1 {
+				e.show(operands[0])
+			}
+		case "\"":
+			if len(operands) == 3 {
+				e.wordSpace = numberOperand(operands, 0)
+				e.charSpace = numberOperand(operands, 1)
+				e.moveLine(0, -e.leading)
+				e.show(operands[2])
+			}
+		case "TJ":
+			if len(operands) == 1 {
+				if items, ok := operands[0].(Array); ok {
+					for _, item := range items {
+						if n, ok := Number(item); ok {
+							e.advance(-n / 1000 * e.fontSize * e.scale)
+						} else {
+							e.show(item)
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) == 1 {
+				name, _ := operands[0].(Name)
+				e.runForm(resources, name, depth)
+			}
+		case "":
+			l.pos++
+		}
+		operands = operands[:0]
+	}
+}
+
+func numberOperand(operands []Object, i int) float64 {
+	if i >= len(operands) {
+		return 0
+	}
+	n, _ := Number(operands[i])
+	return n
+}
+
+// skipInlineImage moves past the data of an inline image, up to its EI operator
+func skipInlineImage(l *lexer) {
+	id := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if id < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += id + 2
+
+	for l.pos < len(l.data) {
+		ei := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if ei < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		at := l.pos + ei
+		l.pos = at + 2
+		if at > 0 && isWhitespace(l.data[at-1]) && (l.pos >= len(l.data) || isWhitespace(l.data[l.pos]) || isDelimiter(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+func (e *textExtractor) runForm(resources Dict, name Name, depth int) {
+	xobjects, _ := e.r.Resolve(resources["XObject"]).(Dict)
+	form, ok := e.r.Resolve(xobjects[name]).(*Stream)
+	if !ok || form.Dict["Subtype"] != Name("Form") {
+		return
+	}
+
+	data, err := e.r.Decode(form)
+	if err != nil {
+		return
+	}
+
+	formResources, ok := e.r.Resolve(form.Dict["Resources"]).(Dict)
+	if !ok {
+		formResources = resources
+	}
+
+	// The form draws in its own text objects, keep the state of the page around it
+	font, fontSize, tm, tlm := e.font, e.fontSize, e.tm, e.tlm
+	e.out.WriteString("\n")
+	e.run(data, formResources, depth+1)
+	e.out.WriteString("\n")
+	e.font, e.fontSize, e.tm, e.tlm = font, fontSize, tm, tlm
+	e.hasLast = false
+}
+
+func (e *textExtractor) moveLine(tx, ty float64) {
+	e.tlm = translate(tx, ty).multiply(e.tlm)
+	e.tm = e.tlm
+}
+
+func (e *textExtractor) advance(tx float64) {
+	e.tm = translate(tx, 0).multiply(e.tm)
+}
+
+// show writes a string with the current font, preceded by a space or a line break when it does not continue
+// the previous string
+func (e *textExtractor) show(o Object) {
+	s, ok := o.(String)
+	if !ok || e.font == nil {
+		return
+	}
+
+	height := math.Hypot(e.tm[2], e.tm[3]) * e.fontSize
+	x, y := e.tm[4], e.tm[5]
+	if e.hasLast {
+		size := math.Max(height, e.lastHeight)
+		switch {
+		case math.Abs(y-e.lastY) > size*0.5:
+			e.out.WriteString("\n")
+		case x-e.lastX > size*0.15, x-e.lastX < -size:
+			e.out.WriteString(" ")
+		}
+	}
+
+	for _, g := range e.font.decode(s) {
+		e.out.WriteString(g.text)
+
+		advance := g.width/1000*e.fontSize + e.charSpace
+		if g.space {
+			advance += e.wordSpace
+		}
+		e.advance(advance * e.scale)
+	}
+
+	e.lastX = e.tm[4]
+	e.lastY = e.tm[5]
+	e.lastHeight = height
+	e.hasLast = true
+}
+
+type glyph struct {
+	text  string
+	width float64
+	space bool
+}
+
+type codeRange struct {
+	lo, hi []byte
+}
+
+// textFont maps the character codes of a font to text and glyph widths
+type textFont struct {
+	// codeSpace lists the byte ranges of the codes of composite fonts, simple fonts have one byte codes
+	codeSpace []codeRange
+	toUnicode map[string]string
+	encoding  *[256]string
+	widths    map[uint32]float64
+	dw        float64
+}
+
+func (e *textExtractor) loadFont(resources Dict, name Name) *textFont {
+	fonts, _ := e.r.Resolve(resources["Font"]).(Dict)
+	ref, isRef := fonts[name].(Ref)
+	if isRef {
+		if f, ok := e.fonts[ref]; ok {
+			return f
+		}
+	}
+
+	dict, ok := e.r.Resolve(fonts[name]).(Dict)
+	if !ok {
+		return nil
+	}
+
+	f := &textFont{widths: map[uint32]float64{}, dw: 1000}
+
+	if dict["Subtype"] == Name("Type0") {
+		f.codeSpace = []codeRange{{lo: []byte{0, 0}, hi: []byte{0xff, 0xff}}}
+		if descendants, ok := e.r.Resolve(dict["DescendantFonts"]).(Array); ok && len(descendants) > 0 {
+			if cid, ok := e.r.Resolve(descendants[0]).(Dict); ok {
+				f.loadCIDWidths(e.r, cid)
+			}
+		}
+	} else {
+		f.encoding = e.r.simpleEncoding(dict)
+		f.dw = 0
+		first, _ := Int(e.r.Resolve(dict["FirstChar"]))
+		if widths, ok := e.r.Resolve(dict["Widths"]).(Array); ok {
+			for i, w := range widths {
+				if n, ok := Number(e.r.Resolve(w)); ok {
+					f.widths[uint32(first)+uint32(i)] = n
+				}
+			}
+		}
+		if len(f.widths) == 0 {
+			// Standard 14 fonts come without widths, use those of Helvetica
+			for c := 32; c <= 126; c++ {
+				f.widths[uint32(c)] = float64(helveticaWidths[c-32])
+			}
+			f.dw = 556
+		}
+	}
+
+	if cmap, ok := e.r.Resolve(dict["ToUnicode"]).(*Stream); ok {
+		if data, err := e.r.Decode(cmap); err == nil {
+			f.parseToUnicode(data)
+		}
+	}
+
+	if isRef {
+		e.fonts[ref] = f
+	}
+	return f
+}
+
+// loadCIDWidths reads the W array of a CIDFont: "c [w1 w2 ...]" and "cFirst cLast w" entries
+func (f *textFont) loadCIDWidths(r *Reader, cid Dict) {
+	if dw, ok := Number(r.Resolve(cid["DW"])); ok {
+		f.dw = dw
+	}
+
+	w, _ := r.Resolve(cid["W"]).(Array)
+	for i := 0; i < len(w); {
+		first, ok := Int(r.Resolve(w[i]))
+		if !ok || i+1 >= len(w) {
+			return
+		}
+		if list, ok := r.Resolve(w[i+1]).(Array); ok {
+			for j, item := range list {
+				if n, ok := Number(r.Resolve(item)); ok {
+					f.widths[uint32(first)+uint32(j)] = n
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, _ := Int(r.Resolve(w[i+1]))
+		n, _ := Number(r.Resolve(w[i+2]))
+		for c := first; c <= last && c-first < 65536; c++ {
+			f.widths[uint32(c)] = n
+		}
+		i += 3
+	}
+}
+
+// parseToUnicode reads the code space and the bfchar and bfrange mappings of a ToUnicode CMap
+func (f *textFont) parseToUnicode(data []byte) {
+	f.toUnicode = map[string]string{}
+	var codeSpace []codeRange
+
+	l := &lexer{data: data}
+	var operands []Object
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			break
+		}
+		c := l.data[l.pos]
+		if c == '/' || c == '(' || c == '<' || c == '[' || c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+			obj, err := l.readObject()
+			if err != nil {
+				return
+			}
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch l.keyword() {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, _ := operands[i].(String)
+				hi, _ := operands[i+1].(String)
+				if len(lo) > 0 && len(lo) == len(hi) {
+					codeSpace = append(codeSpace, codeRange{lo: lo, hi: hi})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, _ := operands[i].(String)
+				dst, _ := operands[i+1].(String)
+				f.toUnicode[string(src)] = utf16Text(dst)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, _ := operands[i].(String)
+				hi, _ := operands[i+1].(String)
+				f.mapRange(lo, hi, operands[i+2])
+			}
+		case "":
+			l.pos++
+		}
+		operands = operands[:0]
+	}
+
+	if len(codeSpace) > 0 {
+		f.codeSpace = codeSpace
+	}
+}
+
+func (f *textFont) mapRange(lo, hi String, dst Object) {
+	if len(lo) == 0 || len(lo) != len(hi) || len(lo) > 4 {
+		return
+	}
+	start, end := codeValue(lo), codeValue(hi)
+	if end < start || end-start > 65535 {
+		return
+	}
+
+	for code := start; code <= end; code++ {
+		src := make([]byte, len(lo))
+		for i := range src {
+			src[len(src)-1-i] = byte(code >> (8 * i))
+		}
+
+		switch d := dst.(type) {
+		case String:
+			// The last byte of the destination is incremented along the range
+			out := append([]byte{}, d...)
+			if len(out) > 0 {
+				out[len(out)-1] += byte(code - start)
+			}
+			f.toUnicode[string(src)] = utf16Text(out)
+		case Array:
+			if int(code-start) < len(d) {
+				if s, ok := d[code-start].(String); ok {
+					f.toUnicode[string(src)] = utf16Text(s)
+				}
+			}
+		}
+	}
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// utf16Text decodes the UTF-16BE text of a ToUnicode destination
+func utf16Text(b []byte) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(b); i += 2 {
+		r := rune(b[i])<<8 | rune(b[i+1])
+		if r >= 0xD800 && r < 0xDC00 && i+3 < len(b) {
+			low := rune(b[i+2])<<8 | rune(b[i+3])
+			if low >= 0xDC00 && low < 0xE000 {
+				r = (r-0xD800)<<10 + (low - 0xDC00) + 0x10000
+				i += 2
+			}
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// nextCode returns the length of the code starting s, following the code space of the font
+func (f *textFont) nextCode(s []byte) int {
+	if f.codeSpace == nil {
+		return 1
+	}
+	for n := 1; n <= 4 && n <= len(s); n++ {
+		for _, cr := range f.codeSpace {
+			if len(cr.lo) != n {
+				continue
+			}
+			inside := true
+			for i := 0; i < n; i++ {
+				if s[i] < cr.lo[i] || s[i] > cr.hi[i] {
+					inside = false
+					break
+				}
+			}
+			if inside {
+				return n
+			}
+		}
+	}
+	return min(len(f.codeSpace[0].lo), len(s))
+}
+
+func (f *textFont) decode(s String) []glyph {
+	var glyphs []glyph
+	for len(s) > 0 {
+		n := f.nextCode(s)
+		code := s[:n]
+		s = s[n:]
+
+		g := glyph{}
+		if text, ok := f.toUnicode[string(code)]; ok {
+			g.text = text
+		} else if f.encoding != nil && n == 1 {
+			g.text = f.encoding[code[0]]
+		}
+		g.text = strings.ToValidUTF8(strings.Map(func(r rune) rune {
+			if r < 32 && r != '\t' || r == utf8.RuneError {
+				return -1
+			}
+			return r
+		}, g.text), "")
+
+		width, ok := f.widths[codeValue(code)]
+		if !ok {
+			width = f.dw
+		}
+		g.width = width
+		g.space = n == 1 && code[0] == ' '
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
@@ -0,0 +1,124 @@
+package pdf
+
+import "testing"
+
+// pageText returns the text of a page drawn by content, with the resources made by resources or a Helvetica F1
+func pageText(t *testing.T, content string, resources func(w *Writer) Dict) string {
+	t.Helper()
+
+	w := NewWriter()
+	catalog, tree := w.Alloc(), w.Alloc()
+	res := Dict{"Font": Dict{"F1": w.Add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Helvetica")})}}
+	if resources != nil {
+		res = resources(w)
+	}
+	page := w.Add(Dict{"Type": Name("Page"), "Parent": tree, "Resources": res, "Contents": w.AddStream(Dict{}, []byte(content), true)})
+	w.Set(tree, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	w.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": tree})
+
+	r, err := Open(w.Finish(Dict{"Root": catalog}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := r.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := r.PageText(pages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return text
+}
+
+const toUnicodeCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Test-UCS def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+1 beginbfchar
+<0001> <0048>
+endbfchar
+2 beginbfrange
+<0002> <0003> <0069>
+<0004> <0004> [<D83DDE00>]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+
+func TestPageText(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		resources func(w *Writer) Dict
+		want      string
+	}{
+		{"lines", "BT /F1 12 Tf 72 720 Td (Hello) Tj ( world) Tj 0 -14 Td (Next line) Tj ET", nil, "Hello world\nNext line"},
+		{"kerning and word gaps", "BT /F1 12 Tf 72 720 Td [(Hel) 20 (lo) -300 (world)] TJ ET", nil, "Hello world"},
+		{"leading", "BT /F1 10 Tf 14 TL 72 720 Td (One) Tj T* (Two) Tj (Three) ' 0 0 (Four) \" ET", nil, "One\nTwo\nThree\nFour"},
+		{"text matrix", "BT /F1 1 Tf 12 0 0 12 72 720 Tm (Scaled) Tj 12 0 0 12 72 700 Tm (text) Tj ET", nil, "Scaled\ntext"},
+		{"gap after a move on the line", "BT /F1 12 Tf 72 720 Td (Name:) Tj 100 0 Td (Ada) Tj ET", nil, "Name: Ada"},
+		{"win ansi", "BT /F1 12 Tf 72 720 Td (caf\\351 \\223quoted\\224 \\200 \\(1\\)) Tj ET", nil, "caf\u00e9 “quoted” € (1)"},
+		{"unknown font", "BT /F9 12 Tf 72 720 Td (Hidden) Tj /F1 12 Tf (Shown) Tj ET", nil, "Shown"},
+		{"inline image", "BI /W 2 /H 1 /BPC 8 /CS /G ID \x00EI\x01 EI BT /F1 12 Tf 72 720 Td (After) Tj ET", nil, "After"},
+		{"differences", "BT /F1 12 Tf 72 720 Td (ABCDE) Tj ET", func(w *Writer) Dict {
+			encoding := Dict{"Differences": Array{int64(65), Name("eacute"), Name("fi"), Name("uni20AC"), Name("a.sc"), Name("nonsense")}}
+			return Dict{"Font": Dict{"F1": w.Add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Times-Roman"), "Encoding": encoding})}}
+		}, "e\u0301fi€a"},
+		{"to unicode", "BT /F1 12 Tf 72 720 Td <0001000200030004> Tj ET", func(w *Writer) Dict {
+			cid := w.Add(Dict{"Type": Name("Font"), "Subtype": Name("CIDFontType2"), "DW": int64(1000), "W": Array{int64(1), Array{int64(500), int64(500)}, int64(3), int64(4), int64(600)}})
+			font := w.Add(Dict{
+				"Type":            Name("Font"),
+				"Subtype":         Name("Type0"),
+				"Encoding":        Name("Identity-H"),
+				"DescendantFonts": Array{cid},
+				"ToUnicode":       w.AddStream(Dict{}, []byte(toUnicodeCMap), true),
+			})
+			return Dict{"Font": Dict{"F1": font}}
+		}, "Hij😀"},
+		{"form", "BT /F1 12 Tf 72 720 Td (Page) Tj ET q /Fm1 Do Q", func(w *Writer) Dict {
+			font := w.Add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Helvetica")})
+			form := w.AddStream(Dict{"Type": Name("XObject"), "Subtype": Name("Form"), "BBox": Array{int64(0), int64(0), int64(100), int64(20)}},
+				[]byte("BT /F1 8 Tf 0 0 Td (Stamp) Tj ET"), true)
+			return Dict{"Font": Dict{"F1": font}, "XObject": Dict{"Fm1": form}}
+		}, "Page\nStamp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pageText(t, tt.content, tt.resources); got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlyphText(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"A", "A"},
+		{"space", " "},
+		{"eacute", "e\u0301"},
+		{"Ccedilla", "C\u0327"},
+		{"quotedblleft", "“"},
+		{"f_i", "fi"},
+		{"a.sc", "a"},
+		{"uni20AC", "€"},
+		{"uni00410042", "AB"},
+		{"u1F600", "😀"},
+		{"uniXYZW", ""},
+		{"g123", ""},
+	}
+
+	for _, tt := range tests {
+		if got := glyphText(tt.name); got != tt.want {
+			t.Errorf("glyphText(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
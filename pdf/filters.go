@@ -0,0 +1,237 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var ErrUnsupportedFilter = errors.New("pdf: unsupported stream filter")
+
+// maxDecodedSize guards against decompression bombs
+const maxDecodedSize = 256 << 20
+
+// Decode applies the stream's filters and returns the decoded bytes
+func (r *Reader) Decode(s *Stream) ([]byte, error) {
+	data := s.Raw
+
+	filters := []Object{}
+	switch f := r.Resolve(s.Dict["Filter"]).(type) {
+	case Name:
+		filters = append(filters, f)
+	case Array:
+		filters = f
+	}
+
+	params := []Object{}
+	switch p := r.Resolve(s.Dict["DecodeParms"]).(type) {
+	case Dict:
+		params = append(params, p)
+	case Array:
+		params = p
+	}
+
+	for i, f := range filters {
+		name, _ := r.Resolve(f).(Name)
+
+		var param Dict
+		if i < len(params) {
+			param, _ = r.Resolve(params[i]).(Dict)
+		}
+
+		var err error
+		switch name {
+		case "FlateDecode", "Fl":
+			data, err = flateDecode(data)
+			if err == nil {
+				data, err = applyPredictor(data, param)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data, err = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedFilter, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func flateDecode(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(zr, maxDecodedSize))
+	// Many writers produce streams with a bad checksum or missing end, keep what was inflated
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+func applyPredictor(data []byte, param Dict) ([]byte, error) {
+	if param == nil {
+		return data, nil
+	}
+
+	predictor, _ := Int(param["Predictor"])
+	if predictor < 10 {
+		return data, nil
+	}
+
+	columns, ok := Int(param["Columns"])
+	if !ok || columns <= 0 {
+		columns = 1
+	}
+	colors, ok := Int(param["Colors"])
+	if !ok || colors <= 0 {
+		colors = 1
+	}
+	bpc, ok := Int(param["BitsPerComponent"])
+	if !ok || bpc <= 0 {
+		bpc = 8
+	}
+
+	bpp := int((colors*bpc + 7) / 8)
+	rowLen := int((columns*colors*bpc + 7) / 8)
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+
+	for i := 0; i+1 <= len(data); i += rowLen + 1 {
+		filter := data[i]
+		end := i + 1 + rowLen
+		if end > len(data) {
+			end = len(data)
+		}
+		row := make([]byte, rowLen)
+		copy(row, data[i+1:end])
+
+		for j := 0; j < rowLen; j++ {
+			var left, up, upLeft byte
+			if j >= bpp {
+				left = row[j-bpp]
+				upLeft = prev[j-bpp]
+			}
+			up = prev[j]
+
+			switch filter {
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paeth(left, up, upLeft)
+			}
+		}
+
+		out = append(out, row...)
+		prev = row
+	}
+
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func asciiHexDecode(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isWhitespace(c) {
+			continue
+		}
+		digits = append(digits, c)
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = byte(v)
+	}
+	return out, nil
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	var out []byte
+	var group [5]byte
+	n := 0
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if isWhitespace(c) {
+			continue
+		}
+		if c == '~' {
+			break
+		}
+		if c == 'z' && n == 0 {
+			out = append(out, 0, 0, 0, 0)
+			continue
+		}
+		if c < '!' || c > 'u' {
+			return nil, fmt.Errorf("pdf: invalid ascii85 byte %q", c)
+		}
+		group[n] = c - '!'
+		n++
+		if n == 5 {
+			v := uint32(0)
+			for _, g := range group {
+				v = v*85 + uint32(g)
+			}
+			out = append(out, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+			n = 0
+		}
+	}
+
+	if n > 0 {
+		for i := n; i < 5; i++ {
+			group[i] = 84
+		}
+		v := uint32(0)
+		for _, g := range group {
+			v = v*85 + uint32(g)
+		}
+		tail := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		out = append(out, tail[:n-1]...)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"sort"
+	"strconv"
+)
+
+// Fingerprint hashes an object with all references resolved, so the same content gives the same
+// fingerprint regardless of object numbering. Stream length and filter entries are ignored and
+// stream data is compared decoded.
+func (r *Reader) Fingerprint(o Object) []byte {
+	f := fingerprinter{r: r, done: map[int][]byte{}, active: map[int]bool{}}
+	return f.hash(o, 0)
+}
+
+type fingerprinter struct {
+	r      *Reader
+	done   map[int][]byte
+	active map[int]bool
+}
+
+func (f *fingerprinter) hash(o Object, depth int) []byte {
+	h := sha256.New()
+
+	if depth > 48 {
+		h.Write([]byte("deep"))
+		return h.Sum(nil)
+	}
+
+	if ref, ok := o.(Ref); ok {
+		if sum, ok := f.done[ref.Num]; ok {
+			return sum
+		}
+		if f.active[ref.Num] {
+			h.Write([]byte("cycle"))
+			return h.Sum(nil)
+		}
+		f.active[ref.Num] = true
+		obj, _ := f.r.Object(ref)
+		sum := f.hash(obj, depth+1)
+		delete(f.active, ref.Num)
+		f.done[ref.Num] = sum
+		return sum
+	}
+
+	switch v := o.(type) {
+	case Array:
+		h.Write([]byte("["))
+		for _, item := range v {
+			h.Write(f.hash(item, depth+1))
+		}
+	case Dict:
+		f.writeDict(h, v, nil, depth)
+	case *Stream:
+		f.writeDict(h, v.Dict, []Name{"Length", "Filter", "DecodeParms", "DL"}, depth)
+		data, err := f.r.Decode(v)
+		if errors.Is(err, ErrUnsupportedFilter) {
+			data = v.Raw
+		}
+		h.Write([]byte("stream"))
+		h.Write(bytes.TrimRight(data, "\r\n "))
+	case int64:
+		h.Write([]byte("n" + strconv.FormatFloat(float64(v), 'f', -1, 64)))
+	case float64:
+		h.Write([]byte("n" + strconv.FormatFloat(v, 'f', -1, 64)))
+	default:
+		h.Write([]byte(Format(v)))
+	}
+
+	return h.Sum(nil)
+}
+
+func (f *fingerprinter) writeDict(h hash.Hash, d Dict, skip []Name, depth int) {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		skipped := false
+		for _, s := range skip {
+			if k == s {
+				skipped = true
+			}
+		}
+		if !skipped {
+			keys = append(keys, string(k))
+		}
+	}
+	sort.Strings(keys)
+
+	h.Write([]byte("<<"))
+	for _, k := range keys {
+		h.Write([]byte("/" + k))
+		h.Write(f.hash(d[Name(k)], depth+1))
+	}
+}
@@ -0,0 +1,401 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errSyntax = errors.New("pdf: syntax error")
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// lexer reads pdf objects out of a byte slice
+type lexer struct {
+	data []byte
+	pos  int
+	// resolveLength resolves an indirect /Length of a stream, may be nil
+	resolveLength func(Ref) (int64, bool)
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhitespace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// keyword reads a run of regular characters
+func (l *lexer) keyword() string {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *lexer) expect(kw string) error {
+	if got := l.keyword(); got != kw {
+		return fmt.Errorf("%w: expected %q got %q at %d", errSyntax, kw, got, l.pos)
+	}
+	return nil
+}
+
+func (l *lexer) peekByte() byte {
+	if l.pos < len(l.data) {
+		return l.data[l.pos]
+	}
+	return 0
+}
+
+// readObject reads one direct object. Integers followed by "gen R" become references.
+func (l *lexer) readObject() (Object, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, fmt.Errorf("%w: unexpected end of data", errSyntax)
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		return l.readName(), nil
+	case c == '(':
+		return l.readLiteralString()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		return l.readDict()
+	case c == '<':
+		return l.readHexString()
+	case c == '[':
+		return l.readArray()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.readNumberOrRef()
+	}
+
+	kw := l.keyword()
+	switch kw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		l.pos++
+		return nil, fmt.Errorf("%w: unexpected %q at %d", errSyntax, c, l.pos-1)
+	}
+	return nil, fmt.Errorf("%w: unexpected keyword %q at %d", errSyntax, kw, l.pos)
+}
+
+func (l *lexer) readName() Name {
+	l.pos++ // '/'
+	var buf []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhitespace(c) || isDelimiter(c) {
+			break
+		}
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				buf = append(buf, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		buf = append(buf, c)
+		l.pos++
+	}
+	return Name(buf)
+}
+
+func (l *lexer) readNumber() (Object, error) {
+	start := l.pos
+	isReal := false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '.' {
+			isReal = true
+		} else if !(c >= '0' && c <= '9') && !(l.pos == start && (c == '+' || c == '-')) {
+			break
+		}
+		l.pos++
+	}
+	token := string(l.data[start:l.pos])
+	if isReal {
+		f, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return 0.0, nil
+		}
+		return f, nil
+	}
+	i, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		if token == "+" || token == "-" {
+			return int64(0), nil
+		}
+		return nil, fmt.Errorf("%w: bad number %q", errSyntax, token)
+	}
+	return i, nil
+}
+
+func (l *lexer) readNumberOrRef() (Object, error) {
+	num, err := l.readNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	n, isInt := num.(int64)
+	if !isInt || n < 0 {
+		return num, nil
+	}
+
+	// Look ahead for "gen R"
+	save := l.pos
+	l.skipSpace()
+	if l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		gen, genErr := l.readNumber()
+		if g, ok := gen.(int64); genErr == nil && ok {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+				(l.pos+1 >= len(l.data) || isWhitespace(l.data[l.pos+1]) || isDelimiter(l.data[l.pos+1])) {
+				l.pos++
+				return Ref{Num: int(n), Gen: int(g)}, nil
+			}
+		}
+	}
+	l.pos = save
+	return num, nil
+}
+
+func (l *lexer) readLiteralString() (Object, error) {
+	l.pos++ // '('
+	depth := 1
+	var buf []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			buf = append(buf, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(buf), nil
+			}
+			buf = append(buf, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				break
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if l.peekByte() == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					buf = append(buf, byte(v))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return nil, fmt.Errorf("%w: unterminated string", errSyntax)
+}
+
+func (l *lexer) readHexString() (Object, error) {
+	l.pos++ // '<'
+	var digits []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			out := make([]byte, len(digits)/2)
+			for i := range out {
+				v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				out[i] = byte(v)
+			}
+			return String(out), nil
+		}
+		if isWhitespace(c) {
+			continue
+		}
+		digits = append(digits, c)
+	}
+	return nil, fmt.Errorf("%w: unterminated hex string", errSyntax)
+}
+
+func (l *lexer) readArray() (Object, error) {
+	l.pos++ // '['
+	arr := Array{}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return nil, fmt.Errorf("%w: unterminated array", errSyntax)
+		}
+		if l.data[l.pos] == ']' {
+			l.pos++
+			return arr, nil
+		}
+		obj, err := l.readObject()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, obj)
+	}
+}
+
+func (l *lexer) readDict() (Object, error) {
+	l.pos += 2 // '<<'
+	dict := Dict{}
+	for {
+		l.skipSpace()
+		if l.pos+1 >= len(l.data) {
+			return nil, fmt.Errorf("%w: unterminated dictionary", errSyntax)
+		}
+		if l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return dict, nil
+		}
+		if l.data[l.pos] != '/' {
+			return nil, fmt.Errorf("%w: expected name in dictionary at %d", errSyntax, l.pos)
+		}
+		key := l.readName()
+		value, err := l.readObject()
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			dict[key] = value
+		}
+	}
+}
+
+// readIndirect reads "num gen obj ... endobj" at the current position
+func (l *lexer) readIndirect() (Ref, Object, error) {
+	l.skipSpace()
+	num, err := l.readNumber()
+	if err != nil {
+		return Ref{}, nil, err
+	}
+	l.skipSpace()
+	gen, err := l.readNumber()
+	if err != nil {
+		return Ref{}, nil, err
+	}
+	n, ok1 := num.(int64)
+	g, ok2 := gen.(int64)
+	if !ok1 || !ok2 {
+		return Ref{}, nil, fmt.Errorf("%w: bad object header", errSyntax)
+	}
+	if err := l.expect("obj"); err != nil {
+		return Ref{}, nil, err
+	}
+	ref := Ref{Num: int(n), Gen: int(g)}
+
+	obj, err := l.readObject()
+	if err != nil {
+		return ref, nil, err
+	}
+
+	dict, isDict := obj.(Dict)
+	save := l.pos
+	if isDict && l.keyword() == "stream" {
+		raw, streamErr := l.readStreamData(dict)
+		if streamErr != nil {
+			return ref, nil, streamErr
+		}
+		return ref, &Stream{Dict: dict, Raw: raw}, nil
+	}
+	l.pos = save
+
+	return ref, obj, nil
+}
+
+func (l *lexer) readStreamData(dict Dict) ([]byte, error) {
+	// The keyword is followed by CRLF or LF
+	if l.peekByte() == '\r' {
+		l.pos++
+	}
+	if l.peekByte() == '\n' {
+		l.pos++
+	}
+	start := l.pos
+
+	length := int64(-1)
+	switch v := dict["Length"].(type) {
+	case int64:
+		length = v
+	case Ref:
+		if l.resolveLength != nil {
+			if n, ok := l.resolveLength(v); ok {
+				length = n
+			}
+		}
+	}
+
+	if length >= 0 && start+int(length) <= len(l.data) {
+		end := start + int(length)
+		check := lexer{data: l.data, pos: end}
+		if check.keyword() == "endstream" {
+			l.pos = check.pos
+			return l.data[start:end], nil
+		}
+	}
+
+	// Length missing or wrong, fall back to searching for the end marker
+	idx := bytes.Index(l.data[start:], []byte("endstream"))
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: unterminated stream", errSyntax)
+	}
+	end := start + idx
+	l.pos = end + len("endstream")
+	for end > start && (l.data[end-1] == '\n' || l.data[end-1] == '\r') {
+		end--
+	}
+	return l.data[start:end], nil
+}
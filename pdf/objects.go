@@ -0,0 +1,118 @@
+package pdf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Object is any pdf object: nil, bool, int64, float64, Name, String, Array, Dict, Ref or *Stream
+type Object any
+
+type Name string
+
+type String []byte
+
+type Array []Object
+
+type Dict map[Name]Object
+
+type Ref struct {
+	Num int
+	Gen int
+}
+
+type Stream struct {
+	Dict Dict
+	Raw  []byte
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%d %d R", r.Num, r.Gen)
+}
+
+// Int returns the integer value of a number object
+func Int(o Object) (int64, bool) {
+	switch v := o.(type) {
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// Number returns the float value of a number object
+func Number(o Object) (float64, bool) {
+	switch v := o.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Format serializes an object in pdf syntax. Streams are not supported here, they are written by Writer.
+func Format(o Object) string {
+	var sb strings.Builder
+	writeObject(&sb, o)
+	return sb.String()
+}
+
+func writeObject(sb *strings.Builder, o Object) {
+	switch v := o.(type) {
+	case nil:
+		sb.WriteString("null")
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case int64:
+		sb.WriteString(strconv.FormatInt(v, 10))
+	case int:
+		sb.WriteString(strconv.Itoa(v))
+	case float64:
+		sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case Name:
+		sb.WriteByte('/')
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c < '!' || c > '~' || c == '#' || isDelimiter(c) {
+				fmt.Fprintf(sb, "#%02X", c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	case String:
+		sb.WriteByte('<')
+		fmt.Fprintf(sb, "%X", []byte(v))
+		sb.WriteByte('>')
+	case Array:
+		sb.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			writeObject(sb, item)
+		}
+		sb.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		sb.WriteString("<<")
+		for _, k := range keys {
+			writeObject(sb, Name(k))
+			sb.WriteByte(' ')
+			writeObject(sb, v[Name(k)])
+		}
+		sb.WriteString(">>")
+	case Ref:
+		sb.WriteString(v.String())
+	default:
+		sb.WriteString("null")
+	}
+}
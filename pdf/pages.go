@@ -0,0 +1,116 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Page is a leaf of the page tree with inheritable attributes already applied
+type Page struct {
+	Ref  Ref
+	Dict Dict
+}
+
+var inheritable = []Name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// Pages returns the pages of the document in order
+func (r *Reader) Pages() ([]Page, error) {
+	catalog, _ := r.Catalog()
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: missing document catalog", errSyntax)
+	}
+
+	var pages []Page
+	visited := map[Ref]bool{}
+
+	var walk func(node Object, inherited Dict, depth int) error
+	walk = func(node Object, inherited Dict, depth int) error {
+		if depth > 64 {
+			return fmt.Errorf("%w: page tree too deep", errSyntax)
+		}
+
+		ref, isRef := node.(Ref)
+		if isRef {
+			if visited[ref] {
+				return fmt.Errorf("%w: page tree has a cycle", errSyntax)
+			}
+			visited[ref] = true
+		}
+
+		dict, ok := r.Resolve(node).(Dict)
+		if !ok {
+			return fmt.Errorf("%w: bad page tree node", errSyntax)
+		}
+
+		attrs := Dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, key := range inheritable {
+			if v, ok := dict[key]; ok {
+				attrs[key] = v
+			}
+		}
+
+		kids, hasKids := r.Resolve(dict["Kids"]).(Array)
+		if dict["Type"] == Name("Pages") || (hasKids && dict["Type"] != Name("Page")) {
+			for _, kid := range kids {
+				if err := walk(kid, attrs, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		page := Dict{}
+		for k, v := range dict {
+			page[k] = v
+		}
+		for k, v := range attrs {
+			if _, ok := page[k]; !ok {
+				page[k] = v
+			}
+		}
+		pages = append(pages, Page{Ref: ref, Dict: page})
+		return nil
+	}
+
+	if err := walk(catalog["Pages"], Dict{}, 0); err != nil {
+		return nil, err
+	}
+
+	return pages, nil
+}
+
+// Contents returns the content streams of a page in drawing order. Streams with filters this
+// package can not decode are returned raw.
+func (r *Reader) Contents(p Page) ([][]byte, error) {
+	var refs Array
+	switch c := r.Resolve(p.Dict["Contents"]).(type) {
+	case nil:
+		return nil, nil
+	case *Stream:
+		refs = Array{c}
+	case Array:
+		refs = c
+	default:
+		return nil, fmt.Errorf("%w: bad page contents", errSyntax)
+	}
+
+	var out [][]byte
+	for _, item := range refs {
+		stream, ok := r.Resolve(item).(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := r.Decode(stream)
+		if errors.Is(err, ErrUnsupportedFilter) {
+			data = stream.Raw
+		} else if err != nil {
+			return nil, err
+		}
+		out = append(out, data)
+	}
+
+	return out, nil
+}
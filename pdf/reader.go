@@ -0,0 +1,464 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	ErrNotPDF    = errors.New("pdf: not a pdf file")
+	ErrEncrypted = errors.New("pdf: encrypted documents are not supported")
+)
+
+type xrefEntry struct {
+	inStream bool
+	offset   int64
+	gen      int
+	stream   int
+	index    int
+}
+
+// Reader gives random access to the objects of a parsed pdf file
+type Reader struct {
+	data  []byte
+	xref  map[int]xrefEntry
+	cache map[int]Object
+
+	// Trailer of the newest xref section
+	Trailer Dict
+	// StartXref is the offset of the newest xref section
+	StartXref int64
+	// XrefStream is true when the newest xref section is a cross-reference stream
+	XrefStream bool
+}
+
+// Open parses the cross-reference data of a pdf file held in memory
+func Open(data []byte) (*Reader, error) {
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, ErrNotPDF
+	}
+
+	r := &Reader{
+		data:  data,
+		xref:  map[int]xrefEntry{},
+		cache: map[int]Object{},
+	}
+
+	if err := r.readXrefChain(); err != nil {
+		if rebuildErr := r.rebuildXref(); rebuildErr != nil {
+			return nil, fmt.Errorf("%w (rebuild failed: %v)", err, rebuildErr)
+		}
+	}
+
+	if _, ok := r.Trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+
+	if _, ok := r.Resolve(r.Trailer["Root"]).(Dict); !ok {
+		if rebuildErr := r.rebuildXref(); rebuildErr != nil {
+			return nil, rebuildErr
+		}
+		if _, ok := r.Resolve(r.Trailer["Root"]).(Dict); !ok {
+			return nil, fmt.Errorf("%w: missing document catalog", errSyntax)
+		}
+	}
+
+	return r, nil
+}
+
+// Data returns the raw file bytes
+func (r *Reader) Data() []byte {
+	return r.data
+}
+
+// Size is the highest object number in use plus one
+func (r *Reader) Size() int {
+	size := 0
+	if n, ok := Int(r.Trailer["Size"]); ok {
+		size = int(n)
+	}
+	for num := range r.xref {
+		if num+1 > size {
+			size = num + 1
+		}
+	}
+	return size
+}
+
+func (r *Reader) readXrefChain() error {
+	idx := bytes.LastIndex(r.data, []byte("startxref"))
+	if idx < 0 {
+		return fmt.Errorf("%w: missing startxref", errSyntax)
+	}
+
+	l := &lexer{data: r.data, pos: idx + len("startxref")}
+	l.skipSpace()
+	offsetObj, err := l.readNumber()
+	if err != nil {
+		return err
+	}
+	offset, ok := offsetObj.(int64)
+	if !ok || offset <= 0 || offset >= int64(len(r.data)) {
+		return fmt.Errorf("%w: bad startxref offset", errSyntax)
+	}
+	r.StartXref = offset
+
+	seen := map[int64]bool{}
+	first := true
+
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+
+		trailer, isStream, err := r.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+
+		if first {
+			r.Trailer = trailer
+			r.XrefStream = isStream
+			first = false
+		}
+
+		// Hybrid files point at an additional xref stream from the classic trailer
+		if stm, ok := Int(trailer["XRefStm"]); ok && !seen[stm] {
+			seen[stm] = true
+			if _, _, err := r.readXrefSection(stm); err != nil {
+				return err
+			}
+		}
+
+		prev, ok := Int(trailer["Prev"])
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+
+	return nil
+}
+
+func (r *Reader) readXrefSection(offset int64) (Dict, bool, error) {
+	if offset < 0 || offset >= int64(len(r.data)) {
+		return nil, false, fmt.Errorf("%w: xref offset out of range", errSyntax)
+	}
+
+	l := &lexer{data: r.data, pos: int(offset)}
+	save := l.pos
+	if l.keyword() == "xref" {
+		trailer, err := r.readXrefTable(l)
+		return trailer, false, err
+	}
+	l.pos = save
+
+	_, obj, err := l.readIndirect()
+	if err != nil {
+		return nil, false, err
+	}
+	stream, ok := obj.(*Stream)
+	if !ok || stream.Dict["Type"] != Name("XRef") {
+		return nil, false, fmt.Errorf("%w: expected xref stream at %d", errSyntax, offset)
+	}
+
+	return stream.Dict, true, r.readXrefStream(stream)
+}
+
+func (r *Reader) readXrefTable(l *lexer) (Dict, error) {
+	for {
+		l.skipSpace()
+		save := l.pos
+		kw := l.keyword()
+		if kw == "trailer" {
+			break
+		}
+		l.pos = save
+
+		startObj, err := l.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		l.skipSpace()
+		countObj, err := l.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		start, _ := Int(startObj)
+		count, _ := Int(countObj)
+
+		for i := int64(0); i < count; i++ {
+			l.skipSpace()
+			offObj, err := l.readNumber()
+			if err != nil {
+				return nil, err
+			}
+			l.skipSpace()
+			genObj, err := l.readNumber()
+			if err != nil {
+				return nil, err
+			}
+			kind := l.keyword()
+
+			num := int(start + i)
+			if _, exists := r.xref[num]; exists {
+				continue
+			}
+			off, _ := Int(offObj)
+			gen, _ := Int(genObj)
+			if kind == "n" && off > 0 {
+				r.xref[num] = xrefEntry{offset: off, gen: int(gen)}
+			} else if kind == "f" {
+				r.xref[num] = xrefEntry{offset: -1}
+			}
+		}
+	}
+
+	obj, err := l.readObject()
+	if err != nil {
+		return nil, err
+	}
+	trailer, ok := obj.(Dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: bad trailer", errSyntax)
+	}
+	return trailer, nil
+}
+
+func (r *Reader) readXrefStream(s *Stream) error {
+	data, err := r.Decode(s)
+	if err != nil {
+		return err
+	}
+
+	widthsArr, _ := s.Dict["W"].(Array)
+	if len(widthsArr) != 3 {
+		return fmt.Errorf("%w: bad xref stream /W", errSyntax)
+	}
+	widths := make([]int, 3)
+	rowLen := 0
+	for i, w := range widthsArr {
+		n, _ := Int(w)
+		widths[i] = int(n)
+		rowLen += int(n)
+	}
+	if rowLen == 0 {
+		return fmt.Errorf("%w: bad xref stream /W", errSyntax)
+	}
+
+	index, _ := s.Dict["Index"].(Array)
+	if index == nil {
+		size, _ := Int(s.Dict["Size"])
+		index = Array{int64(0), size}
+	}
+
+	field := func(row []byte, i int) int64 {
+		start := 0
+		for j := 0; j < i; j++ {
+			start += widths[j]
+		}
+		v := int64(0)
+		for _, b := range row[start : start+widths[i]] {
+			v = v<<8 | int64(b)
+		}
+		return v
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := Int(index[i])
+		count, _ := Int(index[i+1])
+
+		for j := int64(0); j < count; j++ {
+			if pos+rowLen > len(data) {
+				return nil
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+
+			num := int(start + j)
+			if _, exists := r.xref[num]; exists {
+				continue
+			}
+
+			kind := int64(1)
+			if widths[0] > 0 {
+				kind = field(row, 0)
+			}
+			switch kind {
+			case 0:
+				r.xref[num] = xrefEntry{offset: -1}
+			case 1:
+				r.xref[num] = xrefEntry{offset: field(row, 1), gen: int(field(row, 2))}
+			case 2:
+				r.xref[num] = xrefEntry{inStream: true, stream: int(field(row, 1)), index: int(field(row, 2))}
+			}
+		}
+	}
+
+	return nil
+}
+
+var objHeader = regexp.MustCompile(`(?m)(?:^|[\r\n\s])(\d+)\s+(\d+)\s+obj\b`)
+
+// rebuildXref recovers a damaged file by scanning for object headers
+func (r *Reader) rebuildXref() error {
+	r.xref = map[int]xrefEntry{}
+	r.cache = map[int]Object{}
+
+	for _, m := range objHeader.FindAllSubmatchIndex(r.data, -1) {
+		num, _ := strconv.Atoi(string(r.data[m[2]:m[3]]))
+		gen, _ := strconv.Atoi(string(r.data[m[4]:m[5]]))
+		// Later definitions win, like incremental updates
+		r.xref[num] = xrefEntry{offset: int64(m[2]), gen: gen}
+	}
+
+	if len(r.xref) == 0 {
+		return fmt.Errorf("%w: no objects found", errSyntax)
+	}
+
+	trailer := Dict{}
+	if idx := bytes.LastIndex(r.data, []byte("trailer")); idx >= 0 {
+		l := &lexer{data: r.data, pos: idx + len("trailer")}
+		if obj, err := l.readObject(); err == nil {
+			if d, ok := obj.(Dict); ok {
+				trailer = d
+			}
+		}
+	}
+
+	if _, ok := r.Resolve(trailer["Root"]).(Dict); !ok {
+		for num := range r.xref {
+			obj := r.Resolve(Ref{Num: num, Gen: r.xref[num].gen})
+			if d, ok := obj.(Dict); ok && d["Type"] == Name("Catalog") {
+				trailer["Root"] = Ref{Num: num, Gen: r.xref[num].gen}
+				break
+			}
+			if s, ok := obj.(*Stream); ok && s.Dict["Type"] == Name("XRef") {
+				if root, ok := s.Dict["Root"].(Ref); ok {
+					trailer["Root"] = root
+				}
+			}
+		}
+	}
+
+	r.Trailer = trailer
+	r.Trailer["Size"] = int64(r.Size())
+	return nil
+}
+
+// Object loads the indirect object with the given number
+func (r *Reader) Object(ref Ref) (Object, error) {
+	if obj, ok := r.cache[ref.Num]; ok {
+		return obj, nil
+	}
+
+	entry, ok := r.xref[ref.Num]
+	if !ok || (!entry.inStream && entry.offset < 0) {
+		return nil, nil
+	}
+
+	var obj Object
+	var err error
+
+	if entry.inStream {
+		obj, err = r.objectFromStream(entry.stream, entry.index, ref.Num)
+	} else {
+		if entry.offset >= int64(len(r.data)) {
+			return nil, fmt.Errorf("%w: object %d out of range", errSyntax, ref.Num)
+		}
+		// Mark as in progress so self referencing lengths do not recurse forever
+		r.cache[ref.Num] = nil
+		l := &lexer{data: r.data, pos: int(entry.offset), resolveLength: r.resolveLength}
+		var got Ref
+		got, obj, err = l.readIndirect()
+		delete(r.cache, ref.Num)
+		if err == nil && got.Num != ref.Num {
+			err = fmt.Errorf("%w: xref entry for object %d points at object %d", errSyntax, ref.Num, got.Num)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache[ref.Num] = obj
+	return obj, nil
+}
+
+func (r *Reader) resolveLength(ref Ref) (int64, bool) {
+	obj, err := r.Object(ref)
+	if err != nil {
+		return 0, false
+	}
+	return Int(obj)
+}
+
+func (r *Reader) objectFromStream(streamNum, index, num int) (Object, error) {
+	obj, err := r.Object(Ref{Num: streamNum})
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*Stream)
+	if !ok {
+		return nil, fmt.Errorf("%w: object stream %d missing", errSyntax, streamNum)
+	}
+
+	data, err := r.Decode(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := Int(stream.Dict["N"])
+	first, _ := Int(stream.Dict["First"])
+
+	l := &lexer{data: data}
+	for i := int64(0); i < n; i++ {
+		l.skipSpace()
+		numObj, err := l.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		l.skipSpace()
+		offObj, err := l.readNumber()
+		if err != nil {
+			return nil, err
+		}
+
+		objNum, _ := Int(numObj)
+		off, _ := Int(offObj)
+		if int(i) == index || int(objNum) == num {
+			if int(first+off) >= len(data) {
+				return nil, fmt.Errorf("%w: object %d out of range", errSyntax, num)
+			}
+			inner := &lexer{data: data, pos: int(first + off)}
+			return inner.readObject()
+		}
+	}
+
+	return nil, fmt.Errorf("%w: object %d not found in stream %d", errSyntax, num, streamNum)
+}
+
+// Resolve follows references until a direct object is reached
+func (r *Reader) Resolve(o Object) Object {
+	for depth := 0; depth < 32; depth++ {
+		ref, ok := o.(Ref)
+		if !ok {
+			return o
+		}
+		obj, err := r.Object(ref)
+		if err != nil {
+			return nil
+		}
+		o = obj
+	}
+	return nil
+}
+
+// Catalog returns the document catalog and its reference
+func (r *Reader) Catalog() (Dict, Ref) {
+	ref, _ := r.Trailer["Root"].(Ref)
+	catalog, _ := r.Resolve(r.Trailer["Root"]).(Dict)
+	return catalog, ref
+}
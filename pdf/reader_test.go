@@ -0,0 +1,451 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+// buildPDF lays out a file with a classic cross-reference table by hand, object i+1 being objects[i], so that
+// the reader is not only tested on files of Writer
+func buildPDF(objects []string, trailer string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+// testObjects is a document of two pages, the second rotated, which inherit their resources and media box from
+// the page tree. The first page draws two content streams, the second of an indirect length.
+var testObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 612 792] /Resources << /Font << /F1 5 0 R >> >> >>",
+	"<< /Type /Page /Parent 2 0 R /Contents [6 0 R 7 0 R] >>",
+	"<< /Type /Page /Parent 2 0 R /Rotate 90 /MediaBox [0 0 842 595] /Contents 9 0 R >>",
+	"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	"<< /Length 34 >>\nstream\nBT /F1 12 Tf 72 720 Td (One) Tj ET\nendstream",
+	"<< /Length 8 0 R >>\nstream\nBT /F1 12 Tf 72 700 Td (Two) Tj ET\nendstream",
+	"34",
+	"<< /Length 99 >>\nstream\nBT /F1 12 Tf 72 500 Td (Three) Tj ET\nendstream",
+}
+
+func TestReadObject(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Object
+	}{
+		{"/Name#20with#2Fslash", Name("Name with/slash")},
+		{`(a (nested) \(escaped\) \101\7\0053)`, String("a (nested) (escaped) A\x07\x053")},
+		{"(line\\\ncontinued\\n\\t\\\\)", String("linecontinued\n\t\\")},
+		{"<48656C6C6F>", String("Hello")},
+		{"<48 65 6c 6c 6>", String("Hell`")},
+		{"[1 -2.5 +3 .5 true false null /N 4 0 R]", Array{int64(1), -2.5, int64(3), 0.5, true, false, nil, Name("N"), Ref{Num: 4}}},
+		{"<</A 1 /B null /C <</D [2 0 R]>>>>", Dict{"A": int64(1), "C": Dict{"D": Array{Ref{Num: 2}}}}},
+		{"12 0 obj", int64(12)},
+		{"% comment\n42", int64(42)},
+		{"[1 2 R]", Array{Ref{Num: 1, Gen: 2}}},
+	}
+
+	for _, tt := range tests {
+		l := &lexer{data: []byte(tt.in)}
+		got, err := l.readObject()
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"(unterminated", "[1 2", "<</A>>", "<</A 1", ")", "<4142", "endobj", "[1 0 obj]", "[1 2 Rx]"} {
+		l := &lexer{data: []byte(in)}
+		if got, err := l.readObject(); !errors.Is(err, errSyntax) {
+			t.Errorf("%q = %#v, %v, want a syntax error", in, got, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	obj := Dict{
+		"Type":  Name("A B/C#"),
+		"Text":  String("(x)"),
+		"Items": Array{int64(1), 2.5, true, nil, Ref{Num: 3}},
+	}
+	want := "<</Items [1 2.5 true null 3 0 R]/Text <287829>/Type /A#20B#2FC#23>>"
+	if got := Format(obj); got != want {
+		t.Errorf("Format = %s, want %s", got, want)
+	}
+
+	l := &lexer{data: []byte(Format(obj))}
+	if back, err := l.readObject(); err != nil || !reflect.DeepEqual(back, obj) {
+		t.Errorf("read back %#v, %v", back, err)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	r := &Reader{}
+	deflate := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name   string
+		stream *Stream
+		want   string
+	}{
+		{"none", &Stream{Dict: Dict{}, Raw: []byte("raw")}, "raw"},
+		{"flate", &Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Raw: deflate([]byte("inflated"))}, "inflated"},
+		{"ascii hex", &Stream{Dict: Dict{"Filter": Name("AHx")}, Raw: []byte("48 65\n6c6C 6F>")}, "Hello"},
+		{"ascii85", &Stream{Dict: Dict{"Filter": Name("ASCII85Decode")}, Raw: []byte("87cURD_*#4DfTZ)+T~>")}, "Hello, World!"},
+		{"ascii85 zeros", &Stream{Dict: Dict{"Filter": Name("A85")}, Raw: []byte("z@:E^~>")}, "\x00\x00\x00\x00abc"},
+		{"chained", &Stream{Dict: Dict{"Filter": Array{Name("AHx"), Name("Fl")}}, Raw: []byte(fmt.Sprintf("%X>", deflate([]byte("both"))))}, "both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Decode(tt.stream)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("decoded %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := r.Decode(&Stream{Dict: Dict{"Filter": Name("DCTDecode")}}); !errors.Is(err, ErrUnsupportedFilter) {
+		t.Errorf("DCTDecode = %v, want ErrUnsupportedFilter", err)
+	}
+	if _, err := r.Decode(&Stream{Dict: Dict{"Filter": Name("A85")}, Raw: []byte("87cUR{~>")}); err == nil {
+		t.Error("decoded invalid ascii85")
+	}
+}
+
+// TestPredictor decodes the image data of a PNG, whose rows are filtered as those of a FlateDecode stream with a
+// PNG predictor. The encoder picks the filter of every row, noise and gradients make it use each of them.
+func TestPredictor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	seed := uint32(1)
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			seed = seed*1664525 + 1013904223
+			c := color.RGBA{uint8(x * 6), uint8(y * 6), uint8(x * y), 255}
+			if y%4 == 0 {
+				c = color.RGBA{uint8(seed >> 24), uint8(seed >> 16), uint8(seed >> 8), 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var file bytes.Buffer
+	if err := png.Encode(&file, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// The IDAT chunks hold the zlib stream of the filtered rows
+	var idat []byte
+	data := file.Bytes()[8:]
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data)
+		if string(data[4:8]) == "IDAT" {
+			idat = append(idat, data[8:8+length]...)
+		}
+		data = data[12+length:]
+	}
+
+	r := &Reader{}
+	got, err := r.Decode(&Stream{
+		Dict: Dict{"Filter": Name("FlateDecode"), "DecodeParms": Dict{"Predictor": int64(15), "Colors": int64(3), "Columns": int64(40)}},
+		Raw:  idat,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []byte
+	for i := 0; i < len(img.Pix); i += 4 {
+		want = append(want, img.Pix[i:i+3]...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decoded %d bytes differ from the %d pixel bytes", len(got), len(want))
+	}
+}
+
+// checkTestDocument checks a reader of testObjects
+func checkTestDocument(t *testing.T, r *Reader) {
+	t.Helper()
+
+	pages, err := r.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("%d pages", len(pages))
+	}
+
+	if got := Format(pages[0].Dict["MediaBox"]); got != "[0 0 612 792]" {
+		t.Errorf("inherited media box = %s", got)
+	}
+	if got := Format(pages[1].Dict["MediaBox"]); got != "[0 0 842 595]" || pages[1].Dict["Rotate"] != int64(90) {
+		t.Errorf("second page media box %s rotate %v", got, pages[1].Dict["Rotate"])
+	}
+	if pages[0].Ref != (Ref{Num: 3}) || pages[1].Ref != (Ref{Num: 4}) {
+		t.Errorf("page refs = %v %v", pages[0].Ref, pages[1].Ref)
+	}
+
+	contents, err := r.Contents(pages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{[]byte("BT /F1 12 Tf 72 720 Td (One) Tj ET"), []byte("BT /F1 12 Tf 72 700 Td (Two) Tj ET")}
+	if !reflect.DeepEqual(contents, want) {
+		t.Errorf("contents = %q", contents)
+	}
+	// The length of 99 is wrong, the stream ends at endstream
+	if contents, err := r.Contents(pages[1]); err != nil || len(contents) != 1 || string(contents[0]) != "BT /F1 12 Tf 72 500 Td (Three) Tj ET" {
+		t.Errorf("contents of the second page = %q, %v", contents, err)
+	}
+
+	resources, _ := r.Resolve(pages[1].Dict["Resources"]).(Dict)
+	fonts, _ := r.Resolve(resources["Font"]).(Dict)
+	if font, _ := r.Resolve(fonts["F1"]).(Dict); font["BaseFont"] != Name("Helvetica") {
+		t.Errorf("font = %v", font)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	file := buildPDF(testObjects, "/Root 1 0 R")
+	r, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDocument(t, r)
+	if r.Size() != 10 || r.XrefStream || r.StartXref != int64(bytes.LastIndex(file, []byte("\nxref\n"))+1) {
+		t.Errorf("size %d xref stream %v start %d", r.Size(), r.XrefStream, r.StartXref)
+	}
+	// Object 0 is only known from the table, it is not in a rebuilt one
+	if entry, ok := r.xref[0]; !ok || entry.offset != -1 {
+		t.Errorf("xref table not read, entry of object 0 = %+v", entry)
+	}
+
+	// Offsets shifted by a line added at the start, as by a broken transfer, are rebuilt from the object headers
+	shifted, err := Open(append([]byte("%PDF-1.4 junk\n"), file...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDocument(t, shifted)
+
+	noXref, err := Open(file[:bytes.LastIndex(file, []byte("\nxref\n"))])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestDocument(t, noXref)
+}
+
+func TestOpenRejects(t *testing.T) {
+	cyclic := append([]string{}, testObjects...)
+	cyclic[2] = "<< /Type /Pages /Kids [2 0 R] >>"
+	cyclicReader, err := Open(buildPDF(cyclic, "/Root 1 0 R"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cyclicReader.Pages(); !errors.Is(err, errSyntax) {
+		t.Errorf("cyclic page tree = %v, want a syntax error", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"not a pdf", []byte("<html>not a pdf</html>"), ErrNotPDF},
+		{"encrypted", buildPDF(testObjects, "/Root 1 0 R /Encrypt << /Filter /Standard >>"), ErrEncrypted},
+		{"no objects", []byte("%PDF-1.4\ntrailer\n<< /Root 1 0 R >>\n%%EOF"), errSyntax},
+		{"no catalog", buildPDF(testObjects[1:], "/Root 9 0 R"), errSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// buildXrefStreamPDF lays out testObjects with the page tree in a compressed object stream, indexed by a
+// cross-reference stream with the PNG Up predictor, as most current writers lay out files
+func buildXrefStreamPDF() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := map[int]int{}
+
+	// Objects 1 to 5 go in the object stream 10, streams and the length stay outside
+	var index, body bytes.Buffer
+	for num := 1; num <= 5; num++ {
+		fmt.Fprintf(&index, "%d %d ", num, body.Len())
+		body.WriteString(testObjects[num-1] + "\n")
+	}
+	for num := 6; num <= 9; num++ {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", num, testObjects[num-1])
+	}
+
+	var objStream bytes.Buffer
+	zw := zlib.NewWriter(&objStream)
+	zw.Write(index.Bytes())
+	zw.Write(body.Bytes())
+	zw.Close()
+	offsets[10] = buf.Len()
+	fmt.Fprintf(&buf, "10 0 obj\n<< /Type /ObjStm /N 5 /First %d /Filter /FlateDecode /Length %d >>\nstream\n", index.Len(), objStream.Len())
+	buf.Write(objStream.Bytes())
+	buf.WriteString("\nendstream\nendobj\n")
+	offsets[11] = buf.Len()
+
+	// Rows of type, offset or object stream, generation or index, each given as the difference to the row above
+	rows := [][]byte{{0, 0, 0, 0, 0, 0xff, 0xff}}
+	for num := 1; num <= 11; num++ {
+		row := make([]byte, 7)
+		if num <= 5 {
+			row[0] = 2
+			binary.BigEndian.PutUint32(row[1:], 10)
+			binary.BigEndian.PutUint16(row[5:], uint16(num-1))
+		} else {
+			row[0] = 1
+			binary.BigEndian.PutUint32(row[1:], uint32(offsets[num]))
+		}
+		rows = append(rows, row)
+	}
+	var predicted bytes.Buffer
+	prev := make([]byte, 7)
+	for _, row := range rows {
+		predicted.WriteByte(2)
+		for i := range row {
+			predicted.WriteByte(row[i] - prev[i])
+		}
+		prev = row
+	}
+	var xrefStream bytes.Buffer
+	zw = zlib.NewWriter(&xrefStream)
+	zw.Write(predicted.Bytes())
+	zw.Close()
+
+	fmt.Fprintf(&buf, "11 0 obj\n<< /Type /XRef /Size 12 /W [1 4 2] /Root 1 0 R /Filter /FlateDecode "+
+		"/DecodeParms << /Predictor 12 /Columns 7 >> /Length %d >>\nstream\n", xrefStream.Len())
+	buf.Write(xrefStream.Bytes())
+	fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", offsets[11])
+	return buf.Bytes()
+}
+
+func TestOpenXrefStream(t *testing.T) {
+	r, err := Open(buildXrefStreamPDF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.XrefStream || r.Size() != 12 {
+		t.Errorf("xref stream %v size %d", r.XrefStream, r.Size())
+	}
+	checkTestDocument(t, r)
+}
+
+// TestOpenIncremental reads a file whose second page was replaced by an appended update
+func TestOpenIncremental(t *testing.T) {
+	file := buildPDF(testObjects, "/Root 1 0 R")
+	original, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(file)
+	page := buf.Len()
+	buf.WriteString("4 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 10 0 R >>\nendobj\n")
+	content := buf.Len()
+	buf.WriteString("10 0 obj\n<< /Length 35 >>\nstream\nBT /F1 12 Tf 72 500 Td (Four) Tj ET\nendstream\nendobj\n")
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n4 1\n%010d 00000 n \n10 1\n%010d 00000 n \ntrailer\n<< /Size 11 /Root 1 0 R /Prev %d >>\nstartxref\n%d\n%%%%EOF\n",
+		page, content, original.StartXref, xref)
+
+	r, err := Open(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := r.Pages()
+	if err != nil || len(pages) != 2 {
+		t.Fatalf("%d pages, %v", len(pages), err)
+	}
+	if _, ok := pages[1].Dict["Rotate"]; ok {
+		t.Error("second page of the original")
+	}
+	if contents, _ := r.Contents(pages[1]); len(contents) != 1 || string(contents[0]) != "BT /F1 12 Tf 72 500 Td (Four) Tj ET" {
+		t.Errorf("contents of the updated page = %q", contents)
+	}
+	if contents, _ := r.Contents(pages[0]); len(contents) != 2 {
+		t.Errorf("contents of the first page = %q", contents)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	r, err := Open(buildPDF(testObjects, "/Root 1 0 R"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, _ := r.Pages()
+
+	// The same first page written by Writer, numbered otherwise and with compressed content
+	w := NewWriter()
+	catalog, tree := w.Alloc(), w.Alloc()
+	font := w.Add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Helvetica"), "Encoding": Name("WinAnsiEncoding")})
+	one := w.AddStream(Dict{}, []byte("BT /F1 12 Tf 72 720 Td (One) Tj ET"), true)
+	two := w.AddStream(Dict{}, []byte("BT /F1 12 Tf 72 700 Td (Two) Tj ET"), false)
+	changed := w.AddStream(Dict{}, []byte("BT /F1 12 Tf 72 700 Td (2) Tj ET"), true)
+	page := w.Add(Dict{"Type": Name("Page"), "Parent": tree, "Contents": Array{one, two}})
+	w.Set(tree, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	w.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": tree})
+	other, err := Open(w.Finish(Dict{"Root": catalog}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		a, b  Object
+		equal bool
+	}{
+		{"contents", pages[0].Dict["Contents"], Array{one, two}, true},
+		{"font", Ref{Num: 5}, font, true},
+		{"changed content", pages[0].Dict["Contents"], Array{one, changed}, false},
+		{"content order", pages[0].Dict["Contents"], Array{two, one}, false},
+		{"numbers", int64(612), 612.0, true},
+		{"other numbers", int64(612), 612.5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := bytes.Equal(r.Fingerprint(tt.a), other.Fingerprint(tt.b)); equal != tt.equal {
+				t.Errorf("equal = %v, want %v", equal, tt.equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrSignatureTooLarge = errors.New("pdf: signature does not fit the reserved space")
+
+type SignatureOptions struct {
+	// Name of the signer, shown by pdf readers
+	Name     string
+	Reason   string
+	Location string
+	Time     time.Time
+	// SubFilter defaults to ETSI.CAdES.detached (PAdES)
+	SubFilter Name
+	// Size in bytes reserved for the CMS signature
+	Size int
+}
+
+const byteRangePlaceholder = "[0 0000000000 0000000000 0000000000]"
+
+// Sign appends an incremental update with an invisible signature field on the first page. The
+// sign function receives the bytes covered by the signature (the whole file except the
+// /Contents value) and returns the DER encoded CMS to embed.
+func Sign(data []byte, opts SignatureOptions, sign func(covered []byte) ([]byte, error)) ([]byte, error) {
+	r, err := Open(data)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, catalogRef := r.Catalog()
+	if catalog == nil || catalogRef.Num == 0 {
+		return nil, fmt.Errorf("%w: missing document catalog", errSyntax)
+	}
+
+	pages, err := r.Pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 || pages[0].Ref.Num == 0 {
+		return nil, fmt.Errorf("%w: document has no pages", errSyntax)
+	}
+
+	if opts.SubFilter == "" {
+		opts.SubFilter = "ETSI.CAdES.detached"
+	}
+	if opts.Size == 0 {
+		opts.Size = 24576
+	}
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+
+	w := NewIncrementalWriter(r)
+
+	sigRef := w.Alloc()
+	fieldRef := w.Alloc()
+
+	// Signature dictionary, written raw so the placeholders can be patched in place
+	var sig strings.Builder
+	sig.WriteString("<</Type /Sig /Filter /Adobe.PPKLite /SubFilter ")
+	sig.WriteString(Format(opts.SubFilter))
+	sig.WriteString(" /ByteRange " + byteRangePlaceholder)
+	sig.WriteString(" /Contents <" + strings.Repeat("0", opts.Size*2) + ">")
+	sig.WriteString(" /M " + Format(String(pdfDate(opts.Time))))
+	if opts.Name != "" {
+		sig.WriteString(" /Name " + Format(String(opts.Name)))
+	}
+	if opts.Reason != "" {
+		sig.WriteString(" /Reason " + Format(String(opts.Reason)))
+	}
+	if opts.Location != "" {
+		sig.WriteString(" /Location " + Format(String(opts.Location)))
+	}
+	sig.WriteString(">>")
+	w.SetRaw(sigRef, sig.String())
+
+	// Invisible widget that is also the signature field
+	pageRef := pages[0].Ref
+	w.Set(fieldRef, Dict{
+		"Type":    Name("Annot"),
+		"Subtype": Name("Widget"),
+		"FT":      Name("Sig"),
+		"T":       String(uniqueFieldName(r, catalog, "Signature")),
+		"V":       sigRef,
+		"Rect":    Array{int64(0), int64(0), int64(0), int64(0)},
+		"F":       int64(132),
+		"P":       pageRef,
+	})
+
+	// Add the widget to the page annotations
+	pageDict, _ := r.Resolve(pageRef).(Dict)
+	newPage := copyDict(pageDict)
+	if annotsRef, ok := pageDict["Annots"].(Ref); ok {
+		annots, _ := r.Resolve(annotsRef).(Array)
+		w.Set(annotsRef, append(append(Array{}, annots...), fieldRef))
+	} else {
+		annots, _ := r.Resolve(pageDict["Annots"]).(Array)
+		newPage["Annots"] = append(append(Array{}, annots...), fieldRef)
+		w.Set(pageRef, newPage)
+	}
+
+	// Register the field in the AcroForm
+	acroForm := copyDict(nil)
+	if existing, ok := r.Resolve(catalog["AcroForm"]).(Dict); ok {
+		acroForm = copyDict(existing)
+	}
+	fields, _ := r.Resolve(acroForm["Fields"]).(Array)
+	acroForm["Fields"] = append(append(Array{}, fields...), fieldRef)
+	acroForm["SigFlags"] = int64(3)
+
+	if acroRef, ok := catalog["AcroForm"].(Ref); ok {
+		w.Set(acroRef, acroForm)
+	} else {
+		newCatalog := copyDict(catalog)
+		newCatalog["AcroForm"] = w.Add(acroForm)
+		w.Set(catalogRef, newCatalog)
+	}
+
+	trailer := Dict{"Root": catalogRef}
+	for _, key := range []Name{"Info", "ID"} {
+		if v, ok := r.Trailer[key]; ok {
+			trailer[key] = v
+		}
+	}
+
+	out := w.Finish(trailer)
+
+	// Locate the placeholders inside the signature dictionary
+	sigStart := int(w.Offset(sigRef))
+	rangeAt := sigStart + bytes.Index(out[sigStart:], []byte(byteRangePlaceholder))
+	contentsAt := sigStart + bytes.Index(out[sigStart:], []byte("/Contents <")) + len("/Contents ")
+	contentsEnd := contentsAt + opts.Size*2 + 2
+
+	byteRange := fmt.Sprintf("[0 %d %d %d]", contentsAt, contentsEnd, len(out)-contentsEnd)
+	if len(byteRange) > len(byteRangePlaceholder) {
+		return nil, fmt.Errorf("%w: byte range", ErrSignatureTooLarge)
+	}
+	byteRange += strings.Repeat(" ", len(byteRangePlaceholder)-len(byteRange))
+	copy(out[rangeAt:], byteRange)
+
+	covered := make([]byte, 0, len(out)-(contentsEnd-contentsAt))
+	covered = append(covered, out[:contentsAt]...)
+	covered = append(covered, out[contentsEnd:]...)
+
+	signature, err := sign(covered)
+	if err != nil {
+		return nil, err
+	}
+	if len(signature) > opts.Size {
+		return nil, ErrSignatureTooLarge
+	}
+
+	copy(out[contentsAt+1:], strings.ToUpper(hex.EncodeToString(signature)))
+
+	return out, nil
+}
+
+func copyDict(d Dict) Dict {
+	out := Dict{}
+	for k, v := range d {
+		out[k] = v
+	}
+	return out
+}
+
+func uniqueFieldName(r *Reader, catalog Dict, base string) string {
+	acroForm, _ := r.Resolve(catalog["AcroForm"]).(Dict)
+	fields, _ := r.Resolve(acroForm["Fields"]).(Array)
+
+	used := map[string]bool{}
+	for _, f := range fields {
+		if field, ok := r.Resolve(f).(Dict); ok {
+			if t, ok := r.Resolve(field["T"]).(String); ok {
+				used[string(t)] = true
+			}
+		}
+	}
+
+	name := base
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, (offset%3600)/60)
+}
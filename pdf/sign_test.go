@@ -0,0 +1,226 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fbn776/inkra/cms"
+)
+
+func newTestSigner(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "Inkra test signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// signature returns the signature dictionary of the named field and the bytes its byte range covers
+func signature(t *testing.T, data []byte, field string) (Dict, []byte) {
+	t.Helper()
+
+	r, err := Open(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, _ := r.Catalog()
+	acroForm, _ := r.Resolve(catalog["AcroForm"]).(Dict)
+	if flags, _ := Int(acroForm["SigFlags"]); flags != 3 {
+		t.Errorf("signature flags = %d", flags)
+	}
+	fields, _ := r.Resolve(acroForm["Fields"]).(Array)
+
+	for _, f := range fields {
+		d, _ := r.Resolve(f).(Dict)
+		if name, _ := d["T"].(String); string(name) != field {
+			continue
+		}
+		sig, _ := r.Resolve(d["V"]).(Dict)
+		byteRange, _ := sig["ByteRange"].(Array)
+		if len(byteRange) != 4 {
+			t.Fatalf("byte range = %v", sig["ByteRange"])
+		}
+		var at [4]int
+		for i, v := range byteRange {
+			n, _ := Int(v)
+			at[i] = int(n)
+		}
+		// The range covers the whole revision but the hex string of /Contents
+		if at[0] != 0 || data[at[1]] != '<' || data[at[2]-1] != '>' || at[2]+at[3] > len(data) {
+			t.Fatalf("byte range = %v of %d bytes", at, len(data))
+		}
+		return sig, append(append([]byte{}, data[:at[1]]...), data[at[2]:at[2]+at[3]]...)
+	}
+
+	t.Fatalf("no signature field %s", field)
+	return nil, nil
+}
+
+func TestSign(t *testing.T) {
+	cert, key := newTestSigner(t)
+	sign := func(covered []byte) ([]byte, error) {
+		return cms.Sign(covered, cms.SignOptions{Certificate: cert, Key: key, Detached: true})
+	}
+
+	d := NewTextDocument("Lease")
+	d.Text(Helvetica, 12, 0, "Lease agreement")
+	original := d.Bytes()
+
+	signed, err := Sign(original, SignatureOptions{
+		Name:     "Ada Lovelace",
+		Reason:   "Agreed (in full)",
+		Location: "London",
+		Time:     time.Date(2026, 10, 18, 14, 30, 0, 0, time.FixedZone("", 2*3600)),
+	}, sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(signed, original) {
+		t.Fatal("the signed file does not start with the original")
+	}
+
+	sig, covered := signature(t, signed, "Signature")
+	if len(covered)+2*24576+2 != len(signed) {
+		t.Errorf("%d bytes covered of %d", len(covered), len(signed))
+	}
+	want := map[Name]Object{
+		"Type":      Name("Sig"),
+		"Filter":    Name("Adobe.PPKLite"),
+		"SubFilter": Name("ETSI.CAdES.detached"),
+		"M":         String("D:20261018143000+02'00'"),
+		"Name":      String("Ada Lovelace"),
+		"Reason":    String("Agreed (in full)"),
+		"Location":  String("London"),
+	}
+	for k, v := range want {
+		if Format(sig[k]) != Format(v) {
+			t.Errorf("%s = %s, want %s", k, Format(sig[k]), Format(v))
+		}
+	}
+
+	contents, _ := sig["Contents"].(String)
+	sd, err := cms.Parse([]byte(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, err := sd.Verify(covered); err != nil || !signer.Equal(cert) {
+		t.Errorf("verify = %v", err)
+	}
+	covered[len(original)/2] ^= 1
+	if _, err := sd.Verify(covered); !errors.Is(err, cms.ErrDigestMismatch) {
+		t.Errorf("verify of changed content = %v, want ErrDigestMismatch", err)
+	}
+
+	// The page keeps its text and gets the widget of the field
+	r, err := Open(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, _ := r.Pages()
+	if text, _ := r.PageText(pages[0]); text != "Lease agreement\nPage 1 of 1" {
+		t.Errorf("text = %q", text)
+	}
+	if annots, _ := r.Resolve(pages[0].Dict["Annots"]).(Array); len(annots) != 1 {
+		t.Errorf("annotations = %v", annots)
+	}
+
+	// A second signature gets its own field and leaves the first revision, and its signature, as they are
+	countersigned, err := Sign(signed, SignatureOptions{SubFilter: "adbe.pkcs7.detached", Size: 4096}, sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(countersigned, signed) {
+		t.Fatal("the countersigned file does not start with the signed one")
+	}
+	sig2, covered2 := signature(t, countersigned, "Signature2")
+	if sig2["SubFilter"] != Name("adbe.pkcs7.detached") {
+		t.Errorf("sub filter = %v", sig2["SubFilter"])
+	}
+	contents2, _ := sig2["Contents"].(String)
+	if sd2, err := cms.Parse([]byte(contents2)); err != nil {
+		t.Error(err)
+	} else if _, err := sd2.Verify(covered2); err != nil {
+		t.Errorf("verify of the second signature = %v", err)
+	}
+	if _, first := signature(t, countersigned, "Signature"); len(first) != len(covered) {
+		t.Errorf("first byte range covers %d bytes, was %d", len(first), len(covered))
+	} else if _, err := sd.Verify(first); err != nil {
+		t.Errorf("verify of the first signature after the second = %v", err)
+	}
+}
+
+func TestSignRejects(t *testing.T) {
+	d := NewTextDocument("Lease")
+	d.Text(Helvetica, 12, 0, "Lease agreement")
+	original := d.Bytes()
+	errSigner := errors.New("signer unavailable")
+
+	tests := []struct {
+		name string
+		data []byte
+		size int
+		sig  []byte
+		err  error
+	}{
+		{"not a pdf", []byte("lease.docx"), 0, nil, ErrNotPDF},
+		{"signature too large", original, 16, make([]byte, 17), ErrSignatureTooLarge},
+		{"signer fails", original, 0, nil, errSigner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Sign(tt.data, SignatureOptions{Size: tt.size}, func([]byte) ([]byte, error) {
+				if tt.sig == nil {
+					return nil, errSigner
+				}
+				return tt.sig, nil
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPDFDate(t *testing.T) {
+	tests := []struct {
+		time time.Time
+		want string
+	}{
+		{time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "D:20260102030405+00'00'"},
+		{time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("", -(5*3600+30*60))), "D:20260102030405-05'30'"},
+		{time.Date(2026, 12, 31, 23, 59, 59, 0, time.FixedZone("", 14*3600)), "D:20261231235959+14'00'"},
+	}
+
+	for _, tt := range tests {
+		if got := pdfDate(tt.time); got != tt.want {
+			t.Errorf("pdfDate(%v) = %s, want %s", tt.time, got, tt.want)
+		}
+	}
+}
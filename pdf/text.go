@@ -0,0 +1,247 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Font is one of the standard fonts available to TextDocument
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	Courier
+)
+
+var fontResources = []struct {
+	name     Name
+	baseFont Name
+}{
+	{"F1", "Helvetica"},
+	{"F2", "Helvetica-Bold"},
+	{"F3", "Courier"},
+}
+
+// Widths of the printable ASCII characters (32-126) in thousandths of the font size
+var helveticaWidths = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = []int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+func charWidth(font Font, c byte) int {
+	if font == Courier {
+		return 600
+	}
+	if c < 32 || c > 126 {
+		return 556
+	}
+	if font == HelveticaBold {
+		return helveticaBoldWidths[c-32]
+	}
+	return helveticaWidths[c-32]
+}
+
+// TextWidth is the width of the text in points
+func TextWidth(font Font, size float64, text string) float64 {
+	total := 0
+	for _, c := range []byte(winAnsi(text)) {
+		total += charWidth(font, c)
+	}
+	return float64(total) * size / 1000
+}
+
+// winAnsi maps text to the WinAnsi encoding used by the standard fonts, unknown characters become '?'
+func winAnsi(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			sb.WriteByte(' ')
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			sb.WriteByte(byte(r))
+		case r == '–' || r == '—':
+			sb.WriteByte('-')
+		case r == '‘' || r == '’':
+			sb.WriteByte('\'')
+		case r == '“' || r == '”':
+			sb.WriteByte('"')
+		case r < 32:
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
+
+type textRun struct {
+	font Font
+	size float64
+	x    float64
+	y    float64
+	text string
+}
+
+// TextDocument lays out simple flowing text on A4 pages
+type TextDocument struct {
+	Title string
+
+	pages  [][]textRun
+	cursor float64
+}
+
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 56.0
+)
+
+func NewTextDocument(title string) *TextDocument {
+	d := &TextDocument{Title: title}
+	d.newPage()
+	return d
+}
+
+func (d *TextDocument) newPage() {
+	d.pages = append(d.pages, nil)
+	d.cursor = pageHeight - margin
+}
+
+// Space moves the cursor down
+func (d *TextDocument) Space(points float64) {
+	d.cursor -= points
+}
+
+// Text writes wrapped text starting at the left margin plus indent
+func (d *TextDocument) Text(font Font, size float64, indent float64, text string) {
+	maxWidth := pageWidth - 2*margin - indent
+	lineHeight := size * 1.35
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		for _, line := range wrap(font, size, maxWidth, paragraph) {
+			if d.cursor-lineHeight < margin {
+				d.newPage()
+			}
+			d.cursor -= lineHeight
+			page := len(d.pages) - 1
+			d.pages[page] = append(d.pages[page], textRun{font: font, size: size, x: margin + indent, y: d.cursor, text: line})
+		}
+	}
+}
+
+// Field writes a bold label followed by its value on the next line
+func (d *TextDocument) Field(label string, value string, font Font) {
+	d.Text(HelveticaBold, 9, 0, label)
+	if value == "" {
+		value = "-"
+	}
+	d.Text(font, 10, 0, value)
+	d.Space(4)
+}
+
+func wrap(font Font, size float64, maxWidth float64, text string) []string {
+	text = winAnsi(text)
+	if TextWidth(font, size, text) <= maxWidth {
+		return []string{text}
+	}
+
+	var lines []string
+	line := ""
+	for _, word := range strings.Split(text, " ") {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if TextWidth(font, size, candidate) <= maxWidth {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		// Break words that are longer than a line, such as hashes
+		for TextWidth(font, size, word) > maxWidth {
+			cut := len(word)
+			for cut > 1 && TextWidth(font, size, word[:cut]) > maxWidth {
+				cut--
+			}
+			lines = append(lines, word[:cut])
+			word = word[cut:]
+		}
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func escapeText(text string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	return r.Replace(text)
+}
+
+// Bytes renders the document as a pdf file
+func (d *TextDocument) Bytes() []byte {
+	w := NewWriter()
+
+	catalogRef := w.Alloc()
+	pagesRef := w.Alloc()
+
+	fonts := Dict{}
+	for _, f := range fontResources {
+		fonts[f.name] = w.Add(Dict{
+			"Type":     Name("Font"),
+			"Subtype":  Name("Type1"),
+			"BaseFont": f.baseFont,
+			"Encoding": Name("WinAnsiEncoding"),
+		})
+	}
+	resources := w.Add(Dict{"Font": fonts})
+
+	kids := Array{}
+	for i, runs := range d.pages {
+		var content bytes.Buffer
+		for _, run := range runs {
+			fmt.Fprintf(&content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+				fontResources[run.font].name, run.size, run.x, run.y, escapeText(run.text))
+		}
+
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(d.pages))
+		fmt.Fprintf(&content, "BT /F1 8.0 Tf %.2f %.2f Td (%s) Tj ET\n",
+			pageWidth-margin-TextWidth(Helvetica, 8, footer), margin/2, footer)
+
+		contentRef := w.AddStream(Dict{}, content.Bytes(), true)
+		kids = append(kids, w.Add(Dict{
+			"Type":      Name("Page"),
+			"Parent":    pagesRef,
+			"MediaBox":  Array{int64(0), int64(0), pageWidth, pageHeight},
+			"Resources": resources,
+			"Contents":  contentRef,
+		}))
+	}
+
+	w.Set(pagesRef, Dict{"Type": Name("Pages"), "Kids": kids, "Count": int64(len(kids))})
+	w.Set(catalogRef, Dict{"Type": Name("Catalog"), "Pages": pagesRef})
+
+	info := w.Add(Dict{
+		"Title":    String(winAnsi(d.Title)),
+		"Producer": String("Inkra"),
+	})
+
+	return w.Finish(Dict{"Root": catalogRef, "Info": info})
+}
@@ -0,0 +1,230 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+)
+
+// Writer builds a pdf file object by object
+type Writer struct {
+	buf     bytes.Buffer
+	offsets map[int]int64
+	gens    map[int]int
+	next    int
+
+	// Set for incremental updates, the previous xref section and its kind
+	incremental bool
+	prev        int64
+	xrefStream  bool
+}
+
+// NewWriter starts a new pdf file
+func NewWriter() *Writer {
+	w := &Writer{offsets: map[int]int64{}, gens: map[int]int{}, next: 1}
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+// NewIncrementalWriter starts an incremental update appended to the file of r. Objects written
+// with an existing reference replace the previous version.
+func NewIncrementalWriter(r *Reader) *Writer {
+	w := &Writer{
+		offsets:     map[int]int64{},
+		gens:        map[int]int{},
+		next:        r.Size(),
+		incremental: true,
+		prev:        r.StartXref,
+		xrefStream:  r.XrefStream,
+	}
+
+	w.buf.Write(r.Data())
+	if data := r.Data(); len(data) > 0 && data[len(data)-1] != '\n' && data[len(data)-1] != '\r' {
+		w.buf.WriteByte('\n')
+	}
+	return w
+}
+
+// Alloc reserves an object number, so objects can reference each other before they are written
+func (w *Writer) Alloc() Ref {
+	ref := Ref{Num: w.next}
+	w.next++
+	return ref
+}
+
+// Add writes a new object and returns its reference
+func (w *Writer) Add(obj Object) Ref {
+	ref := w.Alloc()
+	w.Set(ref, obj)
+	return ref
+}
+
+// Set writes the object with the given reference
+func (w *Writer) Set(ref Ref, obj Object) {
+	w.begin(ref)
+	w.buf.WriteString(Format(obj))
+	w.buf.WriteString("\nendobj\n")
+}
+
+// SetRaw writes an object whose body is already in pdf syntax
+func (w *Writer) SetRaw(ref Ref, body string) {
+	w.begin(ref)
+	w.buf.WriteString(body)
+	w.buf.WriteString("\nendobj\n")
+}
+
+// Offset returns where the object was written in the output
+func (w *Writer) Offset(ref Ref) int64 {
+	return w.offsets[ref.Num]
+}
+
+// AddStream writes a new stream object, compressed with FlateDecode when compress is set
+func (w *Writer) AddStream(dict Dict, data []byte, compress bool) Ref {
+	ref := w.Alloc()
+	w.SetStream(ref, dict, data, compress)
+	return ref
+}
+
+// SetStream writes a stream object with the given reference
+func (w *Writer) SetStream(ref Ref, dict Dict, data []byte, compress bool) {
+	d := Dict{}
+	for k, v := range dict {
+		d[k] = v
+	}
+
+	if compress {
+		var zbuf bytes.Buffer
+		zw := zlib.NewWriter(&zbuf)
+		zw.Write(data)
+		zw.Close()
+		data = zbuf.Bytes()
+		d["Filter"] = Name("FlateDecode")
+	}
+	d["Length"] = int64(len(data))
+
+	w.begin(ref)
+	w.buf.WriteString(Format(d))
+	w.buf.WriteString("\nstream\n")
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+func (w *Writer) begin(ref Ref) {
+	w.offsets[ref.Num] = int64(w.buf.Len())
+	w.gens[ref.Num] = ref.Gen
+	if ref.Num >= w.next {
+		w.next = ref.Num + 1
+	}
+	fmt.Fprintf(&w.buf, "%d %d obj\n", ref.Num, ref.Gen)
+}
+
+// Finish writes the cross-reference table and trailer and returns the file
+func (w *Writer) Finish(trailer Dict) []byte {
+	if w.incremental {
+		return w.finishIncremental(trailer)
+	}
+
+	xrefOffset := w.buf.Len()
+	w.buf.WriteString("xref\n")
+	fmt.Fprintf(&w.buf, "0 %d\n", w.next)
+	w.buf.WriteString("0000000000 65535 f\r\n")
+	for num := 1; num < w.next; num++ {
+		if off, ok := w.offsets[num]; ok {
+			fmt.Fprintf(&w.buf, "%010d %05d n\r\n", off, w.gens[num])
+		} else {
+			w.buf.WriteString("0000000000 00000 f\r\n")
+		}
+	}
+
+	t := Dict{}
+	for k, v := range trailer {
+		t[k] = v
+	}
+	t["Size"] = int64(w.next)
+
+	w.buf.WriteString("trailer\n")
+	w.buf.WriteString(Format(t))
+	fmt.Fprintf(&w.buf, "\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	return w.buf.Bytes()
+}
+
+// finishIncremental writes a cross-reference section for the new objects only, as a stream when
+// the previous section was one
+func (w *Writer) finishIncremental(trailer Dict) []byte {
+	t := Dict{}
+	for k, v := range trailer {
+		t[k] = v
+	}
+	delete(t, "XRefStm")
+	delete(t, "Prev")
+	if w.prev > 0 {
+		t["Prev"] = w.prev
+	}
+
+	var xrefRef Ref
+	if w.xrefStream {
+		xrefRef = w.Alloc()
+	}
+
+	xrefOffset := int64(w.buf.Len())
+	if w.xrefStream {
+		w.offsets[xrefRef.Num] = xrefOffset
+	}
+
+	nums := make([]int, 0, len(w.offsets))
+	for num := range w.offsets {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	// Group consecutive object numbers into subsections
+	var sections [][]int
+	for _, num := range nums {
+		if n := len(sections); n > 0 && sections[n-1][len(sections[n-1])-1] == num-1 {
+			sections[n-1] = append(sections[n-1], num)
+		} else {
+			sections = append(sections, []int{num})
+		}
+	}
+
+	t["Size"] = int64(w.next)
+
+	if !w.xrefStream {
+		w.buf.WriteString("xref\n")
+		for _, section := range sections {
+			fmt.Fprintf(&w.buf, "%d %d\n", section[0], len(section))
+			for _, num := range section {
+				fmt.Fprintf(&w.buf, "%010d %05d n\r\n", w.offsets[num], w.gens[num])
+			}
+		}
+		w.buf.WriteString("trailer\n")
+		w.buf.WriteString(Format(t))
+		fmt.Fprintf(&w.buf, "\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+		return w.buf.Bytes()
+	}
+
+	index := Array{}
+	var rows bytes.Buffer
+	for _, section := range sections {
+		index = append(index, int64(section[0]), int64(len(section)))
+		for _, num := range section {
+			off := w.offsets[num]
+			rows.Write([]byte{1, byte(off >> 24), byte(off >> 16), byte(off >> 8), byte(off), byte(w.gens[num] >> 8), byte(w.gens[num])})
+		}
+	}
+
+	t["Type"] = Name("XRef")
+	t["W"] = Array{int64(1), int64(4), int64(2)}
+	t["Index"] = index
+	t["Length"] = int64(rows.Len())
+
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", xrefRef.Num)
+	w.buf.WriteString(Format(t))
+	w.buf.WriteString("\nstream\n")
+	w.buf.Write(rows.Bytes())
+	fmt.Fprintf(&w.buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	return w.buf.Bytes()
+}
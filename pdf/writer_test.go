@@ -0,0 +1,200 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	w := NewWriter()
+	catalog, tree := w.Alloc(), w.Alloc()
+	content := w.AddStream(Dict{}, []byte("BT /F1 12 Tf 72 720 Td (Written) Tj ET"), true)
+	raw := w.AddStream(Dict{"Type": Name("Metadata")}, []byte("<x:xmpmeta/>"), false)
+	page := w.Add(Dict{"Type": Name("Page"), "Parent": tree, "MediaBox": Array{int64(0), int64(0), 595.5, int64(842)}, "Contents": content})
+	// Object 6 is left unwritten and becomes a free entry
+	w.Alloc()
+	label := w.Alloc()
+	w.SetRaw(label, "(Label with \\) paren)")
+	w.Set(tree, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	w.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": tree, "Metadata": raw, "Label": label})
+
+	file := w.Finish(Dict{"Root": catalog})
+	if !bytes.HasPrefix(file, []byte("%PDF-1.7\n")) || !bytes.HasSuffix(file, []byte("%%EOF\n")) {
+		t.Errorf("file starts with %q and ends with %q", file[:10], file[len(file)-6:])
+	}
+
+	r, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != 8 || r.XrefStream {
+		t.Errorf("size %d xref stream %v", r.Size(), r.XrefStream)
+	}
+	// Every entry of the table has to point at its object, the reader would otherwise have rebuilt it
+	for num := 1; num < 8; num++ {
+		entry, ok := r.xref[num]
+		if num == 6 {
+			if !ok || entry.offset != -1 {
+				t.Errorf("entry of the unwritten object = %+v", entry)
+			}
+			continue
+		}
+		if !ok || entry.offset != w.Offset(Ref{Num: num}) || !bytes.HasPrefix(file[entry.offset:], fmt.Appendf(nil, "%d 0 obj\n", num)) {
+			t.Errorf("entry of object %d = %+v", num, entry)
+		}
+	}
+
+	pages, err := r.Pages()
+	if err != nil || len(pages) != 1 {
+		t.Fatalf("%d pages, %v", len(pages), err)
+	}
+	if got := Format(pages[0].Dict["MediaBox"]); got != "[0 0 595.5 842]" {
+		t.Errorf("media box = %s", got)
+	}
+	if contents, _ := r.Contents(pages[0]); len(contents) != 1 || string(contents[0]) != "BT /F1 12 Tf 72 720 Td (Written) Tj ET" {
+		t.Errorf("contents = %q", contents)
+	}
+
+	cat, _ := r.Catalog()
+	if metadata, _ := r.Resolve(cat["Metadata"]).(*Stream); metadata == nil || string(metadata.Raw) != "<x:xmpmeta/>" || metadata.Dict["Filter"] != nil {
+		t.Errorf("metadata = %+v", metadata)
+	}
+	if got := r.Resolve(cat["Label"]); string(got.(String)) != "Label with ) paren" {
+		t.Errorf("label = %q", got)
+	}
+}
+
+func TestIncrementalWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		original   []byte
+		xrefStream bool
+		// size counts the stamp, and the xref stream of the update when the original has one
+		size int
+	}{
+		{"xref table", buildPDF(testObjects, "/Root 1 0 R"), false, 11},
+		{"xref stream", buildXrefStreamPDF(), true, 14},
+		{"without end of line", bytes.TrimRight(buildPDF(testObjects, "/Root 1 0 R"), "\n"), false, 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, err := Open(tt.original)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := NewIncrementalWriter(original)
+			stamp := w.AddStream(Dict{}, []byte("BT /F1 8 Tf 72 72 Td (Stamp) Tj ET"), true)
+			w.Set(Ref{Num: 3}, Dict{"Type": Name("Page"), "Parent": Ref{Num: 2}, "Contents": Array{Ref{Num: 6}, Ref{Num: 7}, stamp}})
+			catalog, catalogRef := original.Catalog()
+			w.Set(catalogRef, catalog)
+			updated := w.Finish(Dict{"Root": catalogRef, "XRefStm": int64(1), "Prev": int64(1)})
+
+			if !bytes.HasPrefix(updated, tt.original) {
+				t.Fatal("the update does not start with the original file")
+			}
+			if stamp.Num != original.Size() {
+				t.Errorf("new object %d, want %d", stamp.Num, original.Size())
+			}
+
+			r, err := Open(updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.XrefStream != tt.xrefStream || r.Size() != tt.size {
+				t.Errorf("xref stream %v size %d", r.XrefStream, r.Size())
+			}
+			if prev, _ := Int(r.Trailer["Prev"]); prev != original.StartXref {
+				t.Errorf("previous xref section %d, want %d", prev, original.StartXref)
+			}
+			// The new section is read, not rebuilt from the object headers
+			if r.StartXref <= int64(len(tt.original)) {
+				t.Errorf("xref section at %d", r.StartXref)
+			}
+
+			pages, err := r.Pages()
+			if err != nil || len(pages) != 2 {
+				t.Fatalf("%d pages, %v", len(pages), err)
+			}
+			contents, _ := r.Contents(pages[0])
+			if len(contents) != 3 || string(contents[2]) != "BT /F1 8 Tf 72 72 Td (Stamp) Tj ET" {
+				t.Errorf("contents of the updated page = %q", contents)
+			}
+			if contents, _ := r.Contents(pages[1]); len(contents) != 1 {
+				t.Errorf("contents of the unchanged page = %q", contents)
+			}
+		})
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	tests := []struct {
+		font Font
+		text string
+		want float64
+	}{
+		{Helvetica, "Hello", 22.78},
+		{HelveticaBold, "Hello", 24.45},
+		{Courier, "Hello", 30},
+		// Characters outside WinAnsi are shown as '?'
+		{Helvetica, "€", 5.56},
+		{Helvetica, "–‘’“”", 3.33 + 1.91*2 + 3.55*2},
+	}
+
+	for _, tt := range tests {
+		if got := TextWidth(tt.font, 10, tt.text); fmt.Sprintf("%.2f", got) != fmt.Sprintf("%.2f", tt.want) {
+			t.Errorf("width of %q = %.2f, want %.2f", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTextDocument(t *testing.T) {
+	d := NewTextDocument("Certificate – Lease")
+	d.Text(HelveticaBold, 16, 0, "Certificate of Completion")
+	d.Field("Document hash", strings.Repeat("0123456789abcdef", 8), Courier)
+	d.Field("Note", "", Helvetica)
+	d.Text(Helvetica, 10, 20, "Signed (in person) by “Ada” \\ Lovelace")
+	for i := 0; i < 80; i++ {
+		d.Text(Helvetica, 10, 0, fmt.Sprintf("Line %d of a long audit trail", i+1))
+	}
+
+	r, err := Open(d.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := r.Resolve(r.Trailer["Info"]).(Dict); string(info["Title"].(String)) != "Certificate - Lease" {
+		t.Errorf("info = %v", info)
+	}
+
+	pages, err := r.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("%d pages, want 2", len(pages))
+	}
+
+	first, err := r.PageText(pages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(first, "\n")
+	// The hash is wider than the page and is broken into two lines
+	want := []string{"Certificate of Completion", "Document hash", strings.Repeat("0123456789abcdef", 5), strings.Repeat("0123456789abcdef", 3), "Note", "-", `Signed (in person) by "Ada" \ Lovelace`, "Line 1 of a long audit trail"}
+	for i, line := range want {
+		if i >= len(lines) || lines[i] != line {
+			t.Fatalf("first page lines = %q\nwant %q", lines[:min(len(lines), len(want))], want)
+		}
+	}
+	if lines[len(lines)-1] != "Page 1 of 2" {
+		t.Errorf("footer = %q", lines[len(lines)-1])
+	}
+
+	second, _ := r.PageText(pages[1])
+	if !strings.Contains(second, "Line 80 of a long audit trail") || !strings.HasSuffix(second, "Page 2 of 2") {
+		t.Errorf("second page = %q", second)
+	}
+}
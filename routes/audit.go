@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/fbn776/inkra/controllers"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+func AuditRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.JWTAuthMiddleware)
+		r.Use(middleware.RequireRole(database.RoleOwner))
+
+		r.Get("/audit/verify", controllers.VerifyAudit)
+	})
+}
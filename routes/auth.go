@@ -1,17 +1,20 @@
 package routes
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/fbn776/inkra/controllers"
 	"github.com/fbn776/inkra/database"
 	"github.com/fbn776/inkra/lib"
+	"github.com/fbn776/inkra/managers"
+	"github.com/fbn776/inkra/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/httprate"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type LoginRequest struct {
@@ -19,9 +22,23 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-func AuthRouter(r chi.Router) {
-	var getUSerByEmailStmt, _ = database.DB.Prepare("SELECT login_email, login_password_hash from settings where login_email = ?")
+type LoginChallengeRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
 
+type LoginResponse struct {
+	managers.SessionTokens
+	User database.User `json:"user"`
+}
+
+// accountLocked answers a login to a user locked out after failed logins, Retry-After telling when to try again
+func accountLocked(res http.ResponseWriter, locked *managers.AccountLockedError) {
+	res.Header().Set("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+	lib.ErrorCodeJSON(res, http.StatusTooManyRequests, "ACCOUNT_LOCKED", "Too many failed logins, try again later")
+}
+
+func AuthRouter(r chi.Router) {
 	r.With(httprate.LimitByIP(5, time.Minute)).Post("/login", func(res http.ResponseWriter, req *http.Request) {
 		var loginRequest LoginRequest
 
@@ -35,25 +52,53 @@ func AuthRouter(r chi.Router) {
 			return
 		}
 
-		var dbEmail, dbPassword string
+		ip, userAgent := lib.GetClientIP(req), req.UserAgent()
+		user, userErr := database.GetUserByEmail(loginRequest.Email)
 
-		tokenErr := getUSerByEmailStmt.QueryRow(loginRequest.Email).Scan(&dbEmail, &dbPassword)
-
-		if tokenErr == sql.ErrNoRows {
+		if errors.Is(userErr, database.ErrUserNotFound) {
+			managers.RecordLogin(nil, loginRequest.Email, ip, userAgent, database.LoginUnknownEmail)
 			lib.ErrorJSON(res, http.StatusUnauthorized, "Invalid credentials")
 			return
-		} else if tokenErr != nil {
+		} else if userErr != nil {
 			lib.ErrorJSON(res, http.StatusInternalServerError, "Could not get user")
+			return
 		}
 
-		compareResult := bcrypt.CompareHashAndPassword([]byte(dbPassword), []byte(loginRequest.Password))
+		var locked *managers.AccountLockedError
+		if errors.As(managers.CheckLoginLock(user), &locked) {
+			managers.RecordLogin(&user, user.Email, ip, userAgent, database.LoginLocked)
+			accountLocked(res, locked)
+			return
+		}
 
-		if compareResult != nil {
+		if !managers.CheckPassword(user, loginRequest.Password) {
+			managers.RecordLogin(&user, user.Email, ip, userAgent, database.LoginWrongPassword)
 			lib.ErrorJSON(res, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
 
-		token, tokenErr := lib.GenerateJWT(loginRequest.Email)
+		if user.Disabled {
+			managers.RecordLogin(&user, user.Email, ip, userAgent, database.LoginDisabled)
+			lib.ErrorJSON(res, http.StatusForbidden, "Account is disabled")
+			return
+		}
+
+		// The session is only opened once a code is given to POST /login/2fa
+		if user.TwoFactorEnabled {
+			challenge, challengeErr := managers.NewLoginChallenge(user)
+
+			if challengeErr != nil {
+				fmt.Println("ERROR: ", challengeErr)
+				lib.ErrorJSON(res, http.StatusInternalServerError, "Could not start two-factor login")
+				return
+			}
+
+			managers.RecordLogin(&user, user.Email, ip, userAgent, database.LoginCodeRequired)
+			lib.SuccessJSON(res, http.StatusOK, challenge)
+			return
+		}
+
+		tokens, tokenErr := managers.StartSession(user, ip, userAgent)
 
 		if tokenErr != nil {
 			fmt.Println("ERROR: ", tokenErr)
@@ -61,8 +106,51 @@ func AuthRouter(r chi.Router) {
 			return
 		}
 
-		lib.SuccessJSON(res, http.StatusOK, map[string]string{
-			"token": token,
+		managers.RecordLogin(&user, user.Email, ip, userAgent, database.LoginSuccess)
+		lib.SuccessJSON(res, http.StatusOK, LoginResponse{SessionTokens: tokens, User: user})
+	})
+
+	r.With(httprate.LimitByIP(10, time.Minute)).Post("/login/2fa", func(res http.ResponseWriter, req *http.Request) {
+		var body LoginChallengeRequest
+
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.ChallengeToken == "" || body.Code == "" {
+			lib.ErrorJSON(res, http.StatusBadRequest, "Missing required fields: challengeToken, code")
+			return
+		}
+
+		user, tokens, err := managers.CompleteLoginChallenge(body.ChallengeToken, body.Code, lib.GetClientIP(req), req.UserAgent())
+
+		var locked *managers.AccountLockedError
+		switch {
+		case errors.As(err, &locked):
+			accountLocked(res, locked)
+		case errors.Is(err, database.ErrChallengeNotFound):
+			lib.ErrorJSON(res, http.StatusUnauthorized, "Login expired, log in again")
+		case errors.Is(err, managers.ErrInvalidCode):
+			lib.ErrorJSON(res, http.StatusUnauthorized, "Invalid code")
+		case errors.Is(err, managers.ErrAccountDisabled):
+			lib.ErrorJSON(res, http.StatusForbidden, "Account is disabled")
+		case err != nil:
+			fmt.Println("ERROR: ", err)
+			lib.ErrorJSON(res, http.StatusInternalServerError, "Could not complete login")
+		default:
+			lib.SuccessJSON(res, http.StatusOK, LoginResponse{SessionTokens: tokens, User: user})
+		}
+	})
+
+	r.With(httprate.LimitByIP(30, time.Minute)).Post("/token/refresh", controllers.RefreshToken)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.JWTAuthMiddleware)
+
+		r.Post("/logout", controllers.Logout)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(database.ScopeFull))
+
+			r.Post("/logout-all", controllers.LogoutAll)
+			r.Get("/sessions", controllers.GetSessions)
+			r.Delete("/sessions/{id}", controllers.DeleteSession)
 		})
 	})
 }
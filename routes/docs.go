@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/fbn776/inkra/controllers"
+	"github.com/fbn776/inkra/database"
 	"github.com/fbn776/inkra/middleware"
 	"github.com/go-chi/chi/v5"
 )
@@ -12,11 +13,28 @@ func DocsRoutes(r chi.Router) {
 
 		r.Get("/docs", controllers.GetAllDocs)
 		r.Get("/docs/{id}", controllers.GetDocById)
-		r.Post("/docs", controllers.CreateDoc)
-		r.Put("/docs/{id}", controllers.UpdateDoc)
-		r.Delete("/docs/{id}", controllers.DeleteDoc)
+		r.Get("/docs/{id}/events", controllers.GetDocEvents)
+		r.Get("/docs/{id}/certificate", controllers.GetDocCertificate)
+		r.Get("/docs/{id}/timestamp", controllers.GetDocTimestamp)
+		r.Get("/docs/{id}/links", controllers.GetSigningLinks)
+		r.Get("/docs/{id}/signers", controllers.GetSigners)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireRole(database.RoleOwner, database.RoleEditor))
+
+			r.With(middleware.RequireScope(database.ScopeUpload)).Post("/docs", controllers.CreateDoc)
+			r.With(middleware.RequireScope(database.ScopeFull)).Put("/docs/{id}", controllers.UpdateDoc)
+			r.With(middleware.RequireScope(database.ScopeFull)).Delete("/docs/{id}", controllers.DeleteDoc)
+
+			r.With(middleware.RequireScope(database.ScopeUpload)).Post("/docs/{id}/links", controllers.CreateSigningLink)
+			r.With(middleware.RequireScope(database.ScopeFull)).Post("/docs/{id}/links/{linkId}/regenerate", controllers.RegenerateSigningLink)
+			r.With(middleware.RequireScope(database.ScopeFull)).Delete("/docs/{id}/links/{linkId}", controllers.RevokeSigningLink)
+
+			r.With(middleware.RequireScope(database.ScopeUpload)).Post("/docs/{id}/signers", controllers.CreateSigner)
+			r.With(middleware.RequireScope(database.ScopeFull)).Delete("/docs/{id}/signers/{signerId}", controllers.DeleteSigner)
+		})
 	})
 
-	r.Get("/docs/view/{id}", controllers.ViewDoc)
-	r.Post("/docs/sign/{id}", controllers.SignDoc)
+	r.Get("/docs/view/{token}", controllers.ViewDoc)
+	r.Post("/docs/sign/{token}", controllers.SignDoc)
 }
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/fbn776/inkra/controllers"
+	"github.com/go-chi/chi/v5"
+)
+
+func FilesRoutes(r chi.Router) {
+	r.Get("/files/{id}/{kind}", controllers.GetDocFile)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/fbn776/inkra/controllers"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+func KeysRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.JWTAuthMiddleware)
+		r.Use(middleware.RequireScope(database.ScopeFull))
+
+		r.Get("/keys", controllers.GetAPIKeys)
+		r.Post("/keys", controllers.CreateAPIKey)
+		r.Delete("/keys/{id}", controllers.RevokeAPIKey)
+	})
+}
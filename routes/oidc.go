@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/fbn776/inkra/controllers"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+)
+
+func OIDCRoutes(r chi.Router) {
+	r.Get("/auth/oidc", controllers.GetOIDC)
+
+	r.Group(func(r chi.Router) {
+		r.Use(httprate.LimitByIP(10, time.Minute))
+
+		r.Get("/auth/oidc/login", controllers.StartOIDCLogin)
+		r.Get("/auth/oidc/callback", controllers.OIDCCallback)
+	})
+}
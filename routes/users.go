@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/fbn776/inkra/controllers"
+	"github.com/fbn776/inkra/database"
+	"github.com/fbn776/inkra/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+)
+
+func UsersRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.JWTAuthMiddleware)
+
+		r.Get("/account", controllers.GetAccount)
+
+		r.With(middleware.RequireScope(database.ScopeFull), httprate.LimitByIP(5, time.Minute)).
+			Post("/account/password", controllers.ChangePassword)
+
+		r.Route("/account/2fa", func(r chi.Router) {
+			r.Use(middleware.RequireScope(database.ScopeFull))
+
+			r.Get("/", controllers.GetTwoFactor)
+			r.Post("/setup", controllers.SetupTwoFactor)
+
+			r.Group(func(r chi.Router) {
+				r.Use(httprate.LimitByIP(10, time.Minute))
+
+				r.Post("/enable", controllers.EnableTwoFactor)
+				r.Post("/disable", controllers.DisableTwoFactor)
+				r.Post("/recovery-codes", controllers.RegenerateRecoveryCodes)
+			})
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireRole(database.RoleOwner))
+			r.Use(middleware.RequireScope(database.ScopeFull))
+
+			r.Get("/users", controllers.GetUsers)
+			r.Post("/users", controllers.InviteUser)
+			r.Put("/users/{id}", controllers.UpdateUser)
+			r.Post("/users/{id}/invite", controllers.ReinviteUser)
+			r.Post("/users/{id}/unlock", controllers.UnlockUser)
+			r.Get("/login-attempts", controllers.GetLoginAttempts)
+		})
+	})
+
+	r.With(httprate.LimitByIP(5, time.Minute)).Post("/invites/accept", controllers.AcceptInvite)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/fbn776/inkra/controllers"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+)
+
+func VerifyRoutes(r chi.Router) {
+	r.With(httprate.LimitByIP(20, time.Minute)).Post("/verify", controllers.VerifyDoc)
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	// Endpoint such as https://s3.eu-central-1.amazonaws.com or http://localhost:9000
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses objects as endpoint/bucket/key instead of bucket.endpoint/key, as MinIO expects
+	PathStyle bool
+	// Prefix is prepended to every key
+	Prefix string
+}
+
+// S3 stores objects in an S3 compatible bucket
+type S3 struct {
+	config S3Config
+	base   *url.URL
+	signer sigV4
+	client *http.Client
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("storage: s3 needs a bucket, access key and secret key")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3.amazonaws.com"
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	base, err := url.Parse(config.Endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("storage: invalid s3 endpoint %q", config.Endpoint)
+	}
+
+	if config.PathStyle {
+		base.Path = "/" + config.Bucket
+	} else {
+		base.Host = config.Bucket + "." + base.Host
+		base.Path = ""
+	}
+
+	return &S3{
+		config: config,
+		base:   base,
+		signer: sigV4{accessKey: config.AccessKey, secretKey: config.SecretKey, region: config.Region, service: "s3"},
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.Prefix != "" {
+		key = strings.Trim(s.config.Prefix, "/") + "/" + key
+	}
+
+	u := *s.base
+	u.Path = u.Path + "/" + key
+	u.RawPath = uriEncode(u.Path, true)
+	return &u, nil
+}
+
+func (s *S3) do(method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	s.signer.sign(req, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, s3Error(res)
+	}
+
+	return res, nil
+}
+
+func s3Error(res *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("storage: s3 %s: %s: %s", res.Status, body.Code, body.Message)
+	}
+	return fmt.Errorf("storage: s3 %s", res.Status)
+}
+
+func objectInfo(key string, res *http.Response) ObjectInfo {
+	info := ObjectInfo{Key: key, Size: res.ContentLength}
+	if t, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = t
+	}
+	return info
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64) error {
+	// S3 needs the length up front
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+
+	res, err := s.do(http.MethodPut, key, r, size, header)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3) Stat(key string) (ObjectInfo, error) {
+	res, err := s.do(http.MethodHead, key, nil, 0, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	res.Body.Close()
+	return objectInfo(key, res), nil
+}
+
+func (s *S3) Get(key string) (io.ReadSeekCloser, ObjectInfo, error) {
+	info, err := s.Stat(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return &s3Object{s3: s, key: key, size: info.Size}, info, nil
+}
+
+func (s *S3) Delete(key string) error {
+	res, err := s.do(http.MethodDelete, key, nil, 0, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3) SignedURL(key string, expires time.Duration, opts SignedURLOptions) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	if opts.ContentType != "" {
+		query.Set("response-content-type", opts.ContentType)
+	}
+	if opts.ContentDisposition != "" {
+		query.Set("response-content-disposition", opts.ContentDisposition)
+	}
+	u.RawQuery = query.Encode()
+
+	return s.signer.presign(u, time.Now(), expires), nil
+}
+
+// s3Object reads an object with ranged GET requests, opened lazily from the current offset
+type s3Object struct {
+	s3     *S3
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		header := http.Header{}
+		header.Set("Range", "bytes="+strconv.FormatInt(o.offset, 10)+"-")
+
+		res, err := o.s3.do(http.MethodGet, o.key, nil, 0, header)
+		if err != nil {
+			return 0, err
+		}
+		o.body = res.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative seek offset")
+	}
+
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body != nil {
+		return o.body.Close()
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Package storage keeps document files in a backend addressed by object keys such as
+// "uploads/<uuid>.pdf", so the database does not depend on where the files live.
+package storage
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("storage: object not found")
+	ErrInvalidKey  = errors.New("storage: invalid object key")
+	ErrUnsupported = errors.New("storage: not supported by this backend")
+)
+
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// SignedURLOptions are response overrides for signed urls
+type SignedURLOptions struct {
+	ContentType        string
+	ContentDisposition string
+}
+
+type Backend interface {
+	// Put stores the content of r under key, replacing any previous object. size may be -1 when unknown.
+	Put(key string, r io.Reader, size int64) error
+	// Get opens an object. The reader is seekable so handlers can serve Range requests.
+	Get(key string) (io.ReadSeekCloser, ObjectInfo, error)
+	Stat(key string) (ObjectInfo, error)
+	Delete(key string) error
+	// SignedURL returns a url to download the object directly from the backend, ErrUnsupported when
+	// the backend cannot serve files itself
+	SignedURL(key string, expires time.Duration, opts SignedURLOptions) (string, error)
+}
+
+// CleanKey validates a key and returns it in canonical form
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(key, "\\", "/")), "/")
+	if key == "" || key == "." {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
+
+// ReadAll reads a whole object
+func ReadAll(b Backend, key string) ([]byte, error) {
+	obj, _, err := b.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	return io.ReadAll(obj)
+}
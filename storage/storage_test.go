@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+		err  error
+	}{
+		{"uploads/a.pdf", "uploads/a.pdf", nil},
+		{"/uploads//a.pdf", "uploads/a.pdf", nil},
+		{"uploads\\a.pdf", "uploads/a.pdf", nil},
+		{"../../etc/passwd", "etc/passwd", nil},
+		{"uploads/../../a.pdf", "a.pdf", nil},
+		{"", "", ErrInvalidKey},
+		{"/", "", ErrInvalidKey},
+		{"..", "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		got, err := CleanKey(tt.key)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("CleanKey(%q) = %q, %v, want %q, %v", tt.key, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// testBackend runs the contract every backend has to fulfil
+func testBackend(t *testing.T, b Backend) {
+	content := []byte("%PDF-1.7 0123456789 %%EOF")
+
+	if err := b.Put("uploads/a b.pdf", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	// Of unknown size, replacing the previous object
+	if err := b.Put("/uploads/a b.pdf", io.MultiReader(bytes.NewReader(content)), -1); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := b.Stat("uploads/a b.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(content)) || info.ModTime.IsZero() {
+		t.Errorf("stat = %+v", info)
+	}
+
+	if data, err := ReadAll(b, "uploads/a b.pdf"); err != nil || !bytes.Equal(data, content) {
+		t.Errorf("read all = %q, %v", data, err)
+	}
+
+	obj, info, err := b.Get("uploads/a b.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(content)) {
+		t.Errorf("get size = %d", info.Size)
+	}
+	seeks := []struct {
+		offset int64
+		whence int
+		want   string
+	}{
+		{9, io.SeekStart, "0123"},
+		{2, io.SeekCurrent, "6789"},
+		{-5, io.SeekEnd, "%%EOF"},
+		{0, io.SeekStart, "%PDF"},
+	}
+	for _, seek := range seeks {
+		if _, err := obj.Seek(seek.offset, seek.whence); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(seek.want))
+		if _, err := io.ReadFull(obj, buf); err != nil || string(buf) != seek.want {
+			t.Errorf("read after seek %d %d = %q, %v, want %q", seek.offset, seek.whence, buf, err, seek.want)
+		}
+	}
+	if _, err := obj.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := obj.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("read at the end = %d, %v", n, err)
+	}
+	if err := obj.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := b.Stat("uploads/missing.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("stat missing = %v, want ErrNotFound", err)
+	}
+	if _, _, err := b.Get("uploads/missing.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get missing = %v, want ErrNotFound", err)
+	}
+	if err := b.Put("..", bytes.NewReader(content), int64(len(content))); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("put invalid key = %v, want ErrInvalidKey", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := b.Delete("uploads/a b.pdf"); err != nil {
+			t.Fatalf("delete %d: %v", i+1, err)
+		}
+	}
+	if _, err := b.Stat("uploads/a b.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("stat deleted = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "docs")
+	local := NewLocal(root)
+	testBackend(t, local)
+
+	// Keys never leave the root
+	if err := local.Put("../outside.pdf", strings.NewReader("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.pdf")); err != nil {
+		t.Errorf("file put outside the root: %v", err)
+	}
+
+	if _, err := local.SignedURL("outside.pdf", time.Minute, SignedURLOptions{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("signed url = %v, want ErrUnsupported", err)
+	}
+}
+
+// fakeS3 is an in-memory bucket answering path style requests signed for it, enough to test the client
+type fakeS3 struct {
+	bucket    string
+	accessKey string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	ranges  []string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{bucket: "inkra", accessKey: "inkra-key", objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	credential := "Credential=" + f.accessKey + "/" + time.Now().UTC().Format("20060102") + "/us-east-1/s3/aws4_request"
+	authorized := strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "+credential+",") &&
+		r.Header.Get("X-Amz-Date") != "" && r.Header.Get("X-Amz-Content-Sha256") == unsignedPayload
+	if query := r.URL.Query(); query.Get("X-Amz-Signature") != "" {
+		authorized = strings.HasPrefix(query.Get("X-Amz-Credential"), f.accessKey+"/") && r.Method == http.MethodGet
+	}
+	if !authorized {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if int64(len(data)) != r.ContentLength {
+			w.WriteHeader(http.StatusLengthRequired)
+			return
+		}
+		f.objects[key] = data
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Range") != "" {
+			f.ranges = append(f.ranges, r.Header.Get("Range"))
+		}
+		if contentType := r.URL.Query().Get("response-content-type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		http.ServeContent(w, r, "", time.Now(), bytes.NewReader(data))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3(t *testing.T) {
+	fake, server := newFakeS3(t)
+	s3, err := NewS3(S3Config{Endpoint: server.URL, Bucket: "inkra", AccessKey: "inkra-key", SecretKey: "secret", PathStyle: true, Prefix: "/tenant/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBackend(t, s3)
+	// Objects are read lazily from the offset they are seeked to
+	for _, want := range []string{"bytes=9-", "bytes=15-", "bytes=20-", "bytes=0-"} {
+		if !strings.Contains(strings.Join(fake.ranges, " "), want) {
+			t.Errorf("ranges = %v, want %s", fake.ranges, want)
+		}
+	}
+
+	if err := s3.Put("signed/b.pdf", strings.NewReader("signed"), 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["tenant/signed/b.pdf"]; !ok {
+		t.Errorf("objects = %v, want the key under the prefix", fake.objects)
+	}
+
+	signed, err := s3.SignedURL("signed/b.pdf", time.Minute, SignedURLOptions{ContentType: "application/pdf", ContentDisposition: "attachment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != "signed" || res.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("signed url %s = %s %q %s", signed, res.Status, body, res.Header.Get("Content-Type"))
+	}
+
+	other, err := NewS3(S3Config{Endpoint: server.URL, Bucket: "inkra", AccessKey: "other-key", SecretKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Stat("signed/b.pdf"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("stat with another key = %v", err)
+	}
+	if err := other.Put("signed/b.pdf", strings.NewReader("x"), 1); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("put with another key = %v, want AccessDenied", err)
+	}
+}
+
+func TestNewS3(t *testing.T) {
+	tests := []struct {
+		name   string
+		config S3Config
+		want   string
+	}{
+		{"virtual hosted", S3Config{Bucket: "inkra", AccessKey: "a", SecretKey: "s"}, "https://inkra.s3.amazonaws.com/uploads/a.pdf"},
+		{"path style", S3Config{Endpoint: "http://localhost:9000", Bucket: "inkra", AccessKey: "a", SecretKey: "s", PathStyle: true}, "http://localhost:9000/inkra/uploads/a.pdf"},
+		{"prefix", S3Config{Endpoint: "http://localhost:9000", Bucket: "inkra", AccessKey: "a", SecretKey: "s", PathStyle: true, Prefix: "prod"}, "http://localhost:9000/inkra/prod/uploads/a.pdf"},
+		{"no bucket", S3Config{AccessKey: "a", SecretKey: "s"}, ""},
+		{"no credentials", S3Config{Bucket: "inkra"}, ""},
+		{"invalid endpoint", S3Config{Endpoint: "localhost", Bucket: "inkra", AccessKey: "a", SecretKey: "s"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3, err := NewS3(tt.config)
+			if tt.want == "" {
+				if err == nil {
+					t.Error("created with an invalid config")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := s3.objectURL("uploads/a.pdf")
+			if err != nil || u.String() != tt.want {
+				t.Errorf("object url = %v, %v, want %s", u, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestMinIO runs the backend contract against a real bucket, such as one of a local MinIO:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	mc mb local/inkra-test
+//	TEST_S3_ENDPOINT=http://localhost:9000 TEST_S3_BUCKET=inkra-test \
+//	TEST_S3_ACCESS_KEY_ID=minioadmin TEST_S3_SECRET_ACCESS_KEY=minioadmin go test ./storage/
+func TestMinIO(t *testing.T) {
+	endpoint := os.Getenv("TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_S3_ENDPOINT not set")
+	}
+
+	s3, err := NewS3(S3Config{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("TEST_S3_REGION"),
+		Bucket:    os.Getenv("TEST_S3_BUCKET"),
+		AccessKey: os.Getenv("TEST_S3_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("TEST_S3_SECRET_ACCESS_KEY"),
+		PathStyle: true,
+		Prefix:    "inkra-test-" + time.Now().Format("20060102150405"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBackend(t, s3)
+
+	if err := s3.Put("signed/b.pdf", strings.NewReader("signed"), 6); err != nil {
+		t.Fatal(err)
+	}
+	defer s3.Delete("signed/b.pdf")
+	signed, err := s3.SignedURL("signed/b.pdf", time.Minute, SignedURLOptions{ContentType: "application/pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != "signed" || res.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("signed url = %s %q %s", res.Status, body, res.Header.Get("Content-Type"))
+	}
+}
@@ -0,0 +1,151 @@
+package totp
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testSecret is the ASCII secret "12345678901234567890" of the test vectors of RFC 4226 and RFC 6238, base32 encoded
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeHOTP checks the HOTP values of RFC 4226 appendix D, the code of each counter
+func TestCodeHOTP(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		got, err := Code(testSecret, int64(counter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != code {
+			t.Errorf("counter %d = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+// TestCodeTOTP checks the SHA-1 values of RFC 6238 appendix B, of which the codes of 6 digits are the last ones
+func TestCodeTOTP(t *testing.T) {
+	tests := []struct {
+		unix int64
+		step int64
+		code string
+	}{
+		{59, 0x1, "94287082"},
+		{1111111109, 0x23523EC, "07081804"},
+		{1111111111, 0x23523ED, "14050471"},
+		{1234567890, 0x273EF07, "89005924"},
+		{2000000000, 0x3F940AA, "69279037"},
+		{20000000000, 0x27BC86AA, "65353130"},
+	}
+
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		if step != tt.step {
+			t.Errorf("step of %d = %X, want %X", tt.unix, step, tt.step)
+		}
+
+		got, err := Code(testSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.code[len(tt.code)-Digits:]; got != want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestCodeSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		err    error
+	}{
+		{"lower case", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", nil},
+		{"padded", "GEZDGNBVGY3TQOJQ======", nil},
+		{"not base32", "GEZDGNBVGY3TQOJ1", ErrInvalidSecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Code(tt.secret, 1); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if lower, _ := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1); lower != "287082" {
+		t.Errorf("lower case secret = %s, want 287082", lower)
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := secretEncoding.DecodeString(secret); err != nil || len(key) != 20 {
+		t.Errorf("generated secret %s = %d bytes, %v", secret, len(key), err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// 1111111111 is the step 0x23523ED, 1111111109 the last second of the one before
+	now := time.Unix(1111111111, 0)
+	code := func(step int64) string {
+		c, _ := Code(testSecret, step)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		step     int64
+		ok       bool
+	}{
+		{"current step", "050471", 0, 0x23523ED, true},
+		{"with spaces", " 050471\n", 0, 0x23523ED, true},
+		{"previous step", "081804", 0, 0x23523EC, true},
+		{"next step", code(0x23523EE), 0, 0x23523EE, true},
+		{"two steps before", code(0x23523EB), 0, 0, false},
+		{"two steps after", code(0x23523EF), 0, 0, false},
+		{"used", "050471", 0x23523ED, 0, false},
+		{"after the last used", code(0x23523EE), 0x23523ED, 0x23523EE, true},
+		{"eight digits", "14050471", 0, 0, false},
+		{"wrong", "050472", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(testSecret, tt.code, now, tt.lastStep)
+			if step != tt.step || ok != tt.ok {
+				t.Errorf("got %X %v, want %X %v", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+
+	if _, ok := Validate("not base32!", "050471", now, 0); ok {
+		t.Error("validated with an invalid secret")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("Inkra Prod", "alice@example.com", testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Inkra Prod:alice@example.com" {
+		t.Errorf("uri = %s", uri)
+	}
+	want := url.Values{
+		"secret":    {testSecret},
+		"issuer":    {"Inkra Prod"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}
+	if got := uri.Query(); got.Encode() != want.Encode() {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package tsa
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"time"
+
+	"github.com/fbn776/inkra/cms"
+)
+
+// OIDAnyPolicy is used as the policy of tokens issued by the built-in authority
+var OIDAnyPolicy = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+
+// Authority is a built-in timestamp authority signing tokens with a local key
+type Authority struct {
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	Key         crypto.Signer
+	Policy      asn1.ObjectIdentifier
+
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Respond answers a DER encoded TimeStampReq with a DER encoded TimeStampResp
+func (a *Authority) Respond(req []byte) ([]byte, error) {
+	var tsq timeStampReq
+	if _, err := asn1.Unmarshal(req, &tsq); err != nil {
+		return rejection("badDataFormat")
+	}
+
+	if !tsq.MessageImprint.HashAlgorithm.Algorithm.Equal(cms.OIDSHA256) || len(tsq.MessageImprint.HashedMessage) != 32 {
+		return rejection("badAlg")
+	}
+
+	token, err := a.issue(tsq)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: statusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// Timestamp issues a token through the same request and response path used by remote authorities
+func (a *Authority) Timestamp(digest []byte) ([]byte, error) {
+	req, nonce, err := NewRequest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.Respond(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseResponse(resp, digest, nonce)
+}
+
+func (a *Authority) issue(tsq timeStampReq) ([]byte, error) {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+
+	policy := a.Policy
+	if policy == nil {
+		policy = OIDAnyPolicy
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         policy,
+		MessageImprint: tsq.MessageImprint,
+		SerialNumber:   serial,
+		GenTime:        now().UTC().Truncate(time.Second),
+		Accuracy:       accuracy{Seconds: 1},
+		Nonce:          tsq.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificate is always included, as if certReq was set
+	return cms.Sign(content, cms.SignOptions{
+		Certificate: a.Certificate,
+		Chain:       a.Chain,
+		Key:         a.Key,
+		ContentType: cms.OIDTSTInfo,
+	})
+}
+
+func rejection(failure string) ([]byte, error) {
+	bit := map[string]int{"badAlg": 0, "badRequest": 2, "badDataFormat": 5}[failure]
+
+	failInfo := asn1.BitString{Bytes: make([]byte, 1), BitLength: bit + 1}
+	failInfo.Bytes[0] = 0x80 >> bit
+
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{Status: 2, StatusString: freeText(failure), FailInfo: failInfo},
+	})
+}
+
+// Ensure both implementations satisfy Timestamper
+var (
+	_ Timestamper = (*Client)(nil)
+	_ Timestamper = (*Authority)(nil)
+)
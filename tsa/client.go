@@ -0,0 +1,55 @@
+package tsa
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client requests timestamps from a remote authority over HTTP
+type Client struct {
+	URL      string
+	Username string
+	Password string
+
+	HTTPClient *http.Client
+}
+
+func NewClient(url string) *Client {
+	return &Client{URL: url, HTTPClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (c *Client) Timestamp(digest []byte) ([]byte, error) {
+	req, nonce, err := NewRequest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/timestamp-query")
+	if c.Username != "" {
+		httpReq.SetBasicAuth(c.Username, c.Password)
+	}
+
+	res, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: http status %d", ErrRejected, res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseResponse(body, digest, nonce)
+}
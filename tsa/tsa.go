@@ -0,0 +1,223 @@
+// Package tsa implements the RFC 3161 Time-Stamp Protocol: a client for remote authorities and a
+// built-in authority that signs tokens with a local key.
+package tsa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fbn776/inkra/cms"
+)
+
+var (
+	ErrRejected         = errors.New("tsa: request rejected")
+	ErrImprintMismatch  = errors.New("tsa: token does not cover the requested digest")
+	ErrNonceMismatch    = errors.New("tsa: token nonce does not match the request")
+	ErrNotTimestamp     = errors.New("tsa: not a timestamp token")
+	ErrUnsupportedHash  = errors.New("tsa: only SHA-256 imprints are supported")
+	ErrInvalidImprint   = errors.New("tsa: digest must be 32 bytes")
+	ErrMissingTimestamp = errors.New("tsa: response has no token")
+)
+
+// Timestamper issues timestamp tokens over a SHA-256 digest. The token is a DER encoded
+// ContentInfo holding a SignedData over a TSTInfo.
+type Timestamper interface {
+	Timestamp(digest []byte) ([]byte, error)
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+	Extensions     asn1.RawValue         `asn1:"optional,tag:0"`
+}
+
+type pkiStatusInfo struct {
+	Status int
+	// StatusString is a PKIFreeText, a SEQUENCE OF UTF8String, which encoding/asn1 can not tag per element
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+func freeText(texts ...string) []asn1.RawValue {
+	values := make([]asn1.RawValue, len(texts))
+	for i, text := range texts {
+		values[i] = asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagUTF8String, Bytes: []byte(text)}
+	}
+	return values
+}
+
+func (s pkiStatusInfo) texts() []string {
+	texts := make([]string, len(s.StatusString))
+	for i, value := range s.StatusString {
+		texts[i] = string(value.Bytes)
+	}
+	return texts
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional,default:false"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// PKIStatus values of a response
+const (
+	statusGranted         = 0
+	statusGrantedWithMods = 1
+)
+
+// Info is the content of a timestamp token
+type Info struct {
+	Policy       asn1.ObjectIdentifier
+	SerialNumber *big.Int
+	GenTime      time.Time
+	Digest       []byte
+	Nonce        *big.Int
+}
+
+// NewRequest builds a DER encoded TimeStampReq for a SHA-256 digest, asking for the signer certificate
+func NewRequest(digest []byte) ([]byte, *big.Int, error) {
+	if len(digest) != 32 {
+		return nil, nil, ErrInvalidImprint
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: cms.OIDSHA256},
+			HashedMessage: digest,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+
+	return req, nonce, err
+}
+
+// ParseResponse reads a DER encoded TimeStampResp, checks it was granted for the digest and nonce
+// of the request, and returns the token
+func ParseResponse(resp []byte, digest []byte, nonce *big.Int) ([]byte, error) {
+	var tsr timeStampResp
+	if _, err := asn1.Unmarshal(resp, &tsr); err != nil {
+		return nil, err
+	}
+
+	if tsr.Status.Status != statusGranted && tsr.Status.Status != statusGrantedWithMods {
+		return nil, fmt.Errorf("%w: status %d %v", ErrRejected, tsr.Status.Status, tsr.Status.texts())
+	}
+
+	if len(tsr.TimeStampToken.FullBytes) == 0 {
+		return nil, ErrMissingTimestamp
+	}
+
+	token := tsr.TimeStampToken.FullBytes
+
+	info, err := ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(info.Digest, digest) {
+		return nil, ErrImprintMismatch
+	}
+
+	if nonce != nil && (info.Nonce == nil || info.Nonce.Cmp(nonce) != 0) {
+		return nil, ErrNonceMismatch
+	}
+
+	return token, nil
+}
+
+// ParseToken reads the TSTInfo of a token without checking its signature
+func ParseToken(token []byte) (Info, error) {
+	sd, err := cms.Parse(token)
+	if err != nil {
+		return Info{}, err
+	}
+
+	return parseTSTInfo(sd)
+}
+
+// VerifyToken checks the signature of a token and that it covers the digest. The trust of the
+// authority certificate is not checked.
+func VerifyToken(token []byte, digest []byte) (Info, error) {
+	sd, err := cms.Parse(token)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info, err := parseTSTInfo(sd)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if !bytes.Equal(info.Digest, digest) {
+		return Info{}, ErrImprintMismatch
+	}
+
+	if _, err := sd.Verify(nil); err != nil {
+		return Info{}, err
+	}
+
+	return info, nil
+}
+
+func parseTSTInfo(sd *cms.SignedData) (Info, error) {
+	if !sd.ContentType.Equal(cms.OIDTSTInfo) {
+		return Info{}, ErrNotTimestamp
+	}
+
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(sd.Content, &tst); err != nil {
+		return Info{}, err
+	}
+
+	if !tst.MessageImprint.HashAlgorithm.Algorithm.Equal(cms.OIDSHA256) {
+		return Info{}, ErrUnsupportedHash
+	}
+
+	return Info{
+		Policy:       tst.Policy,
+		SerialNumber: tst.SerialNumber,
+		GenTime:      tst.GenTime,
+		Digest:       tst.MessageImprint.HashedMessage,
+		Nonce:        tst.Nonce,
+	}, nil
+}
@@ -0,0 +1,285 @@
+package tsa
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fbn776/inkra/cms"
+)
+
+// The request and response in testdata were made by OpenSSL 3 over content.txt, with the timeStamping
+// certificate and key of tsa.pem and tsa.key, policy 1.2.3.4.1 and serial 2:
+//
+//	openssl ts -query -data content.txt -sha256 -cert -out req.tsq
+//	openssl ts -reply -config ts.cnf -queryfile req.tsq -signer tsa.pem -inkey tsa.key -out resp.tsr
+var (
+	testDigest, _ = hex.DecodeString("e731a36bbff033b024e8b760edd0b1a8931df17a2af02fb3eb7cfa9a3afd0987")
+	testNonce, _  = new(big.Int).SetString("0116714834E771DD", 16)
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testAuthority is a built-in authority with the certificate and key OpenSSL used in testdata
+func testAuthority(t *testing.T, now time.Time) *Authority {
+	t.Helper()
+
+	certBlock, _ := pem.Decode(readTestdata(t, "tsa.pem"))
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBlock, _ := pem.Decode(readTestdata(t, "tsa.key"))
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Authority{Certificate: cert, Key: key.(crypto.Signer), Now: func() time.Time { return now }}
+}
+
+func TestParseOpenSSLResponse(t *testing.T) {
+	resp := readTestdata(t, "resp.tsr")
+	if sum := sha256.Sum256(readTestdata(t, "content.txt")); string(sum[:]) != string(testDigest) {
+		t.Fatal("content.txt does not match the digest of the request")
+	}
+
+	token, err := ParseResponse(resp, testDigest, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := VerifyToken(token, testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Policy.Equal(asn1.ObjectIdentifier{1, 2, 3, 4, 1}) || info.SerialNumber.Int64() != 2 || info.Nonce.Cmp(testNonce) != 0 {
+		t.Errorf("info = %+v", info)
+	}
+	if want := time.Date(2026, 10, 18, 3, 37, 38, 0, time.UTC); !info.GenTime.Equal(want) {
+		t.Errorf("time = %v, want %v", info.GenTime, want)
+	}
+
+	otherDigest := sha256.Sum256([]byte("other"))
+	tests := []struct {
+		name   string
+		digest []byte
+		nonce  *big.Int
+		err    error
+	}{
+		{"other digest", otherDigest[:], testNonce, ErrImprintMismatch},
+		{"other nonce", testDigest, big.NewInt(7), ErrNonceMismatch},
+		{"no nonce asked", testDigest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseResponse(resp, tt.digest, tt.nonce); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := VerifyToken(token, otherDigest[:]); !errors.Is(err, ErrImprintMismatch) {
+		t.Errorf("verify other digest = %v, want ErrImprintMismatch", err)
+	}
+}
+
+func TestAuthorityRespondsToOpenSSLRequest(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 15, 500, time.UTC)
+	authority := testAuthority(t, now)
+
+	resp, err := authority.Respond(readTestdata(t, "req.tsq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := ParseResponse(resp, testDigest, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := VerifyToken(token, testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.GenTime.Equal(now.Truncate(time.Second)) || !info.Policy.Equal(OIDAnyPolicy) {
+		t.Errorf("info = %+v", info)
+	}
+
+	sd, err := cms.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, err := sd.Signer(); err != nil || !signer.Equal(authority.Certificate) {
+		t.Errorf("signer = %v, %v", signer, err)
+	}
+}
+
+func TestAuthorityRejects(t *testing.T) {
+	authority := testAuthority(t, time.Now())
+
+	sha1Request, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+			HashedMessage: make([]byte, 20),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortRequest, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: cms.OIDSHA256},
+			HashedMessage: make([]byte, 20),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     []byte
+		failure string
+		bit     int
+	}{
+		{"not a request", []byte("timestamp please"), "badDataFormat", 5},
+		{"sha-1 imprint", sha1Request, "badAlg", 0},
+		{"short imprint", shortRequest, "badAlg", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := authority.Respond(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var tsr timeStampResp
+			if _, err := asn1.Unmarshal(resp, &tsr); err != nil {
+				t.Fatal(err)
+			}
+			if texts := tsr.Status.texts(); tsr.Status.Status != 2 || len(texts) != 1 || texts[0] != tt.failure {
+				t.Errorf("status = %+v", tsr.Status)
+			}
+			if tsr.Status.FailInfo.At(tt.bit) != 1 {
+				t.Errorf("fail info = %x, want bit %d", tsr.Status.FailInfo.Bytes, tt.bit)
+			}
+
+			if _, err := ParseResponse(resp, testDigest, nil); !errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), tt.failure) {
+				t.Errorf("parse = %v, want ErrRejected with %s", err, tt.failure)
+			}
+		})
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	if _, _, err := NewRequest(testDigest[:31]); !errors.Is(err, ErrInvalidImprint) {
+		t.Errorf("short digest = %v, want ErrInvalidImprint", err)
+	}
+
+	req, nonce, err := NewRequest(testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tsq timeStampReq
+	if _, err := asn1.Unmarshal(req, &tsq); err != nil {
+		t.Fatal(err)
+	}
+	if tsq.Version != 1 || !tsq.CertReq || tsq.Nonce.Cmp(nonce) != 0 || string(tsq.MessageImprint.HashedMessage) != string(testDigest) {
+		t.Errorf("request = %+v", tsq)
+	}
+	if !tsq.MessageImprint.HashAlgorithm.Algorithm.Equal(cms.OIDSHA256) {
+		t.Errorf("hash algorithm = %v", tsq.MessageImprint.HashAlgorithm.Algorithm)
+	}
+}
+
+// TestClient requests a timestamp over HTTP from the built-in authority, as from a remote one
+func TestClient(t *testing.T) {
+	authority := testAuthority(t, time.Now())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "inkra" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/timestamp-query" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		req, _ := io.ReadAll(r.Body)
+		resp, err := authority.Respond(req)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if _, err := client.Timestamp(testDigest); !errors.Is(err, ErrRejected) {
+		t.Errorf("without credentials = %v, want ErrRejected", err)
+	}
+
+	client.Username, client.Password = "inkra", "secret"
+	token, err := client.Timestamp(testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyToken(token, testDigest); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestOpenSSLVerifiesAuthorityToken checks the tokens of the built-in authority with OpenSSL, when it is installed
+func TestOpenSSLVerifiesAuthorityToken(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+
+	resp, err := testAuthority(t, time.Now()).Respond(readTestdata(t, "req.tsq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := filepath.Join(t.TempDir(), "resp.tsr")
+	if err := os.WriteFile(reply, resp, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(openssl, "ts", "-verify",
+		"-in", reply,
+		"-queryfile", filepath.Join("testdata", "req.tsq"),
+		"-CAfile", filepath.Join("testdata", "tsa.pem"),
+	).CombinedOutput()
+	if err != nil {
+		t.Errorf("%v\n%s", err, out)
+	}
+}
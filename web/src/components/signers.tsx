@@ -0,0 +1,159 @@
+import { useState } from "react";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { createSigner, deleteSigner, getSigners } from "@/lib/api";
+import type { SigningMode } from "@/types";
+import { Check, CheckCircle2, Clock, Copy, Loader2, Plus, Trash2, Users } from "lucide-react";
+
+interface SignersProps {
+    docId: string;
+    signingMode: SigningMode;
+    // Signers can only be added to a document that is neither deleted nor signed
+    locked: boolean;
+}
+
+// Signers of a document, the url of the link of a new signer is only shown until the page is left
+export function Signers({ docId, signingMode, locked }: SignersProps) {
+    const queryClient = useQueryClient();
+    const [name, setName] = useState("");
+    const [email, setEmail] = useState("");
+    const [newUrl, setNewUrl] = useState<string | null>(null);
+    const [copied, setCopied] = useState(false);
+
+    const { data, isLoading } = useQuery({
+        queryKey: ["signers", docId],
+        queryFn: () => getSigners(docId),
+    });
+
+    const refresh = () => {
+        queryClient.invalidateQueries({ queryKey: ["signers", docId] });
+        queryClient.invalidateQueries({ queryKey: ["signing-links", docId] });
+        queryClient.invalidateQueries({ queryKey: ["doc", docId] });
+    };
+
+    const createMutation = useMutation({
+        mutationFn: () => createSigner(docId, { name, email: email || undefined }),
+        onSuccess: (response) => {
+            setNewUrl(new URL(response.data.url, window.location.origin).toString());
+            setCopied(false);
+            setName("");
+            setEmail("");
+            refresh();
+        },
+    });
+
+    const deleteMutation = useMutation({
+        mutationFn: (signerId: string) => deleteSigner(docId, signerId),
+        onSuccess: refresh,
+    });
+
+    const copyLink = async () => {
+        if (!newUrl) return;
+        await navigator.clipboard.writeText(newUrl);
+        setCopied(true);
+        setTimeout(() => setCopied(false), 2000);
+    };
+
+    const signers = data?.data ?? [];
+
+    return (
+        <Card>
+            <CardHeader className="pb-3">
+                <CardTitle className="text-base flex items-center gap-2">
+                    <Users className="h-4 w-4"/>
+                    Signers
+                </CardTitle>
+                <CardDescription>
+                    {signingMode === "parallel"
+                        ? "Each signer gets their own link and can sign in any order"
+                        : "Each signer gets their own link and signs after the previous one"}
+                </CardDescription>
+            </CardHeader>
+            <CardContent className="space-y-4">
+                {newUrl && (
+                    <div className="flex gap-2">
+                        <code className="flex-1 px-3 py-2 bg-muted rounded text-xs truncate">
+                            {newUrl}
+                        </code>
+                        <Button size="sm" variant="outline" onClick={copyLink}>
+                            {copied ? (
+                                <Check className="h-4 w-4 text-green-500"/>
+                            ) : (
+                                <Copy className="h-4 w-4"/>
+                            )}
+                        </Button>
+                    </div>
+                )}
+
+                {isLoading ? (
+                    <Loader2 className="h-4 w-4 animate-spin text-muted-foreground"/>
+                ) : (
+                    <div className="space-y-2">
+                        {signers.map((signer) => (
+                            <div key={signer.id} className="flex items-center gap-2 text-sm">
+                                {signer.status === "signed" ? (
+                                    <CheckCircle2 className="h-4 w-4 text-green-500"/>
+                                ) : (
+                                    <Clock className="h-4 w-4 text-muted-foreground"/>
+                                )}
+                                <span className="flex-1 truncate" title={signer.email}>
+                                    {signer.order}. {signer.name}
+                                </span>
+                                {signer.signedAt && (
+                                    <span className="text-xs text-muted-foreground">
+                                        {new Date(signer.signedAt).toLocaleDateString()}
+                                    </span>
+                                )}
+                                {signer.status === "pending" && !locked && (
+                                    <Button
+                                        size="sm"
+                                        variant="ghost"
+                                        title="Remove"
+                                        onClick={() => deleteMutation.mutate(signer.id)}
+                                        disabled={deleteMutation.isPending}
+                                    >
+                                        <Trash2 className="h-4 w-4 text-destructive"/>
+                                    </Button>
+                                )}
+                            </div>
+                        ))}
+                    </div>
+                )}
+
+                {!locked && (
+                    <div className="space-y-2">
+                        <div className="space-y-1">
+                            <Label htmlFor="signer-name">Name</Label>
+                            <Input id="signer-name" value={name} onChange={(e) => setName(e.target.value)}/>
+                        </div>
+                        <div className="space-y-1">
+                            <Label htmlFor="signer-email">Email (optional)</Label>
+                            <Input
+                                id="signer-email"
+                                type="email"
+                                value={email}
+                                onChange={(e) => setEmail(e.target.value)}
+                            />
+                        </div>
+                        <Button
+                            size="sm"
+                            className="w-full gap-2"
+                            onClick={() => createMutation.mutate()}
+                            disabled={!name.trim() || createMutation.isPending}
+                        >
+                            {createMutation.isPending ? (
+                                <Loader2 className="h-4 w-4 animate-spin"/>
+                            ) : (
+                                <Plus className="h-4 w-4"/>
+                            )}
+                            Add Signer
+                        </Button>
+                    </div>
+                )}
+            </CardContent>
+        </Card>
+    );
+}
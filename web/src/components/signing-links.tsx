@@ -0,0 +1,178 @@
+import { useState } from "react";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
+import { Button } from "@/components/ui/button";
+import { Badge } from "@/components/ui/badge";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { createSigningLink, getSigningLinks, regenerateSigningLink, revokeSigningLink } from "@/lib/api";
+import type { SigningLink } from "@/types";
+import { Ban, Check, Copy, ExternalLink, Loader2, Plus, RefreshCw } from "lucide-react";
+
+interface SigningLinksProps {
+    docId: string;
+    // Links can only be created for a document that is not deleted
+    deleted: boolean;
+}
+
+function linkStatus(link: SigningLink): "revoked" | "expired" | "active" {
+    if (link.revokedAt) return "revoked";
+    if (link.expiresAt && new Date(link.expiresAt) <= new Date()) return "expired";
+    return "active";
+}
+
+// Signing links of a document, the url of a new or regenerated link is only shown until the page is left
+export function SigningLinks({ docId, deleted }: SigningLinksProps) {
+    const queryClient = useQueryClient();
+    const [recipient, setRecipient] = useState("");
+    const [expiresAt, setExpiresAt] = useState("");
+    const [newUrl, setNewUrl] = useState<string | null>(null);
+    const [copied, setCopied] = useState(false);
+
+    const { data, isLoading } = useQuery({
+        queryKey: ["signing-links", docId],
+        queryFn: () => getSigningLinks(docId),
+    });
+
+    const expiry = () => (expiresAt ? new Date(expiresAt).toISOString() : undefined);
+
+    const showUrl = (url: string) => {
+        setNewUrl(new URL(url, window.location.origin).toString());
+        setCopied(false);
+        queryClient.invalidateQueries({ queryKey: ["signing-links", docId] });
+    };
+
+    const createMutation = useMutation({
+        mutationFn: () => createSigningLink(docId, { recipient: recipient || undefined, expiresAt: expiry() }),
+        onSuccess: (response) => {
+            showUrl(response.data.url);
+            setRecipient("");
+            setExpiresAt("");
+        },
+    });
+
+    const regenerateMutation = useMutation({
+        mutationFn: (linkId: string) => regenerateSigningLink(docId, linkId, { expiresAt: expiry() }),
+        onSuccess: (response) => showUrl(response.data.url),
+    });
+
+    const revokeMutation = useMutation({
+        mutationFn: (linkId: string) => revokeSigningLink(docId, linkId),
+        onSuccess: () => queryClient.invalidateQueries({ queryKey: ["signing-links", docId] }),
+    });
+
+    const copyLink = async () => {
+        if (!newUrl) return;
+        await navigator.clipboard.writeText(newUrl);
+        setCopied(true);
+        setTimeout(() => setCopied(false), 2000);
+    };
+
+    const links = data?.data ?? [];
+
+    return (
+        <Card>
+            <CardHeader className="pb-3">
+                <CardTitle className="text-base flex items-center gap-2">
+                    <ExternalLink className="h-4 w-4"/>
+                    Signing Links
+                </CardTitle>
+                <CardDescription>
+                    Create a link for each client, it is only shown once
+                </CardDescription>
+            </CardHeader>
+            <CardContent className="space-y-4">
+                {newUrl && (
+                    <div className="flex gap-2">
+                        <code className="flex-1 px-3 py-2 bg-muted rounded text-xs truncate">
+                            {newUrl}
+                        </code>
+                        <Button size="sm" variant="outline" onClick={copyLink}>
+                            {copied ? (
+                                <Check className="h-4 w-4 text-green-500"/>
+                            ) : (
+                                <Copy className="h-4 w-4"/>
+                            )}
+                        </Button>
+                    </div>
+                )}
+
+                {!deleted && (
+                    <div className="space-y-2">
+                        <div className="space-y-1">
+                            <Label htmlFor="link-recipient">Recipient</Label>
+                            <Input
+                                id="link-recipient"
+                                value={recipient}
+                                onChange={(e) => setRecipient(e.target.value)}
+                                placeholder="Name or email"
+                            />
+                        </div>
+                        <div className="space-y-1">
+                            <Label htmlFor="link-expires">Expires (optional)</Label>
+                            <Input
+                                id="link-expires"
+                                type="datetime-local"
+                                value={expiresAt}
+                                onChange={(e) => setExpiresAt(e.target.value)}
+                            />
+                        </div>
+                        <Button
+                            size="sm"
+                            className="w-full gap-2"
+                            onClick={() => createMutation.mutate()}
+                            disabled={createMutation.isPending}
+                        >
+                            {createMutation.isPending ? (
+                                <Loader2 className="h-4 w-4 animate-spin"/>
+                            ) : (
+                                <Plus className="h-4 w-4"/>
+                            )}
+                            Create Link
+                        </Button>
+                    </div>
+                )}
+
+                {isLoading ? (
+                    <Loader2 className="h-4 w-4 animate-spin text-muted-foreground"/>
+                ) : (
+                    <div className="space-y-2">
+                        {links.map((link) => {
+                            const status = linkStatus(link);
+                            return (
+                                <div key={link.id} className="flex items-center gap-2 text-sm">
+                                    <span className="flex-1 truncate">{link.recipient ?? "Anyone with the link"}</span>
+                                    <Badge variant={status === "active" ? "secondary" : "outline"} className="text-xs">
+                                        {status}
+                                    </Badge>
+                                    {!deleted && (
+                                        <Button
+                                            size="sm"
+                                            variant="ghost"
+                                            title="New link, with the expiry above"
+                                            onClick={() => regenerateMutation.mutate(link.id)}
+                                            disabled={regenerateMutation.isPending}
+                                        >
+                                            <RefreshCw className="h-4 w-4"/>
+                                        </Button>
+                                    )}
+                                    {status !== "revoked" && (
+                                        <Button
+                                            size="sm"
+                                            variant="ghost"
+                                            title="Revoke"
+                                            onClick={() => revokeMutation.mutate(link.id)}
+                                            disabled={revokeMutation.isPending}
+                                        >
+                                            <Ban className="h-4 w-4 text-destructive"/>
+                                        </Button>
+                                    )}
+                                </div>
+                            );
+                        })}
+                    </div>
+                )}
+            </CardContent>
+        </Card>
+    );
+}
@@ -24,6 +24,7 @@ const uploadSchema = z.object({
     description: z.string().optional(),
     tags: z.string().optional(),
     ipWhitelist: z.string().optional(),
+    signingMode: z.enum(["sequential", "parallel"]).optional(),
 });
 
 type UploadFormData = z.infer<typeof uploadSchema>;
@@ -56,6 +57,7 @@ export function UploadDialog({ open, onOpenChange }: UploadDialogProps) {
             if (data.description) formData.append("description", data.description);
             if (data.tags) formData.append("tags", data.tags);
             if (data.ipWhitelist) formData.append("ipWhitelist", data.ipWhitelist);
+            if (data.signingMode) formData.append("signingMode", data.signingMode);
             formData.append("file", file);
 
             return createDoc(formData);
@@ -207,6 +209,22 @@ export function UploadDialog({ open, onOpenChange }: UploadDialogProps) {
                         </p>
                     </div>
 
+                    {/* Signing Mode */}
+                    <div className="space-y-2">
+                        <Label htmlFor="signingMode">Signing Order</Label>
+                        <select
+                            id="signingMode"
+                            {...register("signingMode")}
+                            className="h-9 w-full rounded-md border border-input bg-transparent px-3 text-sm"
+                        >
+                            <option value="sequential">Signers sign one after the other</option>
+                            <option value="parallel">Signers sign in any order</option>
+                        </select>
+                        <p className="text-xs text-muted-foreground">
+                            Applies when the document has several signers.
+                        </p>
+                    </div>
+
                     {uploadMutation.isError && (
                         <p className="text-sm text-destructive">
                             {uploadMutation.error instanceof Error
@@ -1,5 +1,5 @@
-import ApiInstance from "./axios";
-import type { GetAllDocsResponse, GetDocResponse, GetDocsParams } from "@/types";
+import ApiInstance, { saveTokens } from "./axios";
+import type { APIKey, APIKeyScope, GetAllDocsResponse, Session, GetDocResponse, GetDocsParams, InviteResponse, LoginAttempt, LoginOutcome, LoginResponse, Role, SessionTokens, Signer, SigningLink, SigningLinkResponse, TOTPSetup, TwoFactorStatus, User, ViewDocResponse } from "@/types";
 
 // Get all documents with pagination and filtering
 export async function getDocs(params?: GetDocsParams): Promise<GetAllDocsResponse> {
@@ -29,18 +29,18 @@ export async function deleteDoc(id: string): Promise<{ success: boolean }> {
     return response.data;
 }
 
-// View document publicly (no auth required)
-export async function viewDoc(id: string): Promise<GetDocResponse> {
-    const response = await ApiInstance.get<GetDocResponse>(`/api/docs/view/${id}`);
+// View the document of a signing link (no auth required)
+export async function viewDoc(token: string): Promise<ViewDocResponse> {
+    const response = await ApiInstance.get<ViewDocResponse>(`/api/docs/view/${token}`);
     return response.data;
 }
 
-// Sign a document (multipart form, no auth required)
+// Sign the document of a signing link (multipart form, no auth required)
 export async function signDoc(
-    id: string,
+    token: string,
     formData: FormData
 ): Promise<{ success: boolean }> {
-    const response = await ApiInstance.post(`/api/docs/sign/${id}`, formData, {
+    const response = await ApiInstance.post(`/api/docs/sign/${token}`, formData, {
         headers: {
             "Content-Type": "multipart/form-data",
         },
@@ -48,7 +48,193 @@ export async function signDoc(
     return response.data;
 }
 
-// Get the base URL for static file paths
+// List the signing links of a document, revoked and expired ones included
+export async function getSigningLinks(id: string): Promise<{ data: SigningLink[], success: boolean }> {
+    const response = await ApiInstance.get(`/api/docs/${id}/links`);
+    return response.data;
+}
+
+// Create a signing link of a document, its token is only returned here
+export async function createSigningLink(id: string, body: { recipient?: string, expiresAt?: string }): Promise<SigningLinkResponse> {
+    const response = await ApiInstance.post<SigningLinkResponse>(`/api/docs/${id}/links`, body);
+    return response.data;
+}
+
+// Issue a new token for a signing link, the previous one stops working
+export async function regenerateSigningLink(id: string, linkId: string, body: { expiresAt?: string }): Promise<SigningLinkResponse> {
+    const response = await ApiInstance.post<SigningLinkResponse>(`/api/docs/${id}/links/${linkId}/regenerate`, body);
+    return response.data;
+}
+
+// Revoke a signing link of a document
+export async function revokeSigningLink(id: string, linkId: string): Promise<{ success: boolean }> {
+    const response = await ApiInstance.delete(`/api/docs/${id}/links/${linkId}`);
+    return response.data;
+}
+
+// List the signers of a document in their order
+export async function getSigners(id: string): Promise<{ data: Signer[], success: boolean }> {
+    const response = await ApiInstance.get(`/api/docs/${id}/signers`);
+    return response.data;
+}
+
+// Add a signer after the other signers of a document, the token of its link is only returned here
+export async function createSigner(id: string, body: { name: string, email?: string, expiresAt?: string }): Promise<{ data: SigningLinkResponse["data"] & { signer: Signer }, success: boolean }> {
+    const response = await ApiInstance.post(`/api/docs/${id}/signers`, body);
+    return response.data;
+}
+
+// Remove a signer who has not signed yet
+export async function deleteSigner(id: string, signerId: string): Promise<{ success: boolean }> {
+    const response = await ApiInstance.delete(`/api/docs/${id}/signers/${signerId}`);
+    return response.data;
+}
+
+// End the current session
+export async function logout(): Promise<{ success: boolean }> {
+    const response = await ApiInstance.post("/api/logout");
+    return response.data;
+}
+
+// End every session of the logged-in user
+export async function logoutAll(): Promise<{ data: { sessions: number }, success: boolean }> {
+    const response = await ApiInstance.post("/api/logout-all");
+    return response.data;
+}
+
+// List the active sessions of the logged-in user
+export async function getSessions(): Promise<{ data: Session[], success: boolean }> {
+    const response = await ApiInstance.get("/api/sessions");
+    return response.data;
+}
+
+// End one session of the logged-in user
+export async function deleteSession(id: string): Promise<{ success: boolean }> {
+    const response = await ApiInstance.delete(`/api/sessions/${id}`);
+    return response.data;
+}
+
+// Get the logged-in user
+export async function getAccount(): Promise<{ data: User, success: boolean }> {
+    const response = await ApiInstance.get("/api/account");
+    return response.data;
+}
+
+// Tell whether single sign-on is configured, and the name of the provider (no auth required)
+export async function getOIDC(): Promise<{ data: { enabled: boolean, name?: string }, success: boolean }> {
+    const response = await ApiInstance.get("/api/auth/oidc");
+    return response.data;
+}
+
+// URL that starts a single sign-on login, the browser is sent back to /login with a refresh token
+export function getOIDCLoginUrl(): string {
+    return getFileUrl("/api/auth/oidc/login");
+}
+
+// Exchange a refresh token for new tokens (no auth required)
+export async function refreshSession(refreshToken: string): Promise<{ data: SessionTokens, success: boolean }> {
+    const response = await ApiInstance.post("/api/token/refresh", { refreshToken });
+    return response.data;
+}
+
+// Complete a login with a code of the authenticator app or a recovery code (no auth required)
+export async function completeLogin(body: { challengeToken: string, code: string }): Promise<LoginResponse> {
+    const response = await ApiInstance.post<LoginResponse>("/api/login/2fa", body);
+    return response.data;
+}
+
+// Tell whether the logged-in user has two-factor authentication on
+export async function getTwoFactor(): Promise<{ data: TwoFactorStatus, success: boolean }> {
+    const response = await ApiInstance.get("/api/account/2fa");
+    return response.data;
+}
+
+// Generate the secret of an authenticator app, enabled by enableTwoFactor
+export async function setupTwoFactor(): Promise<{ data: TOTPSetup, success: boolean }> {
+    const response = await ApiInstance.post("/api/account/2fa/setup");
+    return response.data;
+}
+
+// Turn two-factor authentication on, the recovery codes are only returned here
+export async function enableTwoFactor(code: string): Promise<{ data: { recoveryCodes: string[] }, success: boolean }> {
+    const response = await ApiInstance.post("/api/account/2fa/enable", { code });
+    return response.data;
+}
+
+// Replace the recovery codes, given a code or a recovery code
+export async function regenerateRecoveryCodes(code: string): Promise<{ data: { recoveryCodes: string[] }, success: boolean }> {
+    const response = await ApiInstance.post("/api/account/2fa/recovery-codes", { code });
+    return response.data;
+}
+
+// Turn two-factor authentication off
+export async function disableTwoFactor(body: { password: string, code: string }): Promise<{ success: boolean }> {
+    const response = await ApiInstance.post("/api/account/2fa/disable", body);
+    return response.data;
+}
+
+// List the users (owners only)
+export async function getUsers(): Promise<{ data: User[], success: boolean }> {
+    const response = await ApiInstance.get("/api/users");
+    return response.data;
+}
+
+// Invite a user (owners only), the invite url is only returned here
+export async function inviteUser(body: { email: string, name?: string, role: Role }): Promise<InviteResponse> {
+    const response = await ApiInstance.post<InviteResponse>("/api/users", body);
+    return response.data;
+}
+
+// Change the password of the logged-in user, its other sessions end and the new tokens are saved
+export async function changePassword(body: { currentPassword: string, newPassword: string }): Promise<{ data: SessionTokens, success: boolean }> {
+    const response = await ApiInstance.post("/api/account/password", body);
+    saveTokens(response.data.data);
+    return response.data;
+}
+
+// Change the role of a user or disable it (owners only)
+export async function updateUser(id: string, body: { role?: Role, disabled?: boolean }): Promise<{ data: User, success: boolean }> {
+    const response = await ApiInstance.put(`/api/users/${id}`, body);
+    return response.data;
+}
+
+// End the lockout of a user after failed logins (owners only)
+export async function unlockUser(id: string): Promise<{ success: boolean }> {
+    const response = await ApiInstance.post(`/api/users/${id}/unlock`);
+    return response.data;
+}
+
+// List the login attempts, most recent first (owners only)
+export async function getLoginAttempts(params?: { userId?: string, email?: string, ip?: string, outcome?: LoginOutcome, failed?: boolean, since?: string, limit?: number, before?: number }): Promise<{ data: LoginAttempt[], success: boolean }> {
+    const response = await ApiInstance.get("/api/login-attempts", { params });
+    return response.data;
+}
+
+// Set the password of an invited user (no auth required)
+export async function acceptInvite(body: { token: string, name?: string, password: string }): Promise<{ success: boolean }> {
+    const response = await ApiInstance.post("/api/invites/accept", body);
+    return response.data;
+}
+
+// List the API keys of the logged-in user
+export async function getAPIKeys(): Promise<{ data: APIKey[], success: boolean }> {
+    const response = await ApiInstance.get("/api/keys");
+    return response.data;
+}
+
+// Create an API key, the key is only returned here
+export async function createAPIKey(body: { name: string, scope: APIKeyScope }): Promise<{ data: { apiKey: APIKey, key: string }, success: boolean }> {
+    const response = await ApiInstance.post("/api/keys", body);
+    return response.data;
+}
+
+// Revoke an API key
+export async function revokeAPIKey(id: string): Promise<{ success: boolean }> {
+    const response = await ApiInstance.delete(`/api/keys/${id}`);
+    return response.data;
+}
+
+// Get the absolute URL of a file download URL
 export function getFileUrl(path: string): string {
     const baseUrl = import.meta.env.VITE_API_BASE_URL || window.location.origin;
     console.log("Base URL:", baseUrl);
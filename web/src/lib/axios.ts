@@ -17,4 +17,54 @@ ApiInstance.interceptors.request.use((config) => {
     }
 );
 
-export default ApiInstance;
\ No newline at end of file
+// Stores the tokens returned by a login or a refresh
+export function saveTokens(data: { token: string, refreshToken: string }) {
+    localStorage.setItem("token", data.token);
+    localStorage.setItem("refreshToken", data.refreshToken);
+}
+
+export function clearTokens() {
+    localStorage.removeItem("token");
+    localStorage.removeItem("refreshToken");
+}
+
+// Refreshes shared by the requests failing at the same time, a refresh token can only be used once
+let refreshing: Promise<boolean> | null = null;
+
+function refreshTokens(): Promise<boolean> {
+    const refreshToken = localStorage.getItem("refreshToken");
+    if (!refreshToken) {
+        return Promise.resolve(false);
+    }
+
+    refreshing ??= axios.post("/api/token/refresh", {refreshToken}, {baseURL: ApiInstance.defaults.baseURL})
+        .then((res) => {
+            saveTokens(res.data.data);
+            return true;
+        })
+        .catch(() => {
+            clearTokens();
+            return false;
+        })
+        .finally(() => {
+            refreshing = null;
+        });
+
+    return refreshing;
+}
+
+// Access tokens are short-lived: on a 401, get new tokens and retry once
+ApiInstance.interceptors.response.use(undefined, async (error) => {
+    const config = error.config;
+
+    if (error.response?.status === 401 && config && !config._retried && localStorage.getItem("refreshToken")) {
+        config._retried = true;
+        if (await refreshTokens()) {
+            return ApiInstance(config);
+        }
+    }
+
+    return Promise.reject(error);
+});
+
+export default ApiInstance;
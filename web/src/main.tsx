@@ -10,6 +10,7 @@ import SignDocPage from "./pages/sign-doc.tsx";
 import AdminViewDocPage from "./pages/admin/admin-view.tsx";
 import App from "@/App.tsx";
 import NotFoundPage from "@/pages/not-found.tsx";
+import InvitePage from "@/pages/invite.tsx";
 
 const queryClient = new QueryClient({
     defaultOptions: {
@@ -28,13 +29,14 @@ createRoot(document.getElementById('root')!).render(
                     <Route path="/" element={<App/>}/>
 
                     <Route path="/login" element={<LoginPage/>}/>
+                    <Route path="/invite/:token" element={<InvitePage/>}/>
 
                     <Route path="admin" element={<AdminLayout/>}>
                         <Route index element={<AdminPage/>}/>
                         <Route path="view/:doc-id" element={<AdminViewDocPage/>}/>
                     </Route>
 
-                    <Route path="/doc/sign/:doc-id" element={<SignDocPage/>}/>
+                    <Route path="/doc/sign/:token" element={<SignDocPage/>}/>
 
                     <Route path="*" element={<NotFoundPage/>}/>
                 </Routes>
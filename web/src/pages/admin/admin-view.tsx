@@ -3,6 +3,8 @@ import {useNavigate, useParams} from "react-router";
 import {useMutation, useQuery, useQueryClient} from "@tanstack/react-query";
 import {deleteDoc, getDoc, getFileUrl} from "@/lib/api";
 import {Button} from "@/components/ui/button";
+import {Signers} from "@/components/signers";
+import {SigningLinks} from "@/components/signing-links";
 import {Badge} from "@/components/ui/badge";
 import {Card, CardContent, CardDescription, CardHeader, CardTitle} from "@/components/ui/card";
 import {
@@ -19,11 +21,8 @@ import {
     AlertCircle,
     ArrowLeft,
     Calendar,
-    Check,
     CheckCircle2,
     Clock,
-    Copy,
-    ExternalLink,
     FileText,
     Globe,
     Loader2,
@@ -38,7 +37,6 @@ export default function AdminViewDocPage() {
     const navigate = useNavigate();
     const queryClient = useQueryClient();
     const [deleteOpen, setDeleteOpen] = useState(false);
-    const [copied, setCopied] = useState(false);
 
     const {data, isLoading, isError} = useQuery({
         queryKey: ["doc", docId],
@@ -56,14 +54,6 @@ export default function AdminViewDocPage() {
 
     const doc = data?.data;
 
-    const signingLink = new URL(`/doc/sign/${docId}`, window.location.origin).toString();
-
-    const copyLink = async () => {
-        await navigator.clipboard.writeText(signingLink);
-        setCopied(true);
-        setTimeout(() => setCopied(false), 2000);
-    };
-
     if (isLoading) {
         return (
             <div className="flex items-center justify-center min-h-[60vh]">
@@ -128,7 +118,7 @@ export default function AdminViewDocPage() {
                         <CardContent>
                             <div className="border rounded-lg overflow-hidden bg-muted aspect-[4/5]">
                                 <iframe
-                                    src={getFileUrl(doc.isSigned && doc.signedPath ? doc.signedPath : doc.originalPath)}
+                                    src={getFileUrl(doc.signedUrl ?? doc.currentUrl ?? doc.originalUrl!)}
                                     className="w-full h-full"
                                     title={doc.title}
                                 />
@@ -139,32 +129,9 @@ export default function AdminViewDocPage() {
 
                 {/* Sidebar */}
                 <div className="space-y-4">
-                    {/* Shareable Link */}
-                    <Card>
-                        <CardHeader className="pb-3">
-                            <CardTitle className="text-base flex items-center gap-2">
-                                <ExternalLink className="h-4 w-4"/>
-                                Shareable Link
-                            </CardTitle>
-                            <CardDescription>
-                                Send this link to your client for signing
-                            </CardDescription>
-                        </CardHeader>
-                        <CardContent>
-                            <div className="flex gap-2">
-                                <code className="flex-1 px-3 py-2 bg-muted rounded text-xs truncate">
-                                    {signingLink}
-                                </code>
-                                <Button size="sm" variant="outline" onClick={copyLink}>
-                                    {copied ? (
-                                        <Check className="h-4 w-4 text-green-500"/>
-                                    ) : (
-                                        <Copy className="h-4 w-4"/>
-                                    )}
-                                </Button>
-                            </div>
-                        </CardContent>
-                    </Card>
+                    <Signers docId={doc.id} signingMode={doc.signingMode} locked={doc.deleted || doc.isSigned}/>
+
+                    <SigningLinks docId={doc.id} deleted={doc.deleted}/>
 
                     {/* Document Info */}
                     <Card>
@@ -2,6 +2,8 @@ import { Outlet, useNavigate } from "react-router";
 import { useEffect } from "react";
 import { Button } from "@/components/ui/button";
 import { LogOut } from "lucide-react";
+import { logout } from "@/lib/api";
+import { clearTokens } from "@/lib/axios";
 
 export default function AdminLayout() {
     const navigate = useNavigate();
@@ -13,8 +15,13 @@ export default function AdminLayout() {
         }
     }, [navigate]);
 
-    const handleLogout = () => {
-        localStorage.removeItem("token");
+    const handleLogout = async () => {
+        try {
+            await logout();
+        } catch {
+            // The session may have ended already
+        }
+        clearTokens();
         navigate("/login");
     };
 
@@ -156,9 +156,24 @@ export default function AdminPage() {
                                                 <CardTitle className="text-base truncate">
                                                     {doc.title}
                                                 </CardTitle>
-                                                <CardDescription className="line-clamp-2 mt-1">
-                                                    {doc.description || "No description"}
-                                                </CardDescription>
+                                                {doc.snippet ? (
+                                                    // The snippet is escaped by the server, only <mark> is markup
+                                                    <CardDescription className="line-clamp-2 mt-1 [&_mark]:bg-yellow-200"
+                                                                     dangerouslySetInnerHTML={{__html: doc.snippet}}/>
+                                                ) : (
+                                                    <CardDescription className="line-clamp-2 mt-1">
+                                                        {doc.description || "No description"}
+                                                    </CardDescription>
+                                                )}
+                                                {doc.pageHits && doc.pageHits.length > 0 && (
+                                                    <p className="text-xs text-muted-foreground line-clamp-2 mt-1 [&_mark]:bg-yellow-200">
+                                                        <span className="font-medium">
+                                                            Page {doc.pageHits.map((hit) => hit.page).join(", ")}:{" "}
+                                                        </span>
+                                                        {/* Escaped by the server like the snippet above */}
+                                                        <span dangerouslySetInnerHTML={{__html: doc.pageHits[0].snippet}}/>
+                                                    </p>
+                                                )}
                                             </div>
                                             {doc.isSigned ? (
                                                 <Badge variant="default" className="shrink-0 gap-1 bg-green-600">
@@ -0,0 +1,101 @@
+import { useState } from "react"
+import { useForm } from "react-hook-form"
+import { zodResolver } from "@hookform/resolvers/zod"
+import { Button } from "@/components/ui/button"
+import { Input } from "@/components/ui/input"
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
+import {z} from "zod";
+import {useNavigate, useParams} from "react-router";
+import {acceptInvite} from "@/lib/api.ts";
+import {Alert, AlertDescription} from "@/components/ui/alert.tsx";
+import {AlertCircle, Loader2} from "lucide-react";
+
+const inviteSchema = z.object({
+    name: z.string().optional(),
+    password: z.string().min(8, "Password must be at least 8 characters"),
+    confirm: z.string(),
+}).refine((data) => data.password === data.confirm, {
+    message: "Passwords do not match",
+    path: ["confirm"],
+});
+
+type InviteSchema = z.infer<typeof inviteSchema>;
+
+export default function InvitePage() {
+    const navigate = useNavigate();
+    const {token} = useParams();
+    const [error, setError] = useState("")
+    const [isSubmitting, setIsSubmitting] = useState(false)
+
+    const {
+        register,
+        handleSubmit,
+        formState: { errors },
+    } = useForm<InviteSchema>({
+        resolver: zodResolver(inviteSchema),
+    })
+
+    const onSubmit = async (data: InviteSchema) => {
+        try {
+            setError("")
+            setIsSubmitting(true)
+
+            await acceptInvite({token: token ?? "", name: data.name, password: data.password});
+
+            navigate("/login")
+        } catch (err: any) {
+            setError(err?.response?.data?.message || "Could not accept the invite.")
+        } finally {
+            setIsSubmitting(false)
+        }
+    }
+
+    return (
+        <div className="flex items-center justify-center min-h-screen bg-gray-100">
+            <Card className="w-full max-w-md">
+                <CardHeader>
+                    <CardTitle className="text-2xl">Join Inkra</CardTitle>
+                    <CardDescription>Choose a password to accept the invite</CardDescription>
+                </CardHeader>
+                <CardContent>
+                    <form onSubmit={handleSubmit(onSubmit)} className="space-y-4">
+                        {error && (
+                            <Alert variant="destructive">
+                                <AlertCircle className="h-4 w-4" />
+                                <AlertDescription>{error}</AlertDescription>
+                            </Alert>
+                        )}
+
+                        <div className="space-y-2">
+                            <label className="text-sm font-medium text-foreground">Name</label>
+                            <Input {...register("name")} placeholder="Your name" className="bg-input" />
+                        </div>
+
+                        <div className="space-y-2">
+                            <label className="text-sm font-medium text-foreground">Password</label>
+                            <Input {...register("password")} type="password" placeholder="••••••••" className="bg-input" />
+                            {errors.password && <p className="text-sm text-destructive">{errors.password.message}</p>}
+                        </div>
+
+                        <div className="space-y-2">
+                            <label className="text-sm font-medium text-foreground">Confirm password</label>
+                            <Input {...register("confirm")} type="password" placeholder="••••••••" className="bg-input" />
+                            {errors.confirm && <p className="text-sm text-destructive">{errors.confirm.message}</p>}
+                        </div>
+
+                        <Button type="submit" className="w-full" disabled={isSubmitting}>
+                            {isSubmitting ? (
+                                <>
+                                    <Loader2 className="mr-2 h-4 w-4 animate-spin" />
+                                    Saving...
+                                </>
+                            ) : (
+                                "Set Password"
+                            )}
+                        </Button>
+                    </form>
+                </CardContent>
+            </Card>
+        </div>
+    )
+}
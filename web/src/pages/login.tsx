@@ -1,14 +1,15 @@
-import { useState } from "react"
+import { useEffect, useState, type FormEvent } from "react"
 import { useForm } from "react-hook-form"
 import { zodResolver } from "@hookform/resolvers/zod"
 import { Button } from "@/components/ui/button"
 import { Input } from "@/components/ui/input"
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
 import {z} from "zod";
-import {useNavigate} from "react-router";
-import ApiInstance from "@/lib/axios.ts";
+import {useNavigate, useSearchParams} from "react-router";
+import ApiInstance, {saveTokens} from "@/lib/axios.ts";
 import {Alert, AlertDescription} from "@/components/ui/alert.tsx";
 import {AlertCircle, Loader2} from "lucide-react";
+import {completeLogin, getOIDC, getOIDCLoginUrl, refreshSession} from "@/lib/api.ts";
 
 const loginSchema = z.object({
     email: z.email("Invalid email address"),
@@ -19,8 +20,42 @@ type LoginSchema = z.infer<typeof loginSchema>;
 
 export default function LoginPage() {
     const navigate = useNavigate();
-    const [error, setError] = useState("")
+    const [searchParams] = useSearchParams();
+    const [error, setError] = useState(searchParams.get("error") || "")
     const [isSubmitting, setIsSubmitting] = useState(false)
+    // Set when the password was right and a two-factor code is needed
+    const [challengeToken, setChallengeToken] = useState("")
+    const [code, setCode] = useState("")
+    const [oidc, setOIDC] = useState<{ enabled: boolean, name?: string }>({enabled: false})
+
+    useEffect(() => {
+        getOIDC().then((res) => setOIDC(res.data)).catch(() => {})
+    }, [])
+
+    // Single sign-on sends the browser back with the refresh token of the new session in the fragment, or the
+    // challenge token when a two-factor code is still needed
+    useEffect(() => {
+        const fragment = new URLSearchParams(window.location.hash.slice(1))
+        const challenge = fragment.get("challengeToken")
+        if (challenge) {
+            window.history.replaceState(null, "", window.location.pathname)
+            setChallengeToken(challenge)
+            return
+        }
+
+        const refreshToken = fragment.get("refreshToken")
+        if (!refreshToken) {
+            return
+        }
+        window.history.replaceState(null, "", window.location.pathname)
+
+        refreshSession(refreshToken)
+            .then((res) => {
+                saveTokens(res.data)
+                navigate("/admin")
+            })
+            .catch((err) => setError(err?.response?.data?.message || "Single sign-on failed"))
+    }, [navigate])
 
     const {
         register,
@@ -37,7 +72,12 @@ export default function LoginPage() {
 
             const res = await ApiInstance.post("/api/login", data);
 
-            window.localStorage.setItem("token", res?.data?.data?.token);
+            if (res.data.data.twoFactorRequired) {
+                setChallengeToken(res.data.data.challengeToken)
+                return
+            }
+
+            saveTokens(res.data.data);
 
             navigate("/admin")
         } catch (err: any) {
@@ -48,6 +88,30 @@ export default function LoginPage() {
         }
     }
 
+    const onSubmitCode = async (e: FormEvent) => {
+        e.preventDefault()
+        try {
+            setError("")
+            setIsSubmitting(true)
+
+            const res = await completeLogin({challengeToken, code: code.trim()});
+
+            saveTokens(res.data);
+
+            navigate("/admin")
+        } catch (err: any) {
+            const message = err?.response?.data?.message || "Login failed."
+            // The challenge is gone after it expired or had too many wrong codes, start over with the password
+            if (message.startsWith("Login expired")) {
+                setChallengeToken("")
+            }
+            setCode("")
+            setError(message)
+        } finally {
+            setIsSubmitting(false)
+        }
+    }
+
     return (
         <div className="flex items-center justify-center min-h-screen bg-gray-100">
             <Card className="w-full max-w-md">
@@ -56,6 +120,36 @@ export default function LoginPage() {
                     <CardDescription>Sign in to your account to manage documents</CardDescription>
                 </CardHeader>
                 <CardContent>
+                    {challengeToken ? (
+                    <form onSubmit={onSubmitCode} className="space-y-4">
+                        {error && (
+                            <Alert variant="destructive">
+                                <AlertCircle className="h-4 w-4" />
+                                <AlertDescription>{error}</AlertDescription>
+                            </Alert>
+                        )}
+
+                        <div className="space-y-2">
+                            <label className="text-sm font-medium text-foreground">Authentication code</label>
+                            <Input value={code} onChange={(e) => setCode(e.target.value)} autoComplete="one-time-code"
+                                   autoFocus placeholder="123456" className="bg-input" />
+                            <p className="text-sm text-muted-foreground">
+                                Enter the code of your authenticator app, or one of your recovery codes.
+                            </p>
+                        </div>
+
+                        <Button type="submit" className="w-full" disabled={isSubmitting || !code.trim()}>
+                            {isSubmitting ? (
+                                <>
+                                    <Loader2 className="mr-2 h-4 w-4 animate-spin" />
+                                    Verifying...
+                                </>
+                            ) : (
+                                "Verify"
+                            )}
+                        </Button>
+                    </form>
+                    ) : (
                     <form onSubmit={handleSubmit(onSubmit)} className="space-y-4">
                         {error && (
                             <Alert variant="destructive">
@@ -86,7 +180,14 @@ export default function LoginPage() {
                                 "Sign In"
                             )}
                         </Button>
+
+                        {oidc.enabled && (
+                            <Button variant="outline" className="w-full" asChild>
+                                <a href={getOIDCLoginUrl()}>Sign in with {oidc.name}</a>
+                            </Button>
+                        )}
                     </form>
+                    )}
                 </CardContent>
             </Card>
         </div>
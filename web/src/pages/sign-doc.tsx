@@ -7,8 +7,9 @@ import {Input} from "@/components/ui/input";
 import {Textarea} from "@/components/ui/textarea";
 import {Label} from "@/components/ui/label";
 import {Card, CardContent, CardDescription, CardHeader, CardTitle} from "@/components/ui/card";
-import {AlertCircle, CheckCircle2, Loader2, Send,} from "lucide-react";
+import {AlertCircle, CheckCircle2, Clock, Loader2, Send,} from "lucide-react";
 import ApiInstance from "@/lib/axios.ts";
+import type {PublicDocument} from "@/types";
 import {PDFDocument} from "pdf-lib";
 import {
     Drawer,
@@ -21,8 +22,15 @@ import {
     DrawerTrigger,
 } from "@/components/ui/drawer"
 
+// Why the link can not sign the document now
+function signingNotice(doc: PublicDocument): string {
+    if (!doc.signer) return "This link can only view the document.";
+    if (doc.signer.status === "signed") return "You have already signed this document.";
+    return "Waiting for the previous signers to sign first.";
+}
+
 export default function SignDocPage() {
-    const {"doc-id": docId} = useParams<{ "doc-id": string }>();
+    const {token} = useParams<{ token: string }>();
 
     const [pdfLoader, setPdfLoader] = useState(false);
     const [pdfError, setPdfError] = useState<string | null>(null);
@@ -34,22 +42,24 @@ export default function SignDocPage() {
     const iframeRef = useRef<HTMLIFrameElement>(null);
 
     const {data, isLoading, isError} = useQuery({
-        queryKey: ["view-doc", docId],
-        queryFn: () => viewDoc(docId!),
-        enabled: !!docId,
+        queryKey: ["view-doc", token],
+        queryFn: () => viewDoc(token!),
+        enabled: !!token,
     });
 
     /**
      * Fetch PDF → create Blob URL
      */
     useEffect(() => {
-        if (!data?.data?.originalPath) return;
+        // The current file holds the marks of the signers who signed already
+        const fileUrl = data?.data?.currentUrl ?? data?.data?.originalUrl;
+        if (!fileUrl) return;
 
         // eslint-disable-next-line react-hooks/set-state-in-effect
         setPdfLoader(true);
 
         let cancelled = false;
-        ApiInstance.get(data.data.originalPath, {
+        ApiInstance.get(fileUrl, {
             responseType: "arraybuffer",
         })
             .then((res) => {
@@ -165,8 +175,9 @@ export default function SignDocPage() {
             formData.append("file", blob, `signed_${doc?.originalName}`);
             formData.append("remarks", remarks);
             formData.append("metadata", signerName);
+            formData.append("version", String(doc?.version ?? 0));
 
-            return signDoc(docId!, formData);
+            return signDoc(token!, formData);
         },
         onSuccess: () => {
             setSubmitSuccess(true);
@@ -291,6 +302,11 @@ export default function SignDocPage() {
                             </div>
                         </div>
                         <DrawerFooter className="px-6">
+                            {!doc.canSign && (
+                                <div className="mb-4 p-3 bg-muted text-muted-foreground text-sm rounded-lg">
+                                    {signingNotice(doc)}
+                                </div>
+                            )}
                             {signMutation.isError && (
                                 <div className="mb-4 p-3 bg-destructive/10 text-destructive text-sm rounded-lg">
                                     {signMutation.error.message}
@@ -301,7 +317,7 @@ export default function SignDocPage() {
                                 className="w-full gap-2"
                                 size="lg"
                                 onClick={() => signMutation.mutate()}
-                                disabled={pdfLoader || signMutation.isPending}
+                                disabled={pdfLoader || signMutation.isPending || !doc.canSign}
                             >
                                 {signMutation.isPending ? (
                                     <>
@@ -353,9 +369,42 @@ export default function SignDocPage() {
                     </CardContent>
                 </Card>
 
+                {/* Signers */}
+                {doc.signers && (
+                    <Card>
+                        <CardHeader className="pb-3">
+                            <CardTitle className="text-base">Signers</CardTitle>
+                            <CardDescription>
+                                {doc.signingMode === "parallel" ? "Signing in any order" : "Signing in this order"}
+                            </CardDescription>
+                        </CardHeader>
+                        <CardContent className="space-y-2">
+                            {doc.signers.map((signer) => (
+                                <div key={signer.order} className="flex items-center gap-2 text-sm">
+                                    {signer.status === "signed" ? (
+                                        <CheckCircle2 className="h-4 w-4 text-green-500"/>
+                                    ) : (
+                                        <Clock className="h-4 w-4 text-muted-foreground"/>
+                                    )}
+                                    <span className={doc.signer?.order === signer.order ? "font-medium" : ""}>
+                                        {signer.order}. {signer.name}
+                                        {doc.signer?.order === signer.order && " (you)"}
+                                    </span>
+                                </div>
+                            ))}
+                        </CardContent>
+                    </Card>
+                )}
+
                 {/* Submit */}
                 <Card>
                     <CardContent className="pt-6">
+                        {!doc.canSign && (
+                            <div className="mb-4 p-3 bg-muted text-muted-foreground text-sm rounded-lg">
+                                {signingNotice(doc)}
+                            </div>
+                        )}
+
                         {signMutation.isError && (
                             <div className="mb-4 p-3 bg-destructive/10 text-destructive text-sm rounded-lg">
                                 {signMutation.error.message}
@@ -366,7 +415,7 @@ export default function SignDocPage() {
                             className="w-full gap-2"
                             size="lg"
                             onClick={() => signMutation.mutate()}
-                            disabled={pdfLoader || signMutation.isPending}
+                            disabled={pdfLoader || signMutation.isPending || !doc.canSign}
                         >
                             {signMutation.isPending ? (
                                 <>
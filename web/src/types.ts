@@ -6,20 +6,45 @@ export interface Document {
     description: string;
     tags: string[];
     originalName: string;
-    originalPath: string;
+    originalKey: string;
+    originalHash?: string;
     signedName?: string;
-    signedPath?: string;
+    signedKey?: string;
+    signedHash?: string;
+    submittedHash?: string;
+    certificateKey?: string;
+    certificateHash?: string;
+    timestampedAt?: string;
+    timestampAuthority?: string;
     isSigned: boolean;
     signedAt?: string;
     signedByMetadata?: string;
     signedByIp?: string;
     ipWhitelist: string[];
+    signingMode: SigningMode;
+    originalUrl?: string;
+    signedUrl?: string;
+    // File with the marks of the signers who signed so far, until the document is signed
+    currentUrl?: string;
     remarks?: string;
+    // Id of the user who uploaded the document
+    createdBy?: string;
+    textStatus: 'pending' | 'extracted' | 'failed';
+    // Set when listing by keyword
+    searchRank?: number;
+    snippet?: string;
+    pageHits?: PageHit[];
     deleted: boolean;
     createdAt: string;
     updatedAt: string;
 }
 
+// Page of the extracted text of a document matching a search
+export interface PageHit {
+    page: number;
+    snippet: string;
+}
+
 export interface GetAllDocsResponse {
     data: {
         limit: string;
@@ -35,9 +60,193 @@ export interface GetDocResponse {
     success: boolean;
 }
 
-export interface LoginResponse {
+// Document as shown on the public signing page
+export interface PublicDocument {
+    title: string;
+    description?: string;
+    tags?: string[];
+    originalName: string;
+    isSigned: boolean;
+    signedAt?: string;
+    deleted: boolean;
+    createdAt: string;
+    originalUrl: string;
+    signedUrl?: string;
+    urlExpiresAt: string;
+    // File to sign, with the marks of the signers who signed already, and the number of those signatures
+    currentUrl?: string;
+    version: number;
+    canSign: boolean;
+    // Set for a document with signers, signer is the signer of the link
+    signingMode?: SigningMode;
+    signers?: PublicSigner[];
+    signer?: PublicSigner;
+}
+
+export type SigningMode = 'sequential' | 'parallel';
+
+export type SignerStatus = 'pending' | 'signed';
+
+// What a signer sees of the signers of a document
+export interface PublicSigner {
+    name: string;
+    order: number;
+    status: SignerStatus;
+    signedAt?: string;
+}
+
+// Signer of a document, who signs through their own signing link
+export interface Signer {
+    id: string;
+    documentId: string;
+    name: string;
+    email?: string;
+    order: number;
+    status: SignerStatus;
+    // Number of signatures on the file once the signer signed, 1 for the first
+    version?: number;
+    submittedHash?: string;
+    // Name as typed by the signer
+    metadata?: string;
+    remarks?: string;
+    ip?: string;
+    userAgent?: string;
+    signedAt?: string;
+    createdAt: string;
+    updatedAt: string;
+}
+
+export interface ViewDocResponse {
+    data: PublicDocument;
+    success: boolean;
+}
+
+// Signing link of a document, its token is only returned on creation and regeneration
+export interface SigningLink {
+    id: string;
+    documentId: string;
+    // Set for the link of a signer
+    signerId?: string;
+    recipient?: string;
+    // Absent for a link that does not expire
+    expiresAt?: string;
+    revokedAt?: string;
+    createdBy?: string;
+    createdAt: string;
+    updatedAt: string;
+}
+
+export interface SigningLinkResponse {
     data: {
+        link: SigningLink;
         token: string;
+        // Path of the page where the recipient views and signs the document
+        url: string;
+    };
+    success: boolean;
+}
+
+export type Role = 'owner' | 'editor' | 'viewer';
+
+export interface User {
+    id: string;
+    email: string;
+    name: string;
+    role: Role;
+    disabled: boolean;
+    // Invited users who have not set a password yet
+    invitePending: boolean;
+    inviteExpiresAt?: string;
+    invitedBy?: string;
+    // Id of the user at the single sign-on provider, once logged in there
+    oidcSubject?: string;
+    twoFactorEnabled: boolean;
+    // Set while the user is locked out after failed logins
+    lockedUntil?: string;
+    createdAt: string;
+    updatedAt: string;
+}
+
+export type LoginOutcome = 'success' | 'code_required' | 'wrong_password' | 'wrong_code' | 'unknown_email' | 'locked' | 'disabled';
+
+// A login attempt, userId is absent when the email matches no user
+export interface LoginAttempt {
+    id: number;
+    userId?: string;
+    email: string;
+    ip?: string;
+    userAgent?: string;
+    outcome: LoginOutcome;
+    createdAt: string;
+}
+
+export type APIKeyScope = 'read' | 'upload' | 'full';
+
+// API key of a user, the key itself is only returned on creation
+export interface APIKey {
+    id: string;
+    userId: string;
+    name: string;
+    prefix: string;
+    scope: APIKeyScope;
+    lastUsedAt?: string;
+    lastUsedIp?: string;
+    revokedAt?: string;
+    createdAt: string;
+}
+
+// Tokens of a session, the access token is short-lived and renewed with the refresh token
+export interface SessionTokens {
+    token: string;
+    expiresAt: string;
+    refreshToken: string;
+    refreshExpiresAt: string;
+}
+
+export interface Session {
+    id: string;
+    userId: string;
+    userAgent?: string;
+    ip?: string;
+    // Browser and system of the user agent, such as "Firefox on Linux"
+    device: string;
+    current: boolean;
+    createdAt: string;
+    lastUsedAt: string;
+    expiresAt: string;
+}
+
+export interface LoginResponse {
+    data: SessionTokens & {
+        user: User;
+    };
+    success: boolean;
+}
+
+// Returned by the password step of a login when the user has two-factor authentication on
+export interface LoginChallenge {
+    twoFactorRequired: true;
+    challengeToken: string;
+    challengeExpiresAt: string;
+}
+
+export interface TwoFactorStatus {
+    enabled: boolean;
+    recoveryCodesLeft: number;
+}
+
+// Secret of an authenticator app, uri is the otpauth:// uri to show as a QR code
+export interface TOTPSetup {
+    secret: string;
+    uri: string;
+}
+
+export interface InviteResponse {
+    data: {
+        user: User;
+        inviteToken: string;
+        // Path of the page where the invited user sets a password
+        inviteUrl: string;
     };
     success: boolean;
 }